@@ -13,4 +13,11 @@ import (
 type IAgentV1 interface {
 	ChatStream(ctx context.Context, req *v1.ChatStreamReq) (res *v1.ChatStreamRes, err error)
 	AgentStream(ctx context.Context, req *v1.AgentReq) (res *v1.AgentRes, err error)
+	Approval(ctx context.Context, req *v1.ApprovalReq) (res *v1.ApprovalRes, err error)
+	SessionDelete(ctx context.Context, req *v1.SessionDeleteReq) (res *v1.SessionDeleteRes, err error)
+	Artifact(ctx context.Context, req *v1.ArtifactReq) (res *v1.ArtifactRes, err error)
+	Upload(ctx context.Context, req *v1.UploadReq) (res *v1.UploadRes, err error)
+	MCPHealth(ctx context.Context, req *v1.MCPHealthReq) (res *v1.MCPHealthRes, err error)
+	Usage(ctx context.Context, req *v1.UsageReq) (res *v1.UsageRes, err error)
+	Audit(ctx context.Context, req *v1.AuditReq) (res *v1.AuditRes, err error)
 }
@@ -0,0 +1,34 @@
+package v1
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+type ApprovalReq struct {
+	g.Meta    `path:"/approval" method:"post" summary:"Approve, deny or edit a tool call waiting for approval"`
+	SessionID string `json:"session_id" p:"session_id" v:"required"`
+	CallID    string `json:"call_id" p:"call_id" v:"required"`
+	Decision  string `json:"decision" p:"decision" v:"required|in:approve,deny,edit"`
+	Arguments string `json:"arguments" p:"arguments" v:"required-if:decision,edit|json"` // edit 时替换的工具参数
+	Reason    string `json:"reason" p:"reason"`
+}
+type ApprovalRes struct {
+	CallID   string `json:"call_id"`
+	Decision string `json:"decision"`
+}
+
+// ApprovalEvent 工具调用等待审批事件
+type ApprovalEvent struct {
+	CallID    string `json:"call_id"`
+	Tool      string `json:"tool"`
+	Arguments string `json:"arguments"`
+	ExpiresAt int64  `json:"expires_at"` // 超时自动拒绝的时间（Unix 秒）
+}
+
+// ApprovalResolvedEvent 工具调用审批结果事件
+type ApprovalResolvedEvent struct {
+	CallID   string `json:"call_id"`
+	Tool     string `json:"tool"`
+	Decision string `json:"decision"`
+	Reason   string `json:"reason,omitempty"`
+}
@@ -0,0 +1,26 @@
+package v1
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+type ArtifactReq struct {
+	g.Meta    `path:"/artifacts/{id}" method:"get" summary:"Download a tool generated file by signed URL"`
+	ID        string `json:"id" p:"id" in:"path" v:"required"`
+	Expires   int64  `json:"expires" p:"expires" v:"required"`
+	Signature string `json:"signature" p:"signature" v:"required"`
+}
+type ArtifactRes struct {
+	g.Meta `mime:"application/octet-stream"`
+}
+
+// ArtifactEvent 工具生成文件后推送的下载链接
+type ArtifactEvent struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	MimeType  string `json:"mime_type"`
+	Size      int64  `json:"size"`
+	Tool      string `json:"tool"`
+	URL       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at"` // 链接过期时间（Unix 秒）
+}
@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+type AuditReq struct {
+	g.Meta    `path:"/audit" method:"get" summary:"Audit log of tool invocations and approval decisions"`
+	From      *gtime.Time `json:"from" p:"from"` // 开始时间（含），如 2025-01-01 或 2025-01-01 08:00:00
+	To        *gtime.Time `json:"to" p:"to"`     // 结束时间（不含）
+	SessionID string      `json:"session_id" p:"session_id"`
+	UserID    string      `json:"user_id" p:"user_id"`     // 非管理员只能查看自己的记录，忽略该参数
+	TenantID  string      `json:"tenant_id" p:"tenant_id"` // 非管理员忽略该参数
+	Agent     string      `json:"agent" p:"agent"`
+	Tool      string      `json:"tool" p:"tool"`
+	TraceID   string      `json:"trace_id" p:"trace_id"`
+	Approval  string      `json:"approval" p:"approval" v:"in:approve,deny,edit,none"` // 审批结果，none 表示无需审批的调用
+	Failed    bool        `json:"failed" p:"failed"`                                   // 只返回出错的调用
+	Page      int         `json:"page" p:"page" d:"1" v:"min:1"`
+	Size      int         `json:"size" p:"size" d:"20" v:"between:1,100"`
+}
+type AuditRes struct {
+	Total   int           `json:"total"`
+	Page    int           `json:"page"`
+	Size    int           `json:"size"`
+	Entries []*AuditEntry `json:"entries"` // 按时间倒序
+}
+
+// AuditEntry 一次工具调用的审计记录
+type AuditEntry struct {
+	ID             int64       `json:"id"`
+	CreatedAt      *gtime.Time `json:"created_at"` // 调用开始时间
+	TraceID        string      `json:"trace_id"`
+	SessionID      string      `json:"session_id"`
+	UserID         string      `json:"user_id"`
+	TenantID       string      `json:"tenant_id"`
+	Agent          string      `json:"agent"`
+	CallID         string      `json:"call_id"`
+	Tool           string      `json:"tool"`
+	Arguments      string      `json:"arguments"` // 已脱敏，审批时修改过的参数为修改后的值
+	Result         string      `json:"result"`    // 已脱敏的结果摘要
+	Error          string      `json:"error"`
+	DurationMs     int         `json:"duration_ms"`
+	Approval       string      `json:"approval"` // approve / deny / edit，无需审批时为空
+	ApprovalReason string      `json:"approval_reason"`
+	Approver       string      `json:"approver"` // 审批人，超时自动拒绝时为空
+}
@@ -6,23 +6,61 @@ import (
 
 type ChatStreamReq struct {
 	g.Meta    `path:"/chatSteam" method:"get" summary:"You first agent api"`
-	Query     string `json:"query" p:"query" v:"required"`
-	SessionID string `json:"session_id" p:"session_id" v:"required"`
+	Query     string   `json:"query" p:"query" v:"required"`
+	SessionID string   `json:"session_id" p:"session_id" v:"required"`
+	Images    []string `json:"images" p:"images"`   // 图片附件：http(s) URL 或 POST /upload 返回的工作目录路径，不接受 data URL
+	UserID    string   `json:"user_id" p:"user_id"` // 未开启认证时可选的用户 ID，开启认证后使用凭证中的用户
+	Model     string   `json:"model" p:"model"`     // 可选的对话模型，需在租户允许的模型中，默认 ai.model
 }
 
 type ChatStreamRes struct {
 	Content  string `json:"content"`
 	Thinking bool   `json:"thinking"`
 	Done     bool   `json:"done"`
+	Event    string `json:"event,omitempty"` // 结构化事件类型，普通内容帧为空
+	Data     any    `json:"data,omitempty"`  // 结构化事件数据
 }
 
 type AgentReq struct {
 	g.Meta    `path:"/agentStream"  method:"get" summary:"You first agent api"`
-	Query     string `json:"query" p:"query" v:"required"`
-	SessionID string `json:"session_id" p:"session_id" v:"required"`
+	Query     string   `json:"query" p:"query" v:"required"`
+	SessionID string   `json:"session_id" p:"session_id" v:"required"`
+	Images    []string `json:"images" p:"images"`   // 图片附件：http(s) URL 或 POST /upload 返回的工作目录路径，不接受 data URL
+	UserID    string   `json:"user_id" p:"user_id"` // 未开启认证时可选的用户 ID，开启认证后使用凭证中的用户
+	Model     string   `json:"model" p:"model"`     // 可选的对话模型，需在租户允许的模型中，默认 ai.model
 }
 type AgentRes struct {
 	Content      string `json:"content"`
-	TotalTokens  int    `json:"total_tokens"`
-	MessageCount int    `json:"message_count"`
+	TotalTokens  int    `json:"total_tokens"`  // 本轮所有模型和向量化调用的 token 数
+	MessageCount int    `json:"message_count"` // 本轮模型生成的消息数
+}
+
+// RateLimitEvent 对话中超出限流或配额的事件，如工具的每日调用次数
+type RateLimitEvent struct {
+	Limit      string  `json:"limit"`          // 超出的限制类型: tool_calls 等
+	Tool       string  `json:"tool,omitempty"` // 超出 tool_calls 配额的工具名
+	Max        float64 `json:"max"`            // 上限
+	Used       float64 `json:"used"`           // 已使用量
+	RetryAfter int64   `json:"retry_after"`    // 可重试的等待时间（秒）
+	Message    string  `json:"message"`
+}
+
+// GuardrailEvent 护栏标记或拦截了用户输入、工具调用、工具结果或最终回答的事件
+type GuardrailEvent struct {
+	Stage   string `json:"stage"`  // input / tool_call / tool_output / output
+	Source  string `json:"source"` // user、assistant 或工具名
+	Action  string `json:"action"` // flag / block，output 为 block 时前端应隐藏已输出的回答
+	Engine  string `json:"engine"` // rules / classifier / policy
+	Rule    string `json:"rule"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// BudgetEvent 单轮预算耗尽事件
+type BudgetEvent struct {
+	Budget  string `json:"budget"`         // 耗尽的预算类型: max_steps / tool_calls / turn_timeout
+	Tool    string `json:"tool,omitempty"` // 触发 tool_calls 预算的工具名
+	Limit   int64  `json:"limit"`          // 预算上限（turn_timeout 为秒）
+	Used    int64  `json:"used"`           // 已使用量（turn_timeout 为秒）
+	Message string `json:"message"`
 }
@@ -0,0 +1,22 @@
+package v1
+
+import "github.com/gogf/gf/v2/frame/g"
+
+type MCPHealthReq struct {
+	g.Meta `path:"/mcp/health" method:"get" summary:"Connection status of the configured MCP servers"`
+}
+type MCPHealthRes struct {
+	Healthy bool               `json:"healthy"` // 所有服务均已连接
+	Servers []*MCPServerHealth `json:"servers"`
+}
+
+type MCPServerHealth struct {
+	Name        string   `json:"name"`
+	Transport   string   `json:"transport"`
+	Status      string   `json:"status"` // connecting / ready / failed
+	Tools       []string `json:"tools"`
+	Failures    int      `json:"failures"` // 连续失败次数
+	LastError   string   `json:"last_error,omitempty"`
+	ConnectedAt int64    `json:"connected_at,omitempty"` // 最近一次连接成功的时间（Unix 秒）
+	LastCheck   int64    `json:"last_check,omitempty"`   // 最近一次健康检查的时间（Unix 秒）
+}
@@ -0,0 +1,13 @@
+package v1
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+type SessionDeleteReq struct {
+	g.Meta    `path:"/session" method:"delete" summary:"Delete a session with its history and workspace"`
+	SessionID string `json:"session_id" p:"session_id" v:"required"`
+}
+type SessionDeleteRes struct {
+	SessionID string `json:"session_id"`
+}
@@ -0,0 +1,15 @@
+package v1
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+type UploadReq struct {
+	g.Meta    `path:"/upload" method:"post" mime:"multipart/form-data" summary:"Upload an image into the session workspace"`
+	SessionID string            `json:"session_id" p:"session_id" v:"required"`
+	File      *ghttp.UploadFile `json:"file" p:"file" type:"file" v:"required"`
+}
+type UploadRes struct {
+	Path string `json:"path"` // 工作目录内的相对路径，可作为对话请求的 images 参数
+}
@@ -0,0 +1,45 @@
+package v1
+
+import (
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+type UsageReq struct {
+	g.Meta    `path:"/usage" method:"get" summary:"Token usage and cost aggregated over a time range"`
+	From      *gtime.Time `json:"from" p:"from"`                                                                   // 开始时间（含），如 2025-01-01 或 2025-01-01 08:00:00
+	To        *gtime.Time `json:"to" p:"to"`                                                                       // 结束时间（不含）
+	GroupBy   string      `json:"group_by" p:"group_by" d:"model" v:"in:session,user,tenant,agent,model,kind,day"` // 汇总维度：session / user / tenant / agent / model / kind / day
+	SessionID string      `json:"session_id" p:"session_id"`
+	UserID    string      `json:"user_id" p:"user_id"`     // 非管理员只能查看自己的用量，忽略该参数
+	TenantID  string      `json:"tenant_id" p:"tenant_id"` // 非管理员忽略该参数
+	Agent     string      `json:"agent" p:"agent"`
+	Model     string      `json:"model" p:"model"`
+}
+type UsageRes struct {
+	GroupBy  string       `json:"group_by"`
+	Currency string       `json:"currency"`
+	Total    *UsageStat   `json:"total"`
+	Groups   []*UsageStat `json:"groups"` // 按 total_tokens 从大到小排列，group_by=day 时按日期排列
+}
+
+// UsageStat 一组调用的用量合计
+type UsageStat struct {
+	Key              string  `json:"key,omitempty"` // 分组的值，如模型名、会话 ID 或日期
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// UsageEvent 每轮对话结束时的用量事件
+type UsageEvent struct {
+	Calls            int     `json:"calls"`           // 模型调用次数
+	EmbeddingCalls   int     `json:"embedding_calls"` // 向量化调用次数
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	Cost             float64 `json:"cost"`
+	Currency         string  `json:"currency"`
+}
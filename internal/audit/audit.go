@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"agent/internal/auth"
+	"agent/internal/consts"
+	"agent/internal/logging"
+	"agent/internal/model"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultMaxArgumentChars = 4000
+	defaultMaxResultChars   = 500
+)
+
+// Config 审计日志配置
+type Config struct {
+	Enabled          bool
+	Persist          bool   // 写入数据库 tool_audit 表
+	File             string // 同时追加写入的 JSONL 文件，为空时不写
+	MaxArgumentChars int    // 参数最多保留的字符数
+	MaxResultChars   int    // 结果摘要最多保留的字符数
+}
+
+// LoadConfig 读取审计日志配置
+func LoadConfig(ctx context.Context) *Config {
+	cfg := &Config{
+		Enabled:          g.Cfg().MustGet(ctx, consts.AuditEnabled, false).Bool(),
+		Persist:          g.Cfg().MustGet(ctx, consts.AuditPersist, false).Bool(),
+		File:             g.Cfg().MustGet(ctx, consts.AuditFile).String(),
+		MaxArgumentChars: g.Cfg().MustGet(ctx, consts.AuditMaxArgumentChars, defaultMaxArgumentChars).Int(),
+		MaxResultChars:   g.Cfg().MustGet(ctx, consts.AuditMaxResultChars, defaultMaxResultChars).Int(),
+	}
+	if cfg.MaxArgumentChars <= 0 {
+		cfg.MaxArgumentChars = defaultMaxArgumentChars
+	}
+	if cfg.MaxResultChars <= 0 {
+		cfg.MaxResultChars = defaultMaxResultChars
+	}
+	return cfg
+}
+
+// Entry 一次工具调用的审计记录
+type Entry struct {
+	CreatedAt      time.Time `json:"created_at"` // 调用开始时间
+	TraceID        string    `json:"trace_id,omitempty"`
+	SessionID      string    `json:"session_id"`
+	UserID         string    `json:"user_id"`
+	TenantID       string    `json:"tenant_id"`
+	Agent          string    `json:"agent"`
+	CallID         string    `json:"call_id,omitempty"`
+	Tool           string    `json:"tool"`
+	Arguments      string    `json:"arguments"` // 实际执行时的参数，审批时修改过的参数为修改后的值
+	Result         string    `json:"result"`    // 结果摘要，被拦截或拒绝时为返回给模型的说明
+	Error          string    `json:"error,omitempty"`
+	DurationMs     int64     `json:"duration_ms"`
+	Approval       string    `json:"approval,omitempty"` // approve / deny / edit，无需审批时为空
+	ApprovalReason string    `json:"approval_reason,omitempty"`
+	Approver       string    `json:"approver,omitempty"` // 审批人，超时自动拒绝时为空
+
+	mu sync.Mutex
+}
+
+// Approval 工具调用的审批结果
+type Approval struct {
+	Decision  string
+	Reason    string
+	Approver  string
+	Arguments string // 审批时修改后的参数，未修改时为空
+}
+
+var (
+	current = &Config{}
+	// sink 接收完成的审计记录，未开启审计时为空
+	sink func(e *Entry)
+)
+
+// Init 按配置打开审计日志的数据库和文件写入，只应在启动时调用一次；返回的函数在退出时写入剩余的记录
+func Init(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	cfg := LoadConfig(ctx)
+	current = cfg
+	shutdown = func(context.Context) error { return nil }
+	if !cfg.Enabled {
+		return shutdown, nil
+	}
+	var stores []store
+	if cfg.Persist {
+		stores = append(stores, &dbStore{})
+	}
+	if cfg.File != "" {
+		fs, err := newFileStore(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		stores = append(stores, fs)
+	}
+	if len(stores) == 0 {
+		g.Log().Warning(ctx, "audit log is enabled but neither audit.persist nor audit.file is set")
+		return shutdown, nil
+	}
+	w := newWriter(ctx, stores)
+	sink = w.enqueue
+	return w.close, nil
+}
+
+// Persisted 审计记录是否写入数据库，未写入时无法查询
+func Persisted() bool {
+	return current.Enabled && current.Persist
+}
+
+// entryKey 上下文中 *Entry 的键
+type entryKey struct{}
+
+// Start 开始记录一次工具调用，返回的上下文用于补充审批结果；未开启审计时返回 nil，Finish 不做任何事
+func Start(ctx context.Context, tool, callID, arguments string) (context.Context, *Entry) {
+	if sink == nil {
+		return ctx, nil
+	}
+	e := &Entry{
+		CreatedAt: time.Now(),
+		CallID:    callID,
+		Tool:      tool,
+		Arguments: arguments,
+	}
+	if c, ok := ctx.Value(consts.ContextKey).(*model.Context); ok {
+		e.SessionID, e.UserID, e.TenantID, e.Agent = c.SessionID, c.UserID, c.TenantID, c.Agent
+	}
+	if e.UserID == "" {
+		identity := auth.FromCtx(ctx)
+		e.UserID, e.TenantID = identity.UserID, identity.TenantID
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		e.TraceID = sc.TraceID().String()
+	}
+	return context.WithValue(ctx, entryKey{}, e), e
+}
+
+// SetApproval 在当前工具调用的审计记录中补充审批结果
+func SetApproval(ctx context.Context, a Approval) {
+	e, ok := ctx.Value(entryKey{}).(*Entry)
+	if !ok {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Approval, e.ApprovalReason, e.Approver = a.Decision, a.Reason, a.Approver
+	if a.Arguments != "" {
+		e.Arguments = a.Arguments
+	}
+}
+
+// Finish 记录调用结果并写入审计日志，参数、结果和错误按日志的规则脱敏后截断
+func (e *Entry) Finish(ctx context.Context, result string, err error) {
+	if e == nil || sink == nil {
+		return
+	}
+	e.mu.Lock()
+	e.DurationMs = time.Since(e.CreatedAt).Milliseconds()
+	e.Arguments = truncate(logging.Redact(ctx, e.Arguments), current.MaxArgumentChars)
+	e.Result = truncate(logging.Redact(ctx, result), current.MaxResultChars)
+	if err != nil {
+		e.Error = truncate(logging.Redact(ctx, err.Error()), current.MaxResultChars)
+	}
+	e.mu.Unlock()
+	sink(e)
+}
+
+// truncate 按字符截断，并注明截掉的字符数
+func truncate(s string, limit int) string {
+	r := []rune(s)
+	if len(r) <= limit {
+		return s
+	}
+	return fmt.Sprintf("%s...(%d more chars)", string(r[:limit]), len(r)-limit)
+}
@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"agent/internal/consts"
+	"agent/internal/model"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useSink 测试期间使用给定的配置，并收集写入的审计记录
+func useSink(t1 *testing.T, cfg *Config) *[]*Entry {
+	var entries []*Entry
+	oldCfg, oldSink := current, sink
+	current = cfg
+	sink = func(e *Entry) { entries = append(entries, e) }
+	t1.Cleanup(func() { current, sink = oldCfg, oldSink })
+	return &entries
+}
+
+func TestStartFinish(t1 *testing.T) {
+	entries := useSink(t1, &Config{Enabled: true, MaxArgumentChars: 20, MaxResultChars: 5})
+	ctx := context.WithValue(context.Background(), consts.ContextKey, &model.Context{
+		SessionID: "s1",
+		UserID:    "alice",
+		TenantID:  "acme",
+		Agent:     "agent",
+	})
+
+	ctx, entry := Start(ctx, "terminal_operation_tool", "call_1", `{"command":"rm -rf /tmp/cache"}`)
+	SetApproval(ctx, Approval{Decision: "edit", Reason: "narrow scope", Approver: "bob", Arguments: `{"command":"ls"}`})
+	entry.Finish(ctx, "file1 file2 file3", errors.New("exit status 1"))
+
+	if len(*entries) != 1 {
+		t1.Fatalf("got %d entries, want 1", len(*entries))
+	}
+	got := (*entries)[0]
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "session", got: got.SessionID, want: "s1"},
+		{name: "user", got: got.UserID, want: "alice"},
+		{name: "tenant", got: got.TenantID, want: "acme"},
+		{name: "agent", got: got.Agent, want: "agent"},
+		{name: "call id", got: got.CallID, want: "call_1"},
+		{name: "tool", got: got.Tool, want: "terminal_operation_tool"},
+		{name: "edited arguments", got: got.Arguments, want: `{"command":"ls"}`},
+		{name: "truncated result", got: got.Result, want: "file1...(12 more chars)"},
+		{name: "error", got: got.Error, want: "exit ...(8 more chars)"},
+		{name: "approval", got: got.Approval, want: "edit"},
+		{name: "approval reason", got: got.ApprovalReason, want: "narrow scope"},
+		{name: "approver", got: got.Approver, want: "bob"},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			if tt.got != tt.want {
+				t1.Errorf("got %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDisabled(t1 *testing.T) {
+	oldCfg, oldSink := current, sink
+	current, sink = &Config{}, nil
+	t1.Cleanup(func() { current, sink = oldCfg, oldSink })
+
+	ctx, entry := Start(context.Background(), "web_search_tool", "call_1", "{}")
+	if entry != nil {
+		t1.Fatal("Start() must return a nil entry when audit is disabled")
+	}
+	// 未开启时补充审批结果和结束记录都不做任何事
+	SetApproval(ctx, Approval{Decision: "approve"})
+	entry.Finish(ctx, "result", nil)
+	if Persisted() {
+		t1.Error("Persisted() = true, want false")
+	}
+}
+
+func TestTruncate(t1 *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		limit int
+		want  string
+	}{
+		{name: "short", s: "abc", limit: 5, want: "abc"},
+		{name: "exact", s: "abcde", limit: 5, want: "abcde"},
+		{name: "long", s: "abcdefg", limit: 5, want: "abcde...(2 more chars)"},
+		{name: "multibyte", s: "审计日志记录", limit: 2, want: "审计...(4 more chars)"},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			if got := truncate(tt.s, tt.limit); got != tt.want {
+				t1.Errorf("truncate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileStore(t1 *testing.T) {
+	path := filepath.Join(t1.TempDir(), "audit", "audit.jsonl")
+	ctx := context.Background()
+
+	// 两次打开同一文件，记录追加写入而不覆盖
+	for _, tool := range []string{"web_search_tool", "file_operation_tool"} {
+		s, err := newFileStore(path)
+		if err != nil {
+			t1.Fatal(err)
+		}
+		w := newWriter(ctx, []store{s})
+		w.enqueue(&Entry{Tool: tool, SessionID: "s1"})
+		if err = w.close(ctx); err != nil {
+			t1.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t1.Fatal(err)
+	}
+	defer f.Close()
+	var tools []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err = json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t1.Fatalf("invalid JSONL line %q: %v", scanner.Text(), err)
+		}
+		tools = append(tools, e.Tool)
+	}
+	if got := strings.Join(tools, ","); got != "web_search_tool,file_operation_tool" {
+		t1.Errorf("tools = %q, want both entries in order", got)
+	}
+}
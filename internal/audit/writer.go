@@ -0,0 +1,170 @@
+package audit
+
+import (
+	"agent/internal/dao"
+	"agent/internal/model/do"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+const (
+	writerQueueSize = 1024
+	writerBatchSize = 100
+	// enqueueTimeout 队列已满时等待的最长时间，审计记录尽量不丢弃
+	enqueueTimeout = time.Second
+)
+
+// store 审计记录的存储，只追加写入
+type store interface {
+	write(ctx context.Context, batch []*Entry) error
+	close() error
+}
+
+// dbStore 写入数据库 tool_audit 表
+type dbStore struct{}
+
+func (dbStore) write(ctx context.Context, batch []*Entry) error {
+	rows := make([]do.ToolAudit, 0, len(batch))
+	for _, e := range batch {
+		rows = append(rows, do.ToolAudit{
+			TraceId:        e.TraceID,
+			SessionId:      e.SessionID,
+			UserId:         e.UserID,
+			TenantId:       e.TenantID,
+			Agent:          e.Agent,
+			CallId:         e.CallID,
+			Tool:           e.Tool,
+			Arguments:      e.Arguments,
+			Result:         e.Result,
+			Error:          e.Error,
+			DurationMs:     e.DurationMs,
+			Approval:       e.Approval,
+			ApprovalReason: e.ApprovalReason,
+			Approver:       e.Approver,
+			CreatedAt:      gtime.New(e.CreatedAt),
+		})
+	}
+	if _, err := dao.ToolAudit.Ctx(ctx).Data(rows).Insert(); err != nil {
+		return fmt.Errorf("failed to save %d audit entries: %v", len(rows), err)
+	}
+	return nil
+}
+
+func (dbStore) close() error { return nil }
+
+// fileStore 以 JSONL 格式追加写入文件，每行一条记录
+type fileStore struct {
+	f *os.File
+}
+
+func newFileStore(path string) (*fileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create audit log dir: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log file: %v", err)
+	}
+	return &fileStore{f: f}, nil
+}
+
+func (s *fileStore) write(_ context.Context, batch []*Entry) error {
+	var buf []byte
+	for _, e := range batch {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit entry: %v", err)
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if _, err := s.f.Write(buf); err != nil {
+		return fmt.Errorf("failed to write %d audit entries: %v", len(batch), err)
+	}
+	return nil
+}
+
+func (s *fileStore) close() error {
+	return s.f.Close()
+}
+
+// writer 在后台批量写入审计记录，避免存储延迟拖慢工具调用
+type writer struct {
+	ctx     context.Context
+	stores  []store
+	entries chan *Entry
+	once    sync.Once
+	done    chan struct{}
+}
+
+func newWriter(ctx context.Context, stores []store) *writer {
+	w := &writer{
+		ctx:     context.WithoutCancel(ctx),
+		stores:  stores,
+		entries: make(chan *Entry, writerQueueSize),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// enqueue 加入写入队列，队列已满时最多等待 enqueueTimeout，超时后丢弃并记录错误
+func (w *writer) enqueue(e *Entry) {
+	defer func() {
+		// 关闭后仍在结束的工具调用不再写入
+		_ = recover()
+	}()
+	select {
+	case w.entries <- e:
+		return
+	default:
+	}
+	timer := time.NewTimer(enqueueTimeout)
+	defer timer.Stop()
+	select {
+	case w.entries <- e:
+	case <-timer.C:
+		g.Log().Errorf(w.ctx, "audit queue is full, dropping entry of tool %s in session %s", e.Tool, e.SessionID)
+	}
+}
+
+func (w *writer) run() {
+	defer close(w.done)
+	batch := make([]*Entry, 0, writerBatchSize)
+	for e := range w.entries {
+		batch = append(batch, e)
+		// 取出队列中已有的记录一起写入
+		for len(batch) < writerBatchSize && len(w.entries) > 0 {
+			batch = append(batch, <-w.entries)
+		}
+		for _, s := range w.stores {
+			if err := s.write(w.ctx, batch); err != nil {
+				g.Log().Error(w.ctx, err)
+			}
+		}
+		batch = batch[:0]
+	}
+}
+
+// close 停止接收记录，等待队列中的记录写入完成后关闭存储
+func (w *writer) close(ctx context.Context) error {
+	w.once.Do(func() { close(w.entries) })
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for _, s := range w.stores {
+		if err := s.close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
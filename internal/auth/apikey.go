@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"agent/internal/consts"
+	"agent/internal/secret"
+	"context"
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// APIKey 一个 API Key 及其对应的用户
+type APIKey struct {
+	Key    string `json:"key"`
+	User   string `json:"user"`
+	Tenant string `json:"tenant"` // 为空时使用 auth.defaultTenant
+	Admin  bool   `json:"admin"`
+}
+
+// apiKeyAuthenticator 通过 X-API-Key 头、Authorization: Bearer 头或 api_key 参数认证
+type apiKeyAuthenticator struct {
+	keys map[[sha256.Size]byte]*Identity // 按 Key 的摘要索引，不在内存中保留原文
+}
+
+func newAPIKeyAuthenticator(ctx context.Context, defaultTenant string) (*apiKeyAuthenticator, error) {
+	var keys []APIKey
+	if err := g.Cfg().MustGet(ctx, consts.AuthAPIKeys).Scan(&keys); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", consts.AuthAPIKeys, err)
+	}
+	a := &apiKeyAuthenticator{keys: make(map[[sha256.Size]byte]*Identity, len(keys))}
+	for i, k := range keys {
+		// Key 可以是 ${env:...} 等密钥引用
+		key, err := secret.Resolve(ctx, k.Key)
+		if err != nil {
+			return nil, fmt.Errorf("%s[%d]: %v", consts.AuthAPIKeys, i, err)
+		}
+		if key == "" || k.User == "" {
+			return nil, fmt.Errorf("%s[%d]: key and user are required", consts.AuthAPIKeys, i)
+		}
+		if k.Tenant == "" {
+			k.Tenant = defaultTenant
+		}
+		a.keys[sha256.Sum256([]byte(key))] = &Identity{
+			UserID:   k.User,
+			TenantID: k.Tenant,
+			Admin:    k.Admin,
+			Method:   consts.AuthModeAPIKey,
+		}
+	}
+	if len(a.keys) == 0 {
+		return nil, fmt.Errorf("%s is empty", consts.AuthAPIKeys)
+	}
+	return a, nil
+}
+
+func (a *apiKeyAuthenticator) Authenticate(r *ghttp.Request) (*Identity, error) {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key = bearerToken(r, "api_key")
+	}
+	if key == "" {
+		return nil, fmt.Errorf("%w: missing api key", ErrUnauthorized)
+	}
+	identity, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid api key", ErrUnauthorized)
+	}
+	return identity, nil
+}
@@ -0,0 +1,180 @@
+package auth
+
+import (
+	"agent/internal/consts"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+const defaultTenant = "default"
+
+var (
+	// ErrUnauthorized 缺少或无效的凭证
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden 身份有效，但租户配置不允许访问
+	ErrForbidden = errors.New("forbidden")
+)
+
+// Identity 请求方的身份
+type Identity struct {
+	UserID   string
+	TenantID string
+	Admin    bool   // 管理员可查看所有用户的用量等数据
+	Method   string // 认证方式：none / apikey / jwt
+}
+
+// Scope 按租户和用户隔离会话 ID，不同用户使用相同的 session_id 时互不可见；没有用户 ID 时保持原样
+func (i *Identity) Scope(sessionID string) string {
+	if i == nil || i.UserID == "" {
+		return sessionID
+	}
+	sum := sha256.Sum256([]byte(i.TenantID + "\x00" + i.UserID))
+	return hex.EncodeToString(sum[:6]) + "_" + sessionID
+}
+
+// Owns 判断身份是否可以访问属于指定租户和用户的数据，管理员可以访问所有数据
+func (i *Identity) Owns(tenantID, userID string) bool {
+	return i.Admin || (i.TenantID == tenantID && i.UserID == userID)
+}
+
+// authenticator 从请求中解析身份
+type authenticator interface {
+	Authenticate(r *ghttp.Request) (*Identity, error)
+}
+
+// Config 认证配置
+type Config struct {
+	Mode          string   // none / apikey / jwt
+	SkipPaths     []string // 无需认证的路径，以 * 结尾时按前缀匹配
+	DefaultTenant string   // 未指定租户时使用的租户
+}
+
+// LoadConfig 读取认证配置
+func LoadConfig(ctx context.Context) *Config {
+	cfg := &Config{
+		Mode:          g.Cfg().MustGet(ctx, consts.AuthMode, consts.AuthModeNone).String(),
+		SkipPaths:     g.Cfg().MustGet(ctx, consts.AuthSkipPaths, []string{"/metrics", "/swagger*", "/api.json"}).Strings(),
+		DefaultTenant: g.Cfg().MustGet(ctx, consts.AuthDefaultTenant, defaultTenant).String(),
+	}
+	if cfg.DefaultTenant == "" {
+		cfg.DefaultTenant = defaultTenant
+	}
+	return cfg
+}
+
+var (
+	current                    = &Config{Mode: consts.AuthModeNone, DefaultTenant: defaultTenant}
+	authenticate authenticator = &noneAuthenticator{defaultTenant: defaultTenant}
+)
+
+// Init 按配置创建认证方式，需在注册中间件之前调用
+func Init(ctx context.Context) error {
+	cfg := LoadConfig(ctx)
+	var (
+		a   authenticator
+		err error
+	)
+	switch cfg.Mode {
+	case consts.AuthModeNone, "":
+		a = &noneAuthenticator{defaultTenant: cfg.DefaultTenant}
+		g.Log(consts.LoggerAgent).Warning(ctx, "Authentication is disabled (auth.mode=none), do not expose this server publicly")
+	case consts.AuthModeAPIKey:
+		a, err = newAPIKeyAuthenticator(ctx, cfg.DefaultTenant)
+	case consts.AuthModeJWT:
+		a, err = newJWTAuthenticator(ctx, cfg.DefaultTenant)
+	default:
+		err = fmt.Errorf("unsupported auth mode: %s", cfg.Mode)
+	}
+	if err != nil {
+		return err
+	}
+	current, authenticate = cfg, a
+	return nil
+}
+
+// Enabled 是否开启了认证，未开启时所有请求都是开发模式下的匿名身份
+func Enabled() bool {
+	return current.Mode != consts.AuthModeNone && current.Mode != ""
+}
+
+// Middleware 认证中间件，将身份写入请求上下文，认证失败时返回 401
+func Middleware(r *ghttp.Request) {
+	if skipped(current.SkipPaths, r.URL.Path) {
+		r.Middleware.Next()
+		return
+	}
+	identity, err := authenticate.Authenticate(r)
+	if err != nil {
+		g.Log(consts.LoggerAgent).Warningf(r.Context(), "Rejected request to %s: %v", r.URL.Path, err)
+		r.Response.WriteStatusExit(http.StatusUnauthorized, err.Error())
+		return
+	}
+	r.SetCtx(WithIdentity(r.Context(), identity))
+	r.Middleware.Next()
+}
+
+// skipped 判断路径是否无需认证
+func skipped(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(path, prefix) {
+				return true
+			}
+		} else if path == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// identityKey 上下文中身份的键
+type identityKey struct{}
+
+// WithIdentity 在上下文中记录身份
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// FromCtx 获取当前身份，不经过认证中间件时（如 MCP 服务）为默认租户下的匿名用户
+func FromCtx(ctx context.Context) *Identity {
+	if identity, ok := ctx.Value(identityKey{}).(*Identity); ok && identity != nil {
+		return identity
+	}
+	return &Identity{TenantID: current.DefaultTenant, Method: consts.AuthModeNone}
+}
+
+// bearerToken 读取 Authorization: Bearer 头，EventSource 等无法设置请求头的场景可使用查询参数 queryKey
+func bearerToken(r *ghttp.Request, queryKey string) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return r.GetQuery(queryKey).String()
+}
+
+// noneAuthenticator 开发模式：不校验凭证，用户和租户取自请求头或参数；身份不是管理员，管理员接口只能查看自己的数据
+type noneAuthenticator struct {
+	defaultTenant string
+}
+
+func (a *noneAuthenticator) Authenticate(r *ghttp.Request) (*Identity, error) {
+	identity := &Identity{
+		UserID:   r.Header.Get("X-User-ID"),
+		TenantID: r.Header.Get("X-Tenant-ID"),
+		Method:   consts.AuthModeNone,
+	}
+	if identity.UserID == "" {
+		identity.UserID = r.Get("user_id").String()
+	}
+	if identity.TenantID == "" {
+		identity.TenantID = a.defaultTenant
+	}
+	return identity, nil
+}
@@ -0,0 +1,245 @@
+package auth
+
+import (
+	"agent/internal/consts"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/util/guid"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// startServer 启动挂载认证中间件的测试服务，接口返回 用户|租户|是否管理员
+func startServer(t1 *testing.T, a authenticator) string {
+	authenticate = a
+	current = &Config{SkipPaths: []string{"/metrics"}, DefaultTenant: defaultTenant}
+	s := g.Server(guid.S())
+	s.SetAddr("127.0.0.1:0")
+	s.SetDumpRouterMap(false)
+	s.Use(Middleware)
+	s.BindHandler("/whoami", func(r *ghttp.Request) {
+		identity := FromCtx(r.Context())
+		r.Response.Writef("%s|%s|%v", identity.UserID, identity.TenantID, identity.Admin)
+	})
+	s.BindHandler("/metrics", func(r *ghttp.Request) {
+		r.Response.Write("ok")
+	})
+	if err := s.Start(); err != nil {
+		t1.Fatal(err)
+	}
+	t1.Cleanup(func() { _ = s.Shutdown() })
+	return fmt.Sprintf("http://127.0.0.1:%d", s.GetListenedPort())
+}
+
+func get(t1 *testing.T, url string, header map[string]string) (int, string) {
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t1.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestAPIKeyAuthenticator(t1 *testing.T) {
+	url := startServer(t1, &apiKeyAuthenticator{keys: map[[sha256.Size]byte]*Identity{
+		sha256.Sum256([]byte("key-alice")): {UserID: "alice", TenantID: "acme", Method: consts.AuthModeAPIKey},
+	}})
+	tests := []struct {
+		name     string
+		path     string
+		header   map[string]string
+		wantCode int
+		wantBody string
+	}{
+		{name: "header", path: "/whoami", header: map[string]string{"X-API-Key": "key-alice"}, wantCode: 200, wantBody: "alice|acme|false"},
+		{name: "bearer", path: "/whoami", header: map[string]string{"Authorization": "Bearer key-alice"}, wantCode: 200, wantBody: "alice|acme|false"},
+		{name: "query", path: "/whoami?api_key=key-alice", wantCode: 200, wantBody: "alice|acme|false"},
+		{name: "missing", path: "/whoami", wantCode: 401},
+		{name: "invalid", path: "/whoami", header: map[string]string{"X-API-Key": "key-bob"}, wantCode: 401},
+		{name: "skipped path", path: "/metrics", wantCode: 200, wantBody: "ok"},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			code, body := get(t1, url+tt.path, tt.header)
+			if code != tt.wantCode {
+				t1.Fatalf("status = %d, want %d (%s)", code, tt.wantCode, body)
+			}
+			if tt.wantBody != "" && body != tt.wantBody {
+				t1.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestJWTAuthenticator(t1 *testing.T) {
+	// 1. JWKS 服务
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t1.Fatal(err)
+	}
+	jwksServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprintf(w, `{"keys":[{"kty":"RSA","kid":"k1","n":"%s","e":"%s"}]}`,
+			base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+			base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()))
+	}))
+	defer jwksServer.Close()
+
+	secret := []byte("test-secret")
+	url := startServer(t1, &jwtAuthenticator{
+		secret:        secret,
+		jwks:          newJWKS(jwksServer.URL),
+		parser:        jwt.NewParser(jwt.WithValidMethods([]string{"HS256", "RS256"}), jwt.WithExpirationRequired(), jwt.WithIssuer("test")),
+		userClaim:     "sub",
+		tenantClaim:   "tenant",
+		adminClaim:    "admin",
+		defaultTenant: defaultTenant,
+	})
+
+	// 2. 签发 token
+	claims := func(extra jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{"sub": "alice", "iss": "test", "exp": time.Now().Add(time.Hour).Unix()}
+		for k, v := range extra {
+			c[k] = v
+		}
+		return c
+	}
+	hs := func(c jwt.MapClaims) string {
+		s, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString(secret)
+		return s
+	}
+	rs := func(c jwt.MapClaims, kid string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+		token.Header["kid"] = kid
+		s, _ := token.SignedString(rsaKey)
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		token    string
+		query    bool
+		wantCode int
+		wantBody string
+	}{
+		{name: "hs256", token: hs(claims(nil)), wantCode: 200, wantBody: "alice|default|false"},
+		{name: "tenant and admin", token: hs(claims(jwt.MapClaims{"tenant": "acme", "admin": true})), wantCode: 200, wantBody: "alice|acme|true"},
+		{name: "access_token query", token: hs(claims(nil)), query: true, wantCode: 200, wantBody: "alice|default|false"},
+		{name: "rs256 from jwks", token: rs(claims(nil), "k1"), wantCode: 200, wantBody: "alice|default|false"},
+		{name: "unknown kid", token: rs(claims(nil), "k2"), wantCode: 401},
+		{name: "expired", token: hs(claims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})), wantCode: 401},
+		{name: "wrong issuer", token: hs(claims(jwt.MapClaims{"iss": "other"})), wantCode: 401},
+		{name: "no subject", token: hs(jwt.MapClaims{"iss": "test", "exp": time.Now().Add(time.Hour).Unix()}), wantCode: 401},
+		{name: "wrong secret", token: func() string {
+			s, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, claims(nil)).SignedString([]byte("other"))
+			return s
+		}(), wantCode: 401},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			path, header := "/whoami", map[string]string{"Authorization": "Bearer " + tt.token}
+			if tt.query {
+				path, header = "/whoami?access_token="+tt.token, nil
+			}
+			code, body := get(t1, url+path, header)
+			if code != tt.wantCode {
+				t1.Fatalf("status = %d, want %d (%s)", code, tt.wantCode, body)
+			}
+			if tt.wantBody != "" && body != tt.wantBody {
+				t1.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestIdentity_Scope(t1 *testing.T) {
+	alice := &Identity{UserID: "alice", TenantID: "acme"}
+	bob := &Identity{UserID: "bob", TenantID: "acme"}
+	if alice.Scope("s1") == bob.Scope("s1") {
+		t1.Errorf("sessions of different users must not share a key")
+	}
+	if alice.Scope("s1") != (&Identity{UserID: "alice", TenantID: "acme"}).Scope("s1") {
+		t1.Errorf("scope must be stable for the same user")
+	}
+	if got := (&Identity{TenantID: defaultTenant}).Scope("s1"); got != "s1" {
+		t1.Errorf("anonymous scope = %q, want s1", got)
+	}
+	if !alice.Owns("acme", "alice") || alice.Owns("acme", "bob") || !(&Identity{Admin: true}).Owns("acme", "bob") {
+		t1.Errorf("unexpected Owns result")
+	}
+	if got := FromCtx(context.Background()); got.UserID != "" || got.TenantID != defaultTenant {
+		t1.Errorf("FromCtx() without identity = %+v", got)
+	}
+}
+
+func TestPolicy(t1 *testing.T) {
+	tests := []struct {
+		name      string
+		policy    *Policy
+		agent     string
+		wantAgent bool
+		tool      string
+		wantTool  bool
+		model     string
+		wantModel string
+	}{
+		{name: "unrestricted", policy: &Policy{}, agent: "react", wantAgent: true, tool: "web_search_tool", wantTool: true, model: "m1", wantModel: "m1"},
+		{
+			name:   "restricted",
+			policy: &Policy{Agents: []string{"chain"}, Tools: []string{"web_fetch_tool"}, Models: []string{"m2", "m3"}},
+			agent:  "react", wantAgent: false, tool: "web_search_tool", wantTool: false, model: "m1", wantModel: "m2",
+		},
+		{
+			name:   "wildcard",
+			policy: &Policy{Agents: []string{"*"}, Tools: []string{"*"}, Models: []string{"m1", "m2"}},
+			agent:  "react", wantAgent: true, tool: "web_search_tool", wantTool: true, model: "m1", wantModel: "m1",
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			if got := tt.policy.AllowAgent(tt.agent); got != tt.wantAgent {
+				t1.Errorf("AllowAgent(%s) = %v, want %v", tt.agent, got, tt.wantAgent)
+			}
+			if got := tt.policy.AllowTool(tt.tool); got != tt.wantTool {
+				t1.Errorf("AllowTool(%s) = %v, want %v", tt.tool, got, tt.wantTool)
+			}
+			if got := tt.policy.Model(tt.model); got != tt.wantModel {
+				t1.Errorf("Model(%s) = %s, want %s", tt.model, got, tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestSkipped(t1 *testing.T) {
+	patterns := []string{"/metrics", "/swagger*"}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{path: "/metrics", want: true},
+		{path: "/metrics/x", want: false},
+		{path: "/swagger/index.html", want: true},
+		{path: "/agentStream", want: false},
+	}
+	for _, tt := range tests {
+		if got := skipped(patterns, tt.path); got != tt.want {
+			t1.Errorf("skipped(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
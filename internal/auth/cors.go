@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"agent/internal/consts"
+	"slices"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// corsAllowHeaders 除 GoFrame 默认的请求头外，允许跨域携带的请求头
+const corsAllowHeaders = ",Cache-Control,X-API-Key,X-Tenant-ID,X-User-ID"
+
+// CORS 跨域中间件：只对 cors.allowOrigins 中的来源返回跨域响应头，配置 "*" 时允许所有来源；预检请求直接返回
+func CORS(r *ghttp.Request) {
+	origin := r.Header.Get("Origin")
+	origins := g.Cfg().MustGet(r.Context(), consts.CORSAllowOrigins).Strings()
+	if origin != "" && (slices.Contains(origins, origin) || slices.Contains(origins, "*")) {
+		options := r.Response.DefaultCORSOptions()
+		options.AllowOrigin = origin
+		options.AllowHeaders += corsAllowHeaders
+		if !g.Cfg().MustGet(r.Context(), consts.CORSAllowCredentials, false).Bool() {
+			options.AllowCredentials = ""
+		}
+		r.Response.Header().Add("Vary", "Origin")
+		r.Response.CORS(options)
+	}
+	r.Middleware.Next()
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"agent/internal/consts"
+	"agent/internal/secret"
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	jwtLeeway = 30 * time.Second
+	// jwksTTL 公钥缓存时间，遇到未知的 kid 时最多每 jwksMinRefresh 重新拉取一次
+	jwksTTL        = time.Hour
+	jwksMinRefresh = time.Minute
+	jwksTimeout    = 10 * time.Second
+	jwksMaxBytes   = 1 << 20
+)
+
+// jwtAuthenticator 校验 Authorization: Bearer 头或 access_token 参数中的 JWT，HS 系列使用共享密钥，RS 系列使用 JWKS 中的公钥
+type jwtAuthenticator struct {
+	secret        []byte
+	jwks          *jwks
+	parser        *jwt.Parser
+	userClaim     string
+	tenantClaim   string
+	adminClaim    string
+	defaultTenant string
+}
+
+// jwtSecrets HS256/384/512 的共享密钥
+var jwtSecrets = secret.Declare("auth", consts.AuthJWTSecret)
+
+func newJWTAuthenticator(ctx context.Context, defaultTenant string) (*jwtAuthenticator, error) {
+	key, err := jwtSecrets.Get(ctx, consts.AuthJWTSecret)
+	if err != nil {
+		return nil, err
+	}
+	a := &jwtAuthenticator{
+		secret:        []byte(key),
+		userClaim:     g.Cfg().MustGet(ctx, consts.AuthJWTUserClaim, "sub").String(),
+		tenantClaim:   g.Cfg().MustGet(ctx, consts.AuthJWTTenantClaim, "tenant").String(),
+		adminClaim:    g.Cfg().MustGet(ctx, consts.AuthJWTAdminClaim, "admin").String(),
+		defaultTenant: defaultTenant,
+	}
+	var methods []string
+	if len(a.secret) > 0 {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if url := g.Cfg().MustGet(ctx, consts.AuthJWTJWKSURL).String(); url != "" {
+		a.jwks = newJWKS(url)
+		methods = append(methods, "RS256", "RS384", "RS512")
+	}
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("%s or %s is required for jwt auth", consts.AuthJWTSecret, consts.AuthJWTJWKSURL)
+	}
+
+	opts := []jwt.ParserOption{jwt.WithValidMethods(methods), jwt.WithLeeway(jwtLeeway), jwt.WithExpirationRequired()}
+	if issuer := g.Cfg().MustGet(ctx, consts.AuthJWTIssuer).String(); issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+	if audience := g.Cfg().MustGet(ctx, consts.AuthJWTAudience).String(); audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+	a.parser = jwt.NewParser(opts...)
+	return a, nil
+}
+
+func (a *jwtAuthenticator) Authenticate(r *ghttp.Request) (*Identity, error) {
+	token := bearerToken(r, "access_token")
+	if token == "" {
+		return nil, fmt.Errorf("%w: missing bearer token", ErrUnauthorized)
+	}
+	claims := jwt.MapClaims{}
+	if _, err := a.parser.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
+		return a.key(r.Context(), t)
+	}); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnauthorized, err)
+	}
+
+	identity := &Identity{
+		UserID:   gconv.String(claims[a.userClaim]),
+		TenantID: gconv.String(claims[a.tenantClaim]),
+		Admin:    gconv.Bool(claims[a.adminClaim]),
+		Method:   consts.AuthModeJWT,
+	}
+	if identity.UserID == "" {
+		return nil, fmt.Errorf("%w: token has no %s claim", ErrUnauthorized, a.userClaim)
+	}
+	if identity.TenantID == "" {
+		identity.TenantID = a.defaultTenant
+	}
+	return identity, nil
+}
+
+// key 按签名算法返回校验密钥
+func (a *jwtAuthenticator) key(ctx context.Context, t *jwt.Token) (any, error) {
+	switch t.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return a.secret, nil
+	case *jwt.SigningMethodRSA:
+		kid, _ := t.Header["kid"].(string)
+		return a.jwks.key(ctx, kid)
+	default:
+		return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
+	}
+}
+
+// jwks 从 JWKS 地址拉取并缓存 RSA 公钥
+type jwks struct {
+	url    string
+	client *http.Client
+
+	refreshMu sync.Mutex // 同一时间只拉取一次
+	mu        sync.RWMutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+func newJWKS(url string) *jwks {
+	return &jwks{url: url, client: &http.Client{Timeout: jwksTimeout}}
+}
+
+// key 按 kid 查找公钥，缓存过期或找不到时重新拉取
+func (j *jwks) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	key, ok, fetchedAt := j.lookup(kid)
+	if ok && time.Since(fetchedAt) < jwksTTL {
+		return key, nil
+	}
+	if err := j.refresh(ctx, fetchedAt); err != nil {
+		// 拉取失败时继续使用缓存中的公钥
+		if ok {
+			g.Log(consts.LoggerAgent).Warningf(ctx, "failed to refresh jwks, using cached keys: %v", err)
+			return key, nil
+		}
+		return nil, err
+	}
+	if key, ok, _ = j.lookup(kid); !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	return key, nil
+}
+
+// lookup 查找缓存中的公钥，token 未指定 kid 且只有一个公钥时使用该公钥
+func (j *jwks) lookup(kid string) (*rsa.PublicKey, bool, time.Time) {
+	j.mu.RLock()
+	defer j.mu.RUnlock()
+	if key, ok := j.keys[kid]; ok {
+		return key, true, j.fetchedAt
+	}
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, true, j.fetchedAt
+		}
+	}
+	return nil, false, j.fetchedAt
+}
+
+// refresh 重新拉取公钥，since 之后已有其他请求拉取过或距上次拉取不足 jwksMinRefresh 时跳过
+func (j *jwks) refresh(ctx context.Context, since time.Time) error {
+	j.refreshMu.Lock()
+	defer j.refreshMu.Unlock()
+	j.mu.RLock()
+	fetchedAt := j.fetchedAt
+	j.mu.RUnlock()
+	if fetchedAt.After(since) || time.Since(fetchedAt) < jwksMinRefresh {
+		return nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, j.url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create jwks request: %v", err)
+	}
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to fetch jwks: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to fetch jwks: status %d", resp.StatusCode)
+	}
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, jwksMaxBytes))
+	if err != nil {
+		return fmt.Errorf("failed to read jwks: %v", err)
+	}
+	if err = gjson.DecodeTo(body, &set); err != nil {
+		return fmt.Errorf("failed to decode jwks: %v", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return fmt.Errorf("failed to decode modulus of key %q: %v", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return fmt.Errorf("failed to decode exponent of key %q: %v", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	j.mu.Lock()
+	j.keys, j.fetchedAt = keys, time.Now()
+	j.mu.Unlock()
+	return nil
+}
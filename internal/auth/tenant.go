@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"agent/internal/consts"
+	"context"
+	"slices"
+
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// wildcard 通配符：auth.tenants 中适用于未单独配置的租户，名单中表示不限制
+const wildcard = "*"
+
+// Policy 租户可使用的 Agent、工具和模型，为空表示不限制
+type Policy struct {
+	Agents []string `json:"agents"` // chain / react / mcp（MCP 服务的工具和 ask_agent）
+	Tools  []string `json:"tools"`  // 工具名，如 web_search_tool、mcp_github_search
+	Models []string `json:"models"` // 对话模型，第一个为未指定模型时的默认模型
+}
+
+// TenantPolicy 读取租户配置，未配置的租户使用 auth.tenants["*"]，都未配置时不限制
+func TenantPolicy(ctx context.Context, tenantID string) *Policy {
+	var tenants map[string]*Policy
+	if err := g.Cfg().MustGet(ctx, consts.AuthTenants).Scan(&tenants); err != nil {
+		g.Log(consts.LoggerAgent).Warningf(ctx, "failed to parse %s: %v", consts.AuthTenants, err)
+	}
+	if p, ok := tenants[tenantID]; ok && p != nil {
+		return p
+	}
+	if p, ok := tenants[wildcard]; ok && p != nil {
+		return p
+	}
+	return &Policy{}
+}
+
+// AllowAgent 是否允许使用该 Agent
+func (p *Policy) AllowAgent(name string) bool {
+	return allowed(p.Agents, name)
+}
+
+// AllowTool 是否允许使用该工具
+func (p *Policy) AllowTool(name string) bool {
+	return allowed(p.Tools, name)
+}
+
+// AllowModel 是否允许使用该模型
+func (p *Policy) AllowModel(name string) bool {
+	return allowed(p.Models, name)
+}
+
+// Model 请求未指定模型时使用的模型：全局默认模型可用时使用它，否则使用租户的第一个模型
+func (p *Policy) Model(fallback string) string {
+	if p.AllowModel(fallback) || len(p.Models) == 0 {
+		return fallback
+	}
+	return p.Models[0]
+}
+
+func allowed(list []string, name string) bool {
+	return len(list) == 0 || slices.Contains(list, wildcard) || slices.Contains(list, name)
+}
@@ -2,12 +2,31 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
 
+	"agent/internal/audit"
+	"agent/internal/auth"
+	"agent/internal/consts"
 	"agent/internal/controller/agent"
+	"agent/internal/guardrail"
+	"agent/internal/limit"
+	"agent/internal/logging"
+	"agent/internal/metrics"
+	"agent/internal/pii"
+	"agent/internal/secret"
+	"agent/internal/service"
+	"agent/internal/tools"
+	"agent/internal/tracing"
+	"agent/internal/usage"
 
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
 	"github.com/gogf/gf/v2/os/gcmd"
+	"github.com/mark3labs/mcp-go/server"
 )
 
 var (
@@ -16,15 +35,215 @@ var (
 		Usage: "main",
 		Brief: "start http server",
 		Func: func(ctx context.Context, parser *gcmd.Parser) (err error) {
+			// 日志脱敏和上下文标签，需在输出其他日志之前设置
+			if err = logging.Init(ctx); err != nil {
+				return err
+			}
+			// 解析配置中的密钥引用，需在连接数据库和 Redis 之前调用
+			if err = secret.Init(ctx); err != nil {
+				return err
+			}
+			// 敏感信息识别规则，开启脱敏的租户在发给模型、写入历史和日志前替换
+			if err = pii.Init(ctx); err != nil {
+				return err
+			}
+			// 链路追踪，需在处理请求之前注册全局回调
+			shutdownTracing, err := tracing.Init(ctx, os.Stdout)
+			if err != nil {
+				return err
+			}
+			defer shutdownTracing(context.WithoutCancel(ctx))
+			// 统计每次模型和向量化调用的 token 用量，退出前写入队列中的记录
+			shutdownUsage := usage.Init(ctx)
+			defer shutdownWithTimeout(ctx, shutdownUsage)
+			// 工具调用的审计日志，退出前写入队列中的记录
+			shutdownAudit, err := audit.Init(ctx)
+			if err != nil {
+				return err
+			}
+			defer shutdownWithTimeout(ctx, shutdownAudit)
+			// 输入、工具结果和回答的内容安全检查
+			if err = guardrail.Init(ctx); err != nil {
+				return err
+			}
+
 			// -------------初始化 http 服务----------
 			s := g.Server()
+			// Prometheus 指标
+			if g.Cfg().MustGet(ctx, consts.MetricsEnabled, true).Bool() {
+				metrics.Init()
+				s.Use(metrics.Middleware)
+				s.BindHandler("GET:"+g.Cfg().MustGet(ctx, consts.MetricsPath, "/metrics").String(), ghttp.WrapH(metrics.Handler()))
+			}
+			// 跨域和认证，预检请求在认证之前返回
+			if err = auth.Init(ctx); err != nil {
+				return err
+			}
+			if address := g.Cfg().MustGet(ctx, consts.ServerAddress).String(); !auth.Enabled() && !isLoopback(address) {
+				g.Log(consts.LoggerAgent).Warningf(ctx, "HTTP server listens on %s without authentication, anyone who can reach it can use the agent: "+
+					"set auth.mode to apikey or jwt, or listen on a loopback address such as 127.0.0.1:8090", address)
+			}
+			s.Use(auth.CORS, auth.Middleware)
+			// 按用户的限流和配额，只作用于业务接口，不限制指标和文档
+			if err = limit.Init(ctx); err != nil {
+				return err
+			}
 			s.Group("/", func(group *ghttp.RouterGroup) {
+				group.Middleware(limit.Middleware)
 				group.Bind(
 					agent.NewV1(),
 				)
 			})
+			// 定时清理过期的会话工作目录
+			tools.StartWorkspaceJanitor(ctx)
+			// 定时清理超过保留时间的产物
+			tools.StartArtifactJanitor(ctx)
+			// 在后台连接配置的 MCP 服务，连接失败不影响启动
+			if err = tools.StartMCPManager(ctx); err != nil {
+				return err
+			}
 			s.Run()
-			return nil
+			tools.ShutdownMCPManager()
+			// 服务退出后关闭共享浏览器，等待进行中的页面渲染完成
+			shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			defer cancel()
+			return tools.ShutdownBrowserPool(shutdownCtx)
+		},
+	}
+
+	Mcp = gcmd.Command{
+		Name:  "mcp",
+		Usage: "main mcp [-t stdio|http] [-a :8091]",
+		Brief: "serve tools, the agent and the knowledge base over MCP",
+		Arguments: []gcmd.Argument{
+			{Name: "transport", Short: "t", Brief: "stdio or http (streamable HTTP), default mcpServer.transport"},
+			{Name: "address", Short: "a", Brief: "listen address of the http transport, default mcpServer.address"},
+		},
+		Func: func(ctx context.Context, parser *gcmd.Parser) (err error) {
+			transport := parser.GetOpt("transport", g.Cfg().MustGet(ctx, consts.MCPServerTransport, "stdio").String()).String()
+			address := parser.GetOpt("address", g.Cfg().MustGet(ctx, consts.MCPServerAddress, "127.0.0.1:8091").String()).String()
+			var console io.Writer = os.Stdout
+			if transport == "stdio" {
+				// stdout 用于 MCP 协议，日志和追踪改为输出到 stderr
+				logging.SetWriter(os.Stderr)
+				console = os.Stderr
+			}
+			if err = logging.Init(ctx); err != nil {
+				return err
+			}
+			if err = secret.Init(ctx); err != nil {
+				return err
+			}
+			if err = pii.Init(ctx); err != nil {
+				return err
+			}
+			shutdownTracing, err := tracing.Init(ctx, console)
+			if err != nil {
+				return err
+			}
+			defer shutdownTracing(context.WithoutCancel(ctx))
+			// 统计每次模型和向量化调用的 token 用量，退出前写入队列中的记录
+			shutdownUsage := usage.Init(ctx)
+			defer shutdownWithTimeout(ctx, shutdownUsage)
+			// 工具调用的审计日志，退出前写入队列中的记录
+			shutdownAudit, err := audit.Init(ctx)
+			if err != nil {
+				return err
+			}
+			defer shutdownWithTimeout(ctx, shutdownAudit)
+			if err = guardrail.Init(ctx); err != nil {
+				return err
+			}
+			// 每日工具调用和 token 配额，http 传输还按用户限流
+			if err = limit.Init(ctx); err != nil {
+				return err
+			}
+
+			tools.StartWorkspaceJanitor(ctx)
+			tools.StartArtifactJanitor(ctx)
+			if err = tools.StartMCPManager(ctx); err != nil {
+				return err
+			}
+			defer tools.ShutdownMCPManager()
+			defer func() {
+				shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+				defer cancel()
+				if shutdownErr := tools.ShutdownBrowserPool(shutdownCtx); err == nil {
+					err = shutdownErr
+				}
+			}()
+
+			srv, err := service.Agent().NewMCPServer(ctx)
+			if err != nil {
+				return err
+			}
+			switch transport {
+			case "stdio":
+				return server.ServeStdio(srv)
+			case "http":
+				return serveStreamableHTTP(ctx, srv, address)
+			default:
+				return fmt.Errorf("unsupported mcp transport: %s", transport)
+			}
 		},
 	}
 )
+
+func init() {
+	if err := Main.AddCommand(&Mcp); err != nil {
+		panic(err)
+	}
+}
+
+// shutdownWithTimeout 在退出时调用 shutdown，最多等待 10 秒
+func shutdownWithTimeout(ctx context.Context, shutdown func(context.Context) error) {
+	shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancel()
+	if err := shutdown(shutdownCtx); err != nil {
+		g.Log().Warningf(ctx, "shutdown failed: %v", err)
+	}
+}
+
+// serveStreamableHTTP 以 streamable HTTP 提供 MCP 服务，与 HTTP 接口使用相同的认证和限流，身份写入请求上下文，
+// 工具调用按租户策略和用户配额执行；未开启认证时只允许监听本机地址。收到退出信号后关闭
+func serveStreamableHTTP(ctx context.Context, srv *server.MCPServer, address string) error {
+	if err := auth.Init(ctx); err != nil {
+		return err
+	}
+	if !auth.Enabled() && !isLoopback(address) {
+		return fmt.Errorf("refusing to serve MCP on %s without authentication: set auth.mode to apikey or jwt, "+
+			"or listen on a loopback address such as 127.0.0.1:8091", address)
+	}
+	path := g.Cfg().MustGet(ctx, consts.MCPServerPath, "/mcp").String()
+	handler := server.NewStreamableHTTPServer(srv, server.WithEndpointPath(path))
+
+	s := g.Server(mcpHTTPServerName)
+	s.SetAddr(address)
+	// 未单独配置时会继承 HTTP 服务的配置，MCP 服务不提供接口文档
+	s.SetOpenApiPath("")
+	s.SetSwaggerPath("")
+	s.Use(auth.Middleware, limit.Middleware)
+	s.BindHandler(path, func(r *ghttp.Request) {
+		// MCP 请求的上下文由认证后的请求上下文派生，工具调用可以取到身份；SSE 需要直接写出，不经过响应缓冲
+		handler.ServeHTTP(r.Response.RawWriter(), r.Request.WithContext(r.Context()))
+	})
+	g.Log().Infof(ctx, "MCP server listening on %s%s", address, path)
+	s.Run()
+	return nil
+}
+
+// mcpHTTPServerName MCP http 传输使用的 HTTP 服务实例名，可通过 server.mcp 单独配置
+const mcpHTTPServerName = "mcp"
+
+// isLoopback 监听地址是否只接受本机连接
+func isLoopback(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -1,5 +1,10 @@
 package consts
 
+import "github.com/gogf/gf/v2/os/gctx"
+
+// ContextKey 请求上下文中 *model.Context 的键
+const ContextKey gctx.StrKey = "ContextKey"
+
 const (
 	ApiKey       = "ai.apiKey"
 	Model        = "ai.model"
@@ -7,7 +12,179 @@ const (
 	MilvusAddr   = "ai.milvusAddr"
 	SearchApiKey = "ai.SearchApiKey"
 	PexelsApiKey = "ai.pexelsApiKey"
-	McpApiKey    = "ai.mcpApiKey"
+	VisionModel  = "ai.visionModel"
+	Multimodal   = "ai.multimodal"
+
+	AgentMaxSteps       = "agent.maxSteps"
+	AgentMaxToolCalls   = "agent.maxToolCalls"
+	AgentToolCallLimits = "agent.toolCallLimits"
+	AgentTurnTimeout    = "agent.turnTimeout"
+	ApprovalEnabled     = "agent.approval.enabled"
+	ApprovalTools       = "agent.approval.tools"
+	ApprovalTimeout     = "agent.approval.timeout"
+
+	WorkspaceRoot            = "tools.workspace.root"
+	WorkspaceMaxBytes        = "tools.workspace.maxBytes"
+	WorkspaceMaxFiles        = "tools.workspace.maxFiles"
+	WorkspaceTTL             = "tools.workspace.ttl"
+	WorkspaceJanitorInterval = "tools.workspace.janitorInterval"
+	FileMaxReadBytes         = "tools.file.maxReadBytes"
+	FileMaxListEntries       = "tools.file.maxListEntries"
+	FileMaxSearchResults     = "tools.file.maxSearchResults"
+
+	WebSearchProvider = "tools.webSearch.provider"
+	WebSearchTimeout  = "tools.webSearch.timeout"
+	WebSearchRetries  = "tools.webSearch.retries"
+	WebSearchBackoff  = "tools.webSearch.backoff"
+	WebSearchCount    = "tools.webSearch.count"
+	WebSearchAPIKey   = "tools.webSearch.apiKey"
+	WebSearchEngine   = "tools.webSearch.engine"
+	WebSearchBaseURL  = "tools.webSearch.baseURL"
+	WebSearchFixture  = "tools.webSearch.fixture"
+
+	PhotoSearchProvider = "tools.photoSearch.provider"
+	PhotoSearchAPIKey   = "tools.photoSearch.apiKey"
+	PhotoSearchBaseURL  = "tools.photoSearch.baseURL"
+	PhotoSearchLocalDir = "tools.photoSearch.localDir"
+	PhotoSearchTimeout  = "tools.photoSearch.timeout"
+	PhotoSearchRetries  = "tools.photoSearch.retries"
+	PhotoSearchCount    = "tools.photoSearch.count"
+	PhotoSearchCacheTTL = "tools.photoSearch.cacheTTL"
+
+	BrowserMaxTabs        = "tools.browser.maxTabs"
+	BrowserExecPath       = "tools.browser.execPath"
+	BrowserAcquireTimeout = "tools.browser.acquireTimeout"
+	BrowserHealthInterval = "tools.browser.healthInterval"
+
+	PDFTemplateDir = "tools.pdf.templateDir"
+
+	HTTPAllowPrivateNetworks = "tools.http.allowPrivateNetworks"
+	HTTPMaxRedirects         = "tools.http.maxRedirects"
+	DownloadTimeout          = "tools.download.timeout"
+	DownloadMaxBytes         = "tools.download.maxBytes"
+	DownloadRetries          = "tools.download.retries"
+	DownloadAllowedTypes     = "tools.download.allowedTypes"
+
+	ImageMaxBytes = "tools.image.maxBytes"
+	ImageMaxCount = "tools.image.maxCount"
+	ImageDetail   = "tools.image.detail"
+
+	ArtifactDir             = "tools.artifact.dir"
+	ArtifactSecret          = "tools.artifact.secret"
+	ArtifactBaseURL         = "tools.artifact.baseURL"
+	ArtifactLinkTTL         = "tools.artifact.linkTTL"
+	ArtifactRetention       = "tools.artifact.retention"
+	ArtifactJanitorInterval = "tools.artifact.janitorInterval"
+
+	WebFetchTimeout        = "tools.webFetch.timeout"
+	WebFetchMaxBytes       = "tools.webFetch.maxBytes"
+	WebFetchChunkSize      = "tools.webFetch.chunkSize"
+	WebFetchCacheTTL       = "tools.webFetch.cacheTTL"
+	WebFetchDomainCacheTTL = "tools.webFetch.domainCacheTTL"
+
+	KnowledgeDir       = "tools.knowledge.dir"
+	KnowledgeTopK      = "tools.knowledge.topK"
+	KnowledgeRetriever = "tools.knowledge.retriever"
+
+	TerminalSandbox        = "tools.terminal.sandbox"
+	TerminalNetwork        = "tools.terminal.network"
+	TerminalTimeout        = "tools.terminal.timeout"
+	TerminalCPUSeconds     = "tools.terminal.cpuSeconds"
+	TerminalMemoryMB       = "tools.terminal.memoryMB"
+	TerminalMaxOutputBytes = "tools.terminal.maxOutputBytes"
+	TerminalAllowCommands  = "tools.terminal.allowCommands"
+	TerminalDenyCommands   = "tools.terminal.denyCommands"
+
+	MCPServers        = "mcp.servers"
+	MCPTimeout        = "mcp.timeout"
+	MCPRetries        = "mcp.retries"
+	MCPBackoff        = "mcp.backoff"
+	MCPHealthInterval = "mcp.healthInterval"
+
+	TracingEnabled           = "tracing.enabled"
+	TracingServiceName       = "tracing.serviceName"
+	TracingExporter          = "tracing.exporter"
+	TracingEndpoint          = "tracing.endpoint"
+	TracingInsecure          = "tracing.insecure"
+	TracingHeaders           = "tracing.headers"
+	TracingFile              = "tracing.file"
+	TracingSampleRatio       = "tracing.sampleRatio"
+	TracingCozeLoopEnabled   = "tracing.cozeloop.enabled"
+	TracingCozeLoopWorkspace = "tracing.cozeloop.workspaceID"
+	TracingCozeLoopToken     = "tracing.cozeloop.apiToken"
+
+	UsagePersist  = "usage.persist"
+	UsageCurrency = "usage.currency"
+	UsagePrices   = "usage.prices"
+
+	AuditEnabled          = "audit.enabled"
+	AuditPersist          = "audit.persist"
+	AuditFile             = "audit.file"
+	AuditMaxArgumentChars = "audit.maxArgumentChars"
+	AuditMaxResultChars   = "audit.maxResultChars"
+
+	LoggingRedactContent = "logging.redactContent"
+	LoggingRedactKeys    = "logging.redactKeys"
+	LoggingDebugEnabled  = "logging.debug.enabled"
+	LoggingDebugPath     = "logging.debug.path"
+
+	ServerAddress = "server.address"
+
+	AuthMode             = "auth.mode"
+	AuthAPIKeys          = "auth.apiKeys"
+	AuthJWTSecret        = "auth.jwt.secret"
+	AuthJWTJWKSURL       = "auth.jwt.jwksUrl"
+	AuthJWTIssuer        = "auth.jwt.issuer"
+	AuthJWTAudience      = "auth.jwt.audience"
+	AuthJWTUserClaim     = "auth.jwt.userClaim"
+	AuthJWTTenantClaim   = "auth.jwt.tenantClaim"
+	AuthJWTAdminClaim    = "auth.jwt.adminClaim"
+	AuthSkipPaths        = "auth.skipPaths"
+	AuthDefaultTenant    = "auth.defaultTenant"
+	AuthTenants          = "auth.tenants"
+	CORSAllowOrigins     = "cors.allowOrigins"
+	CORSAllowCredentials = "cors.allowCredentials"
+
+	LimitsStore          = "limits.store"
+	LimitsRedisGroup     = "limits.redisGroup"
+	LimitsRate           = "limits.http.rate"
+	LimitsBurst          = "limits.http.burst"
+	LimitsConcurrency    = "limits.concurrency.max"
+	LimitsConcurrencyTTL = "limits.concurrency.ttl"
+	LimitsDailyTokens    = "limits.daily.tokens"
+	LimitsDailyCost      = "limits.daily.cost"
+	LimitsDailyModels    = "limits.daily.models"
+	LimitsDailyTools     = "limits.daily.tools"
+
+	GuardrailEnabled           = "guardrail.enabled"
+	GuardrailBuiltinRules      = "guardrail.builtinRules"
+	GuardrailRules             = "guardrail.rules"
+	GuardrailUntrustedTools    = "guardrail.untrustedTools"
+	GuardrailUntrustMCP        = "guardrail.untrustMCP"
+	GuardrailSensitiveTools    = "guardrail.sensitiveTools"
+	GuardrailClassifierEnabled = "guardrail.classifier.enabled"
+	GuardrailClassifierModel   = "guardrail.classifier.model"
+	GuardrailClassifierStages  = "guardrail.classifier.stages"
+	GuardrailClassifierAction  = "guardrail.classifier.action"
+	GuardrailClassifierTimeout = "guardrail.classifier.timeout"
+
+	PIITenants  = "pii.tenants"
+	PIITypes    = "pii.types"
+	PIINames    = "pii.names"
+	PIIPatterns = "pii.patterns"
+
+	SecretsK8sDir = "secrets.k8sDir"
+
+	MetricsEnabled = "metrics.enabled"
+	MetricsPath    = "metrics.path"
+
+	MCPServerTransport = "mcpServer.transport"
+	MCPServerAddress   = "mcpServer.address"
+	MCPServerPath      = "mcpServer.path"
+
+	// 按组件区分的日志对象名，对应配置 logger.agent、logger.tools
+	LoggerAgent = "agent"
+	LoggerTools = "tools"
 
 	System    = "system"
 	User      = "user"
@@ -17,13 +194,61 @@ const (
 	Ai            = "ai"
 	Tools         = "tools"
 	DefaultSysMsg = "你是豆包，是字节跳动研发的人工智能助手，你可以回答用户的问题"
+
+	// SSE 事件类型
+	EventBudgetExhausted  = "budget_exhausted"
+	EventApprovalRequired = "approval_required"
+	EventApprovalResolved = "approval_resolved"
+	EventArtifact         = "artifact"
+	EventUsage            = "usage"
+	EventRateLimited      = "rate_limited"
+	EventGuardrail        = "guardrail"
+
+	// 用量统计中的 Agent 类型
+	AgentChain = "chain"
+	AgentReact = "react"
+	AgentMCP   = "mcp"
+
+	// 认证方式
+	AuthModeNone   = "none"
+	AuthModeAPIKey = "apikey"
+	AuthModeJWT    = "jwt"
+
+	ApprovalApprove = "approve"
+	ApprovalDeny    = "deny"
+	ApprovalEdit    = "edit"
+
+	BudgetMaxSteps    = "max_steps"
+	BudgetToolCalls   = "tool_calls"
+	BudgetTurnTimeout = "turn_timeout"
+
+	// 限流和配额类型
+	LimitRate        = "rate"
+	LimitConcurrency = "concurrency"
+	LimitDailyTokens = "daily_tokens"
+	LimitDailyCost   = "daily_cost"
+	LimitModelTokens = "model_tokens"
+	LimitToolCalls   = "tool_calls"
+
+	// 护栏检查的阶段
+	GuardrailStageInput      = "input"
+	GuardrailStageToolCall   = "tool_call"
+	GuardrailStageToolOutput = "tool_output"
+	GuardrailStageOutput     = "output"
+
+	// 护栏决策
+	GuardrailAllow = "allow"
+	GuardrailFlag  = "flag"
+	GuardrailBlock = "block"
 )
 
 var (
-	//DangerousCommands 安全检查 - 禁止危险命令
+	//DangerousCommands 安全检查 - 禁止执行的命令名，按 shell 分词后逐条命令匹配
 	DangerousCommands = []string{
-		"rm -rf", "sudo rm", "format", "del /f", "rmdir /s",
-		"shutdown", "reboot", "halt", "poweroff",
-		"dd if=", "mkfs", "fdisk",
+		"sudo", "su", "doas", "shutdown", "reboot", "halt", "poweroff", "init",
+		"dd", "mkfs", "fdisk", "parted", "mount", "umount", "format",
+		"chown", "chroot", "kill", "killall", "pkill",
+		"eval", "exec", "source", ".", "sh", "bash", "zsh", "dash",
+		"nc", "ncat", "ssh", "scp", "telnet",
 	}
 )
@@ -0,0 +1,12 @@
+package agent
+
+import (
+	"context"
+
+	"agent/api/agent/v1"
+	"agent/internal/service"
+)
+
+func (c *ControllerV1) Approval(ctx context.Context, req *v1.ApprovalReq) (res *v1.ApprovalRes, err error) {
+	return service.Agent().Approve(ctx, req)
+}
@@ -0,0 +1,12 @@
+package agent
+
+import (
+	"context"
+
+	"agent/api/agent/v1"
+	"agent/internal/service"
+)
+
+func (c *ControllerV1) Artifact(ctx context.Context, req *v1.ArtifactReq) (res *v1.ArtifactRes, err error) {
+	return service.Agent().ServeArtifact(ctx, req)
+}
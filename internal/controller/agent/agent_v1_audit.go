@@ -0,0 +1,12 @@
+package agent
+
+import (
+	"context"
+
+	"agent/api/agent/v1"
+	"agent/internal/service"
+)
+
+func (c *ControllerV1) Audit(ctx context.Context, req *v1.AuditReq) (res *v1.AuditRes, err error) {
+	return service.Agent().Audit(ctx, req)
+}
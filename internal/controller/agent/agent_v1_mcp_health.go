@@ -0,0 +1,12 @@
+package agent
+
+import (
+	"context"
+
+	"agent/api/agent/v1"
+	"agent/internal/service"
+)
+
+func (c *ControllerV1) MCPHealth(ctx context.Context, req *v1.MCPHealthReq) (res *v1.MCPHealthRes, err error) {
+	return service.Agent().MCPHealth(ctx, req)
+}
@@ -0,0 +1,12 @@
+package agent
+
+import (
+	"context"
+
+	"agent/api/agent/v1"
+	"agent/internal/service"
+)
+
+func (c *ControllerV1) SessionDelete(ctx context.Context, req *v1.SessionDeleteReq) (res *v1.SessionDeleteRes, err error) {
+	return service.Agent().DeleteSession(ctx, req)
+}
@@ -0,0 +1,22 @@
+// =================================================================================
+// This file is auto-generated by the GoFrame CLI tools. You may modify it as needed.
+// =================================================================================
+
+package dao
+
+import (
+	"agent/internal/dao/internal"
+)
+
+// tokenUsageDao is the data access object for the table token_usage.
+// You can define custom methods on it to extend its functionality as needed.
+type tokenUsageDao struct {
+	*internal.TokenUsageDao
+}
+
+var (
+	// TokenUsage is a globally accessible object for table token_usage operations.
+	TokenUsage = tokenUsageDao{internal.NewTokenUsageDao()}
+)
+
+// Add your custom methods and functionality below.
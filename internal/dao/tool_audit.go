@@ -0,0 +1,22 @@
+// =================================================================================
+// This file is auto-generated by the GoFrame CLI tools. You may modify it as needed.
+// =================================================================================
+
+package dao
+
+import (
+	"agent/internal/dao/internal"
+)
+
+// toolAuditDao is the data access object for the table tool_audit.
+// You can define custom methods on it to extend its functionality as needed.
+type toolAuditDao struct {
+	*internal.ToolAuditDao
+}
+
+var (
+	// ToolAudit is a globally accessible object for table tool_audit operations.
+	ToolAudit = toolAuditDao{internal.NewToolAuditDao()}
+)
+
+// Add your custom methods and functionality below.
@@ -0,0 +1,268 @@
+package guardrail
+
+import (
+	"agent/internal/consts"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// tracerName 护栏 span 的 instrumentation 名称
+	tracerName = "agent/internal/guardrail"
+
+	engineRules      = "rules"
+	engineClassifier = "classifier"
+	enginePolicy     = "policy"
+
+	defaultClassifierTimeout = 10 * time.Second
+)
+
+// span 属性
+const (
+	AttrStage  = attribute.Key("guardrail.stage")
+	AttrSource = attribute.Key("guardrail.source")
+	AttrAction = attribute.Key("guardrail.action")
+	AttrEngine = attribute.Key("guardrail.engine")
+	AttrRule   = attribute.Key("guardrail.rule")
+	AttrReason = attribute.Key("guardrail.reason")
+	AttrSize   = attribute.Key("guardrail.content_size")
+)
+
+// Config 护栏配置
+type Config struct {
+	Enabled        bool
+	BuiltinRules   bool          // 启用内置的提示注入和危险命令规则
+	Rules          []*RuleConfig // 自定义规则
+	UntrustedTools []string      // 结果来自外部、需要标记和检查的工具，"*" 表示所有工具
+	UntrustMCP     bool          // MCP 服务提供的工具结果均视为不可信
+	SensitiveTools []string      // 不可信内容违反策略后，本轮不再执行的高风险工具
+
+	Classifier        bool     // 启用大模型分类器
+	ClassifierModel   string   // 分类器使用的模型，为空时使用 ai.model
+	ClassifierStages  []string // 使用分类器的阶段
+	ClassifierAction  string   // 分类器判定违规时的决策：flag / block
+	ClassifierTimeout time.Duration
+}
+
+// LoadConfig 读取护栏配置
+func LoadConfig(ctx context.Context) (*Config, error) {
+	cfg := &Config{
+		Enabled:        g.Cfg().MustGet(ctx, consts.GuardrailEnabled, false).Bool(),
+		BuiltinRules:   g.Cfg().MustGet(ctx, consts.GuardrailBuiltinRules, true).Bool(),
+		UntrustedTools: g.Cfg().MustGet(ctx, consts.GuardrailUntrustedTools).Strings(),
+		UntrustMCP:     g.Cfg().MustGet(ctx, consts.GuardrailUntrustMCP, true).Bool(),
+		SensitiveTools: g.Cfg().MustGet(ctx, consts.GuardrailSensitiveTools).Strings(),
+
+		Classifier:        g.Cfg().MustGet(ctx, consts.GuardrailClassifierEnabled, false).Bool(),
+		ClassifierModel:   g.Cfg().MustGet(ctx, consts.GuardrailClassifierModel).String(),
+		ClassifierStages:  g.Cfg().MustGet(ctx, consts.GuardrailClassifierStages, []string{consts.GuardrailStageInput, consts.GuardrailStageToolOutput}).Strings(),
+		ClassifierAction:  g.Cfg().MustGet(ctx, consts.GuardrailClassifierAction, consts.GuardrailBlock).String(),
+		ClassifierTimeout: g.Cfg().MustGet(ctx, consts.GuardrailClassifierTimeout, defaultClassifierTimeout).Duration(),
+	}
+	if err := g.Cfg().MustGet(ctx, consts.GuardrailRules).Scan(&cfg.Rules); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", consts.GuardrailRules, err)
+	}
+	if cfg.ClassifierModel == "" {
+		cfg.ClassifierModel = g.Cfg().MustGet(ctx, consts.Model).String()
+	}
+	if cfg.ClassifierAction != consts.GuardrailFlag {
+		cfg.ClassifierAction = consts.GuardrailBlock
+	}
+	if cfg.ClassifierTimeout <= 0 {
+		cfg.ClassifierTimeout = defaultClassifierTimeout
+	}
+	return cfg, nil
+}
+
+// guard 生效中的护栏
+type guard struct {
+	cfg        *Config
+	rules      []*rule
+	classifier classifier
+}
+
+var current = &guard{cfg: &Config{}}
+
+// Init 按配置编译规则并创建分类器，需在处理请求之前调用
+func Init(ctx context.Context) error {
+	cfg, err := LoadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	gd, err := newGuard(cfg)
+	if err != nil {
+		return err
+	}
+	if cfg.Enabled && cfg.Classifier {
+		if gd.classifier, err = newLLMClassifier(ctx, cfg.ClassifierModel, cfg.ClassifierTimeout); err != nil {
+			return err
+		}
+	}
+	current = gd
+	if cfg.Enabled {
+		g.Log(consts.LoggerAgent).Infof(ctx, "Guardrails enabled with %d rules, classifier: %v", len(gd.rules), gd.classifier != nil)
+	}
+	return nil
+}
+
+// newGuard 编译内置和自定义规则
+func newGuard(cfg *Config) (*guard, error) {
+	var configs []*RuleConfig
+	if cfg.BuiltinRules {
+		configs = append(configs, builtinRules...)
+	}
+	configs = append(configs, cfg.Rules...)
+	rules := make([]*rule, 0, len(configs))
+	for _, c := range configs {
+		r, err := compileRule(c)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, r)
+	}
+	return &guard{cfg: cfg, rules: rules}, nil
+}
+
+// Enabled 是否启用护栏
+func Enabled() bool {
+	return current.cfg.Enabled
+}
+
+// Untrusted 工具的结果是否来自外部、需要标记和检查，mcp 表示工具由 MCP 服务提供
+func Untrusted(name string, mcp bool) bool {
+	return (mcp && current.cfg.UntrustMCP) || matchTool(current.cfg.UntrustedTools, name)
+}
+
+// Sensitive 是否为不可信内容违反策略后需要拦截的高风险工具
+func Sensitive(name string) bool {
+	return matchTool(current.cfg.SensitiveTools, name)
+}
+
+func matchTool(names []string, name string) bool {
+	return slices.Contains(names, "*") || slices.Contains(names, name)
+}
+
+// Decision 一次护栏检查的决策
+type Decision struct {
+	Stage  string // input / tool_call / tool_output / output
+	Source string // 内容来源：user、assistant 或工具名
+	Action string // allow / flag / block
+	Engine string // rules / classifier / policy
+	Rule   string // 命中的规则名或分类器给出的类别
+	Reason string
+}
+
+// Violated 是否违反策略（标记或拦截）
+func (d *Decision) Violated() bool {
+	return d.Action != consts.GuardrailAllow
+}
+
+// Blocked 是否拦截
+func (d *Decision) Blocked() bool {
+	return d.Action == consts.GuardrailBlock
+}
+
+// Message 面向用户和模型的说明
+func (d *Decision) Message() string {
+	return fmt.Sprintf("%s from %s was %s by guardrail rule %s: %s", d.Stage, d.Source, actionVerb(d.Action), d.Rule, d.Reason)
+}
+
+func actionVerb(action string) string {
+	if action == consts.GuardrailBlock {
+		return "blocked"
+	}
+	return "flagged"
+}
+
+// Check 依次使用规则和分类器检查内容，决策记录到链路追踪中；未启用护栏时直接放行
+func Check(ctx context.Context, stage, source, text string) *Decision {
+	d := &Decision{Stage: stage, Source: source, Action: consts.GuardrailAllow}
+	if !Enabled() {
+		return d
+	}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "guardrail "+stage)
+	defer span.End()
+
+	// 1. 规则，拦截优先于标记
+	for _, r := range current.rules {
+		if !r.applies(stage) {
+			continue
+		}
+		if match, ok := r.match(text); ok && rank(r.action) > rank(d.Action) {
+			d.Action, d.Engine, d.Rule = r.action, engineRules, r.name
+			d.Reason = fmt.Sprintf("matched %q", truncate(match, 80))
+		}
+	}
+
+	// 2. 规则未拦截时使用分类器
+	if !d.Blocked() && current.classifier != nil && slices.Contains(current.cfg.ClassifierStages, stage) {
+		v, err := current.classifier.Classify(ctx, stage, source, text)
+		switch {
+		case err != nil:
+			// 分类器不可用时不影响对话，错误记录在 span 中
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			g.Log(consts.LoggerAgent).Warningf(ctx, "guardrail classifier failed, using rule decision: %v", err)
+		case v.Violation:
+			d.Action, d.Engine, d.Rule, d.Reason = current.cfg.ClassifierAction, engineClassifier, v.Category, v.Reason
+		}
+	}
+
+	span.SetAttributes(AttrSize.Int(len(text)))
+	record(ctx, d)
+	return d
+}
+
+// Block 记录按策略（而非内容检查）做出的拦截决策，如不可信内容违规后拦截高风险工具
+func Block(ctx context.Context, stage, source, rule, reason string) *Decision {
+	d := &Decision{Stage: stage, Source: source, Action: consts.GuardrailBlock, Engine: enginePolicy, Rule: rule, Reason: reason}
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "guardrail "+stage)
+	defer span.End()
+	record(ctx, d)
+	return d
+}
+
+// record 将决策写入当前 span，违反策略的决策同时写入日志
+func record(ctx context.Context, d *Decision) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(
+		AttrStage.String(d.Stage),
+		AttrSource.String(d.Source),
+		AttrAction.String(d.Action),
+	)
+	if !d.Violated() {
+		return
+	}
+	span.SetAttributes(AttrEngine.String(d.Engine), AttrRule.String(d.Rule), AttrReason.String(d.Reason))
+	g.Log(consts.LoggerAgent).Warningf(ctx, "guardrail %s %s from %s: rule=%s engine=%s reason=%s",
+		actionVerb(d.Action), d.Stage, d.Source, d.Rule, d.Engine, d.Reason)
+}
+
+// rank 决策的严重程度
+func rank(action string) int {
+	switch action {
+	case consts.GuardrailBlock:
+		return 2
+	case consts.GuardrailFlag:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "..."
+	}
+	return s
+}
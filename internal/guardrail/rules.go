@@ -0,0 +1,113 @@
+package guardrail
+
+import (
+	"agent/internal/consts"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// RuleConfig 关键词和正则规则，关键词不区分大小写，正则可用 (?i) 忽略大小写
+type RuleConfig struct {
+	Name     string   `json:"name"`
+	Stages   []string `json:"stages"`   // 生效的阶段，为空时对所有阶段生效
+	Keywords []string `json:"keywords"` // 包含任一关键词即命中
+	Patterns []string `json:"patterns"` // 匹配任一正则即命中
+	Action   string   `json:"action"`   // flag / block，默认 block
+}
+
+// builtinRules 内置规则：外部内容中的提示注入和角色伪造、用户输入中的越狱，以及工具参数中的危险命令
+var builtinRules = []*RuleConfig{
+	{
+		Name:   "prompt_injection",
+		Stages: []string{consts.GuardrailStageInput, consts.GuardrailStageToolOutput},
+		Patterns: []string{
+			`(?i)\b(ignore|disregard|forget|override)\s+(all\s+|any\s+)?(of\s+)?(the\s+|your\s+)?(previous|prior|above|earlier|preceding|system)\s+(instructions?|prompts?|rules|directions|guidelines)`,
+			`(?i)\b(reveal|print|show|repeat|output|leak|disclose)\s+(me\s+)?(your|the)\s+(full\s+|entire\s+|hidden\s+|original\s+)?(system\s+prompt|system\s+message|initial\s+instructions|hidden\s+instructions)`,
+			`(?i)\byou\s+are\s+now\s+(in\s+)?(developer|dan|jailbreak|god)\s*mode`,
+			`(忽略|无视|忘记|忘掉)(之前|以上|上面|前面|先前|所有)(的|所有的)?(所有)?(指令|指示|提示|提示词|规则|要求)`,
+			`(输出|泄露|透露|显示|重复|告诉我)(你的|你)?(系统提示|系统提示词|系统指令|初始指令)`,
+		},
+		Action: consts.GuardrailBlock,
+	},
+	{
+		Name:   "role_spoofing",
+		Stages: []string{consts.GuardrailStageToolOutput},
+		Patterns: []string{
+			`(?i)<\|?\s*(im_start|im_end|system|endoftext)\s*\|?>`,
+			`(?im)^\s*(system|assistant)\s*:\s*(you|ignore|new\s+instructions)`,
+			`(?i)</?\s*untrusted_content`,
+		},
+		Action: consts.GuardrailFlag,
+	},
+	{
+		Name:   "dangerous_command",
+		Stages: []string{consts.GuardrailStageToolCall},
+		Patterns: []string{
+			`(?i)\b(curl|wget)\b[^\n|]*\|\s*(sudo\s+)?(ba|z|da)?sh\b`,
+			`(?i)\brm\s+-[a-z]*r[a-z]*f?\s+(/|~|\$HOME)(\s|$|")`,
+			`(?i)(\.ssh/id_[a-z0-9]+|/etc/shadow|/etc/passwd|\.aws/credentials|\.env\b)`,
+			`(?i)\b(nc|ncat|netcat)\b\s+[^\n]*\s-e\s`,
+			`(?i)base64\s+(-d|--decode)[^\n|]*\|\s*(ba|z|da)?sh\b`,
+		},
+		Action: consts.GuardrailBlock,
+	},
+}
+
+// rule 编译后的规则
+type rule struct {
+	name     string
+	stages   []string
+	keywords []string
+	patterns []*regexp.Regexp
+	action   string
+}
+
+func compileRule(c *RuleConfig) (*rule, error) {
+	if c.Name == "" {
+		return nil, fmt.Errorf("guardrail rule name is required")
+	}
+	action := c.Action
+	if action == "" {
+		action = consts.GuardrailBlock
+	}
+	if action != consts.GuardrailBlock && action != consts.GuardrailFlag {
+		return nil, fmt.Errorf("unsupported action of guardrail rule %s: %s", c.Name, action)
+	}
+	r := &rule{name: c.Name, stages: c.Stages, action: action}
+	for _, k := range c.Keywords {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			r.keywords = append(r.keywords, k)
+		}
+	}
+	for _, p := range c.Patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile pattern of guardrail rule %s: %v", c.Name, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// applies 规则是否对该阶段生效
+func (r *rule) applies(stage string) bool {
+	return len(r.stages) == 0 || slices.Contains(r.stages, stage)
+}
+
+// match 返回命中的内容
+func (r *rule) match(text string) (string, bool) {
+	lower := strings.ToLower(text)
+	for _, k := range r.keywords {
+		if strings.Contains(lower, k) {
+			return k, true
+		}
+	}
+	for _, re := range r.patterns {
+		if m := re.FindString(text); m != "" {
+			return m, true
+		}
+	}
+	return "", false
+}
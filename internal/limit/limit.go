@@ -0,0 +1,278 @@
+package limit
+
+import (
+	"agent/internal/auth"
+	"agent/internal/consts"
+	"agent/internal/usage"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/util/guid"
+)
+
+const (
+	storeMemory = "memory"
+	storeRedis  = "redis"
+
+	redisKeyPrefix        = "agent:limit:"
+	defaultConcurrencyTTL = 30 * time.Minute
+	// dailyTTL 每日计数的保留时间，略长于一天以覆盖时区差异
+	dailyTTL = 48 * time.Hour
+)
+
+// Config 限流和配额配置，数值为 0 表示不限制
+type Config struct {
+	Store          string           // memory / redis
+	RedisGroup     string           // store 为 redis 时使用的 redis 配置分组
+	Rate           float64          // 每个用户（未认证时每个 IP）每秒的请求数
+	Burst          int              // 令牌桶容量，即允许的突发请求数
+	Concurrency    int              // 每个用户同时进行的对话数
+	ConcurrencyTTL time.Duration    // 并发名额的最长占用时间，防止进程退出后名额泄漏
+	DailyTokens    int64            // 每个用户每天的 token 数
+	DailyCost      float64          // 每个用户每天的费用，币种同 usage.currency
+	Models         map[string]int64 // 每个用户每天在各模型上的 token 数
+	Tools          map[string]int64 // 每个用户每天的工具调用次数
+}
+
+// LoadConfig 读取限流和配额配置
+func LoadConfig(ctx context.Context) (*Config, error) {
+	cfg := &Config{
+		Store:          g.Cfg().MustGet(ctx, consts.LimitsStore, storeMemory).String(),
+		RedisGroup:     g.Cfg().MustGet(ctx, consts.LimitsRedisGroup, "default").String(),
+		Rate:           g.Cfg().MustGet(ctx, consts.LimitsRate, 0).Float64(),
+		Burst:          g.Cfg().MustGet(ctx, consts.LimitsBurst, 0).Int(),
+		Concurrency:    g.Cfg().MustGet(ctx, consts.LimitsConcurrency, 0).Int(),
+		ConcurrencyTTL: g.Cfg().MustGet(ctx, consts.LimitsConcurrencyTTL, defaultConcurrencyTTL).Duration(),
+		DailyTokens:    g.Cfg().MustGet(ctx, consts.LimitsDailyTokens, 0).Int64(),
+		DailyCost:      g.Cfg().MustGet(ctx, consts.LimitsDailyCost, 0).Float64(),
+	}
+	if err := g.Cfg().MustGet(ctx, consts.LimitsDailyModels).Scan(&cfg.Models); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", consts.LimitsDailyModels, err)
+	}
+	if err := g.Cfg().MustGet(ctx, consts.LimitsDailyTools).Scan(&cfg.Tools); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", consts.LimitsDailyTools, err)
+	}
+	if cfg.Burst < 1 {
+		cfg.Burst = int(math.Max(1, math.Ceil(cfg.Rate)))
+	}
+	if cfg.ConcurrencyTTL <= 0 {
+		cfg.ConcurrencyTTL = defaultConcurrencyTTL
+	}
+	return cfg, nil
+}
+
+var (
+	current       = &Config{}
+	store   Store = newMemoryStore()
+)
+
+// Init 按配置创建计数存储并开始按用量累计每日配额，需在处理请求之前调用
+func Init(ctx context.Context) error {
+	cfg, err := LoadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	switch cfg.Store {
+	case storeMemory, "":
+		store = newMemoryStore()
+	case storeRedis:
+		redis := g.Redis(cfg.RedisGroup)
+		if redis == nil {
+			return fmt.Errorf("redis group %q is not configured", cfg.RedisGroup)
+		}
+		store = newRedisStore(redis, redisKeyPrefix)
+	default:
+		return fmt.Errorf("unsupported limits store: %s", cfg.Store)
+	}
+	current = cfg
+	usage.OnRecord(recordUsage)
+	return nil
+}
+
+// Error 超出限流或配额
+type Error struct {
+	Limit      string        // rate / concurrency / daily_tokens / daily_cost / model_tokens / tool_calls
+	Tool       string        // 超出 tool_calls 配额的工具
+	Max        float64       // 上限
+	Used       float64       // 已使用量
+	RetryAfter time.Duration // 可重试的等待时间
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// WriteError 返回 429，err 为 *Error 时在 Retry-After 中给出可重试的秒数
+func WriteError(r *ghttp.Request, err error) {
+	var limitErr *Error
+	if errors.As(err, &limitErr) && limitErr.RetryAfter > 0 {
+		r.Response.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+	}
+	r.Response.WriteStatus(http.StatusTooManyRequests, err.Error())
+}
+
+// Subject 限流和配额的对象：已认证的用户按租户和用户区分，匿名请求按客户端 IP 区分
+func Subject(ctx context.Context) string {
+	if identity := auth.FromCtx(ctx); identity.UserID != "" {
+		return "user:" + identity.TenantID + "/" + identity.UserID
+	}
+	if r := ghttp.RequestFromCtx(ctx); r != nil {
+		return "ip:" + r.GetClientIp()
+	}
+	return "anonymous"
+}
+
+// Middleware 令牌桶限制每个用户（未认证时每个 IP）的请求速率，超出时返回 429；计数存储不可用时放行
+func Middleware(r *ghttp.Request) {
+	if current.Rate <= 0 {
+		r.Middleware.Next()
+		return
+	}
+	ctx := r.Context()
+	retryAfter, err := store.Allow(ctx, "rate:"+Subject(ctx), current.Rate, current.Burst)
+	if err != nil {
+		g.Log().Warningf(ctx, "rate limit check failed, allowing request: %v", err)
+	} else if retryAfter > 0 {
+		WriteError(r, &Error{
+			Limit:      consts.LimitRate,
+			Max:        current.Rate,
+			RetryAfter: retryAfter,
+			Message:    fmt.Sprintf("rate limit exceeded, retry after %s", retryAfter.Round(time.Millisecond)),
+		})
+		return
+	}
+	r.Middleware.Next()
+}
+
+// Acquire 占用当前用户的一个生成名额，返回的函数在生成结束时释放名额；超过并发上限时返回 *Error
+func Acquire(ctx context.Context) (release func(), err error) {
+	release = func() {}
+	if current.Concurrency <= 0 {
+		return release, nil
+	}
+	key, id := "concurrency:"+Subject(ctx), guid.S()
+	ok, err := store.Acquire(ctx, key, id, current.Concurrency, current.ConcurrencyTTL)
+	if err != nil {
+		g.Log().Warningf(ctx, "concurrency limit check failed, allowing generation: %v", err)
+		return release, nil
+	}
+	if !ok {
+		return nil, &Error{
+			Limit:      consts.LimitConcurrency,
+			Max:        float64(current.Concurrency),
+			Used:       float64(current.Concurrency),
+			RetryAfter: time.Second,
+			Message:    fmt.Sprintf("too many concurrent generations, at most %d per user", current.Concurrency),
+		}
+	}
+	return func() {
+		if err := store.Release(context.WithoutCancel(ctx), key, id); err != nil {
+			g.Log().Warningf(ctx, "failed to release generation slot: %v", err)
+		}
+	}, nil
+}
+
+// CheckQuota 每轮对话开始前检查当前用户今天的 token、费用和所用模型的配额，超出时返回 *Error
+func CheckQuota(ctx context.Context, modelName string) error {
+	subject, day := Subject(ctx), today()
+	quotas := []struct {
+		limit string
+		name  string
+		max   float64
+	}{
+		{consts.LimitDailyTokens, "tokens", float64(current.DailyTokens)},
+		{consts.LimitDailyCost, "cost", current.DailyCost},
+		{consts.LimitModelTokens, "model:" + modelName, float64(current.Models[modelName])},
+	}
+	for _, q := range quotas {
+		if q.max <= 0 {
+			continue
+		}
+		used, err := store.Get(ctx, dailyKey(day, subject, q.name))
+		if err != nil {
+			g.Log().Warningf(ctx, "quota check failed, allowing generation: %v", err)
+			continue
+		}
+		if used >= q.max {
+			return &Error{
+				Limit:      q.limit,
+				Max:        q.max,
+				Used:       used,
+				RetryAfter: untilTomorrow(),
+				Message:    fmt.Sprintf("daily quota of %s exceeded: used %g of %g", q.name, used, q.max),
+			}
+		}
+	}
+	return nil
+}
+
+// TakeTool 计入一次工具调用，超过该工具每天的调用次数时返回 *Error
+func TakeTool(ctx context.Context, name string) error {
+	maxCalls := current.Tools[name]
+	if maxCalls <= 0 {
+		return nil
+	}
+	used, err := store.Add(ctx, dailyKey(today(), Subject(ctx), "tool:"+name), 1, dailyTTL)
+	if err != nil {
+		g.Log().Warningf(ctx, "tool quota check failed, allowing call: %v", err)
+		return nil
+	}
+	if used > float64(maxCalls) {
+		return &Error{
+			Limit:      consts.LimitToolCalls,
+			Tool:       name,
+			Max:        float64(maxCalls),
+			Used:       float64(maxCalls),
+			RetryAfter: untilTomorrow(),
+			Message:    fmt.Sprintf("daily quota of %s exceeded: at most %d calls per day", name, maxCalls),
+		}
+	}
+	return nil
+}
+
+// recordUsage 按模型调用的用量累计每日配额，只累计配置了上限的项
+func recordUsage(ctx context.Context, r *usage.Record) {
+	// 流式输出在请求结束后才统计完，不能使用已取消的上下文
+	ctx = context.WithoutCancel(ctx)
+	subject, day := Subject(ctx), today()
+	counts := []struct {
+		enabled bool
+		name    string
+		n       float64
+	}{
+		{current.DailyTokens > 0, "tokens", float64(r.TotalTokens)},
+		{current.DailyCost > 0, "cost", r.Cost},
+		{current.Models[r.Model] > 0, "model:" + r.Model, float64(r.TotalTokens)},
+	}
+	for _, c := range counts {
+		if !c.enabled || c.n == 0 {
+			continue
+		}
+		if _, err := store.Add(ctx, dailyKey(day, subject, c.name), c.n, dailyTTL); err != nil {
+			g.Log().Warningf(ctx, "failed to record quota usage: %v", err)
+		}
+	}
+}
+
+func dailyKey(day, subject, name string) string {
+	return "daily:" + day + ":" + subject + ":" + name
+}
+
+func today() string {
+	return time.Now().Format("2006-01-02")
+}
+
+// untilTomorrow 距离明天零点的时间，每日配额在零点重置
+func untilTomorrow() time.Duration {
+	now := time.Now()
+	y, m, d := now.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, now.Location()).Sub(now)
+}
@@ -0,0 +1,205 @@
+package limit
+
+import (
+	"agent/internal/auth"
+	"agent/internal/consts"
+	"agent/internal/usage"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock 可手动推进的时钟
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newTestStore() (*memoryStore, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	s := newMemoryStore()
+	s.now = clock.now
+	return s, clock
+}
+
+// useConfig 测试期间使用给定的配置和内存存储
+func useConfig(t1 *testing.T, cfg *Config) {
+	oldCfg, oldStore := current, store
+	current, store = cfg, newMemoryStore()
+	t1.Cleanup(func() { current, store = oldCfg, oldStore })
+}
+
+func userCtx(user string) context.Context {
+	return auth.WithIdentity(context.Background(), &auth.Identity{UserID: user, TenantID: "acme"})
+}
+
+func TestMemoryStore_Allow(t1 *testing.T) {
+	s, clock := newTestStore()
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		advance time.Duration
+		want    time.Duration
+	}{
+		{name: "burst 1", want: 0},
+		{name: "burst 2", want: 0},
+		{name: "empty", want: 500 * time.Millisecond},
+		{name: "half refilled", advance: 250 * time.Millisecond, want: 250 * time.Millisecond},
+		{name: "refilled", advance: 250 * time.Millisecond, want: 0},
+		{name: "never exceeds burst", advance: time.Hour, want: 0},
+		{name: "burst after idle", want: 0},
+		{name: "empty after idle", want: 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		clock.t = clock.t.Add(tt.advance)
+		got, err := s.Allow(ctx, "k", 2, 2)
+		if err != nil {
+			t1.Fatal(err)
+		}
+		if got != tt.want {
+			t1.Errorf("%s: Allow() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if got, _ := s.Allow(ctx, "other", 2, 2); got != 0 {
+		t1.Errorf("keys must not share a bucket, got retry after %v", got)
+	}
+}
+
+func TestMemoryStore_AcquireRelease(t1 *testing.T) {
+	s, clock := newTestStore()
+	ctx := context.Background()
+	if ok, _ := s.Acquire(ctx, "k", "a", 2, time.Minute); !ok {
+		t1.Fatal("first slot must be acquired")
+	}
+	if ok, _ := s.Acquire(ctx, "k", "b", 2, time.Minute); !ok {
+		t1.Fatal("second slot must be acquired")
+	}
+	if ok, _ := s.Acquire(ctx, "k", "c", 2, time.Minute); ok {
+		t1.Fatal("third slot must be rejected")
+	}
+	_ = s.Release(ctx, "k", "a")
+	if ok, _ := s.Acquire(ctx, "k", "c", 2, time.Minute); !ok {
+		t1.Fatal("released slot must be reusable")
+	}
+	// 未释放的名额在 ttl 后过期
+	clock.t = clock.t.Add(time.Minute)
+	if ok, _ := s.Acquire(ctx, "k", "d", 1, time.Minute); !ok {
+		t1.Fatal("expired slots must be reclaimed")
+	}
+}
+
+func TestMemoryStore_AddGet(t1 *testing.T) {
+	s, clock := newTestStore()
+	ctx := context.Background()
+	if got, _ := s.Add(ctx, "k", 1.5, time.Hour); got != 1.5 {
+		t1.Errorf("Add() = %v, want 1.5", got)
+	}
+	if got, _ := s.Add(ctx, "k", 2, time.Hour); got != 3.5 {
+		t1.Errorf("Add() = %v, want 3.5", got)
+	}
+	if got, _ := s.Get(ctx, "k"); got != 3.5 {
+		t1.Errorf("Get() = %v, want 3.5", got)
+	}
+	clock.t = clock.t.Add(time.Hour)
+	if got, _ := s.Get(ctx, "k"); got != 0 {
+		t1.Errorf("Get() after expiry = %v, want 0", got)
+	}
+	if got, _ := s.Add(ctx, "k", 1, time.Hour); got != 1 {
+		t1.Errorf("Add() after expiry = %v, want 1", got)
+	}
+}
+
+func TestAcquire(t1 *testing.T) {
+	useConfig(t1, &Config{Concurrency: 1, ConcurrencyTTL: time.Minute})
+	alice, bob := userCtx("alice"), userCtx("bob")
+
+	release, err := Acquire(alice)
+	if err != nil {
+		t1.Fatal(err)
+	}
+	var limitErr *Error
+	if _, err = Acquire(alice); !errors.As(err, &limitErr) || limitErr.Limit != consts.LimitConcurrency {
+		t1.Fatalf("second generation of the same user: err = %v, want concurrency limit", err)
+	}
+	releaseBob, err := Acquire(bob)
+	if err != nil {
+		t1.Fatalf("users must not share slots: %v", err)
+	}
+	releaseBob()
+	release()
+	if _, err = Acquire(alice); err != nil {
+		t1.Fatalf("generation after release: %v", err)
+	}
+}
+
+func TestCheckQuota(t1 *testing.T) {
+	useConfig(t1, &Config{
+		DailyTokens: 1000,
+		DailyCost:   1,
+		Models:      map[string]int64{"m1": 100},
+	})
+	tests := []struct {
+		name      string
+		user      string
+		records   []*usage.Record
+		model     string
+		wantLimit string
+	}{
+		{name: "unused", user: "u1", model: "m1"},
+		{name: "under quota", user: "u2", records: []*usage.Record{{Model: "m1", TotalTokens: 99}}, model: "m1"},
+		{name: "model quota", user: "u3", records: []*usage.Record{{Model: "m1", TotalTokens: 100}}, model: "m1", wantLimit: consts.LimitModelTokens},
+		{name: "other model", user: "u4", records: []*usage.Record{{Model: "m1", TotalTokens: 100}}, model: "m2"},
+		{
+			name: "daily tokens", user: "u5", model: "m2",
+			records:   []*usage.Record{{Model: "m2", TotalTokens: 600}, {Model: "m2", TotalTokens: 400}},
+			wantLimit: consts.LimitDailyTokens,
+		},
+		{name: "daily cost", user: "u6", records: []*usage.Record{{Model: "m2", TotalTokens: 10, Cost: 1.2}}, model: "m2", wantLimit: consts.LimitDailyCost},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			ctx := userCtx(tt.user)
+			for _, r := range tt.records {
+				recordUsage(ctx, r)
+			}
+			err := CheckQuota(ctx, tt.model)
+			if tt.wantLimit == "" {
+				if err != nil {
+					t1.Fatalf("CheckQuota() = %v, want nil", err)
+				}
+				return
+			}
+			var limitErr *Error
+			if !errors.As(err, &limitErr) || limitErr.Limit != tt.wantLimit {
+				t1.Fatalf("CheckQuota() = %v, want %s", err, tt.wantLimit)
+			}
+			if limitErr.RetryAfter <= 0 || limitErr.RetryAfter > 24*time.Hour {
+				t1.Errorf("RetryAfter = %v, want until tomorrow", limitErr.RetryAfter)
+			}
+		})
+	}
+}
+
+func TestTakeTool(t1 *testing.T) {
+	useConfig(t1, &Config{Tools: map[string]int64{"web_search_tool": 2}})
+	ctx := userCtx("alice")
+	for i := 0; i < 2; i++ {
+		if err := TakeTool(ctx, "web_search_tool"); err != nil {
+			t1.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	var limitErr *Error
+	if err := TakeTool(ctx, "web_search_tool"); !errors.As(err, &limitErr) || limitErr.Tool != "web_search_tool" {
+		t1.Fatalf("third call: err = %v, want tool quota", err)
+	}
+	if err := TakeTool(ctx, "web_fetch_tool"); err != nil {
+		t1.Errorf("tools without quota must not be limited: %v", err)
+	}
+	if err := TakeTool(userCtx("bob"), "web_search_tool"); err != nil {
+		t1.Errorf("users must not share quotas: %v", err)
+	}
+}
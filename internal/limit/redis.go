@@ -0,0 +1,105 @@
+package limit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gogf/gf/v2/database/gredis"
+)
+
+// 脚本中使用 Redis 的时间，避免各副本时钟不一致
+const (
+	// allowScript 令牌桶，返回需要等待的毫秒数，0 表示允许
+	allowScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local data = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+tokens = math.min(burst, tokens + (now - ts) / 1000 * rate)
+local wait = 0
+if tokens >= 1 then
+  tokens = tokens - 1
+else
+  wait = math.ceil((1 - tokens) / rate * 1000)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate * 1000) + 1000)
+return wait`
+
+	// acquireScript 并发名额，有序集合中的成员为名额 ID，分数为占用时间
+	acquireScript = `
+local limit = tonumber(ARGV[1])
+local ttl = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - ttl)
+if redis.call('ZCARD', KEYS[1]) >= limit then
+  return 0
+end
+redis.call('ZADD', KEYS[1], now, ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ttl)
+return 1`
+
+	// addScript 累加计数并设置过期时间
+	addScript = `
+local value = redis.call('INCRBYFLOAT', KEYS[1], ARGV[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[2])
+return value`
+)
+
+// redisStore 多副本共享的 Redis 存储
+type redisStore struct {
+	redis  *gredis.Redis
+	prefix string
+}
+
+func newRedisStore(redis *gredis.Redis, prefix string) *redisStore {
+	return &redisStore{redis: redis, prefix: prefix}
+}
+
+func (s *redisStore) Allow(ctx context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	v, err := s.redis.Do(ctx, "EVAL", allowScript, 1, s.prefix+key, rate, burst)
+	if err != nil {
+		return 0, fmt.Errorf("failed to take token: %v", err)
+	}
+	return time.Duration(v.Int64()) * time.Millisecond, nil
+}
+
+func (s *redisStore) Acquire(ctx context.Context, key, id string, limit int, ttl time.Duration) (bool, error) {
+	v, err := s.redis.Do(ctx, "EVAL", acquireScript, 1, s.prefix+key, limit, id, ttl.Milliseconds())
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire slot: %v", err)
+	}
+	return v.Int() == 1, nil
+}
+
+func (s *redisStore) Release(ctx context.Context, key, id string) error {
+	if _, err := s.redis.Do(ctx, "ZREM", s.prefix+key, id); err != nil {
+		return fmt.Errorf("failed to release slot: %v", err)
+	}
+	return nil
+}
+
+func (s *redisStore) Add(ctx context.Context, key string, n float64, ttl time.Duration) (float64, error) {
+	v, err := s.redis.Do(ctx, "EVAL", addScript, 1, s.prefix+key, n, ttl.Milliseconds())
+	if err != nil {
+		return 0, fmt.Errorf("failed to add counter: %v", err)
+	}
+	return v.Float64(), nil
+}
+
+func (s *redisStore) Get(ctx context.Context, key string) (float64, error) {
+	v, err := s.redis.Do(ctx, "GET", s.prefix+key)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get counter: %v", err)
+	}
+	return v.Float64(), nil
+}
@@ -0,0 +1,148 @@
+package limit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Store 限流和配额的计数存储，多副本部署时使用 Redis 共享
+type Store interface {
+	// Allow 令牌桶：每秒补充 rate 个令牌，最多 burst 个，取一个令牌；令牌不足时返回需要等待的时间
+	Allow(ctx context.Context, key string, rate float64, burst int) (retryAfter time.Duration, err error)
+	// Acquire 占用一个并发名额，已占用 limit 个时返回 false；名额在 ttl 后自动释放，避免进程退出后泄漏
+	Acquire(ctx context.Context, key, id string, limit int, ttl time.Duration) (bool, error)
+	// Release 释放并发名额
+	Release(ctx context.Context, key, id string) error
+	// Add 累加计数并返回累加后的值，计数在 ttl 后过期
+	Add(ctx context.Context, key string, n float64, ttl time.Duration) (float64, error)
+	// Get 读取计数，不存在时为 0
+	Get(ctx context.Context, key string) (float64, error)
+}
+
+// memoryPurgeSize 内存存储的键超过该数量时清理过期的键
+const memoryPurgeSize = 10000
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type counter struct {
+	value    float64
+	expireAt time.Time
+}
+
+// memoryStore 单副本使用的内存存储
+type memoryStore struct {
+	mu       sync.Mutex
+	now      func() time.Time
+	buckets  map[string]*bucket
+	slots    map[string]map[string]time.Time // key -> 名额 ID -> 过期时间
+	counters map[string]*counter
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		now:      time.Now,
+		buckets:  make(map[string]*bucket),
+		slots:    make(map[string]map[string]time.Time),
+		counters: make(map[string]*counter),
+	}
+}
+
+func (s *memoryStore) Allow(_ context.Context, key string, rate float64, burst int) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	b, ok := s.buckets[key]
+	if !ok {
+		if len(s.buckets) > memoryPurgeSize {
+			s.purgeBuckets(now, rate, burst)
+		}
+		b = &bucket{tokens: float64(burst), last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, nil
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second)), nil
+}
+
+// purgeBuckets 删除已补满的令牌桶，与新建的桶等价
+func (s *memoryStore) purgeBuckets(now time.Time, rate float64, burst int) {
+	for key, b := range s.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= float64(burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func (s *memoryStore) Acquire(_ context.Context, key, id string, limit int, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	slots := s.slots[key]
+	for slotID, expireAt := range slots {
+		if !now.Before(expireAt) {
+			delete(slots, slotID)
+		}
+	}
+	if len(slots) >= limit {
+		return false, nil
+	}
+	if slots == nil {
+		slots = make(map[string]time.Time)
+		s.slots[key] = slots
+	}
+	slots[id] = now.Add(ttl)
+	return true, nil
+}
+
+func (s *memoryStore) Release(_ context.Context, key, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.slots[key], id)
+	if len(s.slots[key]) == 0 {
+		delete(s.slots, key)
+	}
+	return nil
+}
+
+func (s *memoryStore) Add(_ context.Context, key string, n float64, ttl time.Duration) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	c, ok := s.counters[key]
+	if !ok || !now.Before(c.expireAt) {
+		if len(s.counters) > memoryPurgeSize {
+			s.purgeCounters(now)
+		}
+		c = &counter{}
+		s.counters[key] = c
+	}
+	c.value += n
+	c.expireAt = now.Add(ttl)
+	return c.value, nil
+}
+
+func (s *memoryStore) Get(_ context.Context, key string) (float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c, ok := s.counters[key]; ok && s.now().Before(c.expireAt) {
+		return c.value, nil
+	}
+	return 0, nil
+}
+
+func (s *memoryStore) purgeCounters(now time.Time) {
+	for key, c := range s.counters {
+		if !now.Before(c.expireAt) {
+			delete(s.counters, key)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/encoding/gjson"
+)
+
+// debugHandler 将每次模型调用的原始提示词和输出写入 debug 日志
+type debugHandler struct{}
+
+// NewDebugHandler 创建写入 debug 日志的 Eino 回调，只处理模型调用
+func NewDebugHandler() callbacks.Handler {
+	return &debugHandler{}
+}
+
+func (h *debugHandler) Needed(_ context.Context, info *callbacks.RunInfo, _ callbacks.CallbackTiming) bool {
+	return info != nil && info.Component == components.ComponentOfChatModel
+}
+
+func (h *debugHandler) OnStart(ctx context.Context, info *callbacks.RunInfo, input callbacks.CallbackInput) context.Context {
+	in := model.ConvCallbackInput(input)
+	if in == nil {
+		return ctx
+	}
+	Debug(ctx, "[prompt] %s: %s", info.Name, formatMessages(in.Messages))
+	return ctx
+}
+
+func (h *debugHandler) OnEnd(ctx context.Context, info *callbacks.RunInfo, output callbacks.CallbackOutput) context.Context {
+	if out := model.ConvCallbackOutput(output); out != nil && out.Message != nil {
+		Debug(ctx, "[completion] %s: %s", info.Name, formatMessages([]*schema.Message{out.Message}))
+	}
+	return ctx
+}
+
+func (h *debugHandler) OnError(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
+	Debug(ctx, "[error] %s: %v", info.Name, err)
+	return ctx
+}
+
+func (h *debugHandler) OnStartWithStreamInput(ctx context.Context, _ *callbacks.RunInfo,
+	input *schema.StreamReader[callbacks.CallbackInput]) context.Context {
+	input.Close()
+	return ctx
+}
+
+// OnEndWithStreamOutput 在后台拼接流式输出，读完后写入完整的回答
+func (h *debugHandler) OnEndWithStreamOutput(ctx context.Context, info *callbacks.RunInfo,
+	output *schema.StreamReader[callbacks.CallbackOutput]) context.Context {
+	go func() {
+		defer output.Close()
+		var chunks []*schema.Message
+		for {
+			frame, err := output.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				Debug(ctx, "[error] %s: %v", info.Name, err)
+				return
+			}
+			if out := model.ConvCallbackOutput(frame); out != nil && out.Message != nil {
+				chunks = append(chunks, out.Message)
+			}
+		}
+		if len(chunks) == 0 {
+			return
+		}
+		message, err := schema.ConcatMessages(chunks)
+		if err != nil {
+			Debug(ctx, "[error] %s: failed to concat stream: %v", info.Name, err)
+			return
+		}
+		Debug(ctx, "[completion] %s: %s", info.Name, formatMessages([]*schema.Message{message}))
+	}()
+	return ctx
+}
+
+// formatMessages 每条消息一行：角色、内容和工具调用
+func formatMessages(messages []*schema.Message) string {
+	var b strings.Builder
+	for _, m := range messages {
+		b.WriteString("\n  ")
+		b.WriteString(string(m.Role))
+		b.WriteString(": ")
+		b.WriteString(m.Content)
+		if len(m.ToolCalls) > 0 {
+			calls, _ := gjson.EncodeString(m.ToolCalls)
+			b.WriteString(" tool_calls=")
+			b.WriteString(calls)
+		}
+		if m.ToolName != "" {
+			b.WriteString(" tool=")
+			b.WriteString(m.ToolName)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,185 @@
+package logging
+
+import (
+	"agent/internal/auth"
+	"agent/internal/consts"
+	"agent/internal/model"
+	"agent/internal/pii"
+	"agent/internal/secret"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/glog"
+	"github.com/gogf/gf/v2/util/gconv"
+)
+
+const defaultDebugPath = "resource/log/debug"
+
+// Components 按组件区分的日志对象，可在 logger.<组件名> 下分别配置日志级别
+var Components = []string{consts.LoggerAgent, consts.LoggerTools}
+
+// Config 日志配置
+type Config struct {
+	RedactContent bool     // 日志中只保留用户输入和模型输出的长度
+	RedactKeys    []string // 额外需要隐藏值的配置项，如 database.default.link
+	Debug         bool     // 将原始提示词和模型输出写入 debug 日志
+	DebugPath     string
+}
+
+// LoadConfig 读取日志配置
+func LoadConfig(ctx context.Context) *Config {
+	cfg := &Config{
+		RedactContent: g.Cfg().MustGet(ctx, consts.LoggingRedactContent, false).Bool(),
+		RedactKeys:    g.Cfg().MustGet(ctx, consts.LoggingRedactKeys).Strings(),
+		Debug:         g.Cfg().MustGet(ctx, consts.LoggingDebugEnabled, false).Bool(),
+		DebugPath:     g.Cfg().MustGet(ctx, consts.LoggingDebugPath, defaultDebugPath).String(),
+	}
+	if cfg.DebugPath == "" {
+		cfg.DebugPath = defaultDebugPath
+	}
+	return cfg
+}
+
+var (
+	// current 当前生效的配置和脱敏规则，Init 之前只按通用规则脱敏
+	current  = &Config{}
+	redactor = newRedactor(nil)
+	debugLog *glog.Logger
+)
+
+// Init 按配置初始化日志：为所有日志加上会话、Agent 和工具名并脱敏，开启时注册写入 debug 日志的 Eino 全局回调。
+// 只应在启动时、输出其他日志之前调用一次
+func Init(ctx context.Context) error {
+	cfg := LoadConfig(ctx)
+	data, err := g.Cfg().Data(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %v", err)
+	}
+	secrets := configSecrets(data, cfg.RedactKeys)
+	current, redactor = cfg, newRedactor(secrets)
+	// 配置中直接写明的密钥同样在 SSE 错误、工具结果等非日志输出中替换
+	secret.Register(secrets...)
+	glog.SetDefaultHandler(Handler)
+
+	if cfg.Debug {
+		debugLog = glog.New()
+		if err = debugLog.SetConfigWithMap(g.Map{
+			"path":   cfg.DebugPath,
+			"file":   "debug-{Y-m-d}.log",
+			"level":  "all",
+			"stdout": false,
+		}); err != nil {
+			return fmt.Errorf("failed to configure debug log: %v", err)
+		}
+		callbacks.AppendGlobalHandlers(NewDebugHandler())
+		g.Log().Infof(ctx, "Debug log of prompts and completions enabled in %s", cfg.DebugPath)
+	}
+	return nil
+}
+
+// SetWriter 将默认和各组件的日志改为输出到 w，用于 stdout 被占用的场景
+func SetWriter(w io.Writer) {
+	for _, name := range append([]string{""}, Components...) {
+		g.Log(name).SetStdoutPrint(false)
+		g.Log(name).SetWriter(w)
+	}
+}
+
+// Handler glog 的默认处理器：在上下文字段中加入会话、用户、Agent 和工具名，并隐藏密钥；租户开启脱敏时同时隐藏手机号等敏感信息
+func Handler(ctx context.Context, in *glog.HandlerInput) {
+	if tags := contextTags(ctx); tags != "" {
+		if in.CtxStr != "" {
+			in.CtxStr += ", "
+		}
+		in.CtxStr += tags
+	}
+	in.Content = Redact(ctx, in.Content)
+	for i, v := range in.Values {
+		if v != nil {
+			in.Values[i] = Redact(ctx, gconv.String(v))
+		}
+	}
+	in.Next(ctx)
+}
+
+// Redact 按日志的规则隐藏文本中的密钥，租户开启脱敏时同时隐藏手机号等敏感信息，用于审计日志等需要落盘的内容
+func Redact(ctx context.Context, s string) string {
+	s = secret.Mask(redactor.Redact(s))
+	if pii.EnabledFor(tenantOf(ctx)) {
+		s = pii.Mask(s)
+	}
+	return s
+}
+
+// toolKey 上下文中当前工具名的键
+type toolKey struct{}
+
+// WithTool 在上下文中记录当前执行的工具，工具内的日志都带上工具名
+func WithTool(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, toolKey{}, name)
+}
+
+// contextTags 上下文中的会话 ID、租户、用户 ID、Agent 和工具名，如 session=s1 agent=react tool=web_search_tool
+func contextTags(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	var tags []string
+	if c, ok := contextValue(ctx); ok {
+		if c.SessionID != "" {
+			tags = append(tags, "session="+c.SessionID)
+		}
+		if c.TenantID != "" {
+			tags = append(tags, "tenant="+c.TenantID)
+		}
+		if c.UserID != "" {
+			tags = append(tags, "user="+c.UserID)
+		}
+		if c.Agent != "" {
+			tags = append(tags, "agent="+c.Agent)
+		}
+	}
+	if name, ok := ctx.Value(toolKey{}).(string); ok && name != "" {
+		tags = append(tags, "tool="+name)
+	}
+	return strings.Join(tags, " ")
+}
+
+// tenantOf 日志所属的租户，Agent 上下文之外（如中间件和访问日志）使用认证身份中的租户
+func tenantOf(ctx context.Context) string {
+	if c, ok := contextValue(ctx); ok && c.TenantID != "" {
+		return c.TenantID
+	}
+	if ctx == nil {
+		return ""
+	}
+	return auth.FromCtx(ctx).TenantID
+}
+
+func contextValue(ctx context.Context) (*model.Context, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	c, ok := ctx.Value(consts.ContextKey).(*model.Context)
+	return c, ok
+}
+
+// Content 记录用户输入或模型输出时使用，开启 redactContent 后只保留长度
+func Content(s string) string {
+	if current.RedactContent {
+		return fmt.Sprintf("[redacted %d chars]", len([]rune(s)))
+	}
+	return s
+}
+
+// Debug 写入 debug 日志，未开启时忽略；debug 日志只在本地文件中，不受 redactContent 影响
+func Debug(ctx context.Context, format string, v ...any) {
+	if debugLog == nil {
+		return
+	}
+	debugLog.Debugf(ctx, format, v...)
+}
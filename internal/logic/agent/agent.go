@@ -2,87 +2,139 @@ package agent
 
 import (
 	v1 "agent/api/agent/v1"
+	"agent/internal/consts"
+	"agent/internal/guardrail"
+	"agent/internal/limit"
+	"agent/internal/logging"
+	"agent/internal/metrics"
+	"agent/internal/pii"
 	"agent/internal/tools"
+	"agent/internal/usage"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/cloudwego/eino/callbacks"
+	einomodel "github.com/cloudwego/eino/components/model"
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/compose"
-	"github.com/cloudwego/eino/flow/agent"
 	"github.com/cloudwego/eino/flow/agent/react"
 	"github.com/cloudwego/eino/schema"
 	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/util/guid"
+)
+
+const (
+	agentNodeKey    = "react_agent"
+	agentRunnerName = "AgentRunner"
 )
 
 func (s *sAgent) ReactAgentStream(ctx context.Context, in *v1.AgentReq) (out *v1.AgentRes, err error) {
+	r := ghttp.RequestFromCtx(ctx)
+	// 工具通过会话 ID 定位各自的工作目录，会话 ID 已按租户和用户隔离
+	requestCtx, err := requestContext(ctx, in.SessionID, in.Model, consts.AgentReact)
+	if err != nil {
+		writeForbidden(r, err)
+		return nil, nil
+	}
+	ctx = context.WithValue(ctx, consts.ContextKey, requestCtx)
+	sessionID := requestCtx.SessionID
+	// 每个用户同时进行的生成数和每日配额
+	release, err := acquireGeneration(ctx, requestCtx.Model)
+	if err != nil {
+		limit.WriteError(r, err)
+		return nil, nil
+	}
+	defer release()
+	ctx, turn := usage.StartTurn(ctx)
+	// 发给模型的内容中的敏感信息替换为占位符，工具执行和输出时还原
+	vault := s.vaults.Get(requestCtx.TenantID, sessionID)
+	query := vault.Redact(in.Query)
+	if !guardInput(ctx, r, query) {
+		return nil, nil
+	}
+	// 首个 token 由 LoggerCallback 发送时记录
+	ctx, stream := metrics.StartStream(ctx, consts.AgentReact)
+	defer stream.End()
 	chatModel := NewChatModel(ctx)
 
-	toolCallChecker := func(ctx context.Context, sr *schema.StreamReader[*schema.Message]) (bool, error) {
-		defer sr.Close()
-		for {
-			msg, err := sr.Recv()
-			if err != nil {
-				if errors.Is(err, io.EOF) {
-					// finish
-					break
-				}
-
-				return false, err
-			}
-
-			if len(msg.ToolCalls) > 0 {
-				return true, nil
-			}
-		}
-		return false, nil
+	budget := newTurnBudget(ctx)
+	budget.onExhausted = func(event *v1.BudgetEvent) {
+		SndEvent(r, consts.EventBudgetExhausted, event)
 	}
+	runID := sessionID + ":" + guid.S()
+	defer s.approvals.remove(runID)
+	defer s.checkPoints.Delete(runID)
 
-	raAgent, err := react.NewAgent(ctx, &react.AgentConfig{
-		ToolCallingModel: chatModel,
-		ToolsConfig: compose.ToolsNodeConfig{
-			Tools: []tool.BaseTool{
-				tools.NewPDFGenerationTool(),
-				tools.NewWebSearchTool(),
-				tools.NewResourceDownloadTool(),
-				tools.NewPhotoSearchTool(),
-				//tools.NewFileOperationTool(),
-				//tools.NewTerminalOperationTool(),
-				//GetGdMapMCPTool(ctx),
-			},
-			ExecuteSequentially: false,
-		},
-		StreamToolCallChecker: toolCallChecker,
-	})
+	baseTools := defaultTools(ctx)
+	if approvalEnabled(ctx) {
+		// 高风险工具仅在审批模式下启用
+		baseTools = append(baseTools,
+			tools.NewFileOperationTool(),
+			tools.NewTerminalOperationTool(),
+		)
+	}
+	baseTools = allowedTools(ctx, baseTools)
+	guard := newTurnGuard(ctx, baseTools, sendGuardrailEvent(r))
+	middlewares := []toolMiddleware{budget.Middleware, quotaMiddleware(r), guard.Middleware, piiMiddleware(vault), artifactMiddleware(r), maskMiddleware}
+	if approvalEnabled(ctx) {
+		// 审批中间件位于最外层，审批通过后才计入预算
+		middlewares = append([]toolMiddleware{s.approvals.Middleware(ctx, runID, sessionID, vault)}, middlewares...)
+	}
+	// 审计中间件位于最外层，被审批拒绝、护栏或预算拦截的调用同样记录
+	middlewares = append([]toolMiddleware{auditMiddleware}, middlewares...)
+	runnable, err := s.buildAgent(ctx, chatModel, budget, baseTools, middlewares...)
 	if err != nil {
 		return
 	}
 
 	template := AgentTemplate(ctx, &v1.ChatStreamReq{
-		Query:     in.Query,
-		SessionID: in.SessionID,
+		Query:     query,
+		SessionID: sessionID,
 	})
+	if err = attachImages(ctx, template[len(template)-1], in.Images, true); err != nil {
+		return nil, err
+	}
 
-	r := ghttp.RequestFromCtx(ctx)
-	// 设置 SSE 响应头
+	// 设置 SSE 响应头，跨域响应头由 CORS 中间件按配置设置
 	r.Response.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
 	r.Response.Header().Set("Cache-Control", "no-cache")
 	r.Response.Header().Set("Connection", "keep-alive")
-	r.Response.Header().Set("Access-Control-Allow-Origin", "*")
-	r.Response.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
+	defer endStream(r)
 
 	r.Response.WriteHeader(200)
 	r.Response.Flush()
 
-	resp, err := raAgent.Stream(ctx, template, agent.WithComposeOptions(compose.WithCallbacks(&LoggerCallback{})))
-	if err != nil {
-		return nil, err
+	var resp *schema.StreamReader[*schema.Message]
+	for {
+		runCtx, cancel := context.WithTimeout(ctx, budget.hardRemaining())
+		resp, err = runnable.Stream(runCtx, template,
+			compose.WithCallbacks(&LoggerCallback{vault: vault}),
+			compose.WithCheckPointID(runID),
+		)
+		if err == nil {
+			defer cancel()
+			break
+		}
+		cancel()
+		if _, ok := compose.ExtractInterruptInfo(err); !ok {
+			return nil, err
+		}
+
+		// 工具调用等待审批，审批完成后从检查点恢复运行
+		waitStart := time.Now()
+		err = s.approvals.Await(ctx, r, runID)
+		budget.pause(time.Since(waitStart))
+		if err != nil {
+			return nil, err
+		}
 	}
 	defer resp.Close()
 	data := ctx.Value("data")
@@ -94,45 +146,127 @@ func (s *sAgent) ReactAgentStream(ctx context.Context, in *v1.AgentReq) (out *v1
 				Done:     true,
 			}
 			d, _ := gjson.Marshal(resps)
-			r.Response.Write([]byte(fmt.Sprintf("data: %s\n\n", d)))
-			r.Response.Flush()
+			sndFrame(r, d)
 			time.Sleep(100 * time.Microsecond)
 		}
 	}
+	// 预算耗尽后模型仍只调用工具，本轮没有回答时补充说明，预算事件已在耗尽时发送
+	if answer := budget.FallbackAnswer(); answer != "" {
+		d, _ := gjson.Marshal(v1.ChatStreamRes{Content: answer})
+		sndFrame(r, d)
+	}
+	// 本轮所有模型和向量化调用的用量，在结束帧之前发送
+	summary := turn.Summary()
+	SndEvent(r, consts.EventUsage, usageEvent(ctx, summary))
 	respss := v1.ChatStreamRes{
 		Content:  "",
 		Thinking: false,
 		Done:     true,
 	}
 	d, _ := gjson.Marshal(respss)
-	r.Response.Write([]byte(fmt.Sprintf("data: %s\n\n", d)))
-	r.Response.Flush()
-	out = &v1.AgentRes{}
+	sndFrame(r, d)
+	out = &v1.AgentRes{
+		TotalTokens:  summary.TotalTokens,
+		MessageCount: summary.Calls,
+	}
 	return
 }
 
+// defaultTools 无需审批即可使用的工具
+func defaultTools(ctx context.Context) []tool.BaseTool {
+	baseTools := []tool.BaseTool{
+		tools.NewPDFGenerationTool(),
+		tools.NewWebSearchTool(),
+		tools.NewWebFetchTool(),
+		tools.NewResourceDownloadTool(),
+		tools.NewPhotoSearchTool(),
+		tools.NewImageDescribeTool(),
+	}
+	// 已连接的 MCP 服务提供的工具
+	return append(baseTools, tools.MCPTools(ctx)...)
+}
+
+// buildAgent 创建受预算约束的 ReAct Agent，工具按顺序套用中间件
+func (s *sAgent) buildAgent(ctx context.Context, chatModel einomodel.ToolCallingChatModel, budget *turnBudget,
+	baseTools []tool.BaseTool, middlewares ...toolMiddleware) (compose.Runnable[[]*schema.Message, *schema.Message], error) {
+	toolCallChecker := func(ctx context.Context, sr *schema.StreamReader[*schema.Message]) (bool, error) {
+		defer sr.Close()
+		hasToolCalls, hasContent := false, false
+		for {
+			msg, err := sr.Recv()
+			if err != nil {
+				if errors.Is(err, io.EOF) {
+					// finish
+					break
+				}
+
+				return false, err
+			}
+
+			hasToolCalls = hasToolCalls || len(msg.ToolCalls) > 0
+			hasContent = hasContent || strings.TrimSpace(msg.Content) != ""
+		}
+		// 预算耗尽后模型仍调用工具时结束本轮，本次模型输出即为最终答案
+		return budget.ContinueWithTools(hasToolCalls, hasContent), nil
+	}
+
+	agentTools, err := wrapTools(ctx, baseTools, middlewares...)
+	if err != nil {
+		return nil, err
+	}
+	raAgent, err := react.NewAgent(ctx, &react.AgentConfig{
+		ToolCallingModel: chatModel,
+		ToolsConfig: compose.ToolsNodeConfig{
+			Tools:               agentTools,
+			ExecuteSequentially: false,
+		},
+		MessageModifier:       budget.MessageModifier,
+		MaxStep:               budget.graphMaxSteps(),
+		StreamToolCallChecker: toolCallChecker,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.compileAgent(ctx, raAgent)
+}
+
+// compileAgent 将 ReAct 图作为子图编译，挂载检查点存储以支持中断后恢复
+func (s *sAgent) compileAgent(ctx context.Context, raAgent *react.Agent) (compose.Runnable[[]*schema.Message, *schema.Message], error) {
+	sub, opts := raAgent.ExportGraph()
+
+	graph := compose.NewGraph[[]*schema.Message, *schema.Message]()
+	if err := graph.AddGraphNode(agentNodeKey, sub, opts...); err != nil {
+		return nil, err
+	}
+	if err := graph.AddEdge(compose.START, agentNodeKey); err != nil {
+		return nil, err
+	}
+	if err := graph.AddEdge(agentNodeKey, compose.END); err != nil {
+		return nil, err
+	}
+	return graph.Compile(ctx,
+		compose.WithGraphName(agentRunnerName),
+		compose.WithCheckPointStore(s.checkPoints),
+	)
+}
+
 type LoggerCallback struct {
-	callbacks.HandlerBuilder // 可以用 callbacks.HandlerBuilder 来辅助实现 callback
+	callbacks.HandlerBuilder            // 可以用 callbacks.HandlerBuilder 来辅助实现 callback
+	vault                    *pii.Vault // 租户开启脱敏时，输出前还原模型回答和工具结果中的占位符
 }
 
+// OnStart 原始输入只写入 logging.debug 开启的 debug 日志，这里不输出
 func (cb *LoggerCallback) OnStart(ctx context.Context, info *callbacks.RunInfo, input callbacks.CallbackInput) context.Context {
-	//fmt.Println("==================")
-	//inputStr, _ := gjson.MarshalIndent(input, "", "  ")
-	//fmt.Printf("[OnStart] %s\n", string(inputStr))
 	return ctx
 }
 
+// OnEnd 原始输出只写入 logging.debug 开启的 debug 日志，这里不输出
 func (cb *LoggerCallback) OnEnd(ctx context.Context, info *callbacks.RunInfo, output callbacks.CallbackOutput) context.Context {
-	//fmt.Println("=========[OnEnd]=========")
-	//outputStr, _ := gjson.MarshalIndent(output, "", "  ")
-
-	//fmt.Println(string(outputStr))
 	return ctx
 }
 
 func (cb *LoggerCallback) OnError(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
-	fmt.Println("=========[OnError]=========")
-	fmt.Println(err, "\ninfo.Name:", info.Name)
+	g.Log(consts.LoggerAgent).Errorf(ctx, "%s failed: %v", info.Name, err)
 	return ctx
 }
 
@@ -147,13 +281,13 @@ func (cb *LoggerCallback) OnEndWithStreamOutput(ctx context.Context, info *callb
 
 		defer func() {
 			if err := recover(); err != nil {
-				fmt.Println("[OnEndStream] panic err:", err)
+				g.Log(consts.LoggerAgent).Errorf(ctx, "[OnEndStream] panic: %v", err)
 			}
 		}()
 
 		defer output.Close() // remember to close the stream in defer
-		fmt.Println("=========[OnEndStream]=========")
 		tol2 := ""
+		restorer := cb.vault.NewRestorer()
 		for {
 			if output == nil {
 				return
@@ -163,40 +297,38 @@ func (cb *LoggerCallback) OnEndWithStreamOutput(ctx context.Context, info *callb
 				break
 			}
 			if err != nil {
-				fmt.Printf("internal error: %s\n", err)
+				g.Log(consts.LoggerAgent).Warningf(ctx, "%s stream error: %v", info.Name, err)
 				return
 			}
 			s, _ := json.Marshal(frame)
 			if info.Name == react.ToolsNodeName {
-				str := fmt.Sprintf(string(s))
 				var toolResp []ToolResponse
-				err = gjson.DecodeTo(str, &toolResp)
+				err = gjson.DecodeTo(s, &toolResp)
 				if err != nil {
-					fmt.Printf("internal error: %s\n", err)
+					g.Log(consts.LoggerAgent).Warningf(ctx, "failed to decode tool response: %v", err)
 					return
 				}
-				var data2 []SearchResult
+				var data2 tools.SearchResponse
 				if len(toolResp) == 0 {
-					fmt.Println("warning: toolResp is empty")
+					g.Log(consts.LoggerAgent).Warning(ctx, "tool response is empty")
 					return
 				}
-				err = gjson.DecodeTo(toolResp[0].Content, &data2)
+				// 不可信的工具结果带有护栏标签，去掉后再解析
+				err = gjson.DecodeTo(guardrail.Unwrap(toolResp[0].Content), &data2)
 				if err != nil {
 					return
 				}
-				for _, data3 := range data2 {
+				for _, data3 := range data2.Results {
 					resp := v1.ChatStreamRes{
-						Content: fmt.Sprintf("Date: %s\nDisplayedLink: %s\nLink: %s\nPosition: %d\nSnippet: %s\nSnippetHighlightedWords: %s\nThumbnail: %s\nTitle: %s\n\n",
-							data3.Date, data3.DisplayedLink, data3.Link, data3.Position, data3.Snippet, data3.SnippetHighlightedWords, data3.Thumbnail, data3.Title),
+						Content: cb.vault.Restore(fmt.Sprintf("Date: %s\nDisplayedLink: %s\nLink: %s\nPosition: %d\nSnippet: %s\nSource: %s\nThumbnail: %s\nTitle: %s\n\n",
+							data3.Date, data3.DisplayedLink, data3.Link, data3.Position, data3.Snippet, data3.Source, data3.Thumbnail, data3.Title)),
 						Thinking: true,
 						Done:     false,
 					}
 					// 序列化响应对象
 					d, _ := gjson.Marshal(resp)
 					// 使用 SSE 格式发送数据
-					r.Response.Write([]byte(fmt.Sprintf("data: %s\n\n", d)))
-					r.Response.Flush() // 立即发送数据
-					fmt.Printf(resp.Content)
+					sndFrame(r, d)
 					time.Sleep(100 * time.Microsecond)
 				}
 				return
@@ -204,7 +336,7 @@ func (cb *LoggerCallback) OnEndWithStreamOutput(ctx context.Context, info *callb
 				var data CallbackOutput
 				err = gjson.DecodeTo(s, &data)
 				if err != nil {
-					fmt.Printf("%s", string(s))
+					g.Log(consts.LoggerAgent).Warningf(ctx, "failed to decode model output: %v", err)
 					str := "\n" + string(s) + "\n\n\n"
 					for _, char := range str {
 						resp := v1.ChatStreamRes{
@@ -213,26 +345,28 @@ func (cb *LoggerCallback) OnEndWithStreamOutput(ctx context.Context, info *callb
 							Done:     false,
 						}
 						d, _ := gjson.Marshal(resp)
-						r.Response.Write([]byte(fmt.Sprintf("data: %s\n\n", d)))
-						r.Response.Flush()
+						sndFrame(r, d)
 					}
 					return
 				}
+				if data.Message.Content != "" {
+					metrics.StreamFromCtx(ctx).FirstToken()
+				}
+				content := restorer.Write(data.Message.Content)
 				resp := v1.ChatStreamRes{
-					Content:  data.Message.Content,
+					Content:  content,
 					Thinking: true,
 					Done:     false,
 				}
 				d, _ := gjson.Marshal(resp)
-				r.Response.Write([]byte(fmt.Sprintf("data: %s\n\n", d)))
-				r.Response.Flush()
-				*tol += data.Message.Content
+				sndFrame(r, d)
+				*tol += content
 				tol2 += data.Message.Content
 			} else if info.Name == react.GraphName {
 				var message schema.Message
 				err = gjson.DecodeTo(s, &message)
 				if err != nil {
-					fmt.Printf("%s", string(s))
+					g.Log(consts.LoggerAgent).Warningf(ctx, "failed to decode agent output: %v", err)
 					str := "\n" + string(s) + "\n"
 					for _, char := range str {
 						resp := v1.ChatStreamRes{
@@ -241,24 +375,39 @@ func (cb *LoggerCallback) OnEndWithStreamOutput(ctx context.Context, info *callb
 							Done:     false,
 						}
 						d, _ := gjson.Marshal(resp)
-						r.Response.Write([]byte(fmt.Sprintf("data: %s\n\n", d)))
-						r.Response.Flush()
+						sndFrame(r, d)
 					}
 					return
 				}
+				if message.Content != "" {
+					metrics.StreamFromCtx(ctx).FirstToken()
+				}
+				content := restorer.Write(message.Content)
 				resp := v1.ChatStreamRes{
-					Content:  message.Content,
+					Content:  content,
 					Thinking: false,
 					Done:     false,
 				}
 				d, _ := gjson.Marshal(resp)
-				r.Response.Write([]byte(fmt.Sprintf("data: %s\n\n", d)))
-				r.Response.Flush()
-				*tol += message.Content
+				sndFrame(r, d)
+				*tol += content
 				tol2 += message.Content
 			}
 		}
-		fmt.Printf("%s:%s\n", info.Name, tol2)
+		// 流末尾等待补全的占位符
+		if rest := restorer.Flush(); rest != "" {
+			d, _ := gjson.Marshal(v1.ChatStreamRes{
+				Content:  rest,
+				Thinking: info.Name == react.ModelNodeName,
+				Done:     false,
+			})
+			sndFrame(r, d)
+			*tol += rest
+		}
+		logging.Debug(ctx, "[stream] %s: %s", info.Name, tol2)
+		if info.Name == react.GraphName {
+			guardOutput(ctx, r, tol2)
+		}
 	}(&tol)
 	wg.Wait()
 	ctx = context.WithValue(ctx, "data", tol)
@@ -278,17 +427,6 @@ type ToolResponse struct {
 	ToolName   string `json:"tool_name"`
 }
 
-type SearchResult struct {
-	Date                    string   `json:"date"`
-	DisplayedLink           string   `json:"displayed_link"`
-	Link                    string   `json:"link"`
-	Position                int      `json:"position"`
-	Snippet                 string   `json:"snippet"`
-	SnippetHighlightedWords []string `json:"snippet_highlighted_words"`
-	Thumbnail               string   `json:"thumbnail,omitempty"` // omitempty 表示字段为空时省略
-	Title                   string   `json:"title"`
-}
-
 type CallbackOutput struct {
 	// Message is the message generated by the model.
 	Message *schema.Message
@@ -0,0 +1,262 @@
+package agent
+
+import (
+	v1 "agent/api/agent/v1"
+	"agent/internal/audit"
+	"agent/internal/auth"
+	"agent/internal/consts"
+	"agent/internal/pii"
+	"agent/internal/secret"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/gogf/gf/v2/errors/gerror"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+const defaultApprovalTimeout = 300 * time.Second
+
+// pendingApproval 一次等待审批的工具调用
+type pendingApproval struct {
+	runID     string
+	sessionID string
+	deadline  time.Time // 超时自动拒绝的时间，事件中只精确到秒
+	event     *v1.ApprovalEvent
+	decided   chan *approvalDecision
+}
+
+// approvalDecision 审批结果和审批人
+type approvalDecision struct {
+	*v1.ApprovalReq
+	approver string // 审批人，超时自动拒绝时为空
+	runID    string
+}
+
+// approvalManager 管理等待审批的工具调用以及审批结果
+type approvalManager struct {
+	mu        sync.Mutex
+	pending   map[string]*pendingApproval  // call id -> 等待审批的调用
+	decisions map[string]*approvalDecision // call id -> 审批结果，工具重新执行时取走
+}
+
+func newApprovalManager() *approvalManager {
+	return &approvalManager{
+		pending:   make(map[string]*pendingApproval),
+		decisions: make(map[string]*approvalDecision),
+	}
+}
+
+// approvalEnabled 是否开启审批模式
+func approvalEnabled(ctx context.Context) bool {
+	return g.Cfg().MustGet(ctx, consts.ApprovalEnabled).Bool()
+}
+
+// approvalTimeout 审批超时时间
+func approvalTimeout(ctx context.Context) time.Duration {
+	timeout := g.Cfg().MustGet(ctx, consts.ApprovalTimeout, defaultApprovalTimeout).Duration()
+	if timeout <= 0 {
+		timeout = defaultApprovalTimeout
+	}
+	return timeout
+}
+
+// Middleware 审批中间件，需要审批的工具在没有审批结果时中断运行，等待审批后由 Eino 重新执行；
+// 推送给前端的参数与其他输出一样隐藏密钥，敏感信息替换为会话的占位符
+func (m *approvalManager) Middleware(ctx context.Context, runID, sessionID string, vault *pii.Vault) toolMiddleware {
+	required := make(map[string]bool)
+	for _, name := range g.Cfg().MustGet(ctx, consts.ApprovalTools).Strings() {
+		required[name] = true
+	}
+	timeout := approvalTimeout(ctx)
+
+	return func(next toolEndpoint) toolEndpoint {
+		return func(ctx context.Context, name, argumentsInJSON string, opts ...tool.Option) (string, error) {
+			if !required[name] {
+				return next(ctx, name, argumentsInJSON, opts...)
+			}
+
+			callID := compose.GetToolCallID(ctx)
+			if decision := m.takeDecision(callID); decision != nil {
+				approval := audit.Approval{Decision: decision.Decision, Reason: decision.Reason, Approver: decision.approver}
+				if decision.Decision == consts.ApprovalEdit {
+					approval.Arguments = decision.Arguments
+				}
+				audit.SetApproval(ctx, approval)
+				switch decision.Decision {
+				case consts.ApprovalApprove:
+					return next(ctx, name, argumentsInJSON, opts...)
+				case consts.ApprovalEdit:
+					return next(ctx, name, decision.Arguments, opts...)
+				default:
+					return fmt.Sprintf("Tool call was denied by the user: %s. Do not retry this call.", decision.Reason), nil
+				}
+			}
+
+			deadline := time.Now().Add(timeout)
+			m.mu.Lock()
+			m.pending[callID] = &pendingApproval{
+				runID:     runID,
+				sessionID: sessionID,
+				deadline:  deadline,
+				event: &v1.ApprovalEvent{
+					CallID:    callID,
+					Tool:      name,
+					Arguments: secret.Mask(vault.Redact(argumentsInJSON)),
+					ExpiresAt: deadline.Unix(),
+				},
+				decided: make(chan *approvalDecision, 1),
+			}
+			m.mu.Unlock()
+			return "", compose.NewInterruptAndRerunErr(callID)
+		}
+	}
+}
+
+// takeDecision 取走某次调用的审批结果
+func (m *approvalManager) takeDecision(callID string) *approvalDecision {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	decision, ok := m.decisions[callID]
+	if ok {
+		delete(m.decisions, callID)
+	}
+	return decision
+}
+
+// runPending 获取某次运行中等待审批的调用
+func (m *approvalManager) runPending(runID string) []*pendingApproval {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var pending []*pendingApproval
+	for _, p := range m.pending {
+		if p.runID == runID {
+			pending = append(pending, p)
+		}
+	}
+	return pending
+}
+
+// Await 推送审批事件并等待审批结果，超时自动拒绝
+func (m *approvalManager) Await(ctx context.Context, r *ghttp.Request, runID string) error {
+	pending := m.runPending(runID)
+	if len(pending) == 0 {
+		return gerror.New("run was interrupted without pending approvals")
+	}
+	for _, p := range pending {
+		SndEvent(r, consts.EventApprovalRequired, p.event)
+	}
+
+	for _, p := range pending {
+		var decision *approvalDecision
+		timer := time.NewTimer(time.Until(p.deadline))
+		select {
+		case decision = <-p.decided:
+		case <-timer.C:
+			decision = &approvalDecision{ApprovalReq: &v1.ApprovalReq{
+				CallID:   p.event.CallID,
+				Decision: consts.ApprovalDeny,
+				Reason:   "approval timed out",
+			}}
+		case <-ctx.Done():
+			timer.Stop()
+			m.remove(runID)
+			return ctx.Err()
+		}
+		timer.Stop()
+
+		decision.runID = runID
+		m.mu.Lock()
+		delete(m.pending, p.event.CallID)
+		m.decisions[p.event.CallID] = decision
+		m.mu.Unlock()
+
+		SndEvent(r, consts.EventApprovalResolved, &v1.ApprovalResolvedEvent{
+			CallID:   p.event.CallID,
+			Tool:     p.event.Tool,
+			Decision: decision.Decision,
+			Reason:   decision.Reason,
+		})
+	}
+	return nil
+}
+
+// Resolve 提交审批结果，approver 为审批人
+func (m *approvalManager) Resolve(sessionID, approver string, in *v1.ApprovalReq) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.pending[in.CallID]
+	if !ok || p.sessionID != sessionID {
+		return gerror.Newf("no tool call waiting for approval: %s", in.CallID)
+	}
+	select {
+	case p.decided <- &approvalDecision{ApprovalReq: in, approver: approver}:
+		return nil
+	default:
+		return gerror.Newf("tool call already resolved: %s", in.CallID)
+	}
+}
+
+// remove 清理某次运行遗留的审批状态
+func (m *approvalManager) remove(runID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for callID, p := range m.pending {
+		if p.runID == runID {
+			delete(m.pending, callID)
+		}
+	}
+	// 运行出错或被取消时，已审批但未重新执行的结果同样清理
+	for callID, d := range m.decisions {
+		if d.runID == runID {
+			delete(m.decisions, callID)
+		}
+	}
+}
+
+// Approve 处理等待审批的工具调用，只能审批当前用户会话中的调用
+func (s *sAgent) Approve(ctx context.Context, in *v1.ApprovalReq) (out *v1.ApprovalRes, err error) {
+	identity := auth.FromCtx(ctx)
+	if err = s.approvals.Resolve(identity.Scope(in.SessionID), identity.UserID, in); err != nil {
+		return nil, err
+	}
+	return &v1.ApprovalRes{
+		CallID:   in.CallID,
+		Decision: in.Decision,
+	}, nil
+}
+
+// memoryCheckPointStore 内存版 CheckPointStore，用于中断后在同一请求内恢复运行
+type memoryCheckPointStore struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func newMemoryCheckPointStore() *memoryCheckPointStore {
+	return &memoryCheckPointStore{data: make(map[string][]byte)}
+}
+
+func (s *memoryCheckPointStore) Get(_ context.Context, checkPointID string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.data[checkPointID]
+	return data, ok, nil
+}
+
+func (s *memoryCheckPointStore) Set(_ context.Context, checkPointID string, checkPoint []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data[checkPointID] = checkPoint
+	return nil
+}
+
+// Delete 运行结束后删除检查点
+func (s *memoryCheckPointStore) Delete(checkPointID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data, checkPointID)
+}
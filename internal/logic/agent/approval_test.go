@@ -0,0 +1,261 @@
+package agent
+
+import (
+	v1 "agent/api/agent/v1"
+	"agent/internal/auth"
+	"agent/internal/consts"
+	"agent/internal/secret"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcfg"
+)
+
+// setTestConfig 在测试期间修改配置项，测试结束后恢复
+func setTestConfig(t1 *testing.T, key string, value any) {
+	adapter := g.Cfg().GetAdapter().(*gcfg.AdapterFile)
+	old, _ := adapter.Get(context.Background(), key)
+	if err := adapter.Set(key, value); err != nil {
+		t1.Fatalf("failed to set config %s: %v", key, err)
+	}
+	t1.Cleanup(func() { _ = adapter.Set(key, old) })
+}
+
+// fakeTool 记录收到的参数并原样返回
+type fakeTool struct {
+	name  string
+	calls []string
+}
+
+func (t *fakeTool) Info(_ context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{Name: t.name, Desc: "fake tool for tests"}, nil
+}
+
+func (t *fakeTool) InvokableRun(_ context.Context, argumentsInJSON string, _ ...tool.Option) (string, error) {
+	t.calls = append(t.calls, argumentsInJSON)
+	return "ran " + argumentsInJSON, nil
+}
+
+// toolCallMessage 模型发出的一次工具调用
+func toolCallMessage(callID, name, arguments string) *schema.Message {
+	return schema.AssistantMessage("", []schema.ToolCall{{
+		ID:       callID,
+		Function: schema.FunctionCall{Name: name, Arguments: arguments},
+	}})
+}
+
+// toolsState 工具节点的输入，中断后恢复时工具节点收到空输入，与 ReAct Agent 一样从状态中取回
+type toolsState struct {
+	Input *schema.Message
+}
+
+func init() {
+	_ = compose.RegisterSerializableType[toolsState]("agent_test_tools_state")
+}
+
+// newToolsRunnable 只包含工具节点的图，工具挂载给定中间件，检查点保存在 store 中
+func newToolsRunnable(t1 *testing.T, ctx context.Context, store compose.CheckPointStore, t tool.BaseTool,
+	mws ...toolMiddleware) compose.Runnable[*schema.Message, []*schema.Message] {
+	preHandler := func(_ context.Context, input *schema.Message, state *toolsState) (*schema.Message, error) {
+		if input == nil {
+			return state.Input, nil
+		}
+		state.Input = input
+		return input, nil
+	}
+	wrapped, err := wrapTools(ctx, []tool.BaseTool{t}, mws...)
+	if err != nil {
+		t1.Fatal(err)
+	}
+	node, err := compose.NewToolNode(ctx, &compose.ToolsNodeConfig{Tools: wrapped})
+	if err != nil {
+		t1.Fatal(err)
+	}
+	graph := compose.NewGraph[*schema.Message, []*schema.Message](compose.WithGenLocalState(func(context.Context) *toolsState {
+		return &toolsState{}
+	}))
+	if err = graph.AddToolsNode("tools", node, compose.WithStatePreHandler(preHandler)); err != nil {
+		t1.Fatal(err)
+	}
+	if err = graph.AddEdge(compose.START, "tools"); err != nil {
+		t1.Fatal(err)
+	}
+	if err = graph.AddEdge("tools", compose.END); err != nil {
+		t1.Fatal(err)
+	}
+	runnable, err := graph.Compile(ctx, compose.WithCheckPointStore(store))
+	if err != nil {
+		t1.Fatal(err)
+	}
+	return runnable
+}
+
+func TestApprovalManager_Middleware(t1 *testing.T) {
+	setTestConfig(t1, consts.ApprovalTools, []string{"fake_tool"})
+	setTestConfig(t1, consts.ApprovalTimeout, "300ms")
+	secret.Register("sk-approval-secret-123")
+	const arguments = `{"command":"ls","token":"sk-approval-secret-123"}`
+
+	tests := []struct {
+		name       string
+		decision   *v1.ApprovalReq // 为空时等待超时
+		wantCalls  []string
+		wantOutput string
+	}{
+		{
+			name:       "approve",
+			decision:   &v1.ApprovalReq{Decision: consts.ApprovalApprove},
+			wantCalls:  []string{arguments},
+			wantOutput: "ran " + arguments,
+		},
+		{
+			name:       "deny",
+			decision:   &v1.ApprovalReq{Decision: consts.ApprovalDeny, Reason: "not now"},
+			wantOutput: "Tool call was denied by the user: not now. Do not retry this call.",
+		},
+		{
+			name:       "edit",
+			decision:   &v1.ApprovalReq{Decision: consts.ApprovalEdit, Arguments: `{"command":"pwd"}`},
+			wantCalls:  []string{`{"command":"pwd"}`},
+			wantOutput: `ran {"command":"pwd"}`,
+		},
+		{
+			name:       "timeout",
+			wantOutput: "Tool call was denied by the user: approval timed out. Do not retry this call.",
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			ctx := context.Background()
+			m, store := newApprovalManager(), newMemoryCheckPointStore()
+			fake := &fakeTool{name: "fake_tool"}
+			runID := "s1:" + tt.name
+			runnable := newToolsRunnable(t1, ctx, store, fake, m.Middleware(ctx, runID, "s1", nil))
+			input := toolCallMessage("call_1", "fake_tool", arguments)
+
+			// 1. 首次运行中断，等待审批
+			start := time.Now()
+			_, err := runnable.Invoke(ctx, input, compose.WithCheckPointID(runID))
+			if _, ok := compose.ExtractInterruptInfo(err); !ok {
+				t1.Fatalf("Invoke() error = %v, want interrupt", err)
+			}
+			if len(fake.calls) != 0 {
+				t1.Fatalf("tool ran before approval: %v", fake.calls)
+			}
+			pending := m.runPending(runID)
+			if len(pending) != 1 {
+				t1.Fatalf("runPending() = %d, want 1", len(pending))
+			}
+			if event := pending[0].event; strings.Contains(event.Arguments, "sk-approval-secret-123") {
+				t1.Errorf("approval event leaks the secret: %s", event.Arguments)
+			}
+
+			// 2. 提交审批结果，或等待超时自动拒绝
+			if tt.decision != nil {
+				tt.decision.CallID = "call_1"
+				if err = m.Resolve("s1", "alice", tt.decision); err != nil {
+					t1.Fatalf("Resolve() error = %v", err)
+				}
+			}
+			if err = m.Await(ctx, nil, runID); err != nil {
+				t1.Fatalf("Await() error = %v", err)
+			}
+			if tt.decision == nil && time.Since(start) < 300*time.Millisecond {
+				t1.Errorf("Await() auto-denied after %v, before the timeout", time.Since(start))
+			}
+
+			// 3. 从检查点恢复运行，工具按审批结果执行
+			out, err := runnable.Invoke(ctx, input, compose.WithCheckPointID(runID))
+			if err != nil {
+				t1.Fatalf("Invoke() after approval error = %v", err)
+			}
+			if len(out) != 1 || out[0].Content != tt.wantOutput {
+				t1.Errorf("Invoke() = %v, want %q", out, tt.wantOutput)
+			}
+			if strings.Join(fake.calls, "|") != strings.Join(tt.wantCalls, "|") {
+				t1.Errorf("tool calls = %v, want %v", fake.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestApprovalManager_Resolve(t1 *testing.T) {
+	m := newApprovalManager()
+	m.pending["call_1"] = &pendingApproval{
+		runID:     "run",
+		sessionID: "s1",
+		deadline:  time.Now().Add(time.Minute),
+		event:     &v1.ApprovalEvent{CallID: "call_1", Tool: "fake_tool"},
+		decided:   make(chan *approvalDecision, 1),
+	}
+	approve := func(callID string) *v1.ApprovalReq {
+		return &v1.ApprovalReq{CallID: callID, Decision: consts.ApprovalApprove}
+	}
+
+	tests := []struct {
+		name      string
+		sessionID string
+		in        *v1.ApprovalReq
+		wantErr   bool
+	}{
+		{name: "unknown call", sessionID: "s1", in: approve("call_2"), wantErr: true},
+		{name: "other session", sessionID: "s2", in: approve("call_1"), wantErr: true},
+		{name: "approve", sessionID: "s1", in: approve("call_1")},
+		{name: "already resolved", sessionID: "s1", in: approve("call_1"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			if err := m.Resolve(tt.sessionID, "alice", tt.in); (err != nil) != tt.wantErr {
+				t1.Errorf("Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// 审批结果被取走后调用不再等待审批
+	if err := m.Await(context.Background(), nil, "run"); err != nil {
+		t1.Fatalf("Await() error = %v", err)
+	}
+	if err := m.Resolve("s1", "alice", approve("call_1")); err == nil {
+		t1.Errorf("Resolve() accepted a call that was already decided")
+	}
+	m.remove("run")
+	if len(m.pending) != 0 || len(m.decisions) != 0 {
+		t1.Errorf("remove() left pending = %v, decisions = %v", m.pending, m.decisions)
+	}
+}
+
+func TestAgent_Approve(t1 *testing.T) {
+	alice := &auth.Identity{UserID: "alice", TenantID: "acme"}
+	bob := &auth.Identity{UserID: "bob", TenantID: "acme"}
+	tests := []struct {
+		name     string
+		identity *auth.Identity
+		wantErr  bool
+	}{
+		{name: "other user", identity: bob, wantErr: true},
+		{name: "session owner", identity: alice},
+	}
+	s := &sAgent{approvals: newApprovalManager()}
+	s.approvals.pending["call_1"] = &pendingApproval{
+		runID:     "run",
+		sessionID: alice.Scope("s1"),
+		deadline:  time.Now().Add(time.Minute),
+		event:     &v1.ApprovalEvent{CallID: "call_1", Tool: "fake_tool"},
+		decided:   make(chan *approvalDecision, 1),
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			ctx := auth.WithIdentity(context.Background(), tt.identity)
+			_, err := s.Approve(ctx, &v1.ApprovalReq{SessionID: "s1", CallID: "call_1", Decision: consts.ApprovalApprove})
+			if (err != nil) != tt.wantErr {
+				t1.Errorf("Approve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package agent
+
+import (
+	v1 "agent/api/agent/v1"
+	"agent/internal/auth"
+	"agent/internal/consts"
+	"agent/internal/tools"
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// artifactMiddleware 工具登记产物后通过 SSE 推送下载链接
+func artifactMiddleware(r *ghttp.Request) toolMiddleware {
+	return func(next toolEndpoint) toolEndpoint {
+		return func(ctx context.Context, name, argumentsInJSON string, opts ...tool.Option) (string, error) {
+			ctx = tools.WithArtifactSink(ctx, func(a *tools.Artifact) {
+				SndEvent(r, consts.EventArtifact, &v1.ArtifactEvent{
+					ID:        a.ID,
+					Name:      a.Name,
+					MimeType:  a.MimeType,
+					Size:      a.Size,
+					Tool:      a.Tool,
+					URL:       a.URL,
+					ExpiresAt: a.ExpiresAt.Unix(),
+				})
+			})
+			return next(ctx, name, argumentsInJSON, opts...)
+		}
+	}
+}
+
+// ServeArtifact 校验签名链接和产物所属用户，返回工具生成的文件
+func (s *sAgent) ServeArtifact(ctx context.Context, in *v1.ArtifactReq) (out *v1.ArtifactRes, err error) {
+	r := ghttp.RequestFromCtx(ctx)
+	artifact, err := tools.OpenArtifact(ctx, in.ID, in.Expires, in.Signature)
+	// 其他用户的产物按不存在处理
+	if err == nil && !auth.FromCtx(ctx).Owns(artifact.TenantID, artifact.UserID) {
+		err = tools.ErrArtifactNotFound
+	}
+	switch {
+	case errors.Is(err, tools.ErrArtifactLinkInvalid):
+		r.Response.WriteStatus(http.StatusForbidden, err.Error())
+		return nil, nil
+	case errors.Is(err, tools.ErrArtifactNotFound):
+		r.Response.WriteStatus(http.StatusNotFound, err.Error())
+		return nil, nil
+	case err != nil:
+		g.Log(consts.LoggerAgent).Errorf(ctx, "failed to open artifact %s: %v", in.ID, err)
+		return nil, err
+	}
+	r.Response.Header().Set("Content-Type", artifact.MimeType)
+	r.Response.ServeFileDownload(artifact.Path, artifact.Name)
+	return nil, nil
+}
@@ -0,0 +1,104 @@
+package agent
+
+import (
+	v1 "agent/api/agent/v1"
+	"agent/internal/audit"
+	"agent/internal/auth"
+	"agent/internal/dao"
+	"agent/internal/guardrail"
+	"agent/internal/model/do"
+	"agent/internal/model/entity"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/compose"
+)
+
+// auditMiddleware 审计中间件，位于最外层，记录每次工具调用的参数、结果、耗时和审批结果，包括被拦截和拒绝的调用
+func auditMiddleware(next toolEndpoint) toolEndpoint {
+	return func(ctx context.Context, name, argumentsInJSON string, opts ...tool.Option) (string, error) {
+		ctx, entry := audit.Start(ctx, name, compose.GetToolCallID(ctx), argumentsInJSON)
+		output, err := next(ctx, name, argumentsInJSON, opts...)
+		// 等待审批时中断的调用不记录，审批后重新执行时记录
+		if _, interrupted := compose.IsInterruptRerunError(err); !interrupted {
+			entry.Finish(ctx, guardrail.Unwrap(output), err)
+		}
+		return output, err
+	}
+}
+
+// Audit 按条件分页查询工具调用的审计日志，按时间倒序；非管理员只能查看自己的记录
+func (s *sAgent) Audit(ctx context.Context, in *v1.AuditReq) (out *v1.AuditRes, err error) {
+	if !audit.Persisted() {
+		return nil, errors.New("audit log is not persisted to the database, enable audit.enabled and audit.persist")
+	}
+	if identity := auth.FromCtx(ctx); !identity.Admin {
+		in.TenantID, in.UserID = identity.TenantID, identity.UserID
+		if in.SessionID != "" {
+			in.SessionID = identity.Scope(in.SessionID)
+		}
+	}
+	cols := dao.ToolAudit.Columns()
+
+	// 1. 时间范围和过滤条件
+	m := dao.ToolAudit.Ctx(ctx).OmitEmptyWhere().Where(do.ToolAudit{
+		SessionId: in.SessionID,
+		UserId:    in.UserID,
+		TenantId:  in.TenantID,
+		Agent:     in.Agent,
+		Tool:      in.Tool,
+		TraceId:   in.TraceID,
+	})
+	switch in.Approval {
+	case "":
+	case "none":
+		m = m.Where(cols.Approval, "")
+	default:
+		m = m.Where(cols.Approval, in.Approval)
+	}
+	if in.Failed {
+		m = m.WhereNot(cols.Error, "")
+	}
+	if in.From != nil {
+		m = m.WhereGTE(cols.CreatedAt, in.From)
+	}
+	if in.To != nil {
+		m = m.WhereLT(cols.CreatedAt, in.To)
+	}
+
+	// 2. 分页
+	var rows []*entity.ToolAudit
+	var total int
+	if err = m.OrderDesc(cols.Id).Page(in.Page, in.Size).ScanAndCount(&rows, &total, false); err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %v", err)
+	}
+	out = &v1.AuditRes{
+		Total:   total,
+		Page:    in.Page,
+		Size:    in.Size,
+		Entries: make([]*v1.AuditEntry, 0, len(rows)),
+	}
+	for _, row := range rows {
+		out.Entries = append(out.Entries, &v1.AuditEntry{
+			ID:             row.Id,
+			CreatedAt:      row.CreatedAt,
+			TraceID:        row.TraceId,
+			SessionID:      row.SessionId,
+			UserID:         row.UserId,
+			TenantID:       row.TenantId,
+			Agent:          row.Agent,
+			CallID:         row.CallId,
+			Tool:           row.Tool,
+			Arguments:      row.Arguments,
+			Result:         row.Result,
+			Error:          row.Error,
+			DurationMs:     row.DurationMs,
+			Approval:       row.Approval,
+			ApprovalReason: row.ApprovalReason,
+			Approver:       row.Approver,
+		})
+	}
+	return out, nil
+}
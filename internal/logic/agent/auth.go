@@ -0,0 +1,54 @@
+package agent
+
+import (
+	"agent/internal/auth"
+	"agent/internal/consts"
+	"agent/internal/model"
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// requestContext 按当前身份生成请求上下文：会话 ID 按租户和用户隔离，并校验租户是否允许使用该 Agent 和模型
+func requestContext(ctx context.Context, sessionID, modelName, agent string) (*model.Context, error) {
+	identity := auth.FromCtx(ctx)
+	policy := auth.TenantPolicy(ctx, identity.TenantID)
+	if !policy.AllowAgent(agent) {
+		return nil, fmt.Errorf("%w: agent %s is not allowed for tenant %s", auth.ErrForbidden, agent, identity.TenantID)
+	}
+	if modelName == "" {
+		modelName = policy.Model(g.Cfg().MustGet(ctx, consts.Model).String())
+	} else if !policy.AllowModel(modelName) {
+		return nil, fmt.Errorf("%w: model %s is not allowed for tenant %s", auth.ErrForbidden, modelName, identity.TenantID)
+	}
+	return &model.Context{
+		SessionID: identity.Scope(sessionID),
+		UserID:    identity.UserID,
+		TenantID:  identity.TenantID,
+		Agent:     agent,
+		Model:     modelName,
+	}, nil
+}
+
+// allowedTools 去掉租户不允许使用的工具
+func allowedTools(ctx context.Context, baseTools []tool.BaseTool) []tool.BaseTool {
+	policy := auth.TenantPolicy(ctx, auth.FromCtx(ctx).TenantID)
+	allowed := make([]tool.BaseTool, 0, len(baseTools))
+	for _, t := range baseTools {
+		info, err := t.Info(ctx)
+		if err != nil || !policy.AllowTool(info.Name) {
+			continue
+		}
+		allowed = append(allowed, t)
+	}
+	return allowed
+}
+
+// writeForbidden 租户不允许访问时返回 403
+func writeForbidden(r *ghttp.Request, err error) {
+	r.Response.WriteStatus(http.StatusForbidden, err.Error())
+}
@@ -0,0 +1,212 @@
+package agent
+
+import (
+	v1 "agent/api/agent/v1"
+	"agent/internal/consts"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+const (
+	defaultMaxSteps     = 8
+	defaultMaxToolCalls = 3
+	defaultTurnTimeout  = 120 * time.Second
+
+	// turnTimeoutGrace 软超时后留给模型生成最终答案的时间
+	turnTimeoutGrace = 30 * time.Second
+)
+
+// forceFinalAnswerPrompt 预算耗尽后追加给模型的系统提示
+const forceFinalAnswerPrompt = `The budget for this turn has been exhausted (%s).
+Do not call any more tools. Answer the user now based on the information you already have,
+and briefly mention that the answer may be incomplete.`
+
+// fallbackAnswer 模型被要求直接回答后仍只调用工具时，代替空回答返回给用户
+const fallbackAnswer = "I could not finish this request within the budget for this turn (%s). " +
+	"Please narrow down the question or ask me to continue."
+
+// turnBudget 单轮 ReAct 运行的预算：推理步数、单个工具调用次数、总耗时
+type turnBudget struct {
+	maxSteps     int
+	maxToolCalls int
+	toolLimits   map[string]int
+	timeout      time.Duration
+	start        time.Time
+
+	mu          sync.Mutex
+	steps       int
+	toolCalls   map[string]int
+	exhausted   *v1.BudgetEvent
+	onExhausted func(event *v1.BudgetEvent)
+	forced      bool // 最近一次模型调用是否已要求模型直接回答
+	dropped     bool // 模型在要求直接回答后仍只调用工具，本轮没有回答
+}
+
+// newTurnBudget 从配置中读取预算
+func newTurnBudget(ctx context.Context) *turnBudget {
+	b := &turnBudget{
+		maxSteps:     g.Cfg().MustGet(ctx, consts.AgentMaxSteps, defaultMaxSteps).Int(),
+		maxToolCalls: g.Cfg().MustGet(ctx, consts.AgentMaxToolCalls, defaultMaxToolCalls).Int(),
+		toolLimits:   make(map[string]int),
+		timeout:      g.Cfg().MustGet(ctx, consts.AgentTurnTimeout, defaultTurnTimeout).Duration(),
+		start:        time.Now(),
+		toolCalls:    make(map[string]int),
+	}
+	for name, limit := range g.Cfg().MustGet(ctx, consts.AgentToolCallLimits).MapStrVar() {
+		b.toolLimits[name] = limit.Int()
+	}
+	if b.maxSteps <= 0 {
+		b.maxSteps = defaultMaxSteps
+	}
+	if b.maxToolCalls <= 0 {
+		b.maxToolCalls = defaultMaxToolCalls
+	}
+	if b.timeout <= 0 {
+		b.timeout = defaultTurnTimeout
+	}
+	return b
+}
+
+// graphMaxSteps 换算为 Eino 图的最大运行步数，每次推理包含模型节点和工具节点
+func (b *turnBudget) graphMaxSteps() int {
+	return b.maxSteps*2 + 2
+}
+
+// hardRemaining 距整轮硬超时的剩余时间，软超时触发后仍留出生成最终答案的时间
+func (b *turnBudget) hardRemaining() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return time.Until(b.start.Add(b.timeout + turnTimeoutGrace))
+}
+
+// pause 将等待（如人工审批）的时间排除在单轮耗时之外
+func (b *turnBudget) pause(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.start = b.start.Add(d)
+}
+
+// exhaust 记录第一个被耗尽的预算并通知
+func (b *turnBudget) exhaust(event *v1.BudgetEvent) {
+	b.mu.Lock()
+	if b.exhausted != nil {
+		b.mu.Unlock()
+		return
+	}
+	b.exhausted = event
+	onExhausted := b.onExhausted
+	b.mu.Unlock()
+
+	if onExhausted != nil {
+		onExhausted(event)
+	}
+}
+
+// checkTimeout 检查是否超过单轮耗时上限
+func (b *turnBudget) checkTimeout() {
+	b.mu.Lock()
+	elapsed := time.Since(b.start)
+	b.mu.Unlock()
+	if elapsed < b.timeout {
+		return
+	}
+	b.exhaust(&v1.BudgetEvent{
+		Budget:  consts.BudgetTurnTimeout,
+		Limit:   int64(b.timeout.Seconds()),
+		Used:    int64(elapsed.Seconds()),
+		Message: fmt.Sprintf("turn took longer than %s", b.timeout),
+	})
+}
+
+// MessageModifier 每次调用模型前计数，预算耗尽时要求模型直接给出最终答案
+func (b *turnBudget) MessageModifier(_ context.Context, input []*schema.Message) []*schema.Message {
+	b.mu.Lock()
+	b.steps++
+	steps := b.steps
+	b.mu.Unlock()
+
+	b.checkTimeout()
+	if steps >= b.maxSteps {
+		b.exhaust(&v1.BudgetEvent{
+			Budget:  consts.BudgetMaxSteps,
+			Limit:   int64(b.maxSteps),
+			Used:    int64(steps),
+			Message: fmt.Sprintf("reached the maximum of %d reasoning steps", b.maxSteps),
+		})
+	}
+
+	b.mu.Lock()
+	exhausted := b.exhausted
+	b.forced = exhausted != nil
+	b.mu.Unlock()
+	if exhausted == nil {
+		return input
+	}
+	return append(input, schema.SystemMessage(fmt.Sprintf(forceFinalAnswerPrompt, exhausted.Message)))
+}
+
+// ContinueWithTools 模型输出后是否继续执行工具。没有工具调用时结束本轮；
+// 已要求模型直接回答、模型仍调用工具时同样结束，没有回答内容时由 FallbackAnswer 补充。
+// 按本次模型调用前是否追加了提示判断，不受工具中间件随后耗尽预算的影响
+func (b *turnBudget) ContinueWithTools(hasToolCalls, hasContent bool) bool {
+	if !hasToolCalls {
+		return false
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.forced {
+		return true
+	}
+	b.dropped = !hasContent
+	return false
+}
+
+// FallbackAnswer 模型在预算耗尽后仍只调用工具时返回给用户的回答，其他情况返回空字符串
+func (b *turnBudget) FallbackAnswer() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.dropped || b.exhausted == nil {
+		return ""
+	}
+	return fmt.Sprintf(fallbackAnswer, b.exhausted.Message)
+}
+
+// Middleware 工具调用预算中间件，超出预算的调用不再执行
+func (b *turnBudget) Middleware(next toolEndpoint) toolEndpoint {
+	return func(ctx context.Context, name, argumentsInJSON string, opts ...tool.Option) (string, error) {
+		b.checkTimeout()
+
+		b.mu.Lock()
+		limit, ok := b.toolLimits[name]
+		if !ok {
+			limit = b.maxToolCalls
+		}
+		b.toolCalls[name]++
+		used := b.toolCalls[name]
+		b.mu.Unlock()
+
+		if used > limit {
+			b.exhaust(&v1.BudgetEvent{
+				Budget:  consts.BudgetToolCalls,
+				Tool:    name,
+				Limit:   int64(limit),
+				Used:    int64(used),
+				Message: fmt.Sprintf("%s can be called at most %d times per turn", name, limit),
+			})
+		}
+
+		b.mu.Lock()
+		exhausted := b.exhausted
+		b.mu.Unlock()
+		if exhausted != nil {
+			return fmt.Sprintf("Tool call skipped: %s. Answer with the information you already have.", exhausted.Message), nil
+		}
+		return next(ctx, name, argumentsInJSON, opts...)
+	}
+}
@@ -0,0 +1,240 @@
+package agent
+
+import (
+	v1 "agent/api/agent/v1"
+	"agent/internal/consts"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// newTestBudget 不读取配置的预算，记录所有耗尽事件
+func newTestBudget(maxSteps, maxToolCalls int, timeout time.Duration, events *[]*v1.BudgetEvent) *turnBudget {
+	return &turnBudget{
+		maxSteps:     maxSteps,
+		maxToolCalls: maxToolCalls,
+		toolLimits:   map[string]int{"web_search_tool": 1},
+		timeout:      timeout,
+		start:        time.Now(),
+		toolCalls:    make(map[string]int),
+		onExhausted: func(event *v1.BudgetEvent) {
+			*events = append(*events, event)
+		},
+	}
+}
+
+// countingEndpoint 记录实际执行的工具调用
+func countingEndpoint(calls *[]string) toolEndpoint {
+	return func(_ context.Context, name, _ string, _ ...tool.Option) (string, error) {
+		*calls = append(*calls, name)
+		return "ok", nil
+	}
+}
+
+func TestTurnBudget_MessageModifier(t1 *testing.T) {
+	var events []*v1.BudgetEvent
+	b := newTestBudget(2, 3, time.Minute, &events)
+	input := []*schema.Message{schema.UserMessage("hi")}
+
+	tests := []struct {
+		name       string
+		wantForced bool
+	}{
+		{name: "first step", wantForced: false},
+		{name: "max steps reached", wantForced: true},
+		{name: "after exhaustion", wantForced: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			got := b.MessageModifier(context.Background(), input)
+			if !tt.wantForced {
+				if len(got) != len(input) {
+					t1.Errorf("MessageModifier() = %d messages, want %d", len(got), len(input))
+				}
+				return
+			}
+			last := got[len(got)-1]
+			if len(got) != len(input)+1 || last.Role != schema.System || !strings.Contains(last.Content, "Do not call any more tools") {
+				t1.Errorf("MessageModifier() did not append the final answer prompt: %v", got)
+			}
+		})
+	}
+	if len(events) != 1 || events[0].Budget != consts.BudgetMaxSteps || events[0].Limit != 2 || events[0].Used != 2 {
+		t1.Errorf("events = %+v, want one max_steps event", events)
+	}
+}
+
+func TestTurnBudget_Middleware(t1 *testing.T) {
+	tests := []struct {
+		name      string
+		timeout   time.Duration
+		elapsed   time.Duration
+		tools     []string
+		wantCalls []string
+		wantEvent string
+		wantTool  string
+	}{
+		{
+			name:      "within limits",
+			timeout:   time.Minute,
+			tools:     []string{"web_search_tool", "web_fetch_tool", "web_fetch_tool"},
+			wantCalls: []string{"web_search_tool", "web_fetch_tool", "web_fetch_tool"},
+		},
+		{
+			name:      "per tool limit",
+			timeout:   time.Minute,
+			tools:     []string{"web_search_tool", "web_search_tool", "web_fetch_tool"},
+			wantCalls: []string{"web_search_tool"},
+			wantEvent: consts.BudgetToolCalls,
+			wantTool:  "web_search_tool",
+		},
+		{
+			name:      "default limit",
+			timeout:   time.Minute,
+			tools:     []string{"web_fetch_tool", "web_fetch_tool", "web_fetch_tool", "web_fetch_tool"},
+			wantCalls: []string{"web_fetch_tool", "web_fetch_tool", "web_fetch_tool"},
+			wantEvent: consts.BudgetToolCalls,
+			wantTool:  "web_fetch_tool",
+		},
+		{
+			name:      "turn timeout",
+			timeout:   time.Second,
+			elapsed:   2 * time.Second,
+			tools:     []string{"web_fetch_tool"},
+			wantEvent: consts.BudgetTurnTimeout,
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			var events []*v1.BudgetEvent
+			var calls []string
+			b := newTestBudget(8, 3, tt.timeout, &events)
+			b.start = b.start.Add(-tt.elapsed)
+			endpoint := b.Middleware(countingEndpoint(&calls))
+			for _, name := range tt.tools {
+				output, err := endpoint(context.Background(), name, "{}")
+				if err != nil {
+					t1.Fatalf("Middleware() error = %v", err)
+				}
+				if output != "ok" && !strings.HasPrefix(output, "Tool call skipped") {
+					t1.Errorf("Middleware() = %q", output)
+				}
+			}
+			if strings.Join(calls, ",") != strings.Join(tt.wantCalls, ",") {
+				t1.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if tt.wantEvent == "" {
+				if len(events) != 0 {
+					t1.Errorf("events = %+v, want none", events)
+				}
+				return
+			}
+			if len(events) != 1 || events[0].Budget != tt.wantEvent || events[0].Tool != tt.wantTool {
+				t1.Errorf("events = %+v, want one %s event", events, tt.wantEvent)
+			}
+		})
+	}
+}
+
+func TestTurnBudget_FirstExhaustion(t1 *testing.T) {
+	var events []*v1.BudgetEvent
+	var calls []string
+	b := newTestBudget(1, 3, time.Minute, &events)
+	endpoint := b.Middleware(countingEndpoint(&calls))
+	for i := 0; i < 2; i++ {
+		if _, err := endpoint(context.Background(), "web_search_tool", "{}"); err != nil {
+			t1.Fatal(err)
+		}
+	}
+	// 之后再超出步数和耗时不会覆盖第一个耗尽的预算
+	b.start = b.start.Add(-2 * time.Minute)
+	got := b.MessageModifier(context.Background(), nil)
+	if len(events) != 1 || events[0].Budget != consts.BudgetToolCalls {
+		t1.Fatalf("events = %+v, want only the tool_calls event", events)
+	}
+	if len(got) != 1 || !strings.Contains(got[0].Content, events[0].Message) {
+		t1.Errorf("MessageModifier() = %v, want the prompt for the first exhausted budget", got)
+	}
+}
+
+func TestTurnBudget_ContinueWithTools(t1 *testing.T) {
+	tests := []struct {
+		name         string
+		exhausted    bool // 模型调用前预算已耗尽
+		exhaustAfter bool // 模型调用后由工具中间件耗尽
+		hasToolCalls bool
+		hasContent   bool
+		want         bool
+		wantFallback bool
+	}{
+		{name: "answer", want: false},
+		{name: "tool calls", hasToolCalls: true, want: true},
+		{name: "exhausted by tools after the model call", exhaustAfter: true, hasToolCalls: true, want: true},
+		{name: "answer after exhaustion", exhausted: true, hasContent: true, want: false},
+		{name: "tool calls with answer after exhaustion", exhausted: true, hasToolCalls: true, hasContent: true, want: false},
+		{name: "only tool calls after exhaustion", exhausted: true, hasToolCalls: true, want: false, wantFallback: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			var events []*v1.BudgetEvent
+			b := newTestBudget(8, 3, time.Minute, &events)
+			exhaust := func() {
+				b.exhaust(&v1.BudgetEvent{Budget: consts.BudgetToolCalls, Message: "web_search_tool can be called at most 1 times per turn"})
+			}
+			if tt.exhausted {
+				exhaust()
+			}
+			b.MessageModifier(context.Background(), nil)
+			if tt.exhaustAfter {
+				exhaust()
+			}
+			if got := b.ContinueWithTools(tt.hasToolCalls, tt.hasContent); got != tt.want {
+				t1.Errorf("ContinueWithTools() = %v, want %v", got, tt.want)
+			}
+			answer := b.FallbackAnswer()
+			if (answer != "") != tt.wantFallback {
+				t1.Errorf("FallbackAnswer() = %q, wantFallback %v", answer, tt.wantFallback)
+			}
+			if tt.wantFallback && !strings.Contains(answer, "at most 1 times") {
+				t1.Errorf("FallbackAnswer() = %q, want the exhausted budget", answer)
+			}
+		})
+	}
+}
+
+func TestTurnBudget_Steps(t1 *testing.T) {
+	tests := []struct {
+		maxSteps int
+		want     int
+	}{
+		{maxSteps: 1, want: 4},
+		{maxSteps: 3, want: 8},
+		{maxSteps: 8, want: 18},
+	}
+	for _, tt := range tests {
+		t1.Run(fmt.Sprint(tt.maxSteps), func(t1 *testing.T) {
+			if got := (&turnBudget{maxSteps: tt.maxSteps}).graphMaxSteps(); got != tt.want {
+				t1.Errorf("graphMaxSteps() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTurnBudget_HardRemaining(t1 *testing.T) {
+	var events []*v1.BudgetEvent
+	b := newTestBudget(8, 3, 10*time.Second, &events)
+	want := 10*time.Second + turnTimeoutGrace
+	if got := b.hardRemaining(); got > want || got < want-time.Second {
+		t1.Errorf("hardRemaining() = %v, want about %v", got, want)
+	}
+	// 等待审批的时间不计入单轮耗时
+	b.pause(5 * time.Second)
+	if got := b.hardRemaining(); got > want+5*time.Second || got < want+4*time.Second {
+		t1.Errorf("hardRemaining() after pause = %v, want about %v", got, want+5*time.Second)
+	}
+}
@@ -2,60 +2,122 @@ package agent
 
 import (
 	v1 "agent/api/agent/v1"
+	"agent/internal/auth"
+	"agent/internal/consts"
+	"agent/internal/limit"
+	"agent/internal/metrics"
+	"agent/internal/secret"
 	"agent/internal/service"
+	"agent/internal/tools"
+	"agent/internal/usage"
 	"context"
 	"fmt"
 	"io"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
 	"github.com/cloudwego/eino/schema"
 	"github.com/gogf/gf/v2/encoding/gjson"
 	"github.com/gogf/gf/v2/frame/g"
 	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/gogf/gf/v2/os/gtimer"
 )
 
+// sessionJanitorInterval 清理过期会话数据的间隔
+const sessionJanitorInterval = 10 * time.Minute
+
 func init() {
 	service.RegisterAgent(New())
 }
 
 type sAgent struct {
-	historicalMessages map[string][]*schema.Message
+	historicalMessages *historyStore
+	approvals          *approvalManager
+	checkPoints        *memoryCheckPointStore
+	vaults             *vaultStore
 }
 
 func New() *sAgent {
-	return &sAgent{
-		historicalMessages: make(map[string][]*schema.Message),
+	s := &sAgent{
+		historicalMessages: newHistoryStore(),
+		approvals:          newApprovalManager(),
+		checkPoints:        newMemoryCheckPointStore(),
+		vaults:             newVaultStore(),
 	}
+	// 会话的占位符映射与工作目录同时过期；历史消息中的占位符无法再还原，一并删除
+	gtimer.AddSingleton(context.Background(), sessionJanitorInterval, func(ctx context.Context) {
+		for _, sessionID := range s.vaults.Expire(tools.WorkspaceTTL(ctx)) {
+			s.historicalMessages.Delete(sessionID)
+			g.Log(consts.LoggerAgent).Infof(ctx, "Expired pii vault and history of session: %s", sessionID)
+		}
+	})
+	return s
 }
 
 // ChainAgentStream 流式链式 Agent
 func (s *sAgent) ChainAgentStream(ctx context.Context, in *v1.ChatStreamReq) {
 	r := ghttp.RequestFromCtx(ctx)
-	// 设置 SSE 响应头
+	requestCtx, err := requestContext(ctx, in.SessionID, in.Model, consts.AgentChain)
+	if err != nil {
+		writeForbidden(r, err)
+		return
+	}
+	ctx = context.WithValue(ctx, consts.ContextKey, requestCtx)
+	sessionID := requestCtx.SessionID
+	release, err := acquireGeneration(ctx, requestCtx.Model)
+	if err != nil {
+		limit.WriteError(r, err)
+		return
+	}
+	defer release()
+	// 知识库检索的向量化和模型调用都计入本轮用量
+	ctx, turn := usage.StartTurn(ctx)
+	// 发给模型和写入历史的内容中的敏感信息替换为占位符，回答中的占位符在输出时还原
+	vault := s.vaults.Get(requestCtx.TenantID, sessionID)
+	query := vault.Redact(in.Query)
+	if !guardInput(ctx, r, query) {
+		return
+	}
+	ctx, stream := metrics.StartStream(ctx, consts.AgentChain)
+	defer stream.End()
+	// 设置 SSE 响应头，跨域响应头由 CORS 中间件按配置设置
 	r.Response.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
 	r.Response.Header().Set("Cache-Control", "no-cache")
 	r.Response.Header().Set("Connection", "keep-alive")
-	r.Response.Header().Set("Access-Control-Allow-Origin", "*")
-	r.Response.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
+	defer endStream(r)
 
 	chatModel := NewChatModel(ctx)
 
 	template, example := Template(ctx, &v1.ChatStreamReq{
-		Query:     in.Query,
+		Query:     query,
 		SessionID: in.SessionID,
 	})
-	sessionMessages := s.GetSessionBySessionID(ctx, in.SessionID)
+	sessionMessages := s.GetSessionBySessionID(ctx, sessionID)
 	variables := map[string]any{
 		"role":        "expert in the field of relationships with many years of experience",
 		"example":     example,
-		"task":        in.Query,
+		"task":        query,
 		"history_key": sessionMessages,
 	}
 	messages, _ := template.Format(ctx, variables)
+	if err := attachImages(ctx, messages[len(messages)-1], in.Images, false); err != nil {
+		SndErr(r, err)
+		return
+	}
 
-	reader, err := chatModel.Stream(ctx, messages)
+	// 直接调用模型不经过编排图，需手动初始化回调，链路追踪等全局回调才会生效
+	modelCtx := callbacks.InitCallbacks(ctx, &callbacks.RunInfo{
+		Name:      "ChainAgent",
+		Type:      chatModel.GetType(),
+		Component: components.ComponentOfChatModel,
+	})
+	reader, err := chatModel.Stream(modelCtx, messages)
 	if err != nil {
 		SndErr(r, err)
+		return
 	}
 	defer reader.Close()
 
@@ -64,27 +126,33 @@ func (s *sAgent) ChainAgentStream(ctx context.Context, in *v1.ChatStreamReq) {
 
 	var fullContent strings.Builder
 	var chunk *schema.Message
+	var blocked bool
+	restorer := vault.NewRestorer()
 	for {
 		chunk, err = reader.Recv()
 		if err != nil {
-			r.Response.Write([]byte("data: {\"content\":\"\",\"done\":true}\n\n"))
-			r.Response.Flush()
+			// 流末尾等待补全的占位符
+			if rest := restorer.Flush(); rest != "" {
+				sndContent(r, rest)
+			}
+			blocked = guardOutput(ctx, r, fullContent.String())
+			SndEvent(r, consts.EventUsage, usageEvent(ctx, turn.Summary()))
+			sndFrame(r, []byte(`{"content":"","done":true}`))
 			break
 		}
-		fullContent.Write([]byte(chunk.Content))
-		resp := v1.ChatStreamRes{
-			Content: chunk.Content,
-			Done:    false,
+		if chunk.Content != "" {
+			stream.FirstToken()
 		}
-		data, _ := gjson.Marshal(resp)
-		r.Response.Write([]byte(fmt.Sprintf("data: %s\n\n", data)))
-		r.Response.Flush()
+		fullContent.Write([]byte(chunk.Content))
+		sndContent(r, restorer.Write(chunk.Content))
 	}
 
-	finalContent := fullContent.String()
-	s.historicalMessages[in.SessionID] = append(s.historicalMessages[in.SessionID],
-		schema.UserMessage(in.Query),
-		schema.AssistantMessage(finalContent, nil))
+	// 被护栏拦截的回答不写入历史，避免影响后续对话
+	if !blocked {
+		s.historicalMessages.Append(sessionID,
+			schema.UserMessage(query),
+			schema.AssistantMessage(fullContent.String(), nil))
+	}
 
 	if err != nil && err != io.EOF {
 		SndErr(r, err)
@@ -95,17 +163,67 @@ func (s *sAgent) ChainAgentStream(ctx context.Context, in *v1.ChatStreamReq) {
 func SndErr(r *ghttp.Request, err error) {
 	// 发送错误信息
 	errorData := g.Map{
-		"error": err.Error(),
+		"error": secret.Mask(err.Error()),
 		"done":  true,
 	}
 	jsonData, _ := gjson.Marshal(errorData)
-	r.Response.Write([]byte(fmt.Sprintf("data: %s\n\n", jsonData)))
+	sndFrame(r, jsonData)
+}
+
+// sndContent 发送一段回答
+func sndContent(r *ghttp.Request, content string) {
+	data, _ := gjson.Marshal(v1.ChatStreamRes{
+		Content: content,
+		Done:    false,
+	})
+	sndFrame(r, data)
+}
+
+// SndEvent 发送结构化事件
+func SndEvent(r *ghttp.Request, event string, data any) {
+	resp := v1.ChatStreamRes{
+		Event: event,
+		Data:  data,
+	}
+	jsonData, _ := gjson.Marshal(resp)
+	sndFrame(r, jsonData)
+}
+
+// streamLocks 每个流式请求的写锁，工具并行执行时事件和回答会在不同的 goroutine 中写入
+var streamLocks sync.Map // *ghttp.Request -> *sync.Mutex
+
+// sndFrame 发送一帧 SSE 数据，同一请求的写入互斥，避免帧交错；没有 HTTP 请求时（如 MCP 调用）不发送
+func sndFrame(r *ghttp.Request, data []byte) {
+	if r == nil {
+		return
+	}
+	v, _ := streamLocks.LoadOrStore(r, &sync.Mutex{})
+	mu := v.(*sync.Mutex)
+	mu.Lock()
+	defer mu.Unlock()
+	r.Response.Write([]byte(fmt.Sprintf("data: %s\n\n", data)))
 	r.Response.Flush()
 }
 
+// endStream 请求结束后释放写锁
+func endStream(r *ghttp.Request) {
+	streamLocks.Delete(r)
+}
+
 // GetSessionBySessionID 获取会话
 func (s *sAgent) GetSessionBySessionID(ctx context.Context, sessionId string) []*schema.Message {
-	return s.historicalMessages[sessionId]
+	return s.historicalMessages.Get(sessionId)
+}
+
+// DeleteSession 删除当前用户会话的历史消息和工作目录
+func (s *sAgent) DeleteSession(ctx context.Context, in *v1.SessionDeleteReq) (out *v1.SessionDeleteRes, err error) {
+	sessionID := auth.FromCtx(ctx).Scope(in.SessionID)
+	s.historicalMessages.Delete(sessionID)
+	s.vaults.Delete(sessionID)
+	if err = tools.RemoveSessionWorkspace(ctx, sessionID); err != nil {
+		return nil, err
+	}
+	return &v1.SessionDeleteRes{SessionID: in.SessionID}, nil
 }
 
 // IsRelevant 检查检索结果是否与查询相关
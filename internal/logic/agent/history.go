@@ -0,0 +1,39 @@
+package agent
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/cloudwego/eino/schema"
+)
+
+// historyStore 各会话的历史消息，对话、读取和删除会话可能在不同请求中同时进行
+type historyStore struct {
+	mu       sync.RWMutex
+	messages map[string][]*schema.Message
+}
+
+func newHistoryStore() *historyStore {
+	return &historyStore{messages: make(map[string][]*schema.Message)}
+}
+
+// Get 返回会话历史消息的副本
+func (s *historyStore) Get(sessionID string) []*schema.Message {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.messages[sessionID])
+}
+
+// Append 在会话历史末尾追加消息
+func (s *historyStore) Append(sessionID string, messages ...*schema.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[sessionID] = append(s.messages[sessionID], messages...)
+}
+
+// Delete 删除会话历史
+func (s *historyStore) Delete(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.messages, sessionID)
+}
@@ -0,0 +1,83 @@
+package agent
+
+import (
+	v1 "agent/api/agent/v1"
+	"agent/internal/auth"
+	"agent/internal/consts"
+	"agent/internal/model"
+	"agent/internal/tools"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// Upload 保存上传的图片到当前用户的会话工作目录
+func (s *sAgent) Upload(ctx context.Context, in *v1.UploadReq) (out *v1.UploadRes, err error) {
+	identity := auth.FromCtx(ctx)
+	ctx = context.WithValue(ctx, consts.ContextKey, &model.Context{
+		SessionID: identity.Scope(in.SessionID),
+		UserID:    identity.UserID,
+		TenantID:  identity.TenantID,
+	})
+	file, err := in.File.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open upload: %v", err)
+	}
+	defer file.Close()
+	path, err := tools.SaveImage(ctx, in.File.Filename, file)
+	if err != nil {
+		return nil, err
+	}
+	g.Log(consts.LoggerAgent).Infof(ctx, "Saved upload %s for session %s", path, in.SessionID)
+	return &v1.UploadRes{Path: path}, nil
+}
+
+// attachImages 将图片附件加入用户消息。对话模型支持图片时作为多模态片段发送；
+// 否则在 toolFallback 为 true 时把图片列在消息中，由模型调用 image_describe_tool 查看
+func attachImages(ctx context.Context, msg *schema.Message, refs []string, toolFallback bool) error {
+	// 1. 校验数量并规范化引用
+	if len(refs) == 0 {
+		return nil
+	}
+	if maxCount := tools.ImageMaxCount(ctx); len(refs) > maxCount {
+		return fmt.Errorf("too many images: %d, at most %d per message", len(refs), maxCount)
+	}
+	normalized := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		ref, err := tools.NormalizeImageRef(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("invalid image: %v", err)
+		}
+		normalized = append(normalized, ref)
+	}
+
+	// 2. 模型不支持图片输入时改由工具查看
+	if !g.Cfg().MustGet(ctx, consts.Multimodal, false).Bool() {
+		if !toolFallback {
+			return fmt.Errorf("the chat model does not accept images, enable ai.multimodal or use the agent endpoint")
+		}
+		var b strings.Builder
+		b.WriteString(msg.Content)
+		b.WriteString("\n\nThe user attached the following images, use image_describe_tool to look at them:")
+		for _, ref := range normalized {
+			b.WriteString("\n- " + ref)
+		}
+		msg.Content = b.String()
+		return nil
+	}
+
+	// 3. 组装多模态消息，文本在前
+	parts := []schema.ChatMessagePart{{Type: schema.ChatMessagePartTypeText, Text: msg.Content}}
+	for _, ref := range normalized {
+		part, err := tools.ImagePart(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("invalid image: %v", err)
+		}
+		parts = append(parts, part)
+	}
+	msg.MultiContent = parts
+	return nil
+}
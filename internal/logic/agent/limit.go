@@ -0,0 +1,56 @@
+package agent
+
+import (
+	v1 "agent/api/agent/v1"
+	"agent/internal/consts"
+	"agent/internal/limit"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/gogf/gf/v2/net/ghttp"
+)
+
+// acquireGeneration 占用当前用户的生成名额并检查每日配额，超出时返回 *limit.Error
+func acquireGeneration(ctx context.Context, modelName string) (release func(), err error) {
+	release, err = limit.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = limit.CheckQuota(ctx, modelName); err != nil {
+		release()
+		return nil, err
+	}
+	return release, nil
+}
+
+// quotaMiddleware 按工具的每日调用次数限制调用，超出时通过 SSE 通知前端，并将错误作为工具结果返回给模型
+func quotaMiddleware(r *ghttp.Request) toolMiddleware {
+	return func(next toolEndpoint) toolEndpoint {
+		return func(ctx context.Context, name, argumentsInJSON string, opts ...tool.Option) (string, error) {
+			err := limit.TakeTool(ctx, name)
+			var limitErr *limit.Error
+			if errors.As(err, &limitErr) {
+				if r != nil {
+					SndEvent(r, consts.EventRateLimited, rateLimitEvent(limitErr))
+				}
+				return fmt.Sprintf("Error: %s. Do not call %s again today, answer with the information you already have.", limitErr.Message, name), nil
+			}
+			return next(ctx, name, argumentsInJSON, opts...)
+		}
+	}
+}
+
+// rateLimitEvent 超出限流或配额的事件
+func rateLimitEvent(err *limit.Error) *v1.RateLimitEvent {
+	return &v1.RateLimitEvent{
+		Limit:      err.Limit,
+		Tool:       err.Tool,
+		Max:        err.Max,
+		Used:       err.Used,
+		RetryAfter: int64(math.Ceil(err.RetryAfter.Seconds())),
+		Message:    err.Message,
+	}
+}
@@ -0,0 +1,28 @@
+package agent
+
+import (
+	v1 "agent/api/agent/v1"
+	"agent/internal/tools"
+	"context"
+)
+
+// MCPHealth 返回 MCP 服务的连接状态，全部服务连接成功时 healthy 为 true
+func (s *sAgent) MCPHealth(ctx context.Context, in *v1.MCPHealthReq) (out *v1.MCPHealthRes, err error) {
+	out = &v1.MCPHealthRes{Healthy: true, Servers: []*v1.MCPServerHealth{}}
+	for _, h := range tools.MCPHealth() {
+		if h.Status != tools.MCPStatusReady {
+			out.Healthy = false
+		}
+		out.Servers = append(out.Servers, &v1.MCPServerHealth{
+			Name:        h.Name,
+			Transport:   h.Transport,
+			Status:      h.Status,
+			Tools:       h.Tools,
+			Failures:    h.Failures,
+			LastError:   h.LastError,
+			ConnectedAt: h.ConnectedAt,
+			LastCheck:   h.LastCheck,
+		})
+	}
+	return out, nil
+}
@@ -0,0 +1,183 @@
+package agent
+
+import (
+	v1 "agent/api/agent/v1"
+	"agent/internal/auth"
+	"agent/internal/consts"
+	"agent/internal/guardrail"
+	"agent/internal/limit"
+	"agent/internal/model"
+	"agent/internal/secret"
+	"agent/internal/tools"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	mcpServerName    = "agent"
+	mcpServerVersion = "1.0.0"
+	askAgentTool     = "ask_agent"
+
+	milvusConnectTimeout = 5 * time.Second
+)
+
+// NewMCPServer 创建 MCP 服务：每个工具对应一个 MCP 工具，ReAct Agent 作为 ask_agent 工具，知识库文档作为资源
+func (s *sAgent) NewMCPServer(ctx context.Context) (*server.MCPServer, error) {
+	srv := server.NewMCPServer(mcpServerName, mcpServerVersion,
+		server.WithToolCapabilities(false),
+		server.WithResourceCapabilities(false, false),
+		server.WithRecovery(),
+		// 按调用方的租户策略过滤和限制工具，并计入用户配额
+		server.WithToolFilter(mcpToolFilter),
+		server.WithToolHandlerMiddleware(mcpToolMiddleware),
+		server.WithInstructions("Use ask_agent for open-ended tasks that need planning across several tools, "+
+			"or call the individual tools directly. Knowledge base documents are available as resources and through knowledge_search_tool."),
+	)
+
+	// 1. 工具，需要审批的高风险工具不对外提供
+	baseTools := append(defaultTools(ctx), tools.NewKnowledgeSearchTool(knowledgeRetriever(ctx)))
+	for _, t := range baseTools {
+		serverTool, err := tools.MCPServerTool(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		srv.AddTools(serverTool)
+	}
+
+	// 2. ask_agent
+	srv.AddTool(mcp.NewTool(askAgentTool,
+		mcp.WithDescription("Ask the ReAct agent to complete a task. The agent plans, calls the tools of this server "+
+			"as needed and returns its final answer. Files it creates are kept in the workspace of the MCP session."),
+		mcp.WithString("query", mcp.Required(), mcp.Description("The task or question for the agent")),
+		mcp.WithArray("images", mcp.WithStringItems(), mcp.Description("Optional image URLs or workspace paths attached to the query")),
+	), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query, err := request.RequireString("query")
+		if err != nil || strings.TrimSpace(query) == "" {
+			return mcp.NewToolResultError("query parameter is required"), nil
+		}
+		answer, err := s.ask(ctx, query, request.GetStringSlice("images", nil))
+		if err != nil {
+			return mcp.NewToolResultError(secret.Mask(fmt.Sprintf("agent failed: %v", err))), nil
+		}
+		return mcp.NewToolResultText(answer), nil
+	})
+
+	// 3. 知识库资源
+	resources, err := tools.MCPKnowledgeResources(ctx)
+	if err != nil {
+		g.Log(consts.LoggerAgent).Warningf(ctx, "knowledge base resources are unavailable: %v", err)
+	}
+	srv.AddResources(resources...)
+	return srv, nil
+}
+
+// mcpToolFilter 列出工具时去掉调用方租户不允许使用的工具
+func mcpToolFilter(ctx context.Context, list []mcp.Tool) []mcp.Tool {
+	policy := auth.TenantPolicy(ctx, auth.FromCtx(ctx).TenantID)
+	if !policy.AllowAgent(consts.AgentMCP) {
+		return nil
+	}
+	allowed := make([]mcp.Tool, 0, len(list))
+	for _, t := range list {
+		if t.Name == askAgentTool || policy.AllowTool(t.Name) {
+			allowed = append(allowed, t)
+		}
+	}
+	return allowed
+}
+
+// mcpToolMiddleware 按调用方身份设置会话上下文，检查租户策略和工具的每日配额；
+// http 传输的身份来自认证中间件，stdio 为默认租户下的匿名用户
+func mcpToolMiddleware(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name := request.Params.Name
+		requestCtx, err := requestContext(ctx, tools.MCPSessionID(ctx), "", consts.AgentMCP)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if name != askAgentTool && !auth.TenantPolicy(ctx, requestCtx.TenantID).AllowTool(name) {
+			return mcp.NewToolResultError(fmt.Sprintf("%v: tool %s is not allowed for tenant %s", auth.ErrForbidden, name, requestCtx.TenantID)), nil
+		}
+		ctx = context.WithValue(ctx, consts.ContextKey, requestCtx)
+		if name != askAgentTool {
+			if err = limit.TakeTool(ctx, name); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+		return next(ctx, request)
+	}
+}
+
+// ask 运行 ReAct Agent 并返回最终回答，不依赖 HTTP 请求，只使用无需审批且租户允许的工具
+func (s *sAgent) ask(ctx context.Context, query string, images []string) (string, error) {
+	requestCtx, ok := ctx.Value(consts.ContextKey).(*model.Context)
+	if !ok {
+		return "", errors.New("missing request context")
+	}
+	sessionID := requestCtx.SessionID
+	// 每个用户同时进行的生成数和每日配额
+	release, err := acquireGeneration(ctx, requestCtx.Model)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	vault := s.vaults.Get(requestCtx.TenantID, sessionID)
+	query = vault.Redact(query)
+	if d := guardrail.Check(ctx, consts.GuardrailStageInput, consts.User, query); d.Blocked() {
+		return "", errors.New(d.Message())
+	}
+	budget := newTurnBudget(ctx)
+	baseTools := allowedTools(ctx, defaultTools(ctx))
+	guard := newTurnGuard(ctx, baseTools, nil)
+	runnable, err := s.buildAgent(ctx, NewChatModel(ctx), budget, baseTools,
+		auditMiddleware, budget.Middleware, quotaMiddleware(nil), guard.Middleware, piiMiddleware(vault), maskMiddleware)
+	if err != nil {
+		return "", err
+	}
+
+	messages := AgentTemplate(ctx, &v1.ChatStreamReq{
+		Query:     query,
+		SessionID: sessionID,
+	})
+	if err = attachImages(ctx, messages[len(messages)-1], images, true); err != nil {
+		return "", err
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, budget.hardRemaining())
+	defer cancel()
+	resp, err := runnable.Invoke(runCtx, messages)
+	if err != nil {
+		return "", err
+	}
+	if answer := budget.FallbackAnswer(); answer != "" {
+		resp.Content = answer
+	}
+	if guardOutput(ctx, nil, resp.Content) {
+		return "", errors.New("the answer was blocked by the content safety guardrail")
+	}
+	return vault.Restore(resp.Content), nil
+}
+
+// knowledgeRetriever 按配置创建知识库检索器，不可用时返回 nil，由 knowledge_search_tool 按关键词检索
+func knowledgeRetriever(ctx context.Context) retriever.Retriever {
+	if g.Cfg().MustGet(ctx, consts.KnowledgeRetriever, "keyword").String() != "milvus" {
+		return nil
+	}
+	// 向量库不可达时连接会一直阻塞，限制连接时间
+	connectCtx, cancel := context.WithTimeout(ctx, milvusConnectTimeout)
+	defer cancel()
+	r, err := newMilvusRetriever(connectCtx)
+	if err != nil {
+		g.Log(consts.LoggerAgent).Warningf(ctx, "vector search is unavailable, using keyword search: %v", err)
+		return nil
+	}
+	return r
+}
@@ -3,59 +3,34 @@ package agent
 import (
 	v1 "agent/api/agent/v1"
 	"agent/internal/consts"
+	"agent/internal/guardrail"
+	"agent/internal/model"
+	"agent/internal/secret"
 	"agent/internal/service"
 	"context"
 	"fmt"
-	"log"
 
 	askembedding "github.com/cloudwego/eino-ext/components/embedding/ark"
 	askmodel "github.com/cloudwego/eino-ext/components/model/ark"
 	"github.com/cloudwego/eino-ext/components/retriever/milvus"
-	mcpp "github.com/cloudwego/eino-ext/components/tool/mcp"
 	"github.com/cloudwego/eino/components/prompt"
-	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	"github.com/gogf/gf/v2/frame/g"
-	clie "github.com/mark3labs/mcp-go/client"
-	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/milvus-io/milvus-sdk-go/v2/client"
 )
 
-func GetGdMapMCPTool(ctx context.Context) tool.BaseTool {
-	url := "https://mcp.amap.com/sse?key=" + g.Cfg().MustGet(ctx, consts.McpApiKey).String()
-	cli, err := clie.NewSSEMCPClient(url)
-	if err != nil {
-		log.Fatal(err)
-	}
-	err = cli.Start(ctx)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	initRequest := mcp.InitializeRequest{}
-	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
-	initRequest.Params.ClientInfo = mcp.Implementation{
-		Name:    "example-client",
-		Version: "1.0.0",
-	}
-
-	_, err = cli.Initialize(ctx, initRequest)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	tools, err := mcpp.GetTools(ctx, &mcpp.Config{Cli: cli, ToolNameList: []string{"maps_around_search"}})
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	return tools[0]
-}
+// agentSecrets 对话模型和向量化使用的密钥
+var agentSecrets = secret.Declare("agent", consts.ApiKey)
 
+// NewChatModel 创建对话模型，请求上下文中指定了模型时使用该模型
 func NewChatModel(ctx context.Context) *askmodel.ChatModel {
+	modelName := g.Cfg().MustGet(ctx, consts.Model).String()
+	if c, ok := ctx.Value(consts.ContextKey).(*model.Context); ok && c.Model != "" {
+		modelName = c.Model
+	}
 	chatModel, err := askmodel.NewChatModel(ctx, &askmodel.ChatModelConfig{
-		APIKey: g.Cfg().MustGet(ctx, consts.ApiKey).String(),
-		Model:  g.Cfg().MustGet(ctx, consts.Model).String(),
+		APIKey: agentSecrets.Value(ctx, consts.ApiKey),
+		Model:  modelName,
 	})
 	if err != nil {
 		panic(err)
@@ -64,19 +39,32 @@ func NewChatModel(ctx context.Context) *askmodel.ChatModel {
 }
 
 func NewMilVusRetriever(ctx context.Context) *milvus.Retriever {
+	retriever, err := newMilvusRetriever(ctx)
+	if err != nil {
+		panic(err)
+	}
+	return retriever
+}
+
+// newMilvusRetriever 创建知识库的向量检索器，失败时返回错误
+func newMilvusRetriever(ctx context.Context) (*milvus.Retriever, error) {
 	cli, err := client.NewClient(ctx, client.Config{
 		Address: g.Cfg().MustGet(ctx, consts.MilvusAddr).String(),
 	})
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to connect milvus: %v", err)
 	}
 
+	apiKey, err := agentSecrets.Get(ctx, consts.ApiKey)
+	if err != nil {
+		return nil, err
+	}
 	emb, err := askembedding.NewEmbedder(ctx, &askembedding.EmbeddingConfig{
-		APIKey: g.Cfg().MustGet(ctx, consts.ApiKey).String(),
+		APIKey: apiKey,
 		Model:  g.Cfg().MustGet(ctx, consts.EmbModel).String(),
 	})
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to create embedder: %v", err)
 	}
 
 	retriever, err := milvus.NewRetriever(ctx, &milvus.RetrieverConfig{
@@ -93,9 +81,9 @@ func NewMilVusRetriever(ctx context.Context) *milvus.Retriever {
 		Embedding:      emb,
 	})
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("failed to create retriever: %v", err)
 	}
-	return retriever
+	return retriever, nil
 }
 
 func Template(ctx context.Context, in *v1.ChatStreamReq) (*prompt.DefaultChatTemplate, string) {
@@ -158,6 +146,10 @@ and List your thinking steps.
 		"history_key": sessionMessages,
 	}
 	messages, _ := template.Format(ctx, variables)
+	// 说明如何对待带有护栏标签的不可信工具结果
+	if notice := guardrail.SystemNotice(); notice != "" && len(messages) > 0 {
+		messages[0].Content += notice
+	}
 
 	return messages
 }
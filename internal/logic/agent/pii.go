@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"agent/internal/pii"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// vaultStore 各会话的敏感信息占位符映射，历史消息中保存的是占位符，需与会话同时删除。
+// 映射中保存着敏感信息原文，长时间未使用的会话按工作目录的保留时间过期
+type vaultStore struct {
+	mu     sync.Mutex
+	vaults map[string]*pii.Vault
+	used   map[string]time.Time
+}
+
+func newVaultStore() *vaultStore {
+	return &vaultStore{vaults: make(map[string]*pii.Vault), used: make(map[string]time.Time)}
+}
+
+// Get 返回会话的占位符映射，租户未开启脱敏时返回 nil
+func (s *vaultStore) Get(tenant, sessionID string) *pii.Vault {
+	if !pii.EnabledFor(tenant) {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.vaults[sessionID]
+	if !ok {
+		v = pii.NewVault(tenant)
+		s.vaults[sessionID] = v
+	}
+	s.used[sessionID] = time.Now()
+	return v
+}
+
+// Delete 删除会话的占位符映射
+func (s *vaultStore) Delete(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.vaults, sessionID)
+	delete(s.used, sessionID)
+}
+
+// Expire 删除超过 ttl 未使用的占位符映射，返回被删除的会话
+func (s *vaultStore) Expire(ttl time.Duration) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	deadline := time.Now().Add(-ttl)
+	var expired []string
+	for sessionID, used := range s.used {
+		if used.Before(deadline) {
+			delete(s.vaults, sessionID)
+			delete(s.used, sessionID)
+			expired = append(expired, sessionID)
+		}
+	}
+	return expired
+}
+
+// piiMiddleware 工具参数中的占位符还原为原文后执行工具，工具结果中的敏感信息替换为占位符后再交给模型
+func piiMiddleware(vault *pii.Vault) toolMiddleware {
+	return func(next toolEndpoint) toolEndpoint {
+		return func(ctx context.Context, name, argumentsInJSON string, opts ...tool.Option) (string, error) {
+			if vault == nil {
+				return next(ctx, name, argumentsInJSON, opts...)
+			}
+			output, err := next(ctx, name, vault.Restore(argumentsInJSON), opts...)
+			return vault.Redact(output), err
+		}
+	}
+}
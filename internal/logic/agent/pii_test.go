@@ -0,0 +1,25 @@
+package agent
+
+import (
+	"agent/internal/pii"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestVaultStore_Expire(t1 *testing.T) {
+	s := newVaultStore()
+	s.vaults["idle"], s.used["idle"] = pii.NewVault("t1"), time.Now().Add(-2*time.Hour)
+	s.vaults["active"], s.used["active"] = pii.NewVault("t1"), time.Now()
+
+	expired := s.Expire(time.Hour)
+	if !slices.Equal(expired, []string{"idle"}) {
+		t1.Errorf("Expire() = %v, want [idle]", expired)
+	}
+	if _, ok := s.vaults["idle"]; ok {
+		t1.Errorf("Expire() kept the idle vault")
+	}
+	if _, ok := s.vaults["active"]; !ok {
+		t1.Errorf("Expire() removed the active vault")
+	}
+}
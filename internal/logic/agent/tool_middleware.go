@@ -0,0 +1,74 @@
+package agent
+
+import (
+	"agent/internal/logging"
+	"agent/internal/secret"
+	"context"
+	"errors"
+
+	"github.com/cloudwego/eino/components/tool"
+)
+
+// toolEndpoint 一次工具调用的执行函数
+type toolEndpoint func(ctx context.Context, name, argumentsInJSON string, opts ...tool.Option) (string, error)
+
+// toolMiddleware 工具调用中间件，用于在工具执行前后插入预算、审批等逻辑
+type toolMiddleware func(next toolEndpoint) toolEndpoint
+
+// wrappedTool 包装后的工具，Info 保持不变，InvokableRun 经过中间件链
+type wrappedTool struct {
+	tool.InvokableTool
+	name     string
+	endpoint toolEndpoint
+}
+
+func (w *wrappedTool) InvokableRun(ctx context.Context, argumentsInJSON string, opts ...tool.Option) (string, error) {
+	// 中间件和工具内的日志都带上工具名
+	ctx = logging.WithTool(ctx, w.name)
+	return w.endpoint(ctx, w.name, argumentsInJSON, opts...)
+}
+
+// maskMiddleware 工具结果和错误中的密钥替换为 ******，再交给模型和其他中间件；应位于最内层
+func maskMiddleware(next toolEndpoint) toolEndpoint {
+	return func(ctx context.Context, name, argumentsInJSON string, opts ...tool.Option) (string, error) {
+		output, err := next(ctx, name, argumentsInJSON, opts...)
+		if err != nil {
+			// 只在包含密钥时替换错误，保留中断等错误的类型
+			if masked := secret.Mask(err.Error()); masked != err.Error() {
+				err = errors.New(masked)
+			}
+		}
+		return secret.Mask(output), err
+	}
+}
+
+// wrapTools 为工具挂载中间件，先传入的中间件位于最外层
+func wrapTools(ctx context.Context, tools []tool.BaseTool, mws ...toolMiddleware) ([]tool.BaseTool, error) {
+	wrapped := make([]tool.BaseTool, 0, len(tools))
+	for _, t := range tools {
+		it, ok := t.(tool.InvokableTool)
+		if !ok {
+			wrapped = append(wrapped, t)
+			continue
+		}
+
+		info, err := it.Info(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		endpoint := func(ctx context.Context, _, argumentsInJSON string, opts ...tool.Option) (string, error) {
+			return it.InvokableRun(ctx, argumentsInJSON, opts...)
+		}
+		for i := len(mws) - 1; i >= 0; i-- {
+			endpoint = mws[i](endpoint)
+		}
+
+		wrapped = append(wrapped, &wrappedTool{
+			InvokableTool: it,
+			name:          info.Name,
+			endpoint:      endpoint,
+		})
+	}
+	return wrapped, nil
+}
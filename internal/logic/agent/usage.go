@@ -0,0 +1,96 @@
+package agent
+
+import (
+	v1 "agent/api/agent/v1"
+	"agent/internal/auth"
+	"agent/internal/dao"
+	"agent/internal/model/do"
+	"agent/internal/usage"
+	"context"
+	"fmt"
+
+	"github.com/gogf/gf/v2/database/gdb"
+)
+
+// Usage 按时间范围汇总 token 用量和费用，按 group_by 指定的维度分组；非管理员只能查看自己的用量
+func (s *sAgent) Usage(ctx context.Context, in *v1.UsageReq) (out *v1.UsageRes, err error) {
+	if identity := auth.FromCtx(ctx); !identity.Admin {
+		in.TenantID, in.UserID = identity.TenantID, identity.UserID
+		if in.SessionID != "" {
+			in.SessionID = identity.Scope(in.SessionID)
+		}
+	}
+	cols := dao.TokenUsage.Columns()
+	groupColumns := map[string]string{
+		"session": cols.SessionId,
+		"user":    cols.UserId,
+		"tenant":  cols.TenantId,
+		"agent":   cols.Agent,
+		"model":   cols.Model,
+		"kind":    cols.Kind,
+		"day":     fmt.Sprintf("DATE(`%s`)", cols.CreatedAt),
+	}
+	groupColumn, ok := groupColumns[in.GroupBy]
+	if !ok {
+		return nil, fmt.Errorf("unsupported group_by: %s", in.GroupBy)
+	}
+	if groupColumn != groupColumns["day"] {
+		groupColumn = "`" + groupColumn + "`"
+	}
+
+	// 1. 时间范围和过滤条件
+	m := dao.TokenUsage.Ctx(ctx).OmitEmptyWhere().Where(do.TokenUsage{
+		SessionId: in.SessionID,
+		UserId:    in.UserID,
+		TenantId:  in.TenantID,
+		Agent:     in.Agent,
+		Model:     in.Model,
+	})
+	if in.From != nil {
+		m = m.WhereGTE(cols.CreatedAt, in.From)
+	}
+	if in.To != nil {
+		m = m.WhereLT(cols.CreatedAt, in.To)
+	}
+	m = m.FieldCount("*", "calls").
+		FieldSum(cols.PromptTokens, "prompt_tokens").
+		FieldSum(cols.CompletionTokens, "completion_tokens").
+		FieldSum(cols.TotalTokens, "total_tokens").
+		FieldSum(cols.Cost, "cost")
+
+	// 2. 合计
+	out = &v1.UsageRes{
+		GroupBy:  in.GroupBy,
+		Currency: usage.LoadConfig(ctx).Currency,
+		Total:    &v1.UsageStat{},
+		Groups:   []*v1.UsageStat{},
+	}
+	if err = m.Scan(out.Total); err != nil {
+		return nil, fmt.Errorf("failed to query usage: %v", err)
+	}
+
+	// 3. 分组
+	grouped := m.Fields(gdb.Raw(groupColumn + " AS `key`")).Group("key")
+	if in.GroupBy == "day" {
+		grouped = grouped.OrderAsc("key")
+	} else {
+		grouped = grouped.OrderDesc("total_tokens")
+	}
+	if err = grouped.Scan(&out.Groups); err != nil {
+		return nil, fmt.Errorf("failed to query usage: %v", err)
+	}
+	return out, nil
+}
+
+// usageEvent 一轮对话的用量事件
+func usageEvent(ctx context.Context, summary usage.Summary) *v1.UsageEvent {
+	return &v1.UsageEvent{
+		Calls:            summary.Calls,
+		EmbeddingCalls:   summary.EmbeddingCalls,
+		PromptTokens:     summary.PromptTokens,
+		CompletionTokens: summary.CompletionTokens,
+		TotalTokens:      summary.TotalTokens,
+		Cost:             summary.Cost,
+		Currency:         usage.LoadConfig(ctx).Currency,
+	}
+}
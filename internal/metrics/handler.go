@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+)
+
+// call 一次组件调用的开始时间和模型名，放在回调上下文中
+type call struct {
+	start time.Time
+	model string
+}
+
+// callKey 上下文中当前组件 *call 的键
+type callKey struct{}
+
+// handler 统计模型、工具和检索调用的次数、耗时和错误
+type handler struct{}
+
+// NewHandler 创建输出 Prometheus 指标的 Eino 回调
+func NewHandler() callbacks.Handler {
+	return &handler{}
+}
+
+// Needed 只处理模型、工具和检索组件
+func (h *handler) Needed(_ context.Context, info *callbacks.RunInfo, _ callbacks.CallbackTiming) bool {
+	if info == nil {
+		return false
+	}
+	switch info.Component {
+	case components.ComponentOfChatModel, components.ComponentOfTool, components.ComponentOfRetriever:
+		return true
+	}
+	return false
+}
+
+func (h *handler) OnStart(ctx context.Context, info *callbacks.RunInfo, input callbacks.CallbackInput) context.Context {
+	c := &call{start: time.Now(), model: info.Type}
+	if info.Component == components.ComponentOfChatModel {
+		if in := model.ConvCallbackInput(input); in != nil && in.Config != nil && in.Config.Model != "" {
+			c.model = in.Config.Model
+		}
+	}
+	return context.WithValue(ctx, callKey{}, c)
+}
+
+func (h *handler) OnEnd(ctx context.Context, info *callbacks.RunInfo, output callbacks.CallbackOutput) context.Context {
+	c, ok := ctx.Value(callKey{}).(*call)
+	if !ok {
+		return ctx
+	}
+	switch info.Component {
+	case components.ComponentOfChatModel:
+		observeModel(c, model.ConvCallbackOutput(output), nil)
+	case components.ComponentOfTool:
+		status := StatusOK
+		if out := tool.ConvCallbackOutput(output); out != nil && strings.HasPrefix(out.Response, "Error") {
+			// 工具将可恢复的错误作为结果返回给模型
+			status = StatusError
+		}
+		observeTool(c, info, status)
+	case components.ComponentOfRetriever:
+		result := ResultMiss
+		if out := retriever.ConvCallbackOutput(output); out != nil && len(out.Docs) > 0 {
+			result = ResultHit
+		}
+		observeRetrieval(c, info, result)
+	}
+	return ctx
+}
+
+func (h *handler) OnError(ctx context.Context, info *callbacks.RunInfo, err error) context.Context {
+	c, ok := ctx.Value(callKey{}).(*call)
+	if !ok {
+		return ctx
+	}
+	switch info.Component {
+	case components.ComponentOfChatModel:
+		observeModel(c, nil, err)
+	case components.ComponentOfTool:
+		observeTool(c, info, StatusError)
+	case components.ComponentOfRetriever:
+		observeRetrieval(c, info, ResultError)
+	}
+	return ctx
+}
+
+func (h *handler) OnStartWithStreamInput(ctx context.Context, info *callbacks.RunInfo,
+	input *schema.StreamReader[callbacks.CallbackInput]) context.Context {
+	input.Close()
+	return context.WithValue(ctx, callKey{}, &call{start: time.Now(), model: info.Type})
+}
+
+// OnEndWithStreamOutput 在后台读完输出流后记录耗时，流式模型调用在输出结束时才算完成
+func (h *handler) OnEndWithStreamOutput(ctx context.Context, info *callbacks.RunInfo,
+	output *schema.StreamReader[callbacks.CallbackOutput]) context.Context {
+	c, ok := ctx.Value(callKey{}).(*call)
+	if !ok {
+		output.Close()
+		return ctx
+	}
+	go func() {
+		defer output.Close()
+		var last *model.CallbackOutput
+		var streamErr error
+		var toolErr bool
+		for {
+			frame, err := output.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				streamErr = err
+				break
+			}
+			switch info.Component {
+			case components.ComponentOfChatModel:
+				if out := model.ConvCallbackOutput(frame); out != nil && out.Config != nil {
+					last = out
+				}
+			case components.ComponentOfTool:
+				if out := tool.ConvCallbackOutput(frame); out != nil && strings.HasPrefix(out.Response, "Error") {
+					toolErr = true
+				}
+			}
+		}
+		switch info.Component {
+		case components.ComponentOfChatModel:
+			observeModel(c, last, streamErr)
+		case components.ComponentOfTool:
+			status := StatusOK
+			if streamErr != nil || toolErr {
+				status = StatusError
+			}
+			observeTool(c, info, status)
+		}
+	}()
+	return ctx
+}
+
+func observeModel(c *call, out *model.CallbackOutput, err error) {
+	name := c.model
+	if out != nil && out.Config != nil && out.Config.Model != "" {
+		name = out.Config.Model
+	}
+	status := StatusOK
+	if err != nil {
+		status = StatusError
+	}
+	modelCalls.WithLabelValues(name, status).Inc()
+	modelDuration.WithLabelValues(name).Observe(time.Since(c.start).Seconds())
+}
+
+func observeTool(c *call, info *callbacks.RunInfo, status string) {
+	toolCalls.WithLabelValues(info.Name, status).Inc()
+	toolDuration.WithLabelValues(info.Name).Observe(time.Since(c.start).Seconds())
+}
+
+func observeRetrieval(c *call, info *callbacks.RunInfo, result string) {
+	name := info.Name
+	if name == "" {
+		name = info.Type
+	}
+	retrievals.WithLabelValues(name, result).Inc()
+	retrievalDuration.WithLabelValues(name).Observe(time.Since(c.start).Seconds())
+}
@@ -0,0 +1,132 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+var (
+	modelInfo     = &callbacks.RunInfo{Name: "ChatModel", Type: "Ark", Component: components.ComponentOfChatModel}
+	toolInfo      = &callbacks.RunInfo{Name: "web_search_tool", Component: components.ComponentOfTool}
+	retrieverInfo = &callbacks.RunInfo{Name: "knowledge_base", Type: "Milvus", Component: components.ComponentOfRetriever}
+)
+
+func TestHandler(t1 *testing.T) {
+	h := NewHandler()
+	ctx := context.Background()
+	tests := []struct {
+		name  string
+		run   func()
+		count func() float64
+		want  float64
+	}{
+		{
+			name: "model ok",
+			run: func() {
+				ctx := h.OnStart(ctx, modelInfo, &model.CallbackInput{Config: &model.Config{Model: "m-ok"}})
+				h.OnEnd(ctx, modelInfo, &model.CallbackOutput{Message: schema.AssistantMessage("hi", nil)})
+			},
+			count: func() float64 { return testutil.ToFloat64(modelCalls.WithLabelValues("m-ok", StatusOK)) },
+			want:  1,
+		},
+		{
+			name: "model error",
+			run: func() {
+				ctx := h.OnStart(ctx, modelInfo, &model.CallbackInput{Config: &model.Config{Model: "m-err"}})
+				h.OnError(ctx, modelInfo, errors.New("rate limited"))
+			},
+			count: func() float64 { return testutil.ToFloat64(modelCalls.WithLabelValues("m-err", StatusError)) },
+			want:  1,
+		},
+		{
+			name: "streaming model",
+			run: func() {
+				ctx := h.OnStart(ctx, modelInfo, &model.CallbackInput{})
+				sr, sw := schema.Pipe[callbacks.CallbackOutput](2)
+				sw.Send(&model.CallbackOutput{Message: schema.AssistantMessage("a", nil), Config: &model.Config{Model: "m-stream"}}, nil)
+				sw.Close()
+				h.OnEndWithStreamOutput(ctx, modelInfo, sr)
+			},
+			count: func() float64 { return testutil.ToFloat64(modelCalls.WithLabelValues("m-stream", StatusOK)) },
+			want:  1,
+		},
+		{
+			name: "tool error result",
+			run: func() {
+				ctx := h.OnStart(ctx, toolInfo, &tool.CallbackInput{ArgumentsInJSON: `{}`})
+				h.OnEnd(ctx, toolInfo, &tool.CallbackOutput{Response: "Error searching: timeout"})
+			},
+			count: func() float64 { return testutil.ToFloat64(toolCalls.WithLabelValues("web_search_tool", StatusError)) },
+			want:  1,
+		},
+		{
+			name: "retrieval hit",
+			run: func() {
+				ctx := h.OnStart(ctx, retrieverInfo, &retriever.CallbackInput{Query: "go"})
+				h.OnEnd(ctx, retrieverInfo, &retriever.CallbackOutput{Docs: []*schema.Document{{Content: "doc"}}})
+			},
+			count: func() float64 { return testutil.ToFloat64(retrievals.WithLabelValues("knowledge_base", ResultHit)) },
+			want:  1,
+		},
+		{
+			name: "retrieval miss",
+			run: func() {
+				ctx := h.OnStart(ctx, retrieverInfo, &retriever.CallbackInput{Query: "go"})
+				h.OnEnd(ctx, retrieverInfo, &retriever.CallbackOutput{})
+			},
+			count: func() float64 { return testutil.ToFloat64(retrievals.WithLabelValues("knowledge_base", ResultMiss)) },
+			want:  1,
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			tt.run()
+			// 流式输出在后台统计
+			got := tt.count()
+			for i := 0; i < 100 && got != tt.want; i++ {
+				time.Sleep(10 * time.Millisecond)
+				got = tt.count()
+			}
+			if got != tt.want {
+				t1.Errorf("count = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStream(t1 *testing.T) {
+	ctx, stream := StartStream(context.Background(), "test")
+	if got := testutil.ToFloat64(activeStreams.WithLabelValues("test")); got != 1 {
+		t1.Errorf("active streams = %v, want 1", got)
+	}
+	StreamFromCtx(ctx).FirstToken()
+	StreamFromCtx(ctx).FirstToken()
+	stream.End()
+	stream.End()
+	if got := testutil.ToFloat64(activeStreams.WithLabelValues("test")); got != 0 {
+		t1.Errorf("active streams = %v, want 0", got)
+	}
+	if got := testutil.CollectAndCount(timeToFirstToken); got != 1 {
+		t1.Errorf("time to first token series = %d, want 1", got)
+	}
+	// 不在流式对话中时不记录
+	StreamFromCtx(context.Background()).FirstToken()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(rec.Body.String(), `agent_stream_duration_seconds_count{agent="test"} 1`) {
+		t1.Errorf("metrics output does not contain the stream duration:\n%s", rec.Body.String())
+	}
+}
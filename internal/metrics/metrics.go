@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/gogf/gf/v2/net/ghttp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "agent"
+
+// 状态标签的取值
+const (
+	StatusOK    = "ok"
+	StatusError = "error"
+
+	ResultHit   = "hit"
+	ResultMiss  = "miss"
+	ResultError = "error"
+)
+
+var (
+	// registry 本服务的指标注册表，包含 Go 运行时和进程指标
+	registry = prometheus.NewRegistry()
+
+	// 模型调用可能持续数十秒，流式对话可能持续数分钟
+	modelBuckets  = []float64{0.1, 0.25, 0.5, 1, 2, 5, 10, 20, 30, 60, 120}
+	streamBuckets = []float64{0.5, 1, 2, 5, 10, 20, 30, 60, 120, 300, 600}
+
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "code"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request duration by method and route, SSE requests last until the stream ends.",
+		Buckets:   streamBuckets,
+	}, []string{"method", "route"})
+
+	activeStreams = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "active_streams",
+		Help:      "SSE streams currently open by agent.",
+	}, []string{"agent"})
+	timeToFirstToken = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stream_time_to_first_token_seconds",
+		Help:      "Time from the start of a turn to the first model content sent to the client.",
+		Buckets:   modelBuckets,
+	}, []string{"agent"})
+	streamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "stream_duration_seconds",
+		Help:      "Total duration of a streamed turn.",
+		Buckets:   streamBuckets,
+	}, []string{"agent"})
+
+	modelCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "model_calls_total",
+		Help:      "Chat model calls by model and status.",
+	}, []string{"model", "status"})
+	modelDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "model_call_duration_seconds",
+		Help:      "Chat model call latency by model, streamed calls end when the output stream is drained.",
+		Buckets:   modelBuckets,
+	}, []string{"model"})
+
+	toolCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tool_calls_total",
+		Help:      "Tool invocations by tool name and status, tool results starting with \"Error\" count as errors.",
+	}, []string{"tool", "status"})
+	toolDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "tool_call_duration_seconds",
+		Help:      "Tool invocation latency by tool name.",
+		Buckets:   modelBuckets,
+	}, []string{"tool"})
+
+	retrievals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "retrievals_total",
+		Help:      "RAG retrievals by retriever and result: hit (documents found), miss or error.",
+	}, []string{"retriever", "result"})
+	retrievalDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "retrieval_duration_seconds",
+		Help:      "RAG retrieval latency by retriever, including the query embedding.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"retriever"})
+
+	tokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_total",
+		Help:      "Tokens consumed by model, call kind (chat / embedding) and type (prompt / completion).",
+	}, []string{"model", "kind", "type"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration,
+		activeStreams, timeToFirstToken, streamDuration,
+		modelCalls, modelDuration,
+		toolCalls, toolDuration,
+		retrievals, retrievalDuration,
+		tokens,
+	)
+}
+
+// Init 注册统计模型、工具和检索调用的 Eino 全局回调，只应在启动时调用一次
+func Init() {
+	callbacks.AppendGlobalHandlers(NewHandler())
+}
+
+// Handler 以 Prometheus 文本格式输出指标
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{Registry: registry})
+}
+
+// Middleware 统计每个路由的请求数和耗时，路由取注册的路径避免标签过多
+func Middleware(r *ghttp.Request) {
+	start := time.Now()
+	r.Middleware.Next()
+	route := "unmatched"
+	if r.Router != nil {
+		route = r.Router.Uri
+	}
+	httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(r.Response.Status)).Inc()
+	httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+}
+
+// ObserveTokens 累计一次模型或向量化调用的 token 数
+func ObserveTokens(model, kind string, promptTokens, completionTokens int) {
+	tokens.WithLabelValues(model, kind, "prompt").Add(float64(promptTokens))
+	if completionTokens > 0 {
+		tokens.WithLabelValues(model, kind, "completion").Add(float64(completionTokens))
+	}
+}
+
+// Stream 一次 SSE 流式对话，统计首个 token 时间和总时长
+type Stream struct {
+	agent string
+	start time.Time
+	first sync.Once
+	end   sync.Once
+}
+
+// streamKey 上下文中 *Stream 的键
+type streamKey struct{}
+
+// StartStream 开始统计一次流式对话，返回的上下文用于记录首个 token
+func StartStream(ctx context.Context, agent string) (context.Context, *Stream) {
+	s := &Stream{agent: agent, start: time.Now()}
+	activeStreams.WithLabelValues(agent).Inc()
+	return context.WithValue(ctx, streamKey{}, s), s
+}
+
+// StreamFromCtx 当前上下文所属的流式对话，不在流式对话中时返回 nil
+func StreamFromCtx(ctx context.Context) *Stream {
+	s, _ := ctx.Value(streamKey{}).(*Stream)
+	return s
+}
+
+// FirstToken 记录首个内容发送给客户端的时间，只有第一次调用生效
+func (s *Stream) FirstToken() {
+	if s == nil {
+		return
+	}
+	s.first.Do(func() {
+		timeToFirstToken.WithLabelValues(s.agent).Observe(time.Since(s.start).Seconds())
+	})
+}
+
+// End 结束流式对话，可重复调用
+func (s *Stream) End() {
+	if s == nil {
+		return
+	}
+	s.end.Do(func() {
+		activeStreams.WithLabelValues(s.agent).Dec()
+		streamDuration.WithLabelValues(s.agent).Observe(time.Since(s.start).Seconds())
+	})
+}
@@ -0,0 +1,10 @@
+package model
+
+// Context 请求上下文中的业务数据，通过 consts.ContextKey 存取
+type Context struct {
+	SessionID string // 当前会话 ID，开启认证时已按租户和用户隔离
+	UserID    string // 当前用户 ID
+	TenantID  string // 当前租户 ID
+	Agent     string // 当前运行的 Agent：chain / react / mcp
+	Model     string // 本次对话使用的模型，为空时使用 ai.model
+}
@@ -0,0 +1,170 @@
+package pii
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// 敏感信息类型
+const (
+	TypePhone   = "phone"
+	TypeEmail   = "email"
+	TypeIDCard  = "id_card"
+	TypeAddress = "address"
+	TypeName    = "name"
+)
+
+// AllTypes 内置的敏感信息类型
+var AllTypes = []string{TypePhone, TypeEmail, TypeIDCard, TypeAddress, TypeName}
+
+// detector 一种敏感信息的识别规则
+type detector struct {
+	typ   string
+	re    *regexp.Regexp
+	group int               // 替换的分组，0 表示整个匹配，如姓名只替换“叫”之后的部分
+	valid func(string) bool // 进一步校验，减少误判
+}
+
+// builtinDetectors 内置识别规则，地址和姓名按常见表述识别，无法覆盖所有写法
+var builtinDetectors = map[string][]*detector{
+	TypePhone: {
+		// 手机号，可带 +86 和空格或短横线分隔
+		{re: regexp.MustCompile(`(?:\+86[\s-]?|\b)1[3-9]\d(?:[\s-]?\d{4}){2}\b`)},
+		// 固定电话
+		{re: regexp.MustCompile(`\b0\d{2,3}-\d{7,8}\b`)},
+	},
+	TypeEmail: {
+		{re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)},
+	},
+	TypeIDCard: {
+		{re: regexp.MustCompile(`\b[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]\b`), valid: validIDCard},
+	},
+	TypeAddress: {
+		// 中文地址：可选的省市区前缀 + 路街巷 + 门牌号，以及楼栋、单元和房间号
+		{re: regexp.MustCompile(`(?:\p{Han}{2,3}(?:省|自治区))?(?:\p{Han}{2,3}(?:市|州))?(?:\p{Han}{1,4}(?:区|县|镇))?\p{Han}{1,8}?(?:路|街|大道|巷|胡同|弄)\d+(?:号|弄)(?:(?:\d+|[一二三四五六七八九十]+)(?:号楼|栋|幢|楼|单元|室|号))*`)},
+		// 英文地址：门牌号 + 街道名 + 街道类型
+		{re: regexp.MustCompile(`\b\d{1,5}\s+(?:[A-Z][a-z]+\s+){1,3}(?:Street|St|Avenue|Ave|Road|Rd|Boulevard|Blvd|Lane|Ln|Drive|Dr|Court|Ct)\b\.?`)},
+	},
+	TypeName: {
+		// 中文：我叫张三、男朋友叫李四、名字是王五
+		{re: regexp.MustCompile(`(?:我叫|他叫|她叫|名叫|叫做|名字是|名字叫|朋友叫|对象叫|老公叫|老婆叫|前任叫|同事叫|闺蜜叫)(\p{Han}{2,3})`), group: 1},
+		// 英文：my name is John Smith、my boyfriend is called Tom
+		{re: regexp.MustCompile(`\b(?:[Mm]y name is|[Nn]amed|[Cc]alled)\s+([A-Z][a-z]+(?:\s[A-Z][a-z]+)?)\b`), group: 1},
+	},
+}
+
+// idCardWeights 身份证校验码的加权因子
+var idCardWeights = []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+
+// validIDCard 校验 18 位身份证号的校验码
+func validIDCard(s string) bool {
+	sum := 0
+	for i, w := range idCardWeights {
+		sum += int(s[i]-'0') * w
+	}
+	return strings.ToUpper(s[17:]) == string("10X98765432"[sum%11])
+}
+
+// PatternConfig 自定义识别规则，pattern 中有分组时只替换第一个分组
+type PatternConfig struct {
+	Type    string `json:"type"`
+	Pattern string `json:"pattern"`
+}
+
+// newDetectors 按启用的类型、自定义规则和姓名列表创建识别规则
+func newDetectors(types []string, patterns []*PatternConfig, names []string) ([]*detector, error) {
+	var detectors []*detector
+	for _, typ := range types {
+		builtin, ok := builtinDetectors[typ]
+		if !ok {
+			return nil, fmt.Errorf("unsupported pii type: %s", typ)
+		}
+		for _, d := range builtin {
+			detectors = append(detectors, &detector{typ: typ, re: d.re, group: d.group, valid: d.valid})
+		}
+	}
+	for _, p := range patterns {
+		if p.Type == "" {
+			return nil, fmt.Errorf("type of pii pattern %q is required", p.Pattern)
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile pii pattern %q: %v", p.Pattern, err)
+		}
+		group := 0
+		if re.NumSubexp() > 0 {
+			group = 1
+		}
+		detectors = append(detectors, &detector{typ: p.Type, re: re, group: group})
+	}
+	if len(names) > 0 {
+		quoted := make([]string, 0, len(names))
+		for _, name := range names {
+			if name = strings.TrimSpace(name); name != "" {
+				quoted = append(quoted, regexp.QuoteMeta(name))
+			}
+		}
+		// 长的姓名优先匹配
+		sort.Slice(quoted, func(i, j int) bool { return len(quoted[i]) > len(quoted[j]) })
+		if len(quoted) > 0 {
+			detectors = append(detectors, &detector{typ: TypeName, re: regexp.MustCompile(strings.Join(quoted, "|"))})
+		}
+	}
+	return detectors, nil
+}
+
+// span 文本中识别出的一段敏感信息
+type span struct {
+	start, end int
+	typ        string
+}
+
+// detect 识别文本中的敏感信息，重叠时保留开始位置靠前、长度更长的一段
+func detect(detectors []*detector, text string) []span {
+	var spans []span
+	for _, d := range detectors {
+		for _, m := range d.re.FindAllStringSubmatchIndex(text, -1) {
+			start, end := m[2*d.group], m[2*d.group+1]
+			if start < 0 || start == end {
+				continue
+			}
+			if d.valid != nil && !d.valid(text[start:end]) {
+				continue
+			}
+			spans = append(spans, span{start: start, end: end, typ: d.typ})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].start != spans[j].start {
+			return spans[i].start < spans[j].start
+		}
+		return spans[i].end > spans[j].end
+	})
+	merged := spans[:0]
+	for _, s := range spans {
+		if len(merged) > 0 && s.start < merged[len(merged)-1].end {
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
+
+// replace 将识别出的敏感信息替换为 fn 的返回值
+func replace(detectors []*detector, text string, fn func(typ, value string) string) string {
+	spans := detect(detectors, text)
+	if len(spans) == 0 {
+		return text
+	}
+	var b strings.Builder
+	last := 0
+	for _, s := range spans {
+		b.WriteString(text[last:s.start])
+		b.WriteString(fn(s.typ, text[s.start:s.end]))
+		last = s.end
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
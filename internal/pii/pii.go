@@ -0,0 +1,175 @@
+package pii
+
+import (
+	"agent/internal/consts"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// Config 敏感信息脱敏配置
+type Config struct {
+	Tenants  []string         // 开启脱敏的租户，"*" 表示所有租户，为空时不脱敏
+	Types    []string         // 启用的内置类型
+	Names    []string         // 需要脱敏的姓名
+	Patterns []*PatternConfig // 自定义识别规则
+}
+
+// LoadConfig 读取脱敏配置
+func LoadConfig(ctx context.Context) (*Config, error) {
+	cfg := &Config{
+		Tenants: g.Cfg().MustGet(ctx, consts.PIITenants).Strings(),
+		Types:   g.Cfg().MustGet(ctx, consts.PIITypes, AllTypes).Strings(),
+		Names:   g.Cfg().MustGet(ctx, consts.PIINames).Strings(),
+	}
+	if err := g.Cfg().MustGet(ctx, consts.PIIPatterns).Scan(&cfg.Patterns); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", consts.PIIPatterns, err)
+	}
+	return cfg, nil
+}
+
+var (
+	current   = &Config{}
+	detectors []*detector
+)
+
+// Init 按配置创建识别规则，需在处理请求和输出日志之前调用
+func Init(ctx context.Context) error {
+	cfg, err := LoadConfig(ctx)
+	if err != nil {
+		return err
+	}
+	d, err := newDetectors(cfg.Types, cfg.Patterns, cfg.Names)
+	if err != nil {
+		return err
+	}
+	current, detectors = cfg, d
+	if len(cfg.Tenants) > 0 {
+		g.Log().Infof(ctx, "PII redaction enabled for tenants %v with types %v", cfg.Tenants, cfg.Types)
+	}
+	return nil
+}
+
+// EnabledFor 租户是否开启了脱敏
+func EnabledFor(tenant string) bool {
+	return slices.Contains(current.Tenants, "*") || (tenant != "" && slices.Contains(current.Tenants, tenant))
+}
+
+// Mask 不可逆地隐藏敏感信息，如 [PHONE]，用于日志
+func Mask(text string) string {
+	return replace(detectors, text, func(typ, _ string) string {
+		return "[" + strings.ToUpper(typ) + "]"
+	})
+}
+
+// placeholderPattern 占位符，如 [PHONE_1]
+var placeholderPattern = regexp.MustCompile(`\[[A-Z][A-Z_]*_\d+\]`)
+
+// Vault 一个会话中敏感信息和占位符的双向映射，同一内容在会话中始终对应同一占位符；nil 表示不脱敏
+type Vault struct {
+	mu       sync.Mutex
+	byValue  map[string]string // 原文 -> 占位符
+	byHolder map[string]string // 占位符 -> 原文
+	counts   map[string]int    // 类型 -> 已分配的序号
+	values   []string          // 已登记的原文，按长度从长到短
+}
+
+// NewVault 租户开启脱敏时创建映射，否则返回 nil
+func NewVault(tenant string) *Vault {
+	if !EnabledFor(tenant) {
+		return nil
+	}
+	return &Vault{
+		byValue:  make(map[string]string),
+		byHolder: make(map[string]string),
+		counts:   make(map[string]int),
+	}
+}
+
+// Redact 将敏感信息替换为占位符；已登记过的原文即使没有被规则识别（如单独出现的姓名）也会被替换
+func (v *Vault) Redact(text string) string {
+	if v == nil || text == "" {
+		return text
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	text = replace(detectors, text, v.placeholder)
+	for _, value := range v.values {
+		if strings.Contains(text, value) {
+			text = strings.ReplaceAll(text, value, v.byValue[value])
+		}
+	}
+	return text
+}
+
+// placeholder 返回原文对应的占位符，没有时分配一个新的
+func (v *Vault) placeholder(typ, value string) string {
+	if holder, ok := v.byValue[value]; ok {
+		return holder
+	}
+	v.counts[typ]++
+	holder := fmt.Sprintf("[%s_%d]", strings.ToUpper(typ), v.counts[typ])
+	v.byValue[value] = holder
+	v.byHolder[holder] = value
+	i := sort.Search(len(v.values), func(i int) bool { return len(v.values[i]) < len(value) })
+	v.values = slices.Insert(v.values, i, value)
+	return holder
+}
+
+// Restore 将占位符还原为原文，未知的占位符保持不变
+func (v *Vault) Restore(text string) string {
+	if v == nil || !strings.Contains(text, "[") {
+		return text
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return placeholderPattern.ReplaceAllStringFunc(text, func(holder string) string {
+		if value, ok := v.byHolder[holder]; ok {
+			return value
+		}
+		return holder
+	})
+}
+
+// maxPlaceholderLen 流式输出中等待占位符补全的最大长度
+const maxPlaceholderLen = 32
+
+// partialPlaceholder 可能是占位符开头的内容
+var partialPlaceholder = regexp.MustCompile(`^\[[A-Z_]*\d*$`)
+
+// Restorer 还原流式输出中的占位符，占位符可能被拆分在多个数据块中
+type Restorer struct {
+	vault   *Vault
+	pending string
+}
+
+// NewRestorer 创建流式还原器，vault 为 nil 时原样输出
+func (v *Vault) NewRestorer() *Restorer {
+	return &Restorer{vault: v}
+}
+
+// Write 写入一个数据块，返回可以输出的已还原内容，末尾可能未完整的占位符留到下一个数据块
+func (r *Restorer) Write(chunk string) string {
+	if r.vault == nil {
+		return chunk
+	}
+	text := r.pending + chunk
+	r.pending = ""
+	if i := strings.LastIndex(text, "["); i >= 0 && len(text)-i <= maxPlaceholderLen && partialPlaceholder.MatchString(text[i:]) {
+		text, r.pending = text[:i], text[i:]
+	}
+	return r.vault.Restore(text)
+}
+
+// Flush 输出剩余的内容，流结束时调用
+func (r *Restorer) Flush() string {
+	text := r.pending
+	r.pending = ""
+	return r.vault.Restore(text)
+}
@@ -0,0 +1,164 @@
+package pii
+
+import (
+	"testing"
+)
+
+// useConfig 测试期间使用给定的配置
+func useConfig(t1 *testing.T, cfg *Config) {
+	d, err := newDetectors(cfg.Types, cfg.Patterns, cfg.Names)
+	if err != nil {
+		t1.Fatal(err)
+	}
+	oldCfg, oldDetectors := current, detectors
+	current, detectors = cfg, d
+	t1.Cleanup(func() { current, detectors = oldCfg, oldDetectors })
+}
+
+func TestMask(t1 *testing.T) {
+	useConfig(t1, &Config{
+		Types:    AllTypes,
+		Names:    []string{"欧阳娜娜"},
+		Patterns: []*PatternConfig{{Type: "order_no", Pattern: `订单号\s*(SO\d{6})`}},
+	})
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{name: "plain", text: "北京今天天气怎么样", want: "北京今天天气怎么样"},
+		{name: "mobile", text: "我的手机号是13812345678，请回电", want: "我的手机号是[PHONE]，请回电"},
+		{name: "mobile with prefix", text: "call +86 138-1234-5678 now", want: "call [PHONE] now"},
+		{name: "landline", text: "座机 010-12345678", want: "座机 [PHONE]"},
+		{name: "email", text: "send to john.doe@example.com please", want: "send to [EMAIL] please"},
+		{name: "id card", text: "身份证 11010519491231002X", want: "身份证 [ID_CARD]"},
+		{name: "id card with bad checksum", text: "编号 110105194912310021", want: "编号 110105194912310021"},
+		{name: "chinese address", text: "收货地址：北京市朝阳区建国路88号3号楼2单元", want: "收货地址：[ADDRESS]"},
+		{name: "english address", text: "I live at 221 Baker Street in London", want: "I live at [ADDRESS] in London"},
+		{name: "chinese name", text: "我叫张三，想查询订单", want: "我叫[NAME]，想查询订单"},
+		{name: "english name", text: "Hi, my name is John Smith.", want: "Hi, my name is [NAME]."},
+		{name: "configured name", text: "帮欧阳娜娜订票", want: "帮[NAME]订票"},
+		{name: "custom pattern", text: "订单号 SO123456 未发货", want: "订单号 [ORDER_NO] 未发货"},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			if got := Mask(tt.text); got != tt.want {
+				t1.Errorf("Mask() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewDetectors(t1 *testing.T) {
+	tests := []struct {
+		name     string
+		types    []string
+		patterns []*PatternConfig
+	}{
+		{name: "unknown type", types: []string{"passport"}},
+		{name: "pattern without type", patterns: []*PatternConfig{{Pattern: `\d+`}}},
+		{name: "invalid pattern", patterns: []*PatternConfig{{Type: "x", Pattern: `(`}}},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			if _, err := newDetectors(tt.types, tt.patterns, nil); err == nil {
+				t1.Error("newDetectors() error = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestEnabledFor(t1 *testing.T) {
+	tests := []struct {
+		name    string
+		tenants []string
+		tenant  string
+		want    bool
+	}{
+		{name: "not configured", tenant: "acme", want: false},
+		{name: "listed", tenants: []string{"acme"}, tenant: "acme", want: true},
+		{name: "not listed", tenants: []string{"acme"}, tenant: "globex", want: false},
+		{name: "empty tenant", tenants: []string{"acme"}, tenant: "", want: false},
+		{name: "all tenants", tenants: []string{"*"}, tenant: "", want: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			useConfig(t1, &Config{Tenants: tt.tenants, Types: AllTypes})
+			if got := EnabledFor(tt.tenant); got != tt.want {
+				t1.Errorf("EnabledFor(%q) = %v, want %v", tt.tenant, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVault(t1 *testing.T) {
+	useConfig(t1, &Config{Tenants: []string{"acme"}, Types: AllTypes})
+	if NewVault("globex") != nil {
+		t1.Fatal("NewVault() must return nil for tenants that did not opt in")
+	}
+	v := NewVault("acme")
+
+	// 1. 同一内容在会话中对应同一占位符，不同内容按类型编号
+	got := v.Redact("我叫张三，手机13812345678，备用13900001111")
+	want := "我叫[NAME_1]，手机[PHONE_1]，备用[PHONE_2]"
+	if got != want {
+		t1.Fatalf("Redact() = %q, want %q", got, want)
+	}
+	if got = v.Redact("13812345678 是张三的手机"); got != "[PHONE_1] 是[NAME_1]的手机" {
+		t1.Errorf("Redact() must reuse known values, got %q", got)
+	}
+
+	// 2. 还原占位符，未知占位符保持不变
+	if got = v.Restore("已给[NAME_1]的[PHONE_2]发短信，[PHONE_9]不存在"); got != "已给张三的13900001111发短信，[PHONE_9]不存在" {
+		t1.Errorf("Restore() = %q", got)
+	}
+
+	// 3. nil 表示不脱敏
+	var none *Vault
+	if got = none.Redact("13812345678"); got != "13812345678" {
+		t1.Errorf("nil Vault Redact() = %q", got)
+	}
+	if got = none.Restore("[PHONE_1]"); got != "[PHONE_1]" {
+		t1.Errorf("nil Vault Restore() = %q", got)
+	}
+}
+
+func TestRestorer(t1 *testing.T) {
+	useConfig(t1, &Config{Tenants: []string{"*"}, Types: AllTypes})
+	v := NewVault("")
+	v.Redact("邮箱 a@example.com 电话 13812345678")
+
+	tests := []struct {
+		name   string
+		chunks []string
+		want   []string
+	}{
+		{name: "whole placeholder", chunks: []string{"联系[PHONE_1]", "即可"}, want: []string{"联系13812345678", "即可", ""}},
+		{name: "split placeholder", chunks: []string{"发送到[EM", "AIL_", "1]。"}, want: []string{"发送到", "", "a@example.com。", ""}},
+		{name: "brackets that are not placeholders", chunks: []string{"数组 [1, 2", "] 和 [x]"}, want: []string{"数组 [1, 2", "] 和 [x]", ""}},
+		{name: "unfinished at end", chunks: []string{"结尾 [PHONE"}, want: []string{"结尾 ", "[PHONE"}},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			restorer := v.NewRestorer()
+			var got []string
+			for _, chunk := range tt.chunks {
+				got = append(got, restorer.Write(chunk))
+			}
+			got = append(got, restorer.Flush())
+			if len(got) != len(tt.want) {
+				t1.Fatalf("got %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t1.Errorf("chunk %d = %q, want %q", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	var none *Vault
+	if got := none.NewRestorer().Write("[PHONE_1]"); got != "[PHONE_1]" {
+		t1.Errorf("nil Vault Restorer.Write() = %q", got)
+	}
+}
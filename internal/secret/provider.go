@@ -0,0 +1,54 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Provider 密钥来源，按引用中的名称返回密钥
+type Provider interface {
+	Resolve(ctx context.Context, name string) (string, error)
+}
+
+// envProvider ${env:NAME}，读取环境变量
+type envProvider struct{}
+
+func (envProvider) Resolve(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// fileProvider ${file:/path/to/file}，读取文件内容并去掉末尾的换行，可用于 Docker secrets 等挂载的文件
+type fileProvider struct{}
+
+func (fileProvider) Resolve(_ context.Context, name string) (string, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %v", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// k8sProvider ${k8s:name/key}，读取以卷挂载到 dir/name/key 的 Kubernetes Secret，每次解析时读取文件；组件的密钥在启动时解析，Secret 更新后需重启生效
+type k8sProvider struct {
+	dir string
+}
+
+func (p k8sProvider) Resolve(ctx context.Context, name string) (string, error) {
+	secretName, key, ok := strings.Cut(name, "/")
+	if !ok || !validSegment(secretName) || !validSegment(key) {
+		return "", fmt.Errorf("invalid kubernetes secret reference %q, want name/key", name)
+	}
+	return fileProvider{}.Resolve(ctx, filepath.Join(p.dir, secretName, key))
+}
+
+// validSegment Secret 名和键不能为空，也不能跳出挂载目录
+func validSegment(s string) bool {
+	return s != "" && s != "." && s != ".." && !strings.ContainsAny(s, `/\`)
+}
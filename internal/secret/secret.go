@@ -0,0 +1,372 @@
+package secret
+
+import (
+	"agent/internal/consts"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/database/gredis"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/util/gconv"
+)
+
+const (
+	// defaultK8sDir Kubernetes Secret 的默认挂载目录，每个 Secret 挂载到其下同名的子目录
+	defaultK8sDir = "/var/run/secrets/agent"
+	// maskedValue 日志和错误中替换密钥的内容，与日志中配置密钥的替换内容一致
+	maskedValue = "******"
+	// minMaskLen 过短的密钥不做替换，避免误伤普通文本
+	minMaskLen = 4
+)
+
+// refPattern 配置值中的密钥引用，如 ${env:ARK_API_KEY}、${file:/run/secrets/ark_api_key}、${k8s:agent-secrets/ark-api-key}
+var refPattern = regexp.MustCompile(`\$\{([a-z0-9]+):([^}]*)\}`)
+
+var (
+	mu        sync.RWMutex
+	providers = map[string]Provider{
+		"env":  envProvider{},
+		"file": fileProvider{},
+		"k8s":  k8sProvider{dir: defaultK8sDir},
+	}
+	known []string // 已解析的密钥，按长度从长到短，用于替换日志和错误中的密钥
+)
+
+// IsReference 值中是否包含密钥引用
+func IsReference(value string) bool {
+	return refPattern.MatchString(value)
+}
+
+// Resolve 解析值中的所有密钥引用，引用可以只是值的一部分，如数据库连接中的密码；不含引用的值原样返回。
+// 错误信息只包含引用本身，不包含密钥
+func Resolve(ctx context.Context, value string) (string, error) {
+	if !strings.Contains(value, "${") {
+		return value, nil
+	}
+	var firstErr error
+	resolved := refPattern.ReplaceAllStringFunc(value, func(ref string) string {
+		m := refPattern.FindStringSubmatch(ref)
+		mu.RLock()
+		p, ok := providers[m[1]]
+		mu.RUnlock()
+		if !ok {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("unsupported secret provider %s in %s", m[1], ref)
+			}
+			return ref
+		}
+		secret, err := p.Resolve(ctx, m[2])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to resolve %s: %v", ref, err)
+			}
+			return ref
+		}
+		remember(secret)
+		return secret
+	})
+	if firstErr != nil {
+		return "", firstErr
+	}
+	return resolved, nil
+}
+
+// Register 登记配置中直接写明的密钥（如名称像密钥的配置项），之后 Mask 同样替换它们
+func Register(secrets ...string) {
+	for _, secret := range secrets {
+		remember(secret)
+	}
+}
+
+// remember 登记解析出的密钥，之后出现在日志和错误中时会被替换
+func remember(secret string) {
+	if len(secret) < minMaskLen {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if slices.Contains(known, secret) {
+		return
+	}
+	i := sort.Search(len(known), func(i int) bool { return len(known[i]) < len(secret) })
+	known = slices.Insert(known, i, secret)
+}
+
+// Mask 将文本中已解析或已登记的密钥替换为 ******
+func Mask(text string) string {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, secret := range known {
+		if strings.Contains(text, secret) {
+			text = strings.ReplaceAll(text, secret, maskedValue)
+		}
+	}
+	return text
+}
+
+// Scope 组件声明的密钥配置项。声明的配置项在启动时（Init）解析一次，组件只能从 Scope 取得解析后的值，
+// 读取时不再访问全局配置，未声明的配置项返回错误
+type Scope struct {
+	owner  string
+	keys   []string
+	mu     sync.RWMutex
+	loaded bool
+	values map[string]any
+	errs   map[string]error
+}
+
+var (
+	scopesMu sync.Mutex
+	scopes   []*Scope // 所有声明的密钥配置项，Init 时统一解析
+)
+
+// Declare 声明组件需要的密钥配置项，owner 用于错误信息；需在包初始化时声明，启动时统一解析
+func Declare(owner string, keys ...string) *Scope {
+	s := &Scope{owner: owner, keys: keys}
+	scopesMu.Lock()
+	scopes = append(scopes, s)
+	scopesMu.Unlock()
+	return s
+}
+
+// Reload 重新读取并解析声明的配置项，Init 时对所有 Scope 调用；配置变更后（如测试中）也可单独调用
+func (s *Scope) Reload(ctx context.Context) {
+	values := make(map[string]any, len(s.keys))
+	errs := make(map[string]error)
+	for _, key := range s.keys {
+		value, err := resolveTree(ctx, key, g.Cfg().MustGet(ctx, key).Val())
+		if err != nil {
+			errs[key] = err
+		}
+		values[key] = value
+	}
+	s.mu.Lock()
+	s.values, s.errs, s.loaded = values, errs, true
+	s.mu.Unlock()
+}
+
+// lookup 取出解析后的值，未在 Init 中解析时（如单独运行的测试）先解析一次
+func (s *Scope) lookup(ctx context.Context, key string) (any, error) {
+	if !slices.Contains(s.keys, key) {
+		return nil, fmt.Errorf("%s has not declared secret %s", s.owner, key)
+	}
+	s.mu.RLock()
+	loaded := s.loaded
+	s.mu.RUnlock()
+	if !loaded {
+		s.Reload(ctx)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.values[key], s.errs[key]
+}
+
+// Get 返回声明过的密钥配置项启动时解析的值，未声明的配置项返回错误，未配置时返回空值
+func (s *Scope) Get(ctx context.Context, key string) (string, error) {
+	value, err := s.lookup(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	return gconv.String(value), nil
+}
+
+// Value 同 Get，出错时记录日志并返回空值，用于没有错误返回值的配置读取
+func (s *Scope) Value(ctx context.Context, key string) string {
+	value, err := s.Get(ctx, key)
+	if err != nil {
+		g.Log(consts.LoggerAgent).Errorf(ctx, "failed to read secret for %s: %v", s.owner, err)
+	}
+	return value
+}
+
+// Scan 将声明过的结构化配置项（如 MCP 服务列表）启动时解析的值转换到 pointer 中
+func (s *Scope) Scan(ctx context.Context, key string, pointer any) error {
+	value, err := s.lookup(ctx, key)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return nil
+	}
+	return gconv.Scan(value, pointer)
+}
+
+// Init 设置 Kubernetes Secret 的挂载目录，检查配置中的所有引用并登记密钥用于脱敏，
+// 数据库和 Redis 的配置中有引用时用解析后的配置初始化；需在连接数据库和 Redis 之前调用
+func Init(ctx context.Context) error {
+	dir := g.Cfg().MustGet(ctx, consts.SecretsK8sDir, defaultK8sDir).String()
+	mu.Lock()
+	providers["k8s"] = k8sProvider{dir: dir}
+	mu.Unlock()
+
+	data, err := g.Cfg().Data(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %v", err)
+	}
+
+	// 1. 启动时解析所有引用，缺少的密钥只记录警告，由使用它的组件报错，避免未启用的功能影响启动
+	refs := 0
+	walk(data, "", func(path, value string) {
+		if !IsReference(value) {
+			return
+		}
+		refs++
+		if _, err := Resolve(ctx, value); err != nil {
+			g.Log(consts.LoggerAgent).Warningf(ctx, "secret reference in %s cannot be resolved: %v", path, err)
+		}
+	})
+	if refs > 0 {
+		g.Log(consts.LoggerAgent).Infof(ctx, "found %d secret references in config", refs)
+	}
+
+	// 2. 解析各组件声明的密钥配置项，之后组件读取的都是这次解析的值
+	scopesMu.Lock()
+	declared := slices.Clone(scopes)
+	scopesMu.Unlock()
+	for _, s := range declared {
+		s.Reload(ctx)
+	}
+
+	// 3. 数据库和 Redis 由 GoFrame 直接读取配置，引用需要解析后再设置
+	if err = configureDatabase(ctx, data); err != nil {
+		return err
+	}
+	return configureRedis(ctx, data)
+}
+
+// configureDatabase 用解析后的配置设置含有引用的数据库分组
+func configureDatabase(ctx context.Context, data map[string]any) error {
+	groups, _ := data["database"].(map[string]any)
+	for group, v := range groups {
+		if !containsReference(v) {
+			continue
+		}
+		var nodes gdb.ConfigGroup
+		switch value := resolveAll(ctx, v).(type) {
+		case map[string]any:
+			nodes = append(nodes, databaseNode(value))
+		case []any:
+			for _, item := range value {
+				if m, ok := item.(map[string]any); ok {
+					nodes = append(nodes, databaseNode(m))
+				}
+			}
+		}
+		if err := gdb.SetConfigGroup(group, nodes); err != nil {
+			return fmt.Errorf("failed to configure database group %s: %v", group, err)
+		}
+	}
+	return nil
+}
+
+func databaseNode(m map[string]any) gdb.ConfigNode {
+	var node gdb.ConfigNode
+	_ = gconv.Struct(m, &node)
+	if link, ok := m["link"]; ok {
+		node.Link = gconv.String(link)
+	}
+	return node
+}
+
+// configureRedis 用解析后的配置设置含有引用的 Redis 分组
+func configureRedis(ctx context.Context, data map[string]any) error {
+	groups, _ := data["redis"].(map[string]any)
+	for group, v := range groups {
+		if !containsReference(v) {
+			continue
+		}
+		cfg, err := gredis.ConfigFromMap(gconv.Map(resolveAll(ctx, v)))
+		if err != nil {
+			return fmt.Errorf("failed to configure redis group %s: %v", group, err)
+		}
+		gredis.SetConfig(cfg, group)
+	}
+	return nil
+}
+
+// walk 遍历配置中的字符串值
+func walk(v any, path string, fn func(path, value string)) {
+	switch value := v.(type) {
+	case map[string]any:
+		for k, child := range value {
+			if path != "" {
+				k = path + "." + k
+			}
+			walk(child, k, fn)
+		}
+	case []any:
+		for i, child := range value {
+			walk(child, fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+	case string:
+		fn(path, value)
+	}
+}
+
+func containsReference(v any) bool {
+	found := false
+	walk(v, "", func(_, value string) {
+		found = found || IsReference(value)
+	})
+	return found
+}
+
+// resolveTree 复制配置项并解析其中的引用，返回第一个无法解析的引用及其路径
+func resolveTree(ctx context.Context, path string, v any) (any, error) {
+	var firstErr error
+	resolved := resolveWith(v, path, func(p, value string) string {
+		resolved, err := Resolve(ctx, value)
+		if err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s: %v", p, err)
+		}
+		return resolved
+	})
+	return resolved, firstErr
+}
+
+// resolveAll 复制配置并解析其中的引用，无法解析的值替换为空值
+func resolveAll(ctx context.Context, v any) any {
+	return resolveWith(v, "", func(_, value string) string {
+		resolved, _ := Resolve(ctx, value)
+		return resolved
+	})
+}
+
+// resolveWith 复制配置，字符串值替换为 fn 的返回值，path 的格式与 walk 一致
+func resolveWith(v any, path string, fn func(path, value string) string) any {
+	join := func(k string) string {
+		if path == "" {
+			return k
+		}
+		return path + "." + k
+	}
+	switch value := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(value))
+		for k, child := range value {
+			m[k] = resolveWith(child, join(k), fn)
+		}
+		return m
+	case []map[string]any:
+		s := make([]any, len(value))
+		for i, child := range value {
+			s[i] = resolveWith(child, fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+		return s
+	case []any:
+		s := make([]any, len(value))
+		for i, child := range value {
+			s[i] = resolveWith(child, fmt.Sprintf("%s[%d]", path, i), fn)
+		}
+		return s
+	case string:
+		return fn(path, value)
+	}
+	return v
+}
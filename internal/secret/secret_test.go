@@ -0,0 +1,128 @@
+package secret
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gogf/gf/v2/database/gdb"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcfg"
+)
+
+func TestResolve(t1 *testing.T) {
+	dir := t1.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "db_password"), []byte("file-secret-1\n"), 0o600); err != nil {
+		t1.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "agent-secrets"), 0o755); err != nil {
+		t1.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "agent-secrets", "ark-api-key"), []byte("k8s-secret-1"), 0o600); err != nil {
+		t1.Fatal(err)
+	}
+	t1.Setenv("SECRET_TEST_KEY", "env-secret-1")
+	old := providers["k8s"]
+	providers["k8s"] = k8sProvider{dir: dir}
+	t1.Cleanup(func() { providers["k8s"] = old })
+
+	tests := []struct {
+		name      string
+		value     string
+		want      string
+		wantError bool
+	}{
+		{name: "plain value", value: "doubao-1.5-pro", want: "doubao-1.5-pro"},
+		{name: "env", value: "${env:SECRET_TEST_KEY}", want: "env-secret-1"},
+		{name: "file trims newline", value: "${file:" + filepath.Join(dir, "db_password") + "}", want: "file-secret-1"},
+		{name: "k8s", value: "${k8s:agent-secrets/ark-api-key}", want: "k8s-secret-1"},
+		{name: "part of value", value: "mysql:root:${env:SECRET_TEST_KEY}@tcp(127.0.0.1:3306)/agent", want: "mysql:root:env-secret-1@tcp(127.0.0.1:3306)/agent"},
+		{name: "missing env", value: "${env:SECRET_TEST_MISSING}", wantError: true},
+		{name: "missing file", value: "${file:" + filepath.Join(dir, "missing") + "}", wantError: true},
+		{name: "unsupported provider", value: "${vault:secret/ark}", wantError: true},
+		{name: "k8s without key", value: "${k8s:agent-secrets}", wantError: true},
+		{name: "k8s path traversal", value: "${k8s:../db_password}", wantError: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			got, err := Resolve(context.Background(), tt.value)
+			if (err != nil) != tt.wantError {
+				t1.Fatalf("Resolve() error = %v, wantError %v", err, tt.wantError)
+			}
+			if got != tt.want {
+				t1.Errorf("Resolve() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMask(t1 *testing.T) {
+	t1.Setenv("SECRET_TEST_MASK", "sk-mask-123456")
+	t1.Setenv("SECRET_TEST_SHORT", "abc")
+	ctx := context.Background()
+	if _, err := Resolve(ctx, "${env:SECRET_TEST_MASK} ${env:SECRET_TEST_SHORT}"); err != nil {
+		t1.Fatal(err)
+	}
+	Register("plain-config-token")
+	got := Mask(`request failed: GET https://api.example.com/search?api_key=sk-mask-123456&q=abc token=plain-config-token`)
+	want := `request failed: GET https://api.example.com/search?api_key=******&q=abc token=******`
+	if got != want {
+		t1.Errorf("Mask() = %q, want %q", got, want)
+	}
+}
+
+func TestScope(t1 *testing.T) {
+	ctx := context.Background()
+	s := Declare("web_search_tool", "tools.webSearch.apiKey")
+	if _, err := s.Get(ctx, "ai.apiKey"); err == nil {
+		t1.Error("Get() of an undeclared secret must fail")
+	}
+	if got := s.Value(ctx, "ai.apiKey"); got != "" {
+		t1.Errorf("Value() of an undeclared secret = %q, want empty", got)
+	}
+
+	// 声明的配置项只在 Reload（启动时由 Init 调用）时读取，之后修改配置不影响组件拿到的值
+	adapter := g.Cfg().GetAdapter().(*gcfg.AdapterFile)
+	old, _ := adapter.Get(ctx, "secretTest.token")
+	t1.Cleanup(func() { _ = adapter.Set("secretTest.token", old) })
+	t1.Setenv("SECRET_TEST_SCOPE", "sk-scope-123")
+	s = Declare("secret_test", "secretTest.token")
+	_ = adapter.Set("secretTest.token", "${env:SECRET_TEST_SCOPE}")
+	s.Reload(ctx)
+	_ = adapter.Set("secretTest.token", "changed")
+	if got, err := s.Get(ctx, "secretTest.token"); err != nil || got != "sk-scope-123" {
+		t1.Errorf("Get() = %q, %v, want the value resolved at startup", got, err)
+	}
+	_ = adapter.Set("secretTest.token", "${env:SECRET_TEST_SCOPE_MISSING}")
+	s.Reload(ctx)
+	if _, err := s.Get(ctx, "secretTest.token"); err == nil || !strings.Contains(err.Error(), "secretTest.token") {
+		t1.Errorf("Get() error = %v, want the unresolvable key", err)
+	}
+}
+
+func TestConfigureDatabase(t1 *testing.T) {
+	t1.Setenv("SECRET_TEST_DB_PASSWORD", "db-secret-1")
+	data := map[string]any{
+		"database": map[string]any{
+			"secret_test": map[string]any{
+				"link":   "mysql:root:${env:SECRET_TEST_DB_PASSWORD}@tcp(127.0.0.1:3306)/agent",
+				"prefix": "t_",
+			},
+			"secret_test_plain": map[string]any{
+				"link": "mysql:root:root@tcp(127.0.0.1:3306)/agent",
+			},
+		},
+	}
+	if err := configureDatabase(context.Background(), data); err != nil {
+		t1.Fatal(err)
+	}
+	nodes := gdb.GetConfig("secret_test")
+	if len(nodes) != 1 || nodes[0].Pass != "db-secret-1" || nodes[0].Prefix != "t_" {
+		t1.Errorf("database config = %+v, want the resolved password", nodes)
+	}
+	if gdb.GetConfig("secret_test_plain") != nil {
+		t1.Error("groups without references must be left to GoFrame")
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"context"
 
 	"github.com/cloudwego/eino/schema"
+	"github.com/mark3labs/mcp-go/server"
 )
 
 type (
@@ -21,6 +22,22 @@ type (
 		GetSessionBySessionID(ctx context.Context, sessionId string) []*schema.Message
 		// IsRelevant 检查检索结果是否与查询相关
 		IsRelevant(query string, results []*schema.Document) bool
+		// Approve 处理等待审批的工具调用
+		Approve(ctx context.Context, in *v1.ApprovalReq) (out *v1.ApprovalRes, err error)
+		// DeleteSession 删除会话的历史消息和工作目录
+		DeleteSession(ctx context.Context, in *v1.SessionDeleteReq) (out *v1.SessionDeleteRes, err error)
+		// ServeArtifact 校验签名链接并返回工具生成的文件
+		ServeArtifact(ctx context.Context, in *v1.ArtifactReq) (out *v1.ArtifactRes, err error)
+		// Upload 保存上传的图片到会话工作目录
+		Upload(ctx context.Context, in *v1.UploadReq) (out *v1.UploadRes, err error)
+		// MCPHealth 返回 MCP 服务的连接状态
+		MCPHealth(ctx context.Context, in *v1.MCPHealthReq) (out *v1.MCPHealthRes, err error)
+		// Usage 按时间范围汇总 token 用量和费用，按 group_by 指定的维度分组
+		Usage(ctx context.Context, in *v1.UsageReq) (out *v1.UsageRes, err error)
+		// Audit 按条件分页查询工具调用的审计日志，按时间倒序
+		Audit(ctx context.Context, in *v1.AuditReq) (out *v1.AuditRes, err error)
+		// NewMCPServer 创建 MCP 服务：每个工具对应一个 MCP 工具，ReAct Agent 作为 ask_agent 工具，知识库文档作为资源
+		NewMCPServer(ctx context.Context) (*server.MCPServer, error)
 	}
 )
 
@@ -0,0 +1,308 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"agent/internal/model"
+	"agent/internal/secret"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtimer"
+)
+
+const (
+	defaultArtifactDir             = "resource/artifacts"
+	defaultArtifactLinkTTL         = 24 * time.Hour
+	defaultArtifactRetention       = 72 * time.Hour
+	defaultArtifactJanitorInterval = time.Hour
+)
+
+var (
+	// artifactIDPattern 产物 ID 为 32 位十六进制字符串
+	artifactIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+	// ErrArtifactNotFound 产物不存在或已被清理
+	ErrArtifactNotFound = errors.New("artifact not found")
+	// ErrArtifactLinkInvalid 下载链接签名错误或已过期
+	ErrArtifactLinkInvalid = errors.New("artifact link is invalid or expired")
+)
+
+// Artifact 工具生成的文件，登记后可以通过签名链接下载
+type Artifact struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	MimeType  string    `json:"mime_type"`
+	Size      int64     `json:"size"`
+	SessionID string    `json:"session_id"`
+	TenantID  string    `json:"tenant_id"` // 产物所属的租户和用户，下载时校验
+	UserID    string    `json:"user_id"`
+	Tool      string    `json:"tool"`
+	Path      string    `json:"path"` // 服务器上的绝对路径，不返回给前端
+	CreatedAt time.Time `json:"created_at"`
+
+	URL       string    `json:"-"` // 签名下载链接，登记时生成
+	ExpiresAt time.Time `json:"-"` // 下载链接过期时间
+}
+
+// artifactSinkKey 上下文中产物登记回调的 key
+type artifactSinkKey struct{}
+
+// WithArtifactSink 在上下文中挂载回调，工具登记产物后调用，用于向前端推送下载链接
+func WithArtifactSink(ctx context.Context, sink func(artifact *Artifact)) context.Context {
+	return context.WithValue(ctx, artifactSinkKey{}, sink)
+}
+
+// artifactDir 产物记录的存放目录
+func artifactDir(ctx context.Context) (string, error) {
+	dir, err := filepath.Abs(g.Cfg().MustGet(ctx, consts.ArtifactDir, defaultArtifactDir).String())
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve artifact dir: %v", err)
+	}
+	return dir, nil
+}
+
+// RegisterArtifact 登记工具生成的文件并生成签名下载链接
+func RegisterArtifact(ctx context.Context, path, tool string) (*Artifact, error) {
+	// 1. 读取文件信息
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat artifact: %v", err)
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("artifact %s is a directory", filepath.Base(path))
+	}
+	id := make([]byte, 16)
+	if _, err = rand.Read(id); err != nil {
+		return nil, fmt.Errorf("failed to generate artifact id: %v", err)
+	}
+	artifact := &Artifact{
+		ID:        hex.EncodeToString(id),
+		Name:      filepath.Base(path),
+		MimeType:  detectMimeType(path),
+		Size:      info.Size(),
+		SessionID: sessionIDFromCtx(ctx),
+		Tool:      tool,
+		Path:      path,
+		CreatedAt: time.Now(),
+	}
+	if c, ok := ctx.Value(consts.ContextKey).(*model.Context); ok {
+		artifact.TenantID, artifact.UserID = c.TenantID, c.UserID
+	}
+
+	// 2. 保存记录
+	dir, err := artifactDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create artifact dir: %v", err)
+	}
+	record, err := gjson.Marshal(artifact)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode artifact: %v", err)
+	}
+	if err = os.WriteFile(filepath.Join(dir, artifact.ID+".json"), record, 0644); err != nil {
+		return nil, fmt.Errorf("failed to save artifact: %v", err)
+	}
+
+	// 3. 生成签名链接并通知前端
+	artifact.URL, artifact.ExpiresAt = SignArtifactURL(ctx, artifact.ID)
+	if sink, ok := ctx.Value(artifactSinkKey{}).(func(*Artifact)); ok && sink != nil {
+		sink(artifact)
+	}
+	g.Log(consts.LoggerTools).Infof(ctx, "Registered artifact %s (%s) from %s", artifact.ID, artifact.Name, tool)
+	return artifact, nil
+}
+
+// detectMimeType 按扩展名判断文件类型，无法判断时读取文件头
+func detectMimeType(path string) string {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		return t
+	}
+	_, mimeType, err := sniffFile(path)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	return mimeType
+}
+
+// GetArtifact 按 ID 读取产物记录
+func GetArtifact(ctx context.Context, id string) (*Artifact, error) {
+	if !artifactIDPattern.MatchString(id) {
+		return nil, ErrArtifactNotFound
+	}
+	dir, err := artifactDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	record, err := os.ReadFile(filepath.Join(dir, id+".json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrArtifactNotFound
+		}
+		return nil, fmt.Errorf("failed to read artifact: %v", err)
+	}
+	var artifact Artifact
+	if err = gjson.DecodeTo(record, &artifact); err != nil {
+		return nil, fmt.Errorf("failed to decode artifact: %v", err)
+	}
+	return &artifact, nil
+}
+
+var (
+	artifactSecret     []byte
+	artifactSecretOnce sync.Once
+	// artifactSecrets 下载链接的签名密钥
+	artifactSecrets = secret.Declare("artifact", consts.ArtifactSecret)
+)
+
+// artifactSigningKey 签名密钥，未配置时使用进程内随机密钥，重启后旧链接失效
+func artifactSigningKey(ctx context.Context) []byte {
+	if key := artifactSecrets.Value(ctx, consts.ArtifactSecret); key != "" {
+		return []byte(key)
+	}
+	artifactSecretOnce.Do(func() {
+		artifactSecret = make([]byte, 32)
+		_, _ = rand.Read(artifactSecret)
+		g.Log(consts.LoggerTools).Warning(ctx, "tools.artifact.secret is not set, artifact links will be invalid after restart")
+	})
+	return artifactSecret
+}
+
+// artifactSignature 计算 id 与过期时间的 HMAC-SHA256 签名
+func artifactSignature(ctx context.Context, id string, expires int64) string {
+	mac := hmac.New(sha256.New, artifactSigningKey(ctx))
+	mac.Write([]byte(id + ":" + strconv.FormatInt(expires, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignArtifactURL 生成带过期时间的签名下载链接
+func SignArtifactURL(ctx context.Context, id string) (string, time.Time) {
+	ttl := g.Cfg().MustGet(ctx, consts.ArtifactLinkTTL, defaultArtifactLinkTTL).Duration()
+	if ttl <= 0 {
+		ttl = defaultArtifactLinkTTL
+	}
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", artifactSignature(ctx, id, expiresAt.Unix()))
+	baseURL := strings.TrimRight(g.Cfg().MustGet(ctx, consts.ArtifactBaseURL).String(), "/")
+	return baseURL + "/artifacts/" + id + "?" + query.Encode(), expiresAt
+}
+
+// VerifyArtifactURL 校验下载链接的签名和过期时间
+func VerifyArtifactURL(ctx context.Context, id string, expires int64, signature string) error {
+	if time.Now().Unix() > expires {
+		return ErrArtifactLinkInvalid
+	}
+	expected := artifactSignature(ctx, id, expires)
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return ErrArtifactLinkInvalid
+	}
+	return nil
+}
+
+// OpenArtifact 校验链接后返回产物，文件必须仍在工作目录内
+func OpenArtifact(ctx context.Context, id string, expires int64, signature string) (*Artifact, error) {
+	if err := VerifyArtifactURL(ctx, id, expires, signature); err != nil {
+		return nil, err
+	}
+	artifact, err := GetArtifact(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	root, err := workspaceRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	path, err := filepath.EvalSymlinks(artifact.Path)
+	if err != nil {
+		return nil, ErrArtifactNotFound
+	}
+	if realRoot, err := filepath.EvalSymlinks(root); err == nil {
+		root = realRoot
+	}
+	if !withinDir(root, path) {
+		return nil, ErrArtifactNotFound
+	}
+	artifact.Path = path
+	return artifact, nil
+}
+
+// CleanExpiredArtifacts 删除超过保留时间的产物文件及记录，文件已不存在的记录一并清理
+func CleanExpiredArtifacts(ctx context.Context, retention time.Duration) error {
+	dir, err := artifactDir(ctx)
+	if err != nil {
+		return err
+	}
+	records, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return fmt.Errorf("failed to list artifacts: %v", err)
+	}
+	deadline := time.Now().Add(-retention)
+	for _, record := range records {
+		artifact, err := GetArtifact(ctx, strings.TrimSuffix(filepath.Base(record), ".json"))
+		if err != nil {
+			continue
+		}
+		_, statErr := os.Stat(artifact.Path)
+		if statErr == nil && artifact.CreatedAt.After(deadline) {
+			continue
+		}
+		if statErr == nil {
+			if err = os.Remove(artifact.Path); err != nil {
+				g.Log(consts.LoggerTools).Warningf(ctx, "failed to remove expired artifact %s: %v", artifact.Path, err)
+				continue
+			}
+		}
+		if err = os.Remove(record); err != nil && !os.IsNotExist(err) {
+			g.Log(consts.LoggerTools).Warningf(ctx, "failed to remove artifact record %s: %v", record, err)
+			continue
+		}
+		g.Log(consts.LoggerTools).Infof(ctx, "Removed expired artifact: %s (%s)", artifact.ID, artifact.Name)
+	}
+	return nil
+}
+
+// StartArtifactJanitor 定时清理过期的产物
+func StartArtifactJanitor(ctx context.Context) {
+	retention := g.Cfg().MustGet(ctx, consts.ArtifactRetention, defaultArtifactRetention).Duration()
+	if retention <= 0 {
+		retention = defaultArtifactRetention
+	}
+	interval := g.Cfg().MustGet(ctx, consts.ArtifactJanitorInterval, defaultArtifactJanitorInterval).Duration()
+	if interval <= 0 {
+		interval = defaultArtifactJanitorInterval
+	}
+	gtimer.AddSingleton(ctx, interval, func(ctx context.Context) {
+		if err := CleanExpiredArtifacts(ctx, retention); err != nil {
+			g.Log(consts.LoggerTools).Warningf(ctx, "artifact janitor: %v", err)
+		}
+	})
+}
+
+// withArtifactLink 登记产物并在工具结果后附上下载链接，登记失败时只返回原结果
+func withArtifactLink(ctx context.Context, result, path, tool string) string {
+	artifact, err := RegisterArtifact(ctx, path, tool)
+	if err != nil {
+		g.Log(consts.LoggerTools).Warningf(ctx, "failed to register artifact %s: %v", path, err)
+		return result
+	}
+	return result + "\nDownload URL: " + artifact.URL
+}
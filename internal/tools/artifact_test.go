@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"agent/internal/model"
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArtifact_RegisterAndOpen(t1 *testing.T) {
+	ctx := context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_artifact"})
+	workspace, err := sessionWorkspace(ctx)
+	if err != nil {
+		t1.Fatalf("sessionWorkspace() error = %v", err)
+	}
+	defer RemoveSessionWorkspace(ctx, "test_artifact")
+	filePath := filepath.Join(workspace, "report.pdf")
+	if err = os.WriteFile(filePath, []byte("%PDF-1.4"), 0644); err != nil {
+		t1.Fatal(err)
+	}
+
+	// 登记产物时回调收到下载链接
+	var pushed *Artifact
+	artifact, err := RegisterArtifact(WithArtifactSink(ctx, func(a *Artifact) { pushed = a }), filePath, "pdf_generation_tool")
+	if err != nil {
+		t1.Fatalf("RegisterArtifact() error = %v", err)
+	}
+	if pushed == nil || pushed.URL != artifact.URL {
+		t1.Fatalf("RegisterArtifact() sink got %v, want %v", pushed, artifact)
+	}
+	if artifact.MimeType != "application/pdf" || artifact.Size != 8 || artifact.SessionID != "test_artifact" {
+		t1.Errorf("RegisterArtifact() = %+v", artifact)
+	}
+	link, err := url.Parse(artifact.URL)
+	if err != nil || link.Path != "/artifacts/"+artifact.ID {
+		t1.Fatalf("RegisterArtifact() url = %v", artifact.URL)
+	}
+	expires, _ := strconv.ParseInt(link.Query().Get("expires"), 10, 64)
+	signature := link.Query().Get("signature")
+
+	tests := []struct {
+		name      string
+		id        string
+		expires   int64
+		signature string
+		wantErr   error
+	}{
+		{name: "valid", id: artifact.ID, expires: expires, signature: signature},
+		{name: "tampered signature", id: artifact.ID, expires: expires, signature: strings.Repeat("0", 64), wantErr: ErrArtifactLinkInvalid},
+		{name: "extended expiry", id: artifact.ID, expires: expires + 3600, signature: signature, wantErr: ErrArtifactLinkInvalid},
+		{name: "expired", id: artifact.ID, expires: time.Now().Add(-time.Minute).Unix(), signature: artifactSignature(ctx, artifact.ID, time.Now().Add(-time.Minute).Unix()), wantErr: ErrArtifactLinkInvalid},
+		{name: "invalid id", id: "../../etc/passwd", expires: expires, signature: artifactSignature(ctx, "../../etc/passwd", expires), wantErr: ErrArtifactNotFound},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			got, err := OpenArtifact(ctx, tt.id, tt.expires, tt.signature)
+			if !errors.Is(err, tt.wantErr) {
+				t1.Fatalf("OpenArtifact() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.Name != "report.pdf" {
+				t1.Errorf("OpenArtifact() = %+v", got)
+			}
+		})
+	}
+
+	// 超过保留时间后文件和记录都被清理
+	if err = CleanExpiredArtifacts(ctx, -time.Second); err != nil {
+		t1.Fatalf("CleanExpiredArtifacts() error = %v", err)
+	}
+	if _, err = os.Stat(filePath); !os.IsNotExist(err) {
+		t1.Errorf("CleanExpiredArtifacts() should remove the file, stat error = %v", err)
+	}
+	if _, err = GetArtifact(ctx, artifact.ID); !errors.Is(err, ErrArtifactNotFound) {
+		t1.Errorf("GetArtifact() error = %v, want %v", err, ErrArtifactNotFound)
+	}
+}
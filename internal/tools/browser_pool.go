@@ -0,0 +1,315 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+const (
+	defaultBrowserMaxTabs        = 4
+	defaultBrowserAcquireTimeout = 30 * time.Second
+	defaultBrowserHealthInterval = 30 * time.Second
+	browserHealthTimeout         = 5 * time.Second
+)
+
+// errBrowserPoolClosed 浏览器池已关闭
+var errBrowserPoolClosed = errors.New("browser pool is closed")
+
+// BrowserPool 共享的无头浏览器，所有基于 chromedp 的工具在同一个浏览器进程中打开标签页
+type BrowserPool struct {
+	mu       sync.Mutex
+	opts     []chromedp.ExecAllocatorOption
+	tabs     chan struct{} // 限制同时打开的标签页数量
+	active   sync.WaitGroup
+	acquire  time.Duration
+	interval time.Duration
+	closed   bool
+	done     chan struct{}
+
+	allocCancel   context.CancelFunc
+	browserCtx    context.Context // 浏览器的第一个标签页，保持浏览器进程存活
+	browserCancel context.CancelFunc
+
+	unavailable error // 无法安全启动浏览器的原因，如过滤代理启动失败
+}
+
+var (
+	sharedBrowserPool   *BrowserPool
+	sharedBrowserProxy  *browserProxy
+	sharedBrowserPoolMu sync.Mutex
+)
+
+// browserPool 获取按配置创建的共享浏览器池，浏览器的所有请求经过过滤代理
+func browserPool(ctx context.Context) *BrowserPool {
+	sharedBrowserPoolMu.Lock()
+	defer sharedBrowserPoolMu.Unlock()
+	if sharedBrowserPool == nil {
+		proxy, proxyErr := startBrowserProxy(ctx)
+		opts := append(chromedp.DefaultExecAllocatorOptions[:],
+			chromedp.Flag("headless", true),
+			chromedp.Flag("disable-gpu", true),
+			chromedp.Flag("disable-dev-shm-usage", true),
+			chromedp.Flag("no-sandbox", true),
+			chromedp.Flag("disable-extensions", true),
+			chromedp.Flag("disable-background-timer-throttling", true),
+			chromedp.Flag("disable-backgrounding-occluded-windows", true),
+			chromedp.Flag("disable-renderer-backgrounding", true),
+			chromedp.WindowSize(1200, 800),
+			// 浏览器不直接连接任何地址，本机地址默认绕过代理，需显式取消；QUIC 和 WebRTC 的 UDP 不经过代理，一并禁用
+			chromedp.Flag("proxy-bypass-list", "<-loopback>"),
+			chromedp.Flag("disable-quic", true),
+			chromedp.Flag("force-webrtc-ip-handling-policy", "disable_non_proxied_udp"),
+		)
+		if proxy != nil {
+			opts = append(opts, chromedp.ProxyServer(proxy.URL()))
+		}
+		if execPath := g.Cfg().MustGet(ctx, consts.BrowserExecPath).String(); execPath != "" {
+			opts = append(opts, chromedp.ExecPath(execPath))
+		}
+		sharedBrowserPool = NewBrowserPool(
+			g.Cfg().MustGet(ctx, consts.BrowserMaxTabs, defaultBrowserMaxTabs).Int(),
+			g.Cfg().MustGet(ctx, consts.BrowserAcquireTimeout, defaultBrowserAcquireTimeout).Duration(),
+			g.Cfg().MustGet(ctx, consts.BrowserHealthInterval, defaultBrowserHealthInterval).Duration(),
+			opts...,
+		)
+		if proxyErr != nil {
+			g.Log(consts.LoggerTools).Errorf(ctx, "Browser is disabled: %v", proxyErr)
+			sharedBrowserPool.unavailable = proxyErr
+		}
+		sharedBrowserProxy = proxy
+	}
+	return sharedBrowserPool
+}
+
+// NewBrowserPool 创建浏览器池，浏览器在第一次打开标签页时启动
+func NewBrowserPool(maxTabs int, acquire, interval time.Duration, opts ...chromedp.ExecAllocatorOption) *BrowserPool {
+	if maxTabs <= 0 {
+		maxTabs = defaultBrowserMaxTabs
+	}
+	if acquire <= 0 {
+		acquire = defaultBrowserAcquireTimeout
+	}
+	if interval <= 0 {
+		interval = defaultBrowserHealthInterval
+	}
+	return &BrowserPool{
+		opts:     opts,
+		tabs:     make(chan struct{}, maxTabs),
+		acquire:  acquire,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// NewTab 打开一个标签页，标签页数量达到上限时等待；调用方必须调用 release 关闭标签页
+func (p *BrowserPool) NewTab(ctx context.Context) (tabCtx context.Context, release func(), err error) {
+	if p.unavailable != nil {
+		return nil, nil, fmt.Errorf("browser is unavailable: %v", p.unavailable)
+	}
+	// 1. 占用一个标签页名额
+	timer := time.NewTimer(p.acquire)
+	defer timer.Stop()
+	select {
+	case p.tabs <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, ctx.Err()
+	case <-timer.C:
+		return nil, nil, fmt.Errorf("no browser tab available after %s", p.acquire)
+	case <-p.done:
+		return nil, nil, errBrowserPoolClosed
+	}
+
+	// 2. 确保浏览器在运行，崩溃后重新启动
+	browserCtx, err := p.ensureBrowser()
+	if err != nil {
+		<-p.tabs
+		return nil, nil, err
+	}
+
+	// 3. 在浏览器中新建标签页，调用方的 ctx 取消时关闭标签页
+	tabCtx, cancel := chromedp.NewContext(browserCtx)
+	stop := context.AfterFunc(ctx, cancel)
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			stop()
+			cancel()
+			<-p.tabs
+			p.active.Done()
+		})
+	}
+	return tabCtx, release, nil
+}
+
+// ensureBrowser 返回运行中的浏览器并登记一个活动标签页，未启动或已退出时启动新的浏览器进程
+func (p *BrowserPool) ensureBrowser() (context.Context, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, errBrowserPoolClosed
+	}
+	if p.browserCtx != nil && p.browserCtx.Err() == nil {
+		p.active.Add(1)
+		return p.browserCtx, nil
+	}
+	if p.browserCtx != nil {
+		g.Log(consts.LoggerTools).Warning(context.Background(), "Browser exited unexpectedly, restarting")
+		p.stopLocked()
+	}
+
+	allocCtx, allocCancel := chromedp.NewExecAllocator(context.Background(), p.opts...)
+	browserCtx, browserCancel := chromedp.NewContext(allocCtx)
+	// 启动浏览器进程并打开第一个标签页
+	if err := chromedp.Run(browserCtx); err != nil {
+		browserCancel()
+		allocCancel()
+		return nil, fmt.Errorf("failed to start browser: %v", err)
+	}
+	p.allocCancel, p.browserCtx, p.browserCancel = allocCancel, browserCtx, browserCancel
+	go p.healthCheck(browserCtx)
+	g.Log(consts.LoggerTools).Info(context.Background(), "Browser started")
+	p.active.Add(1)
+	return browserCtx, nil
+}
+
+// healthCheck 定时检查浏览器是否可响应，无响应时结束浏览器，下次打开标签页时重新启动
+func (p *BrowserPool) healthCheck(browserCtx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-browserCtx.Done():
+			return
+		case <-ticker.C:
+		}
+		ctx, cancel := context.WithTimeout(browserCtx, browserHealthTimeout)
+		err := chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) error {
+			_, _, _, _, _, err := browser.GetVersion().Do(ctx)
+			return err
+		}))
+		cancel()
+		if err == nil || browserCtx.Err() != nil {
+			continue
+		}
+		g.Log(consts.LoggerTools).Warningf(context.Background(), "Browser health check failed, restarting: %v", err)
+		p.mu.Lock()
+		if p.browserCtx == browserCtx {
+			p.stopLocked()
+		}
+		p.mu.Unlock()
+		return
+	}
+}
+
+// stopLocked 结束当前浏览器进程，调用方需持有锁
+func (p *BrowserPool) stopLocked() {
+	if p.browserCancel != nil {
+		p.browserCancel()
+	}
+	if p.allocCancel != nil {
+		p.allocCancel()
+	}
+	p.allocCancel, p.browserCtx, p.browserCancel = nil, nil, nil
+}
+
+// Close 停止接受新标签页，等待已打开的标签页结束后关闭浏览器，ctx 超时后强制关闭
+func (p *BrowserPool) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	close(p.done)
+	p.mu.Unlock()
+
+	idle := make(chan struct{})
+	go func() {
+		p.active.Wait()
+		close(idle)
+	}()
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		g.Log(consts.LoggerTools).Warning(ctx, "Timed out waiting for browser tabs, closing browser")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.browserCtx != nil && p.browserCtx.Err() == nil {
+		// 正常关闭浏览器，让 Chrome 清理用户数据目录
+		if err := chromedp.Cancel(p.browserCtx); err != nil {
+			g.Log(consts.LoggerTools).Warningf(ctx, "failed to close browser gracefully: %v", err)
+		}
+	}
+	p.stopLocked()
+	return nil
+}
+
+// ShutdownBrowserPool 关闭共享浏览器池，在服务退出时调用
+func ShutdownBrowserPool(ctx context.Context) error {
+	sharedBrowserPoolMu.Lock()
+	pool, proxy := sharedBrowserPool, sharedBrowserProxy
+	sharedBrowserPoolMu.Unlock()
+	if pool == nil {
+		return nil
+	}
+	err := pool.Close(ctx)
+	if proxy != nil {
+		_ = proxy.Close()
+	}
+	return err
+}
+
+// loadHTML 在当前标签页中直接载入 HTML 内容，无需写入临时文件
+func loadHTML(htmlContent string) chromedp.Action {
+	return chromedp.Tasks{
+		chromedp.Navigate("about:blank"),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			tree, err := page.GetFrameTree().Do(ctx)
+			if err != nil {
+				return err
+			}
+			return page.SetDocumentContent(tree.Frame.ID, htmlContent).Do(ctx)
+		}),
+	}
+}
+
+// pageSettledScript 等待页面加载完成、字体就绪，并且 500ms 内没有新的网络请求和未加载完的图片
+const pageSettledScript = `(async () => {
+	if (document.readyState !== 'complete') {
+		await new Promise(resolve => window.addEventListener('load', resolve, {once: true}));
+	}
+	if (document.fonts) {
+		await document.fonts.ready;
+	}
+	let last = -1, stable = 0;
+	while (stable < 2) {
+		const count = performance.getEntriesByType('resource').length;
+		const pending = Array.from(document.images).some(img => !img.complete);
+		stable = count === last && !pending ? stable + 1 : 0;
+		last = count;
+		await new Promise(resolve => setTimeout(resolve, 250));
+	}
+	return true;
+})()`
+
+// waitPageSettled 等待页面网络空闲和字体加载完成，代替固定时长的等待
+func waitPageSettled() chromedp.Action {
+	var settled bool
+	return chromedp.Evaluate(pageSettledScript, &settled, func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+		return p.WithAwaitPromise(true)
+	})
+}
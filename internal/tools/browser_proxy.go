@@ -0,0 +1,148 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// hopHeaders 代理转发时需去掉的逐跳请求头
+var hopHeaders = []string{"Connection", "Proxy-Connection", "Proxy-Authorization", "Keep-Alive", "Te", "Trailer", "Transfer-Encoding", "Upgrade"}
+
+// browserProxy 无头浏览器使用的本地过滤代理。浏览器的所有请求（包括重定向、子资源和 WebSocket）都经过代理，
+// 由代理解析域名并在拨号前检查目标 IP，浏览器自身不连接目标地址，内网地址和 DNS 重绑定都无法绕过检查
+type browserProxy struct {
+	listener  net.Listener
+	server    *http.Server
+	dialer    *net.Dialer
+	transport *http.Transport
+}
+
+// startBrowserProxy 在本机随机端口启动过滤代理，按 http.allowPrivateNetworks 决定是否拦截内网地址
+func startBrowserProxy(ctx context.Context) (*browserProxy, error) {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if !allowPrivateNetworks(ctx) {
+		dialer.Control = ssrfControl
+	}
+	return newBrowserProxy(dialer)
+}
+
+func newBrowserProxy(dialer *net.Dialer) (*browserProxy, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start browser proxy: %v", err)
+	}
+	p := &browserProxy{
+		listener: listener,
+		dialer:   dialer,
+		transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+		},
+	}
+	p.server = &http.Server{Handler: p, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := p.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			g.Log(consts.LoggerTools).Errorf(context.Background(), "browser proxy stopped: %v", err)
+		}
+	}()
+	return p, nil
+}
+
+// URL 代理地址，用于浏览器的 --proxy-server 参数
+func (p *browserProxy) URL() string {
+	return "http://" + p.listener.Addr().String()
+}
+
+func (p *browserProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.tunnel(w, r)
+		return
+	}
+	if r.URL.Scheme != "http" || r.URL.Host == "" {
+		http.Error(w, "unsupported proxy request", http.StatusBadRequest)
+		return
+	}
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, h := range hopHeaders {
+		out.Header.Del(h)
+	}
+	res, err := p.transport.RoundTrip(out)
+	if err != nil {
+		p.reject(w, r, err)
+		return
+	}
+	defer res.Body.Close()
+	for _, h := range hopHeaders {
+		res.Header.Del(h)
+	}
+	for k, values := range res.Header {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+	w.WriteHeader(res.StatusCode)
+	_, _ = io.Copy(w, res.Body)
+}
+
+// tunnel 处理 HTTPS 和 WebSocket 的 CONNECT 请求，拨号时检查目标 IP
+func (p *browserProxy) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := p.dialer.DialContext(r.Context(), "tcp", r.Host)
+	if err != nil {
+		p.reject(w, r, err)
+		return
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		upstream.Close()
+		http.Error(w, "tunneling is not supported", http.StatusInternalServerError)
+		return
+	}
+	client, buffered, err := hijacker.Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	if _, err = client.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n")); err != nil {
+		client.Close()
+		upstream.Close()
+		return
+	}
+	go func() {
+		_, _ = io.Copy(upstream, buffered)
+		upstream.Close()
+	}()
+	_, _ = io.Copy(client, upstream)
+	client.Close()
+}
+
+// reject 目标不可访问时返回 502，拦截内网地址时记录日志
+func (p *browserProxy) reject(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, ErrBlockedAddress) {
+		g.Log(consts.LoggerTools).Warningf(r.Context(), "Blocked browser request to %s: %v", r.Host, err)
+		http.Error(w, ErrBlockedAddress.Error(), http.StatusForbidden)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
+// Close 停止代理
+func (p *browserProxy) Close() error {
+	p.transport.CloseIdleConnections()
+	return p.server.Close()
+}
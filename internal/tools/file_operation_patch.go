@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hunkHeader unified diff 的 hunk 头，如 @@ -12,5 +12,6 @@
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// diffHunk unified diff 中的一个 hunk
+type diffHunk struct {
+	oldStart int
+	oldLines []string // 上下文行和删除行
+	newLines []string // 上下文行和新增行
+}
+
+// parseUnifiedDiff 解析单个文件的 unified diff，忽略 ---/+++ 文件头
+func parseUnifiedDiff(diff string) ([]*diffHunk, error) {
+	var (
+		hunks   []*diffHunk
+		current *diffHunk
+	)
+	lines := strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n")
+	// 去掉末尾换行产生的空行
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	for i, line := range lines {
+		if m := hunkHeader.FindStringSubmatch(line); m != nil {
+			start, _ := strconv.Atoi(m[1])
+			current = &diffHunk{oldStart: start}
+			hunks = append(hunks, current)
+			continue
+		}
+		if current == nil {
+			// hunk 之前的文件头（diff/---/+++/index 等）
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, `\`):
+			// \ No newline at end of file
+		case strings.HasPrefix(line, "+"):
+			current.newLines = append(current.newLines, line[1:])
+		case strings.HasPrefix(line, "-"):
+			current.oldLines = append(current.oldLines, line[1:])
+		case strings.HasPrefix(line, " "):
+			current.oldLines = append(current.oldLines, line[1:])
+			current.newLines = append(current.newLines, line[1:])
+		case line == "":
+			// 部分工具会去掉空上下文行前的空格
+			current.oldLines = append(current.oldLines, "")
+			current.newLines = append(current.newLines, "")
+		default:
+			return nil, fmt.Errorf("invalid diff line %d: %q", i+1, line)
+		}
+	}
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("no hunks found in diff")
+	}
+	return hunks, nil
+}
+
+// applyUnifiedDiff 将 unified diff 应用到文本；行号偏移时在附近查找匹配的上下文
+func applyUnifiedDiff(original, diff string) (string, int, error) {
+	hunks, err := parseUnifiedDiff(diff)
+	if err != nil {
+		return "", 0, err
+	}
+
+	trailingNewline := strings.HasSuffix(original, "\n")
+	lines := strings.Split(strings.TrimSuffix(original, "\n"), "\n")
+	if original == "" {
+		lines = nil
+	}
+
+	// offset 为已应用 hunk 带来的行数变化
+	offset := 0
+	for i, h := range hunks {
+		expected := h.oldStart - 1 + offset
+		if len(h.oldLines) == 0 {
+			// 纯新增的 hunk，-0,0 表示插入到该行之后
+			expected = h.oldStart + offset
+		}
+		pos := findHunk(lines, h.oldLines, expected)
+		if pos < 0 {
+			return "", 0, fmt.Errorf("hunk %d does not match the file content near line %d", i+1, h.oldStart)
+		}
+		patched := make([]string, 0, len(lines)-len(h.oldLines)+len(h.newLines))
+		patched = append(patched, lines[:pos]...)
+		patched = append(patched, h.newLines...)
+		patched = append(patched, lines[pos+len(h.oldLines):]...)
+		lines = patched
+		offset += len(h.newLines) - len(h.oldLines)
+	}
+
+	result := strings.Join(lines, "\n")
+	if trailingNewline || original == "" {
+		result += "\n"
+	}
+	return result, len(hunks), nil
+}
+
+// findHunk 从期望位置开始向两侧查找 hunk 原始内容的位置
+func findHunk(lines, oldLines []string, expected int) int {
+	expected = max(0, min(expected, len(lines)))
+	if len(oldLines) == 0 {
+		return expected
+	}
+	matchAt := func(pos int) bool {
+		if pos < 0 || pos+len(oldLines) > len(lines) {
+			return false
+		}
+		for i, line := range oldLines {
+			if lines[pos+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+	for delta := 0; delta <= len(lines); delta++ {
+		if matchAt(expected - delta) {
+			return expected - delta
+		}
+		if delta > 0 && matchAt(expected+delta) {
+			return expected + delta
+		}
+	}
+	return -1
+}
@@ -1,11 +1,20 @@
 package tools
 
 import (
+	"agent/internal/consts"
+	"agent/internal/logging"
+	"bufio"
 	"context"
 	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
@@ -14,10 +23,49 @@ import (
 	"github.com/gogf/gf/v2/os/gfile"
 )
 
+const (
+	defaultFileMaxReadBytes     = 64 * 1024
+	defaultFileMaxListEntries   = 500
+	defaultFileMaxSearchResults = 100
+	// fileMaxSearchBytes 超过该大小的文件不参与搜索
+	fileMaxSearchBytes = 2 * 1024 * 1024
+	// fileMaxDepth list / search 的最大递归深度
+	fileMaxDepth = 10
+	// binarySniffBytes 判断二进制文件时读取的字节数
+	binarySniffBytes = 8000
+)
+
 type FileOperationTool struct {
-	Operation string `json:"operation"`
-	FilePath  string `json:"file_path"`
-	Content   string `json:"content,omitempty"`
+	Operation   string `json:"operation"`
+	FilePath    string `json:"file_path"`
+	Content     string `json:"content,omitempty"`
+	Destination string `json:"destination,omitempty"`
+	Pattern     string `json:"pattern,omitempty"`
+	Glob        string `json:"glob,omitempty"`
+	Depth       int    `json:"depth,omitempty"`
+	StartLine   int    `json:"start_line,omitempty"`
+	EndLine     int    `json:"end_line,omitempty"`
+	OldText     string `json:"old_text,omitempty"`
+	NewText     string `json:"new_text,omitempty"`
+}
+
+// FileStat stat 操作返回的文件信息
+type FileStat struct {
+	Path     string `json:"path"`
+	Type     string `json:"type"` // file / dir / symlink
+	Size     int64  `json:"size"`
+	Mode     string `json:"mode"`
+	Modified string `json:"modified"`
+	Binary   bool   `json:"binary,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	Lines    int    `json:"lines,omitempty"`
+}
+
+// fileLimits 文件操作的输出上限
+type fileLimits struct {
+	MaxReadBytes     int
+	MaxListEntries   int
+	MaxSearchResults int
 }
 
 func NewFileOperationTool() *FileOperationTool {
@@ -27,22 +75,65 @@ func NewFileOperationTool() *FileOperationTool {
 func (t *FileOperationTool) Info(_ context.Context) (*schema.ToolInfo, error) {
 	return &schema.ToolInfo{
 		Name: "file_operation_tool",
-		Desc: "Perform basic file operations (read, write, create, delete) on files in the current directory and its subdirectories",
+		Desc: `Perform file operations on files in the session workspace.
+read returns at most a limited number of bytes, use start_line/end_line to page through large files.
+Binary files are reported with their type and size instead of their content.
+patch applies either a search/replace (old_text/new_text) or a unified diff (content) to a file.`,
 		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
 			"operation": {
 				Type:     schema.String,
 				Desc:     "File operation to perform",
-				Enum:     []string{"read", "write", "create", "delete"},
+				Enum:     []string{"read", "write", "create", "append", "delete", "list", "stat", "search", "patch", "mkdir", "move"},
 				Required: true,
 			},
 			"file_path": {
 				Type:     schema.String,
-				Desc:     "File name or relative path within current directory (e.g., 'file.txt', 'resource/pdf/doc.pdf')",
+				Desc:     "File or directory name, relative path within the session workspace (e.g., 'file.txt', 'pdf/doc.pdf', '.' for the workspace root)",
 				Required: true,
 			},
 			"content": {
 				Type:     schema.String,
-				Desc:     "Content to write (for write/create operations)",
+				Desc:     "Content to write (for write/create/append operations), or a unified diff (for patch)",
+				Required: false,
+			},
+			"destination": {
+				Type:     schema.String,
+				Desc:     "Destination path within the session workspace (for move)",
+				Required: false,
+			},
+			"pattern": {
+				Type:     schema.String,
+				Desc:     "Regular expression to search for (for search)",
+				Required: false,
+			},
+			"glob": {
+				Type:     schema.String,
+				Desc:     "Only include files whose name matches this glob, e.g. '*.md' (for list/search)",
+				Required: false,
+			},
+			"depth": {
+				Type:     schema.Integer,
+				Desc:     "Maximum directory depth to descend, default 1 for list and 10 for search",
+				Required: false,
+			},
+			"start_line": {
+				Type:     schema.Integer,
+				Desc:     "First line to read, starting at 1 (for read)",
+				Required: false,
+			},
+			"end_line": {
+				Type:     schema.Integer,
+				Desc:     "Last line to read, inclusive (for read)",
+				Required: false,
+			},
+			"old_text": {
+				Type:     schema.String,
+				Desc:     "Exact text to replace, must occur exactly once in the file (for patch)",
+				Required: false,
+			},
+			"new_text": {
+				Type:     schema.String,
+				Desc:     "Replacement text (for patch with old_text)",
 				Required: false,
 			},
 		}),
@@ -65,147 +156,662 @@ func (t *FileOperationTool) InvokableRun(ctx context.Context, argumentsInJSON st
 		return "", fmt.Errorf("file_path parameter is required")
 	}
 
-	// 3. 安全检查 - 确保只能操作当前目录的文件
-	if err := t.validateCurrentDirPath(req.FilePath); err != nil {
+	// 3. 安全检查 - 路径被限制在当前会话的工作目录内
+	workspace, err := sessionWorkspace(ctx)
+	if err != nil {
 		return "", err
 	}
+	filePath, err := resolveInWorkspace(workspace, req.FilePath)
+	if err != nil {
+		return "", err
+	}
+	limits := loadFileLimits(ctx)
 
 	// 4. 根据操作类型执行相应的文件操作
 	switch strings.ToLower(req.Operation) {
 	case "read":
-		return t.readFile(ctx, req.FilePath)
+		return t.readFile(ctx, workspace, filePath, req.StartLine, req.EndLine, limits)
 	case "write":
-		return t.writeFile(ctx, req.FilePath, req.Content)
+		return t.writeFile(ctx, workspace, filePath, req.Content)
 	case "create":
-		return t.createFile(ctx, req.FilePath, req.Content)
+		return t.createFile(ctx, workspace, filePath, req.Content)
+	case "append":
+		return t.appendFile(ctx, workspace, filePath, req.Content)
 	case "delete":
-		return t.deleteFile(ctx, req.FilePath)
+		return t.deleteFile(ctx, workspace, filePath)
+	case "list":
+		return t.listDir(ctx, workspace, filePath, req.Glob, req.Depth, limits)
+	case "stat":
+		return t.statFile(ctx, workspace, filePath)
+	case "search":
+		return t.searchFiles(ctx, workspace, filePath, req.Pattern, req.Glob, req.Depth, limits)
+	case "patch":
+		return t.patchFile(ctx, workspace, filePath, req)
+	case "mkdir":
+		return t.makeDir(ctx, workspace, filePath)
+	case "move":
+		if req.Destination == "" {
+			return "", fmt.Errorf("destination parameter is required for move")
+		}
+		destination, err := resolveInWorkspace(workspace, req.Destination)
+		if err != nil {
+			return "", err
+		}
+		return t.moveFile(ctx, workspace, filePath, destination)
 	default:
 		return "", fmt.Errorf("unsupported operation: %s", req.Operation)
 	}
 }
 
-// validateCurrentDirPath 验证路径只能在当前目录及其子目录内
-func (t *FileOperationTool) validateCurrentDirPath(path string) error {
-	// 清理路径，防止路径遍历攻击
-	cleanPath := filepath.Clean(path)
-
-	// 检查是否包含路径遍历字符
-	if strings.Contains(cleanPath, "..") {
-		return fmt.Errorf("path traversal not allowed: %s", path)
+// loadFileLimits 读取文件操作的输出上限配置
+func loadFileLimits(ctx context.Context) fileLimits {
+	l := fileLimits{
+		MaxReadBytes:     g.Cfg().MustGet(ctx, consts.FileMaxReadBytes, defaultFileMaxReadBytes).Int(),
+		MaxListEntries:   g.Cfg().MustGet(ctx, consts.FileMaxListEntries, defaultFileMaxListEntries).Int(),
+		MaxSearchResults: g.Cfg().MustGet(ctx, consts.FileMaxSearchResults, defaultFileMaxSearchResults).Int(),
+	}
+	if l.MaxReadBytes <= 0 {
+		l.MaxReadBytes = defaultFileMaxReadBytes
 	}
+	if l.MaxListEntries <= 0 {
+		l.MaxListEntries = defaultFileMaxListEntries
+	}
+	if l.MaxSearchResults <= 0 {
+		l.MaxSearchResults = defaultFileMaxSearchResults
+	}
+	return l
+}
 
-	// 检查是否为绝对路径
-	if filepath.IsAbs(cleanPath) {
-		return fmt.Errorf("absolute paths not allowed, only relative paths within current directory: %s", path)
+// checkQuota 检查写入 content 后工作目录是否超出配额
+func (t *FileOperationTool) checkQuota(ctx context.Context, workspace, filePath, content string) error {
+	addBytes, addFiles := int64(len(content)), 1
+	if stat, err := os.Stat(filePath); err == nil {
+		addBytes -= stat.Size()
+		addFiles = 0
 	}
+	return loadWorkspaceQuota(ctx).check(workspace, addBytes, addFiles)
+}
 
-	// 获取当前工作目录
-	currentDir, err := os.Getwd()
+// sniffFile 读取文件头部，判断是否为二进制文件并识别 MIME 类型
+func sniffFile(filePath string) (binary bool, mimeType string, err error) {
+	file, err := os.Open(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to get current directory: %v", err)
+		return false, "", err
 	}
+	defer file.Close()
 
-	// 获取目标文件的绝对路径
-	absPath := filepath.Join(currentDir, cleanPath)
-
-	// 确保目标路径在当前目录内（包括子目录）
-	// 添加路径分隔符确保是真正的子目录或当前目录
-	currentDirWithSep := currentDir + string(filepath.Separator)
-	absPathWithSep := absPath + string(filepath.Separator)
-
-	// 检查是否在当前目录或其子目录中
-	if !strings.HasPrefix(absPathWithSep, currentDirWithSep) && absPath != currentDir {
-		return fmt.Errorf("file must be within current directory or its subdirectories: %s", path)
+	head := make([]byte, binarySniffBytes)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return false, "", err
 	}
+	return isBinary(head[:n]), http.DetectContentType(head[:n]), nil
+}
 
-	return nil
+// isBinary 包含 NUL 字节或不是合法 UTF-8 时视为二进制内容
+func isBinary(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+	if strings.IndexByte(string(data), 0) >= 0 {
+		return true
+	}
+	// 截断处可能切断一个多字节字符
+	for i := 0; i < utf8.UTFMax && len(data) > 0; i++ {
+		if utf8.Valid(data) {
+			return false
+		}
+		data = data[:len(data)-1]
+	}
+	return true
 }
 
-// readFile 读取文件内容
-func (t *FileOperationTool) readFile(ctx context.Context, filePath string) (string, error) {
-	if !gfile.Exists(filePath) {
-		return "", fmt.Errorf("file does not exist: %s", filePath)
+// readFile 读取文件内容，支持按行范围读取，超出大小上限时截断
+func (t *FileOperationTool) readFile(ctx context.Context, workspace, filePath string, startLine, endLine int, limits fileLimits) (string, error) {
+	displayPath := workspaceRel(workspace, filePath)
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("file does not exist: %s", displayPath)
+		}
+		return "", fmt.Errorf("failed to stat file %s: %v", displayPath, err)
+	}
+	if stat.IsDir() {
+		return "", fmt.Errorf("path is a directory, not a file: %s", displayPath)
+	}
+	if stat.Size() == 0 {
+		return fmt.Sprintf("File %s is empty", displayPath), nil
 	}
 
-	if gfile.IsDir(filePath) {
-		return "", fmt.Errorf("path is a directory, not a file: %s", filePath)
+	binary, mimeType, err := sniffFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %v", displayPath, err)
+	}
+	if binary {
+		return fmt.Sprintf("Binary file %s (%s, %d bytes) cannot be displayed as text", displayPath, mimeType, stat.Size()), nil
 	}
 
-	content := gfile.GetContents(filePath)
-	if content == "" {
-		// 检查是否是因为文件为空还是读取失败
-		if stat, err := os.Stat(filePath); err == nil && stat.Size() == 0 {
-			return "File is empty", nil
+	if startLine <= 0 {
+		startLine = 1
+	}
+	if endLine > 0 && endLine < startLine {
+		return "", fmt.Errorf("end_line %d is before start_line %d", endLine, startLine)
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %v", displayPath, err)
+	}
+	defer file.Close()
+
+	var (
+		content   strings.Builder
+		lineNo    int
+		lastLine  int
+		longLine  int // 超过上限、只输出了开头部分的行
+		truncated bool
+	)
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadString('\n')
+		if line != "" {
+			lineNo++
+			if lineNo >= startLine && (endLine <= 0 || lineNo <= endLine) && !truncated {
+				if content.Len()+len(line) > limits.MaxReadBytes {
+					// 第一行就超过上限时（如压缩后的 JSON）输出该行的开头部分并跳过该行，避免反复从同一行开始读取
+					if content.Len() == 0 {
+						content.WriteString(cutUTF8(line, limits.MaxReadBytes))
+						lastLine, longLine = lineNo, lineNo
+					}
+					truncated = true
+				} else {
+					content.WriteString(line)
+					lastLine = lineNo
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to read file %s: %v", displayPath, err)
 		}
-		return "", fmt.Errorf("failed to read file or file is empty: %s", filePath)
+	}
+	if startLine > lineNo {
+		return "", fmt.Errorf("start_line %d is beyond the end of %s (%d lines)", startLine, displayPath, lineNo)
 	}
 
-	g.Log().Infof(ctx, "Successfully read file: %s (%d bytes)", filePath, len(content))
-	return fmt.Sprintf("File content of %s:\n%s", filePath, content), nil
+	g.Log(consts.LoggerTools).Infof(ctx, "Successfully read file: %s (lines %d-%d of %d)", displayPath, startLine, lastLine, lineNo)
+	result := fmt.Sprintf("File content of %s (lines %d-%d of %d):\n%s", displayPath, startLine, lastLine, lineNo, content.String())
+	switch {
+	case longLine > 0:
+		result += fmt.Sprintf("\n[truncated: line %d is longer than %d bytes and only its beginning is shown, use start_line=%d to continue with the next line]",
+			longLine, limits.MaxReadBytes, lastLine+1)
+	case truncated:
+		result += fmt.Sprintf("\n[truncated: output limited to %d bytes, use start_line=%d to continue reading]", limits.MaxReadBytes, lastLine+1)
+	}
+	return result, nil
+}
+
+// cutUTF8 截取不超过 n 个字节的前缀，不切断多字节字符
+func cutUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
 }
 
 // writeFile 写入文件内容
-func (t *FileOperationTool) writeFile(ctx context.Context, filePath, content string) (string, error) {
+func (t *FileOperationTool) writeFile(ctx context.Context, workspace, filePath, content string) (string, error) {
+	displayPath := workspaceRel(workspace, filePath)
+	if err := t.checkQuota(ctx, workspace, filePath, content); err != nil {
+		return "", err
+	}
+
 	// 确保目录存在
-	dir := filepath.Dir(filePath)
-	if dir != "." && !gfile.Exists(dir) {
-		err := gfile.Mkdir(dir)
-		if err != nil {
-			return "", fmt.Errorf("failed to create directory %s: %v", dir, err)
-		}
-		g.Log().Infof(ctx, "Created directory: %s", dir)
+	if err := t.ensureParentDir(ctx, workspace, filePath); err != nil {
+		return "", err
 	}
 
 	err := gfile.PutContents(filePath, content)
 	if err != nil {
-		return "", fmt.Errorf("failed to write file %s: %v", filePath, err)
+		return "", fmt.Errorf("failed to write file %s: %v", displayPath, err)
 	}
 
-	g.Log().Infof(ctx, "Successfully wrote to file: %s (%d bytes)", filePath, len(content))
-	return fmt.Sprintf("Successfully wrote %d bytes to file: %s", len(content), filePath), nil
+	g.Log(consts.LoggerTools).Infof(ctx, "Successfully wrote to file: %s (%d bytes)", displayPath, len(content))
+	return fmt.Sprintf("Successfully wrote %d bytes to file: %s", len(content), displayPath), nil
 }
 
 // createFile 创建新文件
-func (t *FileOperationTool) createFile(ctx context.Context, filePath, content string) (string, error) {
+func (t *FileOperationTool) createFile(ctx context.Context, workspace, filePath, content string) (string, error) {
+	displayPath := workspaceRel(workspace, filePath)
 	if gfile.Exists(filePath) {
-		return "", fmt.Errorf("file already exists: %s", filePath)
+		return "", fmt.Errorf("file already exists: %s", displayPath)
+	}
+	if err := t.checkQuota(ctx, workspace, filePath, content); err != nil {
+		return "", err
 	}
 
 	// 确保目录存在
-	dir := filepath.Dir(filePath)
-	if dir != "." && !gfile.Exists(dir) {
-		err := gfile.Mkdir(dir)
-		if err != nil {
-			return "", fmt.Errorf("failed to create directory %s: %v", dir, err)
-		}
-		g.Log().Infof(ctx, "Created directory: %s", dir)
+	if err := t.ensureParentDir(ctx, workspace, filePath); err != nil {
+		return "", err
 	}
 
 	err := gfile.PutContents(filePath, content)
 	if err != nil {
-		return "", fmt.Errorf("failed to create file %s: %v", filePath, err)
+		return "", fmt.Errorf("failed to create file %s: %v", displayPath, err)
 	}
 
-	g.Log().Infof(ctx, "Successfully created file: %s (%d bytes)", filePath, len(content))
-	return fmt.Sprintf("Successfully created file: %s with %d bytes", filePath, len(content)), nil
+	g.Log(consts.LoggerTools).Infof(ctx, "Successfully created file: %s (%d bytes)", displayPath, len(content))
+	return fmt.Sprintf("Successfully created file: %s with %d bytes", displayPath, len(content)), nil
+}
+
+// appendFile 向文件末尾追加内容，文件不存在时创建
+func (t *FileOperationTool) appendFile(ctx context.Context, workspace, filePath, content string) (string, error) {
+	displayPath := workspaceRel(workspace, filePath)
+	if gfile.IsDir(filePath) {
+		return "", fmt.Errorf("path is a directory, not a file: %s", displayPath)
+	}
+	addFiles := 1
+	if gfile.Exists(filePath) {
+		addFiles = 0
+	}
+	if err := loadWorkspaceQuota(ctx).check(workspace, int64(len(content)), addFiles); err != nil {
+		return "", err
+	}
+	if err := t.ensureParentDir(ctx, workspace, filePath); err != nil {
+		return "", err
+	}
+
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file %s: %v", displayPath, err)
+	}
+	defer file.Close()
+	if _, err = file.WriteString(content); err != nil {
+		return "", fmt.Errorf("failed to append to file %s: %v", displayPath, err)
+	}
+
+	g.Log(consts.LoggerTools).Infof(ctx, "Successfully appended to file: %s (%d bytes)", displayPath, len(content))
+	return fmt.Sprintf("Successfully appended %d bytes to file: %s", len(content), displayPath), nil
 }
 
 // deleteFile 删除文件
-func (t *FileOperationTool) deleteFile(ctx context.Context, filePath string) (string, error) {
+func (t *FileOperationTool) deleteFile(ctx context.Context, workspace, filePath string) (string, error) {
+	displayPath := workspaceRel(workspace, filePath)
 	if !gfile.Exists(filePath) {
-		return "", fmt.Errorf("file does not exist: %s", filePath)
+		return "", fmt.Errorf("file does not exist: %s", displayPath)
 	}
 
 	if gfile.IsDir(filePath) {
-		return "", fmt.Errorf("cannot delete directories, only files are supported: %s", filePath)
+		return "", fmt.Errorf("cannot delete directories, only files are supported: %s", displayPath)
 	}
 
 	err := os.Remove(filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to delete file %s: %v", filePath, err)
+		return "", fmt.Errorf("failed to delete file %s: %v", displayPath, err)
+	}
+
+	g.Log(consts.LoggerTools).Infof(ctx, "Successfully deleted file: %s", displayPath)
+	return fmt.Sprintf("Successfully deleted file: %s", displayPath), nil
+}
+
+// listDir 列出目录内容，支持 glob 过滤和递归深度
+func (t *FileOperationTool) listDir(ctx context.Context, workspace, dirPath, glob string, depth int, limits fileLimits) (string, error) {
+	displayPath := workspaceRel(workspace, dirPath)
+	if !gfile.IsDir(dirPath) {
+		return "", fmt.Errorf("directory does not exist: %s", displayPath)
+	}
+	if depth <= 0 {
+		depth = 1
+	}
+	depth = min(depth, fileMaxDepth)
+
+	var (
+		entries   []string
+		truncated bool
+	)
+	err := walkWorkspace(dirPath, depth, func(path string, d fs.DirEntry) error {
+		if glob != "" {
+			if matched, _ := filepath.Match(glob, d.Name()); !matched {
+				return nil
+			}
+		}
+		if len(entries) >= limits.MaxListEntries {
+			truncated = true
+			return fs.SkipAll
+		}
+		rel := workspaceRel(workspace, path)
+		switch {
+		case d.IsDir():
+			entries = append(entries, rel+"/")
+		case d.Type()&fs.ModeSymlink != 0:
+			entries = append(entries, rel+" (symlink)")
+		default:
+			size := int64(0)
+			if info, err := d.Info(); err == nil {
+				size = info.Size()
+			}
+			entries = append(entries, fmt.Sprintf("%s (%d bytes)", rel, size))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list directory %s: %v", displayPath, err)
+	}
+
+	g.Log(consts.LoggerTools).Infof(ctx, "Successfully listed directory: %s (%d entries)", displayPath, len(entries))
+	if len(entries) == 0 {
+		return fmt.Sprintf("No entries found in %s", displayPath), nil
+	}
+	result := fmt.Sprintf("Entries of %s:\n%s", displayPath, strings.Join(entries, "\n"))
+	if truncated {
+		result += fmt.Sprintf("\n[truncated: showing the first %d entries, narrow the glob or depth]", limits.MaxListEntries)
+	}
+	return result, nil
+}
+
+// walkWorkspace 遍历目录（不跟随符号链接），depth 为相对 dir 的最大深度
+func walkWorkspace(dir string, depth int, fn func(path string, d fs.DirEntry) error) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, path)
+		level := strings.Count(rel, string(filepath.Separator)) + 1
+		if level > depth {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		return fn(path, d)
+	})
+}
+
+// statFile 获取文件信息
+func (t *FileOperationTool) statFile(ctx context.Context, workspace, filePath string) (string, error) {
+	displayPath := workspaceRel(workspace, filePath)
+	info, err := os.Lstat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("file does not exist: %s", displayPath)
+		}
+		return "", fmt.Errorf("failed to stat file %s: %v", displayPath, err)
+	}
+
+	stat := FileStat{
+		Path:     displayPath,
+		Type:     "file",
+		Size:     info.Size(),
+		Mode:     info.Mode().String(),
+		Modified: info.ModTime().Format(time.RFC3339),
+	}
+	switch {
+	case info.IsDir():
+		stat.Type = "dir"
+	case info.Mode()&fs.ModeSymlink != 0:
+		stat.Type = "symlink"
+	default:
+		stat.Binary, stat.MimeType, err = sniffFile(filePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to read file %s: %v", displayPath, err)
+		}
+		if !stat.Binary {
+			stat.Lines, err = countLines(filePath)
+			if err != nil {
+				return "", fmt.Errorf("failed to read file %s: %v", displayPath, err)
+			}
+		}
+	}
+
+	g.Log(consts.LoggerTools).Infof(ctx, "Successfully stat file: %s", displayPath)
+	return gjson.EncodeString(stat)
+}
+
+// countLines 统计文本文件行数
+func countLines(filePath string) (int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var (
+		lines int
+		last  byte
+		buf   = make([]byte, 32*1024)
+	)
+	for {
+		n, err := file.Read(buf)
+		for _, c := range buf[:n] {
+			if c == '\n' {
+				lines++
+			}
+		}
+		if n > 0 {
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if last != 0 && last != '\n' {
+		lines++
+	}
+	return lines, nil
+}
+
+// searchFiles 在文件或目录中按正则搜索，跳过二进制文件和过大的文件
+func (t *FileOperationTool) searchFiles(ctx context.Context, workspace, root, pattern, glob string, depth int, limits fileLimits) (string, error) {
+	displayPath := workspaceRel(workspace, root)
+	if pattern == "" {
+		return "", fmt.Errorf("pattern parameter is required for search")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return "", fmt.Errorf("invalid pattern: %v", err)
+	}
+	if depth <= 0 {
+		depth = fileMaxDepth
+	}
+	depth = min(depth, fileMaxDepth)
+
+	var (
+		matches   []string
+		skipped   int
+		truncated bool
+	)
+	searchFile := func(path string) error {
+		info, err := os.Stat(path)
+		if err != nil || info.Size() > fileMaxSearchBytes {
+			skipped++
+			return nil
+		}
+		if binary, _, err := sniffFile(path); err != nil || binary {
+			skipped++
+			return nil
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			skipped++
+			return nil
+		}
+		defer file.Close()
+
+		rel := workspaceRel(workspace, path)
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), fileMaxSearchBytes)
+		for lineNo := 1; scanner.Scan(); lineNo++ {
+			if !re.MatchString(scanner.Text()) {
+				continue
+			}
+			if len(matches) >= limits.MaxSearchResults {
+				truncated = true
+				return fs.SkipAll
+			}
+			matches = append(matches, fmt.Sprintf("%s:%d: %s", rel, lineNo, truncateLine(scanner.Text(), 200)))
+		}
+		return nil
+	}
+
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", fmt.Errorf("path does not exist: %s", displayPath)
+	}
+	if info.IsDir() {
+		err = walkWorkspace(root, depth, func(path string, d fs.DirEntry) error {
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			if glob != "" {
+				if matched, _ := filepath.Match(glob, d.Name()); !matched {
+					return nil
+				}
+			}
+			return searchFile(path)
+		})
+	} else {
+		err = searchFile(root)
+	}
+	if err != nil && err != fs.SkipAll {
+		return "", fmt.Errorf("failed to search %s: %v", displayPath, err)
+	}
+
+	g.Log(consts.LoggerTools).Infof(ctx, "Successfully searched %s for %q (%d matches)", displayPath, logging.Content(pattern), len(matches))
+	if len(matches) == 0 {
+		return fmt.Sprintf("No matches for %q in %s", pattern, displayPath), nil
+	}
+	result := fmt.Sprintf("Matches for %q in %s:\n%s", pattern, displayPath, strings.Join(matches, "\n"))
+	if truncated {
+		result += fmt.Sprintf("\n[truncated: showing the first %d matches, narrow the pattern or glob]", limits.MaxSearchResults)
+	}
+	if skipped > 0 {
+		result += fmt.Sprintf("\n[skipped %d binary or oversized files]", skipped)
+	}
+	return result, nil
+}
+
+// truncateLine 截断过长的单行
+func truncateLine(line string, limit int) string {
+	if len(line) <= limit {
+		return line
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(line[cut]) {
+		cut--
+	}
+	return line[:cut] + "...[truncated]"
+}
+
+// patchFile 修改文件：old_text/new_text 精确替换，或应用 content 中的 unified diff
+func (t *FileOperationTool) patchFile(ctx context.Context, workspace, filePath string, req FileOperationTool) (string, error) {
+	displayPath := workspaceRel(workspace, filePath)
+	if !gfile.IsFile(filePath) {
+		return "", fmt.Errorf("file does not exist: %s", displayPath)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file %s: %v", displayPath, err)
+	}
+	if isBinary(data[:min(len(data), binarySniffBytes)]) {
+		return "", fmt.Errorf("cannot patch binary file: %s", displayPath)
+	}
+	original := string(data)
+
+	var patched, summary string
+	switch {
+	case req.OldText != "":
+		count := strings.Count(original, req.OldText)
+		if count == 0 {
+			return "", fmt.Errorf("old_text was not found in %s", displayPath)
+		}
+		if count > 1 {
+			return "", fmt.Errorf("old_text occurs %d times in %s, include more context to make it unique", count, displayPath)
+		}
+		patched = strings.Replace(original, req.OldText, req.NewText, 1)
+		summary = "replaced 1 occurrence"
+	case req.Content != "":
+		var hunks int
+		patched, hunks, err = applyUnifiedDiff(original, req.Content)
+		if err != nil {
+			return "", fmt.Errorf("failed to apply patch to %s: %v", displayPath, err)
+		}
+		summary = fmt.Sprintf("applied %d hunks", hunks)
+	default:
+		return "", fmt.Errorf("patch requires old_text/new_text or a unified diff in content")
 	}
 
-	g.Log().Infof(ctx, "Successfully deleted file: %s", filePath)
-	return fmt.Sprintf("Successfully deleted file: %s", filePath), nil
+	if err = t.checkQuota(ctx, workspace, filePath, patched); err != nil {
+		return "", err
+	}
+	if err = gfile.PutContents(filePath, patched); err != nil {
+		return "", fmt.Errorf("failed to write file %s: %v", displayPath, err)
+	}
+
+	g.Log(consts.LoggerTools).Infof(ctx, "Successfully patched file: %s (%s)", displayPath, summary)
+	return fmt.Sprintf("Successfully patched file: %s (%s)", displayPath, summary), nil
+}
+
+// makeDir 创建目录（包括父目录）
+func (t *FileOperationTool) makeDir(ctx context.Context, workspace, dirPath string) (string, error) {
+	displayPath := workspaceRel(workspace, dirPath)
+	if gfile.IsFile(dirPath) {
+		return "", fmt.Errorf("a file with the same name already exists: %s", displayPath)
+	}
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory %s: %v", displayPath, err)
+	}
+
+	g.Log(consts.LoggerTools).Infof(ctx, "Successfully created directory: %s", displayPath)
+	return fmt.Sprintf("Successfully created directory: %s", displayPath), nil
+}
+
+// moveFile 移动或重命名文件、目录，目标已存在时报错
+func (t *FileOperationTool) moveFile(ctx context.Context, workspace, filePath, destination string) (string, error) {
+	displayPath := workspaceRel(workspace, filePath)
+	displayDest := workspaceRel(workspace, destination)
+	if filePath == workspace {
+		return "", fmt.Errorf("cannot move the workspace root")
+	}
+	if !gfile.Exists(filePath) {
+		return "", fmt.Errorf("file does not exist: %s", displayPath)
+	}
+	if _, err := os.Lstat(destination); err == nil {
+		return "", fmt.Errorf("destination already exists: %s", displayDest)
+	}
+	if withinDir(filePath, destination) {
+		return "", fmt.Errorf("cannot move %s into itself", displayPath)
+	}
+	if err := t.ensureParentDir(ctx, workspace, destination); err != nil {
+		return "", err
+	}
+	if err := os.Rename(filePath, destination); err != nil {
+		return "", fmt.Errorf("failed to move %s to %s: %v", displayPath, displayDest, err)
+	}
+
+	g.Log(consts.LoggerTools).Infof(ctx, "Successfully moved %s to %s", displayPath, displayDest)
+	return fmt.Sprintf("Successfully moved %s to %s", displayPath, displayDest), nil
+}
+
+// ensureParentDir 确保文件的父目录存在
+func (t *FileOperationTool) ensureParentDir(ctx context.Context, workspace, filePath string) error {
+	dir := filepath.Dir(filePath)
+	if gfile.Exists(dir) {
+		return nil
+	}
+	if err := gfile.Mkdir(dir); err != nil {
+		return fmt.Errorf("failed to create directory %s: %v", workspaceRel(workspace, dir), err)
+	}
+	g.Log(consts.LoggerTools).Infof(ctx, "Created directory: %s", workspaceRel(workspace, dir))
+	return nil
 }
@@ -1,8 +1,12 @@
 package tools
 
 import (
+	"agent/internal/consts"
+	"agent/internal/model"
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/cloudwego/eino/components/tool"
@@ -30,12 +34,12 @@ func TestFileOperationTool_InvokableRun(t1 *testing.T) {
 			name: "TestFileOperationTool_InvokableRun",
 			fields: fields{
 				Operation: "write",
-				FilePath:  "resource/txt/test.txt",
+				FilePath:  "txt/test.txt",
 				Content:   "test is a test",
 			},
 			args: args{
-				ctx:             context.Background(),
-				argumentsInJSON: `{"operation": "create", "file_path": "resource/txt/test.txt", "content": "test is a test"}`,
+				ctx:             context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_file_operation"}),
+				argumentsInJSON: `{"operation": "create", "file_path": "txt/test.txt", "content": "test is a test"}`,
 				in2:             []tool.Option{},
 			},
 		},
@@ -53,3 +57,241 @@ func TestFileOperationTool_InvokableRun(t1 *testing.T) {
 		})
 	}
 }
+
+func TestFileOperationTool_ReadLongLine(t1 *testing.T) {
+	workspace := t1.TempDir()
+	filePath := filepath.Join(workspace, "data.json")
+	if err := os.WriteFile(filePath, []byte(`{"name":"数据集","items":[1,2,3]}`+"\nnext\n"), 0644); err != nil {
+		t1.Fatal(err)
+	}
+	limits := fileLimits{MaxReadBytes: 12}
+	tests := []struct {
+		name      string
+		startLine int
+		want      string
+	}{
+		{
+			name: "line longer than the limit",
+			want: "File content of data.json (lines 1-1 of 2):\n{\"name\":\"数\n" +
+				"[truncated: line 1 is longer than 12 bytes and only its beginning is shown, use start_line=2 to continue with the next line]",
+		},
+		{
+			name:      "continue with the next line",
+			startLine: 2,
+			want:      "File content of data.json (lines 2-2 of 2):\nnext\n",
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			got, err := (&FileOperationTool{}).readFile(context.Background(), workspace, filePath, tt.startLine, 0, limits)
+			if err != nil {
+				t1.Fatal(err)
+			}
+			if got != tt.want {
+				t1.Errorf("readFile() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileOperationTool_Workspace(t1 *testing.T) {
+	ctx := context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_file_operation"})
+	workspace, err := sessionWorkspace(ctx)
+	if err != nil {
+		t1.Fatal(err)
+	}
+	defer RemoveSessionWorkspace(ctx, "test_file_operation")
+	// 指向工作目录之外的符号链接
+	if err = os.Symlink(t1.TempDir(), filepath.Join(workspace, "outside")); err != nil {
+		t1.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		argumentsInJSON string
+		want            string
+		wantErr         bool
+	}{
+		{
+			name:            "write",
+			argumentsInJSON: `{"operation": "write", "file_path": "notes/a.txt", "content": "hello"}`,
+			want:            "Successfully wrote 5 bytes to file: notes/a.txt",
+		},
+		{
+			name:            "read",
+			argumentsInJSON: `{"operation": "read", "file_path": "notes/a.txt"}`,
+			want:            "File content of notes/a.txt (lines 1-1 of 1):\nhello",
+		},
+		{
+			name:            "append",
+			argumentsInJSON: `{"operation": "append", "file_path": "notes/a.txt", "content": "\nworld\nagain\n"}`,
+			want:            "Successfully appended 13 bytes to file: notes/a.txt",
+		},
+		{
+			name:            "read line range",
+			argumentsInJSON: `{"operation": "read", "file_path": "notes/a.txt", "start_line": 2, "end_line": 2}`,
+			want:            "File content of notes/a.txt (lines 2-2 of 3):\nworld\n",
+		},
+		{
+			name:            "search",
+			argumentsInJSON: `{"operation": "search", "file_path": ".", "pattern": "^wor", "glob": "*.txt"}`,
+			want:            "Matches for \"^wor\" in .:\nnotes/a.txt:2: world",
+		},
+		{
+			name:            "patch search replace",
+			argumentsInJSON: `{"operation": "patch", "file_path": "notes/a.txt", "old_text": "world", "new_text": "there"}`,
+			want:            "Successfully patched file: notes/a.txt (replaced 1 occurrence)",
+		},
+		{
+			name:            "patch ambiguous",
+			argumentsInJSON: `{"operation": "patch", "file_path": "notes/a.txt", "old_text": "e"}`,
+			wantErr:         true,
+		},
+		{
+			name:            "patch unified diff",
+			argumentsInJSON: `{"operation": "patch", "file_path": "notes/a.txt", "content": "--- a/notes/a.txt\n+++ b/notes/a.txt\n@@ -2,2 +2,2 @@\n there\n-again\n+done\n"}`,
+			want:            "Successfully patched file: notes/a.txt (applied 1 hunks)",
+		},
+		{
+			name:            "read patched",
+			argumentsInJSON: `{"operation": "read", "file_path": "notes/a.txt"}`,
+			want:            "File content of notes/a.txt (lines 1-3 of 3):\nhello\nthere\ndone\n",
+		},
+		{
+			name:            "mkdir",
+			argumentsInJSON: `{"operation": "mkdir", "file_path": "archive/2024"}`,
+			want:            "Successfully created directory: archive/2024",
+		},
+		{
+			name:            "move",
+			argumentsInJSON: `{"operation": "move", "file_path": "notes/a.txt", "destination": "archive/2024/a.txt"}`,
+			want:            "Successfully moved notes/a.txt to archive/2024/a.txt",
+		},
+		{
+			name:            "move outside",
+			argumentsInJSON: `{"operation": "move", "file_path": "archive/2024/a.txt", "destination": "../a.txt"}`,
+			wantErr:         true,
+		},
+		{
+			name:            "list",
+			argumentsInJSON: `{"operation": "list", "file_path": "archive", "depth": 2}`,
+			want:            "Entries of archive:\narchive/2024/\narchive/2024/a.txt (17 bytes)",
+		},
+		{
+			name:            "binary",
+			argumentsInJSON: `{"operation": "write", "file_path": "bin.dat", "content": "a\u0000b"}`,
+			want:            "Successfully wrote 3 bytes to file: bin.dat",
+		},
+		{
+			name:            "read binary",
+			argumentsInJSON: `{"operation": "read", "file_path": "bin.dat"}`,
+			want:            "Binary file bin.dat (application/octet-stream, 3 bytes) cannot be displayed as text",
+		},
+		{
+			name:            "traversal",
+			argumentsInJSON: `{"operation": "read", "file_path": "../../../../manifest/config/config.yaml"}`,
+			wantErr:         true,
+		},
+		{
+			name:            "absolute",
+			argumentsInJSON: `{"operation": "read", "file_path": "/etc/passwd"}`,
+			wantErr:         true,
+		},
+		{
+			name:            "symlink escape",
+			argumentsInJSON: `{"operation": "write", "file_path": "outside/a.txt", "content": "x"}`,
+			wantErr:         true,
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			got, err := NewFileOperationTool().InvokableRun(ctx, tt.argumentsInJSON)
+			if (err != nil) != tt.wantErr {
+				t1.Errorf("InvokableRun() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t1.Errorf("InvokableRun() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkspaceQuota_Check(t1 *testing.T) {
+	workspace := t1.TempDir()
+	if err := os.WriteFile(filepath.Join(workspace, "a.txt"), make([]byte, 60), 0644); err != nil {
+		t1.Fatal(err)
+	}
+	quota := workspaceQuota{MaxBytes: 100, MaxFiles: 2}
+	tests := []struct {
+		name     string
+		addBytes int64
+		addFiles int
+		wantErr  bool
+	}{
+		{name: "within quota", addBytes: 40, addFiles: 1},
+		{name: "too many bytes", addBytes: 41, addFiles: 1, wantErr: true},
+		{name: "too many files", addBytes: 1, addFiles: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			err := quota.check(workspace, tt.addBytes, tt.addFiles)
+			if (err != nil) != tt.wantErr {
+				t1.Errorf("check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyUnifiedDiff(t1 *testing.T) {
+	tests := []struct {
+		name     string
+		original string
+		diff     string
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "replace line",
+			original: "a\nb\nc\n",
+			diff:     "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:     "a\nB\nc\n",
+		},
+		{
+			name:     "shifted hunk",
+			original: "x\nx\na\nb\nc\n",
+			diff:     "@@ -1,3 +1,4 @@\n a\n b\n+b2\n c\n",
+			want:     "x\nx\na\nb\nb2\nc\n",
+		},
+		{
+			name:     "two hunks",
+			original: "1\n2\n3\n4\n5\n6\n",
+			diff:     "@@ -1,2 +1,1 @@\n-1\n 2\n@@ -5,2 +4,3 @@\n 5\n+5.5\n 6\n",
+			want:     "2\n3\n4\n5\n5.5\n6\n",
+		},
+		{
+			name:     "context mismatch",
+			original: "a\nb\n",
+			diff:     "@@ -1,2 +1,2 @@\n a\n-c\n+d\n",
+			wantErr:  true,
+		},
+		{
+			name:     "no hunks",
+			original: "a\n",
+			diff:     "just text",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			got, _, err := applyUnifiedDiff(tt.original, tt.diff)
+			if (err != nil) != tt.wantErr {
+				t1.Errorf("applyUnifiedDiff() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t1.Errorf("applyUnifiedDiff() got = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
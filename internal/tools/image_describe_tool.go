@@ -0,0 +1,126 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"agent/internal/secret"
+	"context"
+	"fmt"
+	"strings"
+
+	askmodel "github.com/cloudwego/eino-ext/components/model/ark"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+// defaultImagePrompt 未指定问题时的默认提示
+const defaultImagePrompt = "Describe this image in detail, including the main subjects, any visible text, and the overall scene."
+
+type ImageDescribeTool struct {
+	Image  string `json:"image"`
+	Prompt string `json:"prompt,omitempty"`
+
+	// chatModel 视觉模型，为空时按配置创建
+	chatModel model.BaseChatModel
+}
+
+func NewImageDescribeTool() *ImageDescribeTool {
+	return &ImageDescribeTool{}
+}
+
+func (t *ImageDescribeTool) Info(_ context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: "image_describe_tool",
+		Desc: `Look at an image with a vision model and describe it or answer a question about it.
+Use it for images found on the web (e.g. photo search results) and images attached or saved in the workspace.`,
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"image": {
+				Type:     schema.String,
+				Desc:     "The http or https URL of the image, or its relative path in the workspace (e.g. uploads/photo.jpg)",
+				Required: true,
+			},
+			"prompt": {
+				Type:     schema.String,
+				Desc:     "Optional question about the image, by default a detailed description is returned",
+				Required: false,
+			},
+		}),
+	}, nil
+}
+
+func (t *ImageDescribeTool) InvokableRun(ctx context.Context, argumentsInJSON string, _ ...tool.Option) (string, error) {
+	// 1. 反序列化参数
+	var req ImageDescribeTool
+	err := gjson.DecodeTo([]byte(argumentsInJSON), &req)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse arguments: %v", err)
+	}
+
+	// 2. 参数验证和默认值设置
+	if strings.TrimSpace(req.Image) == "" {
+		return "", fmt.Errorf("image parameter is required")
+	}
+	if req.Prompt == "" {
+		req.Prompt = defaultImagePrompt
+	}
+	part, err := ImagePart(ctx, strings.TrimSpace(req.Image))
+	if err != nil {
+		return "", err
+	}
+
+	// 3. 调用视觉模型
+	chatModel, err := t.visionModel(ctx)
+	if err != nil {
+		return "", err
+	}
+	// 在工具的回调上下文中标记为模型调用，链路追踪中作为工具的子 span
+	modelCtx := callbacks.ReuseHandlers(ctx, &callbacks.RunInfo{
+		Name:      "image_describe",
+		Type:      "Vision",
+		Component: components.ComponentOfChatModel,
+	})
+	resp, err := chatModel.Generate(modelCtx, []*schema.Message{{
+		Role: schema.User,
+		MultiContent: []schema.ChatMessagePart{
+			{Type: schema.ChatMessagePartTypeText, Text: req.Prompt},
+			part,
+		},
+	}})
+	if err != nil {
+		return fmt.Sprintf("Error describing image: %v", err), nil
+	}
+	if strings.TrimSpace(resp.Content) == "" {
+		return "Error describing image: the vision model returned an empty answer", nil
+	}
+	return resp.Content, nil
+}
+
+// imageDescribeSecrets 视觉模型使用的密钥
+var imageDescribeSecrets = secret.Declare("image_describe_tool", consts.ApiKey)
+
+// visionModel 视觉模型，未配置 ai.visionModel 时使用对话模型
+func (t *ImageDescribeTool) visionModel(ctx context.Context) (model.BaseChatModel, error) {
+	if t.chatModel != nil {
+		return t.chatModel, nil
+	}
+	modelName := g.Cfg().MustGet(ctx, consts.VisionModel).String()
+	if modelName == "" {
+		modelName = g.Cfg().MustGet(ctx, consts.Model).String()
+	}
+	apiKey, err := imageDescribeSecrets.Get(ctx, consts.ApiKey)
+	if err != nil {
+		return nil, err
+	}
+	chatModel, err := askmodel.NewChatModel(ctx, &askmodel.ChatModelConfig{
+		APIKey: apiKey,
+		Model:  modelName,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create vision model: %v", err)
+	}
+	return chatModel, nil
+}
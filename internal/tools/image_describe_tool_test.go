@@ -0,0 +1,140 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"agent/internal/model"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// pngHeader 足以被识别为 image/png 的文件头
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+// fakeVisionModel 记录收到的消息并返回固定回答
+type fakeVisionModel struct {
+	answer   string
+	err      error
+	received []*schema.Message
+}
+
+func (m *fakeVisionModel) Generate(_ context.Context, input []*schema.Message, _ ...einomodel.Option) (*schema.Message, error) {
+	m.received = input
+	if m.err != nil {
+		return nil, m.err
+	}
+	return schema.AssistantMessage(m.answer, nil), nil
+}
+
+func (m *fakeVisionModel) Stream(_ context.Context, _ []*schema.Message, _ ...einomodel.Option) (*schema.StreamReader[*schema.Message], error) {
+	return nil, errors.New("not implemented")
+}
+
+func TestImageDescribeTool_InvokableRun(t1 *testing.T) {
+	ctx := context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_image"})
+	defer RemoveSessionWorkspace(ctx, "test_image")
+	photo, err := SaveImage(ctx, "cat.png", bytes.NewReader(pngHeader))
+	if err != nil {
+		t1.Fatalf("SaveImage() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		args       string
+		modelErr   error
+		want       string
+		wantPrompt string
+		wantURL    string
+		wantErr    bool
+	}{
+		{
+			name:       "remote image",
+			args:       `{"image":"https://images.pexels.com/photos/1/cat.jpeg","prompt":"What animal is this?"}`,
+			want:       "A cat on a sofa.",
+			wantPrompt: "What animal is this?",
+			wantURL:    "https://images.pexels.com/photos/1/cat.jpeg",
+		},
+		{
+			name:       "workspace image",
+			args:       `{"image":"` + photo + `"}`,
+			want:       "A cat on a sofa.",
+			wantPrompt: defaultImagePrompt,
+			wantURL:    "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngHeader),
+		},
+		{
+			name:     "model error",
+			args:     `{"image":"https://images.pexels.com/photos/1/cat.jpeg"}`,
+			modelErr: errors.New("model unavailable"),
+			want:     "Error describing image: model unavailable",
+		},
+		{name: "missing image", args: `{"prompt":"hi"}`, wantErr: true},
+		{name: "missing file", args: `{"image":"uploads/none.png"}`, wantErr: true},
+		{name: "outside workspace", args: `{"image":"../other/cat.png"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			fake := &fakeVisionModel{answer: "A cat on a sofa.", err: tt.modelErr}
+			t := &ImageDescribeTool{chatModel: fake}
+			got, err := t.InvokableRun(ctx, tt.args)
+			if (err != nil) != tt.wantErr {
+				t1.Fatalf("InvokableRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t1.Errorf("InvokableRun() got = %v, want %v", got, tt.want)
+			}
+			if tt.wantURL == "" {
+				return
+			}
+			parts := fake.received[0].MultiContent
+			if len(parts) != 2 || parts[0].Text != tt.wantPrompt || parts[1].ImageURL == nil || parts[1].ImageURL.URL != tt.wantURL {
+				t1.Errorf("InvokableRun() sent %+v", parts)
+			}
+		})
+	}
+}
+
+func TestNormalizeImageRef(t1 *testing.T) {
+	ctx := context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_image_ref"})
+	defer RemoveSessionWorkspace(ctx, "test_image_ref")
+	if _, err := SaveImage(ctx, "notes.txt", strings.NewReader("plain text")); err == nil {
+		t1.Errorf("SaveImage() accepted a text file")
+	}
+	uploaded, err := SaveImage(ctx, "cover.png", bytes.NewReader(pngHeader))
+	if err != nil {
+		t1.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		ref        string
+		wantPrefix string
+		wantErr    bool
+	}{
+		{name: "remote", ref: "https://example.com/a.jpg", wantPrefix: "https://example.com/a.jpg"},
+		{name: "uploaded", ref: uploaded, wantPrefix: "uploads/cover"},
+		{name: "data url", ref: "data:image/png;base64," + base64.StdEncoding.EncodeToString(pngHeader), wantErr: true},
+		{name: "unsupported scheme", ref: "file:///etc/passwd", wantErr: true},
+		{name: "traversal", ref: "../../etc/passwd", wantErr: true},
+		{name: "empty", ref: " ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			got, err := NormalizeImageRef(ctx, tt.ref)
+			if (err != nil) != tt.wantErr {
+				t1.Fatalf("NormalizeImageRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !strings.HasPrefix(got, tt.wantPrefix) {
+				t1.Errorf("NormalizeImageRef() = %v, want prefix %v", got, tt.wantPrefix)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/util/guid"
+)
+
+const (
+	defaultImageMaxBytes = 10 * 1024 * 1024
+	defaultImageMaxCount = 4
+
+	// imageUploadDir 上传图片在工作目录中的保存位置
+	imageUploadDir = "uploads"
+)
+
+// imageMaxBytes 单张图片的大小上限
+func imageMaxBytes(ctx context.Context) int64 {
+	maxBytes := g.Cfg().MustGet(ctx, consts.ImageMaxBytes, defaultImageMaxBytes).Int64()
+	if maxBytes <= 0 {
+		maxBytes = defaultImageMaxBytes
+	}
+	return maxBytes
+}
+
+// ImageMaxCount 单条消息允许附带的图片数量上限
+func ImageMaxCount(ctx context.Context) int {
+	maxCount := g.Cfg().MustGet(ctx, consts.ImageMaxCount, defaultImageMaxCount).Int()
+	if maxCount <= 0 {
+		maxCount = defaultImageMaxCount
+	}
+	return maxCount
+}
+
+// imageDetail 发送给视觉模型的图片精度
+func imageDetail(ctx context.Context) schema.ImageURLDetail {
+	switch detail := schema.ImageURLDetail(g.Cfg().MustGet(ctx, consts.ImageDetail).String()); detail {
+	case schema.ImageURLDetailHigh, schema.ImageURLDetailLow:
+		return detail
+	default:
+		return schema.ImageURLDetailAuto
+	}
+}
+
+// isRemoteImage 是否为模型可以直接访问的 http(s) 图片地址
+func isRemoteImage(ref string) bool {
+	u, err := url.Parse(ref)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// SaveImage 将上传的图片保存到当前会话工作目录的 uploads 目录，返回工作目录内的相对路径
+func SaveImage(ctx context.Context, name string, src io.Reader) (string, error) {
+	// 1. 读取内容并检查大小和类型
+	maxBytes := imageMaxBytes(ctx)
+	data, err := io.ReadAll(io.LimitReader(src, maxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read image: %v", err)
+	}
+	if int64(len(data)) > maxBytes {
+		return "", fmt.Errorf("image exceeds the limit of %d bytes", maxBytes)
+	}
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("unsupported image type: %s", mimeType)
+	}
+
+	// 2. 生成文件名，扩展名以检测到的类型为准
+	name = sanitizeFilename(strings.TrimSuffix(filepath.Base(name), filepath.Ext(name)))
+	if name == "" {
+		name = "image_" + guid.S()[:8]
+	}
+	if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+		name += exts[len(exts)-1]
+	}
+
+	// 3. 写入工作目录
+	workspace, err := sessionWorkspace(ctx)
+	if err != nil {
+		return "", err
+	}
+	if err = loadWorkspaceQuota(ctx).check(workspace, int64(len(data)), 1); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(workspace, imageUploadDir)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create upload dir: %v", err)
+	}
+	filePath := uniquePath(filepath.Join(dir, name))
+	if err = os.WriteFile(filePath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save image: %v", err)
+	}
+	return workspaceRel(workspace, filePath), nil
+}
+
+// NormalizeImageRef 校验图片引用：http(s) 地址原样返回，工作目录内的路径检查其存在且为图片
+// 对话接口是 GET 请求，不接受内联的 data URL，图片内容需先通过 POST /upload 上传
+func NormalizeImageRef(ctx context.Context, ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	switch {
+	case ref == "":
+		return "", fmt.Errorf("image reference is empty")
+	case isRemoteImage(ref):
+		return ref, nil
+	case strings.HasPrefix(ref, "data:"):
+		return "", fmt.Errorf("data URLs are not accepted, upload the image with POST /upload and pass the returned path")
+	}
+	if _, _, err := readWorkspaceImage(ctx, ref); err != nil {
+		return "", err
+	}
+	return ref, nil
+}
+
+// readWorkspaceImage 读取工作目录内的图片，返回内容和类型
+func readWorkspaceImage(ctx context.Context, path string) ([]byte, string, error) {
+	workspace, err := sessionWorkspace(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	filePath, err := resolveInWorkspace(workspace, path)
+	if err != nil {
+		return nil, "", err
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", fmt.Errorf("image not found: %s", path)
+		}
+		return nil, "", fmt.Errorf("failed to stat image: %v", err)
+	}
+	if info.IsDir() {
+		return nil, "", fmt.Errorf("%s is a directory", path)
+	}
+	if maxBytes := imageMaxBytes(ctx); info.Size() > maxBytes {
+		return nil, "", fmt.Errorf("image exceeds the limit of %d bytes", maxBytes)
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image: %v", err)
+	}
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, "", fmt.Errorf("%s is not an image (%s)", path, mimeType)
+	}
+	return data, mimeType, nil
+}
+
+// ImagePart 将图片引用转换为多模态消息片段，工作目录内的图片以 data URL 内联，模型无需访问本机文件
+func ImagePart(ctx context.Context, ref string) (schema.ChatMessagePart, error) {
+	imageURL := &schema.ChatMessageImageURL{Detail: imageDetail(ctx)}
+	if isRemoteImage(ref) {
+		imageURL.URL = ref
+	} else {
+		data, mimeType, err := readWorkspaceImage(ctx, ref)
+		if err != nil {
+			return schema.ChatMessagePart{}, err
+		}
+		imageURL.URL = "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)
+		imageURL.MIMEType = mimeType
+	}
+	return schema.ChatMessagePart{Type: schema.ChatMessagePartTypeImageURL, ImageURL: imageURL}, nil
+}
@@ -0,0 +1,275 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+const (
+	defaultKnowledgeDir  = "resource/rag/document"
+	defaultKnowledgeTopK = 3
+	maxKnowledgeTopK     = 10
+
+	// KnowledgeURIScheme 知识库文档作为 MCP 资源时的 URI 前缀
+	KnowledgeURIScheme = "knowledge://"
+)
+
+// headingKeys 文档切分时记录标题层级的元数据键
+var headingKeys = []string{"h1", "h2", "h3", "h4"}
+
+type KnowledgeSearchTool struct {
+	Query string `json:"query"`
+	TopK  int    `json:"top_k,omitempty"`
+
+	// retriever 向量检索，为空或检索失败时在知识库文档中按关键词检索
+	retriever retriever.Retriever
+}
+
+// KnowledgeSearchResponse 知识库检索结果
+type KnowledgeSearchResponse struct {
+	Query   string             `json:"query"`
+	Mode    string             `json:"mode"` // vector / keyword
+	Results []*KnowledgeResult `json:"results"`
+}
+
+// KnowledgeResult 单条检索结果
+type KnowledgeResult struct {
+	Source  string  `json:"source,omitempty"` // 来源文档的资源 URI
+	Heading string  `json:"heading,omitempty"`
+	Content string  `json:"content"`
+	Score   float64 `json:"score"`
+}
+
+// KnowledgeDocument 知识库中的一篇文档
+type KnowledgeDocument struct {
+	Name  string // 文件名
+	URI   string
+	Title string // 第一个标题，没有标题时为文件名
+	Path  string // 绝对路径
+}
+
+func NewKnowledgeSearchTool(r retriever.Retriever) *KnowledgeSearchTool {
+	return &KnowledgeSearchTool{retriever: r}
+}
+
+func (t *KnowledgeSearchTool) Info(_ context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: "knowledge_search_tool",
+		Desc: `Search the knowledge base (relationship Q&A documents) and return the most relevant passages with their source document.`,
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"query": {
+				Type:     schema.String,
+				Desc:     "The question or keywords to search for",
+				Required: true,
+			},
+			"top_k": {
+				Type:     schema.Integer,
+				Desc:     fmt.Sprintf("Number of passages to return, default %d, max %d", defaultKnowledgeTopK, maxKnowledgeTopK),
+				Required: false,
+			},
+		}),
+	}, nil
+}
+
+func (t *KnowledgeSearchTool) InvokableRun(ctx context.Context, argumentsInJSON string, _ ...tool.Option) (string, error) {
+	// 1. 反序列化参数
+	var req KnowledgeSearchTool
+	err := gjson.DecodeTo([]byte(argumentsInJSON), &req)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse arguments: %v", err)
+	}
+
+	// 2. 参数验证和默认值设置
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		return "", fmt.Errorf("query parameter is required")
+	}
+	if req.TopK <= 0 {
+		req.TopK = g.Cfg().MustGet(ctx, consts.KnowledgeTopK, defaultKnowledgeTopK).Int()
+	}
+	req.TopK = min(req.TopK, maxKnowledgeTopK)
+
+	// 3. 优先向量检索，失败时退回关键词检索
+	resp := &KnowledgeSearchResponse{Query: req.Query}
+	if t.retriever != nil {
+		// 在工具的回调上下文中标记为检索调用，否则检索的回调会被当作工具回调
+		retrieverCtx := callbacks.ReuseHandlers(ctx, &callbacks.RunInfo{
+			Name:      "knowledge_base",
+			Type:      retrieverType(t.retriever),
+			Component: components.ComponentOfRetriever,
+		})
+		docs, err := t.retriever.Retrieve(retrieverCtx, req.Query, retriever.WithTopK(req.TopK))
+		if err == nil {
+			resp.Mode = "vector"
+			resp.Results = vectorResults(docs)
+			return gjson.EncodeString(resp)
+		}
+		g.Log(consts.LoggerTools).Warningf(ctx, "vector search failed, falling back to keyword search: %v", err)
+	}
+	resp.Mode = "keyword"
+	resp.Results, err = keywordSearch(ctx, req.Query, req.TopK)
+	if err != nil {
+		return fmt.Sprintf("Error searching knowledge base: %v", err), nil
+	}
+	return gjson.EncodeString(resp)
+}
+
+// retrieverType 检索器的类型，如 Milvus
+func retrieverType(r retriever.Retriever) string {
+	if typ, ok := components.GetType(r); ok {
+		return typ
+	}
+	return "Retriever"
+}
+
+// vectorResults 转换向量检索结果，标题取切分时记录的标题层级
+func vectorResults(docs []*schema.Document) []*KnowledgeResult {
+	results := make([]*KnowledgeResult, 0, len(docs))
+	for _, doc := range docs {
+		var headings []string
+		for _, key := range headingKeys {
+			if h, ok := doc.MetaData[key].(string); ok && h != "" {
+				headings = append(headings, h)
+			}
+		}
+		results = append(results, &KnowledgeResult{
+			Heading: strings.Join(headings, " / "),
+			Content: doc.Content,
+			Score:   doc.Score(),
+		})
+	}
+	return results
+}
+
+// keywordSearch 将文档按标题切分为段落，按查询词出现次数排序
+func keywordSearch(ctx context.Context, query string, topK int) ([]*KnowledgeResult, error) {
+	docs, err := ListKnowledgeDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	terms := queryTerms(query)
+	results := make([]*KnowledgeResult, 0)
+	for _, doc := range docs {
+		data, err := os.ReadFile(doc.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %v", doc.Name, err)
+		}
+		for _, section := range markdownSections(string(data)) {
+			lower := strings.ToLower(section.content)
+			score := 0
+			for _, term := range terms {
+				score += strings.Count(lower, term)
+			}
+			if score == 0 {
+				continue
+			}
+			results = append(results, &KnowledgeResult{
+				Source:  doc.URI,
+				Heading: section.heading,
+				Content: section.content,
+				Score:   float64(score),
+			})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if len(results) > topK {
+		results = results[:topK]
+	}
+	return results, nil
+}
+
+// markdownSection 按标题切分出的段落
+type markdownSection struct {
+	heading string
+	content string
+}
+
+// markdownSections 按标题行切分 Markdown，标题包含在段落内容中
+func markdownSections(text string) []markdownSection {
+	var sections []markdownSection
+	var current markdownSection
+	var body strings.Builder
+	flush := func() {
+		current.content = strings.TrimSpace(body.String())
+		if current.content != "" {
+			sections = append(sections, current)
+		}
+		body.Reset()
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(line, "#") {
+			flush()
+			current = markdownSection{heading: strings.TrimSpace(strings.TrimLeft(line, "#"))}
+		}
+		body.WriteString(line)
+		body.WriteString("\n")
+	}
+	flush()
+	return sections
+}
+
+// ListKnowledgeDocuments 列出知识库目录中的 Markdown 文档
+func ListKnowledgeDocuments(ctx context.Context) ([]*KnowledgeDocument, error) {
+	dir, err := filepath.Abs(g.Cfg().MustGet(ctx, consts.KnowledgeDir, defaultKnowledgeDir).String())
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read knowledge base: %v", err)
+	}
+	var docs []*KnowledgeDocument
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(entry.Name()), ".md") {
+			continue
+		}
+		doc := &KnowledgeDocument{
+			Name:  entry.Name(),
+			URI:   KnowledgeURIScheme + url.PathEscape(entry.Name()),
+			Title: strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())),
+			Path:  filepath.Join(dir, entry.Name()),
+		}
+		if data, err := os.ReadFile(doc.Path); err == nil {
+			if sections := markdownSections(string(data)); len(sections) > 0 && sections[0].heading != "" {
+				doc.Title = sections[0].heading
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// ReadKnowledgeDocument 按资源 URI 读取知识库文档
+func ReadKnowledgeDocument(ctx context.Context, uri string) (*KnowledgeDocument, string, error) {
+	docs, err := ListKnowledgeDocuments(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, doc := range docs {
+		if doc.URI != uri {
+			continue
+		}
+		data, err := os.ReadFile(doc.Path)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read %s: %v", doc.Name, err)
+		}
+		return doc, string(data), nil
+	}
+	return nil, "", fmt.Errorf("knowledge document not found: %s", uri)
+}
@@ -0,0 +1,166 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cloudwego/eino/components/retriever"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/encoding/gjson"
+)
+
+// fakeRetriever 返回固定文档的向量检索
+type fakeRetriever struct {
+	docs []*schema.Document
+	err  error
+	topK int
+}
+
+func (r *fakeRetriever) Retrieve(_ context.Context, _ string, opts ...retriever.Option) ([]*schema.Document, error) {
+	r.topK = *retriever.GetCommonOptions(nil, opts...).TopK
+	return r.docs, r.err
+}
+
+// newTestKnowledgeBase 创建包含两篇文档的知识库目录
+func newTestKnowledgeBase(t1 *testing.T) string {
+	dir := t1.TempDir()
+	files := map[string]string{
+		"single.md":  "# 单身篇\n#### 线上交友有哪些注意事项？\n线上交友时要完善个人资料，注意保护隐私。\n\n#### 如何提升魅力？\n保持良好形象，培养兴趣爱好。\n",
+		"married.md": "# 已婚篇\n#### 如何处理婆媳关系？\n多沟通，保持边界。线上交友要谨慎。\n",
+		"notes.txt":  "不是 Markdown 文档",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t1.Fatal(err)
+		}
+	}
+	setTestConfig(t1, consts.KnowledgeDir, dir)
+	return dir
+}
+
+func TestKnowledgeSearchTool_InvokableRun(t1 *testing.T) {
+	ctx := context.Background()
+	newTestKnowledgeBase(t1)
+
+	vector := &fakeRetriever{docs: []*schema.Document{
+		(&schema.Document{
+			Content:  "保持良好形象",
+			MetaData: map[string]any{"h1": "单身篇", "h4": "如何提升魅力？"},
+		}).WithScore(0.98),
+	}}
+	tests := []struct {
+		name        string
+		retriever   *fakeRetriever
+		args        string
+		wantMode    string
+		wantHeading []string
+		wantSource  string
+		wantTopK    int
+		wantErr     bool
+	}{
+		{
+			name:        "keyword search",
+			args:        `{"query":"线上交友 隐私"}`,
+			wantMode:    "keyword",
+			wantHeading: []string{"线上交友有哪些注意事项？", "如何处理婆媳关系？"},
+			wantSource:  KnowledgeURIScheme + "single.md",
+		},
+		{
+			name:        "top_k",
+			args:        `{"query":"线上交友","top_k":1}`,
+			wantMode:    "keyword",
+			wantHeading: []string{"线上交友有哪些注意事项？"},
+		},
+		{
+			name:     "no match",
+			args:     `{"query":"量子力学"}`,
+			wantMode: "keyword",
+		},
+		{
+			name:        "vector search",
+			retriever:   vector,
+			args:        `{"query":"魅力","top_k":20}`,
+			wantMode:    "vector",
+			wantHeading: []string{"单身篇 / 如何提升魅力？"},
+			wantTopK:    maxKnowledgeTopK,
+		},
+		{
+			name:        "vector search failure falls back to keyword",
+			retriever:   &fakeRetriever{err: errors.New("milvus is down")},
+			args:        `{"query":"婆媳"}`,
+			wantMode:    "keyword",
+			wantHeading: []string{"如何处理婆媳关系？"},
+			wantSource:  KnowledgeURIScheme + "married.md",
+		},
+		{
+			name:    "missing query",
+			args:    `{"query":"  "}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			t := NewKnowledgeSearchTool(nil)
+			if tt.retriever != nil {
+				t = NewKnowledgeSearchTool(tt.retriever)
+			}
+			got, err := t.InvokableRun(ctx, tt.args)
+			if (err != nil) != tt.wantErr {
+				t1.Fatalf("InvokableRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			var resp KnowledgeSearchResponse
+			if err = gjson.DecodeTo(got, &resp); err != nil {
+				t1.Fatalf("InvokableRun() returned invalid JSON %q: %v", got, err)
+			}
+			if resp.Mode != tt.wantMode {
+				t1.Errorf("mode = %s, want %s", resp.Mode, tt.wantMode)
+			}
+			var headings []string
+			for _, r := range resp.Results {
+				headings = append(headings, r.Heading)
+			}
+			if strings.Join(headings, "|") != strings.Join(tt.wantHeading, "|") {
+				t1.Errorf("headings = %v, want %v", headings, tt.wantHeading)
+			}
+			if tt.wantSource != "" && resp.Results[0].Source != tt.wantSource {
+				t1.Errorf("source = %s, want %s", resp.Results[0].Source, tt.wantSource)
+			}
+			if tt.wantTopK != 0 && tt.retriever.topK != tt.wantTopK {
+				t1.Errorf("retriever topK = %d, want %d", tt.retriever.topK, tt.wantTopK)
+			}
+		})
+	}
+}
+
+func TestReadKnowledgeDocument(t1 *testing.T) {
+	ctx := context.Background()
+	newTestKnowledgeBase(t1)
+
+	docs, err := ListKnowledgeDocuments(ctx)
+	if err != nil {
+		t1.Fatalf("ListKnowledgeDocuments() error = %v", err)
+	}
+	var titles []string
+	for _, doc := range docs {
+		titles = append(titles, doc.Title)
+	}
+	if strings.Join(titles, ",") != "已婚篇,单身篇" {
+		t1.Errorf("ListKnowledgeDocuments() titles = %v", titles)
+	}
+
+	doc, text, err := ReadKnowledgeDocument(ctx, KnowledgeURIScheme+"single.md")
+	if err != nil || doc.Name != "single.md" || !strings.Contains(text, "线上交友") {
+		t1.Errorf("ReadKnowledgeDocument() = %v, %q, %v", doc, text, err)
+	}
+	if _, _, err = ReadKnowledgeDocument(ctx, KnowledgeURIScheme+"notes.txt"); err == nil {
+		t1.Errorf("ReadKnowledgeDocument() should not read non-markdown files")
+	}
+}
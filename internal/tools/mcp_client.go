@@ -0,0 +1,516 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"agent/internal/secret"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	einomcp "github.com/cloudwego/eino-ext/components/tool/mcp"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	mcpclient "github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	mcpTransportSSE   = "sse"
+	mcpTransportHTTP  = "http"
+	mcpTransportStdio = "stdio"
+
+	// MCP 服务连接状态
+	MCPStatusConnecting = "connecting"
+	MCPStatusReady      = "ready"
+	MCPStatusFailed     = "failed"
+
+	defaultMCPTimeout        = 30 * time.Second
+	defaultMCPRetries        = 3
+	defaultMCPBackoff        = 2 * time.Second
+	defaultMCPHealthInterval = 30 * time.Second
+
+	// mcpClientName 连接 MCP 服务时上报的客户端信息
+	mcpClientName    = "agent"
+	mcpClientVersion = "1.0.0"
+)
+
+// errMCPUnavailable MCP 服务未连接
+var errMCPUnavailable = errors.New("mcp server is unavailable")
+
+// MCPServerConfig 单个 MCP 服务的配置
+type MCPServerConfig struct {
+	Name      string            `json:"name"`
+	Transport string            `json:"transport"` // sse / http / stdio
+	URL       string            `json:"url"`       // sse / http 的服务地址
+	Headers   map[string]string `json:"headers"`   // sse / http 的请求头
+	Command   string            `json:"command"`   // stdio 启动的命令
+	Args      []string          `json:"args"`
+	Env       []string          `json:"env"`      // stdio 额外的环境变量，KEY=VALUE 形式
+	Tools     []string          `json:"tools"`    // 允许暴露给 Agent 的工具，为空表示全部
+	Disabled  bool              `json:"disabled"` // 为 true 时不连接
+}
+
+// MCPServerHealth MCP 服务的健康状态
+type MCPServerHealth struct {
+	Name        string   `json:"name"`
+	Transport   string   `json:"transport"`
+	Status      string   `json:"status"` // connecting / ready / failed
+	Tools       []string `json:"tools"`
+	Failures    int      `json:"failures"` // 连续失败次数
+	LastError   string   `json:"last_error,omitempty"`
+	ConnectedAt int64    `json:"connected_at,omitempty"` // 最近一次连接成功的时间（Unix 秒）
+	LastCheck   int64    `json:"last_check,omitempty"`   // 最近一次健康检查的时间（Unix 秒）
+}
+
+// mcpOptions MCP 连接参数
+type mcpOptions struct {
+	Timeout        time.Duration // 单次连接、调用的超时
+	Retries        int           // 启动时的重试次数
+	Backoff        time.Duration // 首次重试前的等待时间，之后按指数增长
+	HealthInterval time.Duration // 健康检查间隔，失败的服务在检查时重连
+}
+
+// MCPManager 管理多个 MCP 服务的连接，断线后自动重连
+type MCPManager struct {
+	servers []*mcpServer
+	opts    mcpOptions
+
+	// ctx 传输层连接的生命周期，SSE 等长连接在 ctx 取消前保持
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// mcpServer 单个 MCP 服务的连接状态
+type mcpServer struct {
+	cfg MCPServerConfig
+
+	mu          sync.RWMutex
+	client      *mcpclient.Client
+	tools       []*mcpTool
+	status      string
+	failures    int
+	lastErr     string
+	connectedAt time.Time
+	lastCheck   time.Time
+}
+
+// NewMCPManager 创建 MCP 管理器，Start 后开始连接
+func NewMCPManager(servers []MCPServerConfig, opts mcpOptions) *MCPManager {
+	m := &MCPManager{opts: opts}
+	for _, cfg := range servers {
+		m.servers = append(m.servers, &mcpServer{cfg: cfg, status: MCPStatusConnecting})
+	}
+	return m
+}
+
+// Start 在后台连接所有服务并启动健康检查，连接失败不影响服务启动
+func (m *MCPManager) Start(ctx context.Context) {
+	m.ctx, m.cancel = context.WithCancel(context.WithoutCancel(ctx))
+	for _, s := range m.servers {
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.connectWithRetry(s)
+		}()
+	}
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		m.healthLoop()
+	}()
+}
+
+// Close 停止健康检查并断开所有连接
+func (m *MCPManager) Close() {
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.wg.Wait()
+	for _, s := range m.servers {
+		s.mu.Lock()
+		if s.client != nil {
+			_ = s.client.Close()
+			s.client = nil
+		}
+		s.status = MCPStatusFailed
+		s.lastErr = "manager closed"
+		s.mu.Unlock()
+	}
+}
+
+// Tools 返回已连接服务的工具，同名工具只保留先配置的服务
+func (m *MCPManager) Tools(ctx context.Context) []tool.BaseTool {
+	var result []tool.BaseTool
+	seen := make(map[string]string)
+	for _, s := range m.servers {
+		s.mu.RLock()
+		tools := s.tools
+		ready := s.status == MCPStatusReady
+		s.mu.RUnlock()
+		if !ready {
+			continue
+		}
+		for _, t := range tools {
+			if owner, ok := seen[t.info.Name]; ok {
+				g.Log(consts.LoggerTools).Warningf(ctx, "mcp tool %s from %s is shadowed by %s", t.info.Name, s.cfg.Name, owner)
+				continue
+			}
+			seen[t.info.Name] = s.cfg.Name
+			result = append(result, t)
+		}
+	}
+	return result
+}
+
+// Health 返回所有服务的健康状态
+func (m *MCPManager) Health() []*MCPServerHealth {
+	health := make([]*MCPServerHealth, 0, len(m.servers))
+	for _, s := range m.servers {
+		s.mu.RLock()
+		h := &MCPServerHealth{
+			Name:      s.cfg.Name,
+			Transport: s.cfg.Transport,
+			Status:    s.status,
+			Tools:     make([]string, 0, len(s.tools)),
+			Failures:  s.failures,
+			LastError: s.lastErr,
+		}
+		for _, t := range s.tools {
+			h.Tools = append(h.Tools, t.info.Name)
+		}
+		if !s.connectedAt.IsZero() {
+			h.ConnectedAt = s.connectedAt.Unix()
+		}
+		if !s.lastCheck.IsZero() {
+			h.LastCheck = s.lastCheck.Unix()
+		}
+		s.mu.RUnlock()
+		health = append(health, h)
+	}
+	return health
+}
+
+// connectWithRetry 启动时连接服务，失败后按指数退避重试
+func (m *MCPManager) connectWithRetry(s *mcpServer) {
+	for attempt := 0; attempt <= m.opts.Retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-m.ctx.Done():
+				return
+			case <-time.After(m.opts.Backoff * time.Duration(1<<(attempt-1))):
+			}
+		}
+		err := m.connect(s)
+		if err == nil {
+			return
+		}
+		g.Log(consts.LoggerTools).Warningf(m.ctx, "failed to connect mcp server %s (attempt %d/%d): %v", s.cfg.Name, attempt+1, m.opts.Retries+1, err)
+	}
+}
+
+// connect 建立连接、完成初始化并加载工具，成功后替换旧连接
+func (m *MCPManager) connect(s *mcpServer) (err error) {
+	defer func() {
+		if err != nil {
+			s.markFailed(err)
+		}
+	}()
+
+	// 1. 创建客户端并启动传输层
+	cli, err := newMCPClient(s.cfg, m.opts.Timeout)
+	if err != nil {
+		return err
+	}
+	if err = cli.Start(m.ctx); err != nil {
+		_ = cli.Close()
+		return fmt.Errorf("failed to start transport: %v", err)
+	}
+
+	// 2. 初始化并获取工具列表
+	ctx, cancel := context.WithTimeout(m.ctx, m.opts.Timeout)
+	defer cancel()
+	initRequest := mcp.InitializeRequest{}
+	initRequest.Params.ProtocolVersion = mcp.LATEST_PROTOCOL_VERSION
+	initRequest.Params.ClientInfo = mcp.Implementation{Name: mcpClientName, Version: mcpClientVersion}
+	if _, err = cli.Initialize(ctx, initRequest); err != nil {
+		_ = cli.Close()
+		return fmt.Errorf("failed to initialize: %v", err)
+	}
+	baseTools, err := einomcp.GetTools(ctx, &einomcp.Config{Cli: cli, ToolNameList: s.cfg.Tools})
+	if err != nil {
+		_ = cli.Close()
+		return fmt.Errorf("failed to list tools: %v", err)
+	}
+	tools := make([]*mcpTool, 0, len(baseTools))
+	for _, bt := range baseTools {
+		info, err := bt.Info(ctx)
+		if err != nil {
+			continue
+		}
+		tools = append(tools, &mcpTool{server: s, info: info, timeout: m.opts.Timeout})
+	}
+	for _, name := range s.cfg.Tools {
+		if !slices.ContainsFunc(tools, func(t *mcpTool) bool { return t.info.Name == name }) {
+			g.Log(consts.LoggerTools).Warningf(ctx, "mcp server %s does not provide allowed tool %s", s.cfg.Name, name)
+		}
+	}
+
+	// 3. 连接断开时标记失败，由健康检查重连
+	cli.OnConnectionLost(func(err error) {
+		s.markFailed(fmt.Errorf("connection lost: %v", err))
+	})
+
+	s.mu.Lock()
+	old := s.client
+	s.client, s.tools = cli, tools
+	s.status, s.failures, s.lastErr = MCPStatusReady, 0, ""
+	s.connectedAt = time.Now()
+	s.mu.Unlock()
+	if old != nil {
+		_ = old.Close()
+	}
+	g.Log(consts.LoggerTools).Infof(m.ctx, "Connected mcp server %s with %d tools", s.cfg.Name, len(tools))
+	return nil
+}
+
+// healthLoop 定时检查已连接的服务，失败的服务尝试重连
+func (m *MCPManager) healthLoop() {
+	ticker := time.NewTicker(m.opts.HealthInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, s := range m.servers {
+			m.checkHealth(s)
+		}
+	}
+}
+
+// checkHealth 对已连接的服务发送 ping，失败或未连接时重连一次
+func (m *MCPManager) checkHealth(s *mcpServer) {
+	s.mu.Lock()
+	cli, status := s.client, s.status
+	s.lastCheck = time.Now()
+	s.mu.Unlock()
+
+	if status == MCPStatusReady && cli != nil {
+		ctx, cancel := context.WithTimeout(m.ctx, m.opts.Timeout)
+		err := cli.Ping(ctx)
+		cancel()
+		if err == nil {
+			return
+		}
+		if m.ctx.Err() != nil {
+			return
+		}
+		s.markFailed(fmt.Errorf("ping failed: %v", err))
+	}
+	if err := m.connect(s); err != nil {
+		g.Log(consts.LoggerTools).Warningf(m.ctx, "failed to reconnect mcp server %s: %v", s.cfg.Name, err)
+	}
+}
+
+// markFailed 记录失败原因
+func (s *mcpServer) markFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = MCPStatusFailed
+	s.failures++
+	// 连接错误中可能带有地址中的密钥
+	s.lastErr = secret.Mask(err.Error())
+}
+
+// newMCPClient 按传输方式创建客户端
+func newMCPClient(cfg MCPServerConfig, timeout time.Duration) (*mcpclient.Client, error) {
+	switch cfg.Transport {
+	case mcpTransportSSE:
+		return mcpclient.NewSSEMCPClient(cfg.URL, transport.WithHeaders(cfg.Headers))
+	case mcpTransportHTTP:
+		return mcpclient.NewStreamableHttpClient(cfg.URL, transport.WithHTTPHeaders(cfg.Headers), transport.WithHTTPTimeout(timeout))
+	case mcpTransportStdio:
+		// stdio 客户端创建时即启动子进程
+		return mcpclient.NewStdioMCPClientWithOptions(cfg.Command, cfg.Env, cfg.Args)
+	default:
+		return nil, fmt.Errorf("unsupported mcp transport: %s", cfg.Transport)
+	}
+}
+
+// mcpTool 通过所属服务的当前连接调用 MCP 工具，服务重连后无需重新创建
+type mcpTool struct {
+	server  *mcpServer
+	info    *schema.ToolInfo
+	timeout time.Duration
+}
+
+func (t *mcpTool) Info(_ context.Context) (*schema.ToolInfo, error) {
+	return t.info, nil
+}
+
+func (t *mcpTool) InvokableRun(ctx context.Context, argumentsInJSON string, _ ...tool.Option) (string, error) {
+	// 1. 获取当前连接
+	t.server.mu.RLock()
+	cli, status := t.server.client, t.server.status
+	t.server.mu.RUnlock()
+	if status != MCPStatusReady || cli == nil {
+		return fmt.Sprintf("Error calling MCP tool %s: %v: %s", t.info.Name, errMCPUnavailable, t.server.cfg.Name), nil
+	}
+
+	// 2. 调用工具
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	request := mcp.CallToolRequest{}
+	request.Params.Name = t.info.Name
+	request.Params.Arguments = gjson.New(argumentsInJSON).Map()
+	result, err := cli.CallTool(ctx, request)
+	if err != nil {
+		return fmt.Sprintf("Error calling MCP tool %s: %v", t.info.Name, err), nil
+	}
+
+	// 3. 提取文本结果
+	text := mcpResultText(result)
+	if result.IsError {
+		return fmt.Sprintf("Error from MCP tool %s: %s", t.info.Name, text), nil
+	}
+	return text, nil
+}
+
+// mcpResultText 将工具结果转换为文本，非文本内容以 JSON 表示
+func mcpResultText(result *mcp.CallToolResult) string {
+	var parts []string
+	for _, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok {
+			parts = append(parts, text.Text)
+			continue
+		}
+		data, _ := gjson.Marshal(content)
+		parts = append(parts, string(data))
+	}
+	if len(parts) == 0 && result.StructuredContent != nil {
+		data, _ := gjson.Marshal(result.StructuredContent)
+		parts = append(parts, string(data))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// mcpSecrets MCP 服务配置，其中可以有密钥引用
+var mcpSecrets = secret.Declare("mcp_client", consts.MCPServers)
+
+// loadMCPConfig 读取并校验 MCP 服务配置，跳过禁用的服务
+func loadMCPConfig(ctx context.Context) ([]MCPServerConfig, mcpOptions, error) {
+	opts := mcpOptions{
+		Timeout:        g.Cfg().MustGet(ctx, consts.MCPTimeout, defaultMCPTimeout).Duration(),
+		Retries:        g.Cfg().MustGet(ctx, consts.MCPRetries, defaultMCPRetries).Int(),
+		Backoff:        g.Cfg().MustGet(ctx, consts.MCPBackoff, defaultMCPBackoff).Duration(),
+		HealthInterval: g.Cfg().MustGet(ctx, consts.MCPHealthInterval, defaultMCPHealthInterval).Duration(),
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultMCPTimeout
+	}
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = defaultMCPBackoff
+	}
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = defaultMCPHealthInterval
+	}
+
+	// 服务地址、请求头和环境变量中可以有密钥引用，使用启动时解析的配置
+	var all []MCPServerConfig
+	if err := mcpSecrets.Scan(ctx, consts.MCPServers, &all); err != nil {
+		return nil, opts, fmt.Errorf("failed to parse mcp servers: %v", err)
+	}
+	servers := make([]MCPServerConfig, 0, len(all))
+	names := make(map[string]bool)
+	for _, cfg := range all {
+		if cfg.Disabled {
+			continue
+		}
+		switch {
+		case cfg.Name == "":
+			return nil, opts, fmt.Errorf("mcp server name is required")
+		case names[cfg.Name]:
+			return nil, opts, fmt.Errorf("duplicate mcp server name: %s", cfg.Name)
+		case cfg.Transport == mcpTransportStdio && cfg.Command == "":
+			return nil, opts, fmt.Errorf("mcp server %s: command is required for stdio transport", cfg.Name)
+		case (cfg.Transport == mcpTransportSSE || cfg.Transport == mcpTransportHTTP) && cfg.URL == "":
+			return nil, opts, fmt.Errorf("mcp server %s: url is required for %s transport", cfg.Name, cfg.Transport)
+		case cfg.Transport != mcpTransportSSE && cfg.Transport != mcpTransportHTTP && cfg.Transport != mcpTransportStdio:
+			return nil, opts, fmt.Errorf("mcp server %s: unsupported transport %s", cfg.Name, cfg.Transport)
+		}
+		names[cfg.Name] = true
+		servers = append(servers, cfg)
+	}
+	return servers, opts, nil
+}
+
+var (
+	mcpManagerMu sync.Mutex
+	mcpManager   *MCPManager
+)
+
+// StartMCPManager 按配置连接 MCP 服务，服务启动时调用
+func StartMCPManager(ctx context.Context) error {
+	servers, opts, err := loadMCPConfig(ctx)
+	if err != nil {
+		return err
+	}
+	mcpManagerMu.Lock()
+	defer mcpManagerMu.Unlock()
+	if mcpManager != nil {
+		mcpManager.Close()
+	}
+	mcpManager = NewMCPManager(servers, opts)
+	mcpManager.Start(ctx)
+	return nil
+}
+
+// MCPTools 返回已连接的 MCP 服务提供的工具
+func MCPTools(ctx context.Context) []tool.BaseTool {
+	mcpManagerMu.Lock()
+	m := mcpManager
+	mcpManagerMu.Unlock()
+	if m == nil {
+		return nil
+	}
+	return m.Tools(ctx)
+}
+
+// IsMCPTool 是否为 MCP 服务提供的工具
+func IsMCPTool(t tool.BaseTool) bool {
+	_, ok := t.(*mcpTool)
+	return ok
+}
+
+// MCPHealth 返回 MCP 服务的健康状态
+func MCPHealth() []*MCPServerHealth {
+	mcpManagerMu.Lock()
+	m := mcpManager
+	mcpManagerMu.Unlock()
+	if m == nil {
+		return []*MCPServerHealth{}
+	}
+	return m.Health()
+}
+
+// ShutdownMCPManager 断开所有 MCP 连接，服务退出时调用
+func ShutdownMCPManager() {
+	mcpManagerMu.Lock()
+	defer mcpManagerMu.Unlock()
+	if mcpManager != nil {
+		mcpManager.Close()
+		mcpManager = nil
+	}
+}
@@ -0,0 +1,244 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// newTestMCPServer 启动提供 echo 和 secret 两个工具的 streamable HTTP MCP 服务，down 为 true 时拒绝所有请求
+func newTestMCPServer(t1 *testing.T, down *atomic.Bool) *httptest.Server {
+	s := server.NewMCPServer("test", "1.0.0", server.WithToolCapabilities(true))
+	s.AddTool(mcp.NewTool("echo",
+		mcp.WithDescription("Echo the message"),
+		mcp.WithString("message", mcp.Required(), mcp.Description("The message to echo")),
+	), func(_ context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		message, err := request.RequireString("message")
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText("echo: " + message), nil
+	})
+	s.AddTool(mcp.NewTool("secret", mcp.WithDescription("Not allowed")),
+		func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultText("secret"), nil
+		})
+
+	handler := server.NewStreamableHTTPServer(s)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if down.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t1.Cleanup(ts.Close)
+	return ts
+}
+
+// newTestMCPManager 创建未启动后台任务的管理器，由测试直接调用 connect 和 checkHealth
+func newTestMCPManager(t1 *testing.T, servers ...MCPServerConfig) *MCPManager {
+	m := NewMCPManager(servers, mcpOptions{
+		Timeout:        5 * time.Second,
+		Retries:        1,
+		Backoff:        10 * time.Millisecond,
+		HealthInterval: time.Hour,
+	})
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	t1.Cleanup(m.Close)
+	return m
+}
+
+func TestMCPManager(t1 *testing.T) {
+	ctx := context.Background()
+	var down atomic.Bool
+	ts := newTestMCPServer(t1, &down)
+
+	m := newTestMCPManager(t1,
+		MCPServerConfig{Name: "test", Transport: mcpTransportHTTP, URL: ts.URL, Tools: []string{"echo"}},
+		MCPServerConfig{Name: "broken", Transport: mcpTransportHTTP, URL: "http://127.0.0.1:1/mcp"},
+	)
+	m.connectWithRetry(m.servers[0])
+	m.connectWithRetry(m.servers[1])
+
+	// 1. 只暴露允许的工具，未连接的服务不提供工具
+	tools := m.Tools(ctx)
+	if len(tools) != 1 {
+		t1.Fatalf("Tools() returned %d tools, want 1", len(tools))
+	}
+	info, err := tools[0].Info(ctx)
+	if err != nil || info.Name != "echo" {
+		t1.Fatalf("Tools()[0] = %v, %v, want echo", info, err)
+	}
+
+	health := m.Health()
+	if health[0].Status != MCPStatusReady || strings.Join(health[0].Tools, ",") != "echo" || health[0].ConnectedAt == 0 {
+		t1.Errorf("Health()[0] = %+v, want ready with echo", health[0])
+	}
+	if health[1].Status != MCPStatusFailed || health[1].Failures != 2 || health[1].LastError == "" {
+		t1.Errorf("Health()[1] = %+v, want failed after 2 attempts", health[1])
+	}
+
+	// 2. 调用工具
+	echo := tools[0].(*mcpTool)
+	tests := []struct {
+		name    string
+		args    string
+		want    string
+		prepare func()
+	}{
+		{
+			name: "call tool",
+			args: `{"message":"hello"}`,
+			want: "echo: hello",
+		},
+		{
+			name: "tool error",
+			args: `{}`,
+			want: "Error from MCP tool echo",
+		},
+		{
+			name: "server down",
+			args: `{"message":"hello"}`,
+			want: "Error calling MCP tool echo",
+			prepare: func() {
+				down.Store(true)
+			},
+		},
+		{
+			name: "marked failed after health check",
+			args: `{"message":"hello"}`,
+			want: "mcp server is unavailable: test",
+			prepare: func() {
+				m.checkHealth(m.servers[0])
+			},
+		},
+		{
+			name: "reconnected after recovery",
+			args: `{"message":"again"}`,
+			want: "echo: again",
+			prepare: func() {
+				down.Store(false)
+				m.checkHealth(m.servers[0])
+			},
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			if tt.prepare != nil {
+				tt.prepare()
+			}
+			got, err := echo.InvokableRun(ctx, tt.args)
+			if err != nil {
+				t1.Fatalf("InvokableRun() error = %v", err)
+			}
+			if !strings.Contains(got, tt.want) {
+				t1.Errorf("InvokableRun() = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+
+	health = m.Health()
+	if health[0].Status != MCPStatusReady || health[0].Failures != 0 || health[0].LastCheck == 0 {
+		t1.Errorf("Health()[0] after recovery = %+v", health[0])
+	}
+}
+
+func TestLoadMCPConfig(t1 *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name       string
+		servers    []map[string]any
+		wantNames  []string
+		wantHeader string
+		wantErr    string
+	}{
+		{
+			name: "disabled servers are skipped",
+			servers: []map[string]any{
+				{"name": "a", "transport": "http", "url": "http://localhost/mcp", "tools": []string{"x"}},
+				{"name": "b", "transport": "stdio", "command": "mcp-server", "disabled": true},
+				{"name": "c", "transport": "stdio", "command": "mcp-server", "args": []string{"--stdio"}},
+			},
+			wantNames: []string{"a", "c"},
+		},
+		{
+			name: "secret references are resolved",
+			servers: []map[string]any{
+				{"name": "a", "transport": "http", "url": "http://localhost/mcp", "headers": map[string]any{"Authorization": "Bearer ${env:MCP_TEST_TOKEN}"}},
+			},
+			wantNames:  []string{"a"},
+			wantHeader: "Bearer mcp-test-token",
+		},
+		{
+			name: "unresolvable reference",
+			servers: []map[string]any{
+				{"name": "a", "transport": "http", "url": "http://localhost/mcp", "headers": map[string]any{"Authorization": "Bearer ${env:MCP_TEST_MISSING}"}},
+			},
+			wantErr: "MCP_TEST_MISSING",
+		},
+		{
+			name:    "missing url",
+			servers: []map[string]any{{"name": "a", "transport": "sse"}},
+			wantErr: "url is required",
+		},
+		{
+			name:    "missing command",
+			servers: []map[string]any{{"name": "a", "transport": "stdio"}},
+			wantErr: "command is required",
+		},
+		{
+			name:    "unknown transport",
+			servers: []map[string]any{{"name": "a", "transport": "websocket", "url": "ws://localhost"}},
+			wantErr: "unsupported transport",
+		},
+		{
+			name: "duplicate name",
+			servers: []map[string]any{
+				{"name": "a", "transport": "http", "url": "http://localhost/a"},
+				{"name": "a", "transport": "http", "url": "http://localhost/b"},
+			},
+			wantErr: "duplicate mcp server name",
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			t1.Setenv("MCP_TEST_TOKEN", "mcp-test-token")
+			// 服务配置在启动时解析，修改配置后重新解析，测试结束恢复配置后再解析一次
+			t1.Cleanup(func() { mcpSecrets.Reload(ctx) })
+			setTestConfig(t1, consts.MCPServers, tt.servers)
+			mcpSecrets.Reload(ctx)
+			servers, opts, err := loadMCPConfig(ctx)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t1.Fatalf("loadMCPConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t1.Fatalf("loadMCPConfig() error = %v", err)
+			}
+			var names []string
+			for _, s := range servers {
+				names = append(names, s.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+				t1.Errorf("loadMCPConfig() servers = %v, want %v", names, tt.wantNames)
+			}
+			if opts.Timeout <= 0 || opts.HealthInterval <= 0 {
+				t1.Errorf("loadMCPConfig() opts = %+v", opts)
+			}
+			if tt.wantHeader != "" && servers[0].Headers["Authorization"] != tt.wantHeader {
+				t1.Errorf("loadMCPConfig() headers = %v, want %q", servers[0].Headers, tt.wantHeader)
+			}
+		})
+	}
+}
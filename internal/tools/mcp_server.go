@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"agent/internal/audit"
+	"agent/internal/consts"
+	"agent/internal/logging"
+	"agent/internal/model"
+	"agent/internal/secret"
+	"context"
+	"fmt"
+
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// mcpSessionPrefix MCP 会话对应的工作目录前缀，与 HTTP 会话区分
+const mcpSessionPrefix = "mcp_"
+
+// MCPSessionID 当前 MCP 会话对应的会话 ID，stdio 只有一个会话
+func MCPSessionID(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil && session.SessionID() != "" {
+		return mcpSessionPrefix + session.SessionID()
+	}
+	return mcpSessionPrefix + "default"
+}
+
+// MCPServerTool 将工具包装为 MCP 工具，调用时使用 MCP 会话的工作目录
+func MCPServerTool(ctx context.Context, t tool.BaseTool) (server.ServerTool, error) {
+	invokable, ok := t.(tool.InvokableTool)
+	if !ok {
+		return server.ServerTool{}, fmt.Errorf("tool %T is not invokable", t)
+	}
+	info, err := t.Info(ctx)
+	if err != nil {
+		return server.ServerTool{}, fmt.Errorf("failed to get tool info: %v", err)
+	}
+	inputSchema := []byte(`{"type":"object","properties":{}}`)
+	if info.ParamsOneOf != nil {
+		js, err := info.ParamsOneOf.ToJSONSchema()
+		if err != nil {
+			return server.ServerTool{}, fmt.Errorf("failed to convert schema of %s: %v", info.Name, err)
+		}
+		if inputSchema, err = gjson.Marshal(js); err != nil {
+			return server.ServerTool{}, fmt.Errorf("failed to marshal schema of %s: %v", info.Name, err)
+		}
+	}
+
+	return server.ServerTool{
+		Tool: mcp.NewToolWithRawSchema(info.Name, info.Desc, inputSchema),
+		Handler: func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			arguments := []byte("{}")
+			if request.Params.Arguments != nil {
+				if arguments, err = gjson.Marshal(request.Params.Arguments); err != nil {
+					return mcp.NewToolResultError(fmt.Sprintf("invalid arguments: %v", err)), nil
+				}
+			}
+			if _, ok := ctx.Value(consts.ContextKey).(*model.Context); !ok {
+				ctx = context.WithValue(ctx, consts.ContextKey, &model.Context{SessionID: MCPSessionID(ctx), Agent: consts.AgentMCP})
+			}
+			ctx = logging.WithTool(ctx, info.Name)
+			ctx, entry := audit.Start(ctx, info.Name, "", string(arguments))
+			result, err := invokable.InvokableRun(ctx, string(arguments))
+			entry.Finish(ctx, result, err)
+			if err != nil {
+				return mcp.NewToolResultError(secret.Mask(err.Error())), nil
+			}
+			return mcp.NewToolResultText(secret.Mask(result)), nil
+		},
+	}, nil
+}
+
+// MCPKnowledgeResources 将知识库文档作为 MCP 资源提供
+func MCPKnowledgeResources(ctx context.Context) ([]server.ServerResource, error) {
+	docs, err := ListKnowledgeDocuments(ctx)
+	if err != nil {
+		return nil, err
+	}
+	resources := make([]server.ServerResource, 0, len(docs))
+	for _, doc := range docs {
+		resources = append(resources, server.ServerResource{
+			Resource: mcp.NewResource(doc.URI, doc.Title,
+				mcp.WithResourceDescription("Knowledge base document "+doc.Name),
+				mcp.WithMIMEType("text/markdown"),
+			),
+			Handler: func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+				_, text, err := ReadKnowledgeDocument(ctx, request.Params.URI)
+				if err != nil {
+					return nil, err
+				}
+				return []mcp.ResourceContents{mcp.TextResourceContents{
+					URI:      request.Params.URI,
+					MIMEType: "text/markdown",
+					Text:     text,
+				}}, nil
+			},
+		})
+	}
+	return resources, nil
+}
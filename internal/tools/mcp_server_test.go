@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestMCPServerTool(t1 *testing.T) {
+	ctx := context.Background()
+	newTestKnowledgeBase(t1)
+
+	serverTool, err := MCPServerTool(ctx, NewKnowledgeSearchTool(nil))
+	if err != nil {
+		t1.Fatalf("MCPServerTool() error = %v", err)
+	}
+	if serverTool.Tool.Name != "knowledge_search_tool" {
+		t1.Errorf("tool name = %s", serverTool.Tool.Name)
+	}
+	inputSchema := gjson.New(string(serverTool.Tool.RawInputSchema))
+	if inputSchema.Get("properties.query.type").String() != "string" || inputSchema.Get("required.0").String() != "query" {
+		t1.Errorf("input schema = %s", serverTool.Tool.RawInputSchema)
+	}
+
+	tests := []struct {
+		name      string
+		arguments any
+		want      string
+		wantError bool
+	}{
+		{
+			name:      "call tool",
+			arguments: map[string]any{"query": "婆媳"},
+			want:      `"mode":"keyword"`,
+		},
+		{
+			name:      "tool error",
+			arguments: map[string]any{"query": ""},
+			want:      "query parameter is required",
+			wantError: true,
+		},
+		{
+			name:      "missing arguments",
+			want:      "query parameter is required",
+			wantError: true,
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			request := mcp.CallToolRequest{}
+			request.Params.Name = serverTool.Tool.Name
+			request.Params.Arguments = tt.arguments
+			result, err := serverTool.Handler(ctx, request)
+			if err != nil {
+				t1.Fatalf("Handler() error = %v", err)
+			}
+			if result.IsError != tt.wantError {
+				t1.Errorf("IsError = %v, want %v", result.IsError, tt.wantError)
+			}
+			if got := mcpResultText(result); !strings.Contains(got, tt.want) {
+				t1.Errorf("Handler() = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMCPSessionID(t1 *testing.T) {
+	ctx := context.Background()
+	if got := MCPSessionID(ctx); got != "mcp_default" {
+		t1.Errorf("MCPSessionID() = %s, want mcp_default", got)
+	}
+
+}
+
+func TestMCPKnowledgeResources(t1 *testing.T) {
+	ctx := context.Background()
+	newTestKnowledgeBase(t1)
+
+	resources, err := MCPKnowledgeResources(ctx)
+	if err != nil {
+		t1.Fatalf("MCPKnowledgeResources() error = %v", err)
+	}
+	if len(resources) != 2 {
+		t1.Fatalf("MCPKnowledgeResources() returned %d resources, want 2", len(resources))
+	}
+	for _, resource := range resources {
+		if !strings.HasPrefix(resource.Resource.URI, KnowledgeURIScheme) || resource.Resource.MIMEType != "text/markdown" {
+			t1.Errorf("resource = %+v", resource.Resource)
+		}
+		request := mcp.ReadResourceRequest{}
+		request.Params.URI = resource.Resource.URI
+		contents, err := resource.Handler(ctx, request)
+		if err != nil {
+			t1.Fatalf("Handler(%s) error = %v", resource.Resource.URI, err)
+		}
+		text, ok := contents[0].(mcp.TextResourceContents)
+		if !ok || !strings.HasPrefix(text.Text, "# "+resource.Resource.Name) {
+			t1.Errorf("Handler(%s) = %+v", resource.Resource.URI, contents[0])
+		}
+	}
+}
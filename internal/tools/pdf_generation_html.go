@@ -0,0 +1,453 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"html/template"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	pdfFormatMarkdown = "markdown"
+	pdfFormatHTML     = "html"
+	pdfFormatText     = "text"
+
+	defaultPDFTemplate    = "default"
+	defaultPDFTemplateDir = "resource/template/pdf"
+)
+
+// pdfFormats 支持的内容格式
+var pdfFormats = []string{pdfFormatMarkdown, pdfFormatHTML, pdfFormatText}
+
+// pdfTemplateName 模板名只允许字母、数字、下划线和连字符，防止读取模板目录之外的文件
+var pdfTemplateName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// imgSrc HTML 中图片的 src 属性
+var imgSrc = regexp.MustCompile(`(<img\b[^>]*?\bsrc\s*=\s*)("([^"]*)"|'([^']*)')`)
+
+// blankLines 纯文本按空行分段
+var blankLines = regexp.MustCompile(`\n\s*\n`)
+
+// pdfMarkdown Markdown 渲染器，支持 GFM 表格、删除线、任务列表和内嵌 HTML
+var pdfMarkdown = goldmark.New(
+	goldmark.WithExtensions(extension.GFM, extension.Footnote),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+	goldmark.WithRendererOptions(gmhtml.WithUnsafe()),
+)
+
+// pdfTemplateData 渲染 PDF 模板的数据
+type pdfTemplateData struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string
+	Date     string
+	TOC      template.HTML
+	Content  template.HTML
+}
+
+// tocEntry 目录中的一个标题
+type tocEntry struct {
+	Level int
+	ID    string
+	Text  string
+}
+
+// renderPDFBody 将 markdown/html/text 内容转换为 HTML 片段
+func renderPDFBody(format, content string) (string, error) {
+	switch format {
+	case pdfFormatMarkdown:
+		var buf bytes.Buffer
+		if err := pdfMarkdown.Convert([]byte(content), &buf); err != nil {
+			return "", fmt.Errorf("failed to render markdown: %v", err)
+		}
+		return buf.String(), nil
+	case pdfFormatHTML:
+		return content, nil
+	case pdfFormatText:
+		var b strings.Builder
+		for _, para := range blankLines.Split(strings.ReplaceAll(content, "\r\n", "\n"), -1) {
+			para = strings.TrimSpace(para)
+			if para == "" {
+				continue
+			}
+			b.WriteString("<p>")
+			b.WriteString(strings.ReplaceAll(html.EscapeString(para), "\n", "<br>"))
+			b.WriteString("</p>\n")
+		}
+		return b.String(), nil
+	default:
+		return "", fmt.Errorf("unsupported format: %s", format)
+	}
+}
+
+// isHTMLDocument 内容是否为完整的 HTML 文档，完整文档不套用模板
+func isHTMLDocument(content string) bool {
+	head := strings.ToLower(strings.TrimSpace(content))
+	return strings.HasPrefix(head, "<!doctype") || strings.HasPrefix(head, "<html")
+}
+
+// buildTOC 为 h1-h3 标题补全 id 并生成目录，返回处理后的 HTML 和目录
+func buildTOC(body string) (string, []tocEntry, error) {
+	container := &xhtml.Node{Type: xhtml.ElementNode, Data: "div", DataAtom: atom.Div}
+	nodes, err := xhtml.ParseFragment(strings.NewReader(body), container)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to parse content: %v", err)
+	}
+
+	var entries []tocEntry
+	used := make(map[string]bool)
+	var walk func(n *xhtml.Node)
+	walk = func(n *xhtml.Node) {
+		if n.Type == xhtml.ElementNode {
+			if id := attr(n, "id"); id != "" {
+				used[id] = true
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, n := range nodes {
+		walk(n)
+	}
+
+	var collect func(n *xhtml.Node)
+	collect = func(n *xhtml.Node) {
+		if n.Type == xhtml.ElementNode && (n.DataAtom == atom.H1 || n.DataAtom == atom.H2 || n.DataAtom == atom.H3) {
+			text := collapseSpaces(textContent(n))
+			if text != "" {
+				id := attr(n, "id")
+				if id == "" {
+					id = fmt.Sprintf("section-%d", len(entries)+1)
+					for used[id] {
+						id += "-1"
+					}
+					used[id] = true
+					n.Attr = append(n.Attr, xhtml.Attribute{Key: "id", Val: id})
+				}
+				entries = append(entries, tocEntry{Level: int(n.Data[1] - '0'), ID: id, Text: text})
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	var b strings.Builder
+	for _, n := range nodes {
+		collect(n)
+		if err = xhtml.Render(&b, n); err != nil {
+			return "", nil, fmt.Errorf("failed to render content: %v", err)
+		}
+	}
+	return b.String(), entries, nil
+}
+
+// renderTOC 将目录渲染为嵌套列表
+func renderTOC(entries []tocEntry) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	minLevel := entries[0].Level
+	for _, e := range entries {
+		minLevel = min(minLevel, e.Level)
+	}
+	var b strings.Builder
+	b.WriteString(`<nav class="toc"><div class="toc-title">目录</div>`)
+	depth := 0
+	for _, e := range entries {
+		level := e.Level - minLevel + 1
+		for depth < level {
+			b.WriteString("<ul>")
+			depth++
+		}
+		for depth > level {
+			b.WriteString("</ul>")
+			depth--
+		}
+		fmt.Fprintf(&b, `<li class="toc-level-%d"><a href="#%s">%s</a></li>`, e.Level, html.EscapeString(e.ID), html.EscapeString(e.Text))
+	}
+	for ; depth > 0; depth-- {
+		b.WriteString("</ul>")
+	}
+	b.WriteString("</nav>")
+	return b.String()
+}
+
+// pdfTemplateDir 模板目录
+func pdfTemplateDir(ctx context.Context) string {
+	return g.Cfg().MustGet(ctx, consts.PDFTemplateDir, defaultPDFTemplateDir).String()
+}
+
+// pdfTemplateNames 可用的模板名称，内置的 default 模板总是可用
+func pdfTemplateNames(dir string) []string {
+	names := []string{defaultPDFTemplate}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.html"))
+	for _, file := range files {
+		name := strings.TrimSuffix(filepath.Base(file), ".html")
+		if name != defaultPDFTemplate && pdfTemplateName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// loadPDFTemplate 从模板目录加载模板，目录中没有 default.html 时使用内置模板
+func loadPDFTemplate(dir, name string) (*template.Template, error) {
+	if name == "" {
+		name = defaultPDFTemplate
+	}
+	if !pdfTemplateName.MatchString(name) {
+		return nil, fmt.Errorf("invalid template name: %s", name)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, name+".html"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read template %s: %v", name, err)
+		}
+		if name != defaultPDFTemplate {
+			return nil, fmt.Errorf("template %s not found, available templates: %s", name, strings.Join(pdfTemplateNames(dir), ", "))
+		}
+		content = []byte(builtinPDFTemplate)
+	}
+	tmpl, err := template.New(name).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template %s: %v", name, err)
+	}
+	return tmpl, nil
+}
+
+// buildHTMLContent 按格式渲染内容、生成目录并套用模板，得到完整的HTML文档
+func buildHTMLContent(ctx context.Context, req PDFGenerationTool) (string, error) {
+	// 1. 完整的HTML文档直接使用
+	if req.Format == pdfFormatHTML && isHTMLDocument(req.Content) {
+		return inlineWorkspaceImages(ctx, req.Content), nil
+	}
+
+	// 2. 渲染正文
+	body, err := renderPDFBody(req.Format, req.Content)
+	if err != nil {
+		return "", err
+	}
+
+	// 3. 生成目录
+	var toc string
+	if req.TOC {
+		var entries []tocEntry
+		body, entries, err = buildTOC(body)
+		if err != nil {
+			return "", err
+		}
+		toc = renderTOC(entries)
+	}
+
+	// 4. 套用模板
+	tmpl, err := loadPDFTemplate(pdfTemplateDir(ctx), req.Template)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, &pdfTemplateData{
+		Title:    req.Title,
+		Author:   req.Author,
+		Subject:  req.Subject,
+		Keywords: req.Keywords,
+		Date:     time.Now().Format("2006-01-02 15:04:05"),
+		TOC:      template.HTML(toc),
+		Content:  template.HTML(body),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render template %s: %v", tmpl.Name(), err)
+	}
+	return inlineWorkspaceImages(ctx, buf.String()), nil
+}
+
+// inlineWorkspaceImages 将引用工作目录内图片的相对路径替换为 data URL，页面以 about:blank 加载，无法访问本地文件
+func inlineWorkspaceImages(ctx context.Context, content string) string {
+	return imgSrc.ReplaceAllStringFunc(content, func(tag string) string {
+		m := imgSrc.FindStringSubmatch(tag)
+		src := html.UnescapeString(m[3] + m[4])
+		if src == "" || strings.Contains(src, ":") || strings.HasPrefix(src, "/") || strings.HasPrefix(src, "#") {
+			return tag
+		}
+		data, mimeType, err := readWorkspaceImage(ctx, src)
+		if err != nil {
+			g.Log(consts.LoggerTools).Warningf(ctx, "failed to embed image %s: %v", src, err)
+			return tag
+		}
+		return m[1] + `"data:` + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data) + `"`
+	})
+}
+
+// headerFooterTemplate 生成 Chrome 页眉/页脚模板，支持 {page}、{pages}、{title}、{date} 占位符
+func headerFooterTemplate(text string, pageNumbers bool) string {
+	text = html.EscapeString(text)
+	text = strings.NewReplacer(
+		"{page}", `<span class="pageNumber"></span>`,
+		"{pages}", `<span class="totalPages"></span>`,
+		"{title}", `<span class="title"></span>`,
+		"{date}", `<span class="date"></span>`,
+	).Replace(text)
+	if pageNumbers {
+		if text != "" {
+			text += "&nbsp;&nbsp;·&nbsp;&nbsp;"
+		}
+		text += `<span class="pageNumber"></span> / <span class="totalPages"></span>`
+	}
+	// 页眉页脚模板默认字号为 0，需要显式设置样式
+	return `<div style="width:100%;font-size:9px;color:#888;padding:0 12mm;text-align:center;` +
+		`font-family:'PingFang SC','Microsoft YaHei',Arial,sans-serif;">` + text + `</div>`
+}
+
+// pdfPageSizes 纸张尺寸（英寸，纵向）
+var pdfPageSizes = map[string][2]float64{
+	"A3":     {11.69, 16.54},
+	"A4":     {8.27, 11.69},
+	"A5":     {5.83, 8.27},
+	"Letter": {8.5, 11},
+	"Legal":  {8.5, 14},
+}
+
+// pdfPageSizeNames 纸张尺寸名称
+func pdfPageSizeNames() []string {
+	names := make([]string, 0, len(pdfPageSizes))
+	for name := range pdfPageSizes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pdfPageLayout 打印使用的纸张与边距（英寸）
+type pdfPageLayout struct {
+	Width, Height float64
+	Margin        float64
+}
+
+// pageLayout 根据纸张尺寸、方向和边距（毫米）计算打印布局
+func pageLayout(size, orientation string, marginMM float64) (*pdfPageLayout, error) {
+	if size == "" {
+		size = "A4"
+	}
+	dims, ok := pdfPageSizes[size]
+	if !ok {
+		for name, d := range pdfPageSizes {
+			if strings.EqualFold(name, size) {
+				dims, ok = d, true
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("unsupported page_size: %s", size)
+		}
+	}
+	switch orientation {
+	case "", "portrait":
+	case "landscape":
+		dims[0], dims[1] = dims[1], dims[0]
+	default:
+		return nil, fmt.Errorf("unsupported orientation: %s", orientation)
+	}
+	if marginMM < 0 || marginMM > 50 {
+		return nil, fmt.Errorf("margin must be between 0 and 50 mm, got %s", strconv.FormatFloat(marginMM, 'f', -1, 64))
+	}
+	return &pdfPageLayout{Width: dims[0], Height: dims[1], Margin: marginMM / 25.4}, nil
+}
+
+// builtinPDFTemplate 内置的默认模板
+const builtinPDFTemplate = `<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}}</title>
+    {{with .Author}}<meta name="author" content="{{.}}">{{end}}
+    {{with .Subject}}<meta name="description" content="{{.}}">{{end}}
+    {{with .Keywords}}<meta name="keywords" content="{{.}}">{{end}}
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; }
+        body {
+            font-family: "PingFang SC", "Microsoft YaHei", "Hiragino Sans GB", "SimSun", Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+            background-color: white;
+            font-size: 14px;
+        }
+        .header {
+            margin-bottom: 40px;
+            border-bottom: 3px solid #3498db;
+            padding-bottom: 25px;
+            text-align: center;
+        }
+        .title { font-size: 28px; font-weight: bold; color: #2c3e50; margin-bottom: 15px; letter-spacing: 1px; }
+        .meta-info {
+            font-size: 13px;
+            color: #7f8c8d;
+            background-color: #f8f9fa;
+            padding: 10px 20px;
+            border-radius: 5px;
+            display: inline-block;
+        }
+        .toc { page-break-after: always; }
+        .toc-title { font-size: 20px; font-weight: bold; color: #2c3e50; margin-bottom: 15px; }
+        .toc ul { list-style: none; margin-left: 20px; }
+        .toc > ul { margin-left: 0; }
+        .toc li { margin: 6px 0; }
+        .toc a { color: #34495e; text-decoration: none; }
+        .content { line-height: 1.8; text-align: justify; font-size: 15px; }
+        .content h1 { font-size: 22px; color: #2c3e50; margin: 30px 0 20px 0; border-bottom: 2px solid #3498db; padding-bottom: 8px; }
+        .content h2 { font-size: 19px; color: #34495e; margin: 25px 0 15px 0; border-left: 4px solid #3498db; padding-left: 10px; }
+        .content h3 { font-size: 17px; color: #34495e; margin: 20px 0 12px 0; }
+        .content p { margin-bottom: 16px; }
+        .content ul, .content ol { margin: 10px 0 10px 20px; }
+        .content li { margin-bottom: 5px; }
+        .content strong { font-weight: bold; color: #2c3e50; }
+        .content em { font-style: italic; }
+        .content a { color: #2980b9; }
+        .content img { max-width: 100%; }
+        .content blockquote { border-left: 4px solid #3498db; padding-left: 15px; margin: 15px 0; color: #666; font-style: italic; }
+        .content code { font-family: Menlo, Consolas, monospace; background: #f5f5f5; padding: 1px 4px; border-radius: 3px; font-size: 13px; }
+        .content pre { background: #f5f5f5; padding: 12px; border-radius: 5px; margin: 15px 0; white-space: pre-wrap; word-break: break-all; }
+        .content pre code { padding: 0; }
+        .content table { width: 100%; border-collapse: collapse; margin: 15px 0; }
+        .content th, .content td { border: 1px solid #ddd; padding: 8px; text-align: left; }
+        .content th { background-color: #f5f5f5; font-weight: bold; }
+        .content h1, .content h2, .content h3 { page-break-after: avoid; }
+        .content tr, .content img, .content pre { page-break-inside: avoid; }
+    </style>
+</head>
+<body>
+    {{if or .Title .Author .Subject}}
+    <div class="header">
+        {{with .Title}}<div class="title">{{.}}</div>{{end}}
+        <div class="meta-info">
+            {{with .Author}}作者: {{.}}{{end}}{{if and .Author .Subject}} | {{end}}{{with .Subject}}主题: {{.}}{{end}}
+            {{if or .Author .Subject}}<br>{{end}}生成时间: {{.Date}}
+        </div>
+    </div>
+    {{end}}
+    {{.TOC}}
+    <div class="content">
+        {{.Content}}
+    </div>
+</body>
+</html>`
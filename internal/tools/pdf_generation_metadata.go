@@ -0,0 +1,109 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+var (
+	pdfStartXref = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+	pdfSize      = regexp.MustCompile(`/Size\s+(\d+)`)
+	pdfRoot      = regexp.MustCompile(`/Root\s+(\d+\s+\d+\s+R)`)
+	pdfID        = regexp.MustCompile(`/ID\s*\[[^\]]*\]`)
+)
+
+// pdfInfo PDF 文档信息字典中的字段
+type pdfInfo struct {
+	Title    string
+	Author   string
+	Subject  string
+	Keywords string
+	Creator  string
+}
+
+// setPDFMetadata 以增量更新的方式在 PDF 末尾追加新的文档信息字典，不改动原有内容
+func setPDFMetadata(pdf []byte, info pdfInfo, now time.Time) ([]byte, error) {
+	// 1. 找到最后一个交叉引用表及其 trailer
+	m := pdfStartXref.FindSubmatch(pdf)
+	if m == nil {
+		return nil, fmt.Errorf("failed to locate startxref")
+	}
+	prev, err := strconv.Atoi(string(m[1]))
+	if err != nil || prev <= 0 || prev >= len(pdf) {
+		return nil, fmt.Errorf("invalid startxref offset")
+	}
+	if !bytes.HasPrefix(pdf[prev:], []byte("xref")) {
+		// 交叉引用流需要以流的形式追加，暂不支持
+		return nil, fmt.Errorf("cross-reference streams are not supported")
+	}
+	trailerAt := bytes.LastIndex(pdf, []byte("trailer"))
+	if trailerAt < prev {
+		return nil, fmt.Errorf("failed to locate trailer")
+	}
+	trailer := pdf[trailerAt:]
+	sizeMatch := pdfSize.FindSubmatch(trailer)
+	rootMatch := pdfRoot.FindSubmatch(trailer)
+	if sizeMatch == nil || rootMatch == nil {
+		return nil, fmt.Errorf("failed to parse trailer")
+	}
+	objNum, _ := strconv.Atoi(string(sizeMatch[1]))
+
+	// 2. 追加信息字典对象
+	var b bytes.Buffer
+	b.Write(pdf)
+	if !bytes.HasSuffix(pdf, []byte("\n")) {
+		b.WriteByte('\n')
+	}
+	objOffset := b.Len()
+	fmt.Fprintf(&b, "%d 0 obj\n<<", objNum)
+	for _, field := range []struct{ key, value string }{
+		{"Title", info.Title},
+		{"Author", info.Author},
+		{"Subject", info.Subject},
+		{"Keywords", info.Keywords},
+		{"Creator", info.Creator},
+	} {
+		if field.value != "" {
+			fmt.Fprintf(&b, " /%s %s", field.key, pdfString(field.value))
+		}
+	}
+	date := pdfString(now.Format("D:20060102150405-07'00'"))
+	fmt.Fprintf(&b, " /CreationDate %s /ModDate %s >>\nendobj\n", date, date)
+
+	// 3. 追加交叉引用表和 trailer，通过 /Prev 指向原表
+	xrefOffset := b.Len()
+	fmt.Fprintf(&b, "xref\n%d 1\n%010d 00000 n \n", objNum, objOffset)
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root %s /Info %d 0 R /Prev %d", objNum+1, rootMatch[1], objNum, prev)
+	if id := pdfID.Find(trailer); id != nil {
+		b.WriteString(" ")
+		b.Write(id)
+	}
+	fmt.Fprintf(&b, " >>\nstartxref\n%d\n%%%%EOF\n", xrefOffset)
+	return b.Bytes(), nil
+}
+
+// pdfString 编码 PDF 字符串，非 ASCII 文本使用带 BOM 的 UTF-16BE 十六进制串
+func pdfString(s string) string {
+	ascii := true
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return "(" + strings.NewReplacer(`\`, `\\`, "(", `\(`, ")", `\)`).Replace(s) + ")"
+	}
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
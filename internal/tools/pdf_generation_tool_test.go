@@ -1,8 +1,15 @@
 package tools
 
 import (
+	"agent/internal/consts"
+	"agent/internal/model"
+	"bytes"
 	"context"
+	"encoding/base64"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cloudwego/eino/components/tool"
 )
@@ -37,11 +44,11 @@ func TestPDFGenerationTool_InvokableRun(t1 *testing.T) {
 				Subject:  "HTML文档生成测试",
 			},
 			args: args{
-				ctx:             context.Background(),
+				ctx:             context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_pdf"}),
 				argumentsInJSON: `{"filename": "test.pdf", "content": "<h1>主标题</h1><h2>副标题</h2><p>这是正文内容，支持<strong>粗体</strong>和<em>斜体</em>。</p><table><tr><th>列1</th><th>列2</th></tr><tr><td>数据1</td><td>数据2</td></tr></table>", "title": "HTML文档", "author": "Ai助手", "subject": "HTML文档生成测试"}`,
 				in2:             []tool.Option{},
 			},
-			want:    "PDF generation to resource/pdf/test.pdf",
+			want:    "PDF generation successfully to pdf/test.pdf",
 			wantErr: false,
 		},
 		{
@@ -54,11 +61,11 @@ func TestPDFGenerationTool_InvokableRun(t1 *testing.T) {
 				Subject:  "HTML文档生成测试",
 			},
 			args: args{
-				ctx:             context.Background(),
+				ctx:             context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_pdf"}),
 				argumentsInJSON: `{"filename": "test01.pdf", "content": "这是第一段文字。\n\n这是第二段文字。\n包含多行内容。", "title": "HTML文档", "author": "Ai助手", "subject": "HTML文档生成测试"}`,
 				in2:             []tool.Option{},
 			},
-			want:    "PDF generation to resource/pdf/test01.pdf",
+			want:    "PDF generation successfully to pdf/test01.pdf",
 			wantErr: false,
 		},
 	}
@@ -76,9 +83,274 @@ func TestPDFGenerationTool_InvokableRun(t1 *testing.T) {
 				t1.Errorf("InvokableRun() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if got != tt.want {
+			// 第二行为产物下载链接
+			if first, _, _ := strings.Cut(got, "\n"); first != tt.want {
 				t1.Errorf("InvokableRun() got = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestBrowserPool_NewTab(t1 *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(p *BrowserPool)
+		ctx     func() (context.Context, context.CancelFunc)
+		wantErr string
+	}{
+		{
+			name: "tabs exhausted",
+			prepare: func(p *BrowserPool) {
+				p.tabs <- struct{}{}
+			},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			wantErr: "no browser tab available after 50ms",
+		},
+		{
+			name: "caller cancelled",
+			prepare: func(p *BrowserPool) {
+				p.tabs <- struct{}{}
+			},
+			ctx: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			wantErr: context.Canceled.Error(),
+		},
+		{
+			name: "closed",
+			prepare: func(p *BrowserPool) {
+				_ = p.Close(context.Background())
+			},
+			ctx: func() (context.Context, context.CancelFunc) {
+				return context.WithCancel(context.Background())
+			},
+			wantErr: errBrowserPoolClosed.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			p := NewBrowserPool(1, 50*time.Millisecond, time.Minute)
+			tt.prepare(p)
+			ctx, cancel := tt.ctx()
+			defer cancel()
+			_, release, err := p.NewTab(ctx)
+			if err == nil {
+				release()
+				t1.Fatalf("NewTab() expected error %q", tt.wantErr)
+			}
+			if err.Error() != tt.wantErr {
+				t1.Errorf("NewTab() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(p.tabs) > 1 {
+				t1.Errorf("NewTab() leaked a tab slot")
+			}
+		})
+	}
+}
+
+func TestBuildHTMLContent(t1 *testing.T) {
+	ctx := context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_pdf_html"})
+	defer RemoveSessionWorkspace(ctx, "test_pdf_html")
+	photo, err := SaveImage(ctx, "cover.png", bytes.NewReader(pngHeader))
+	if err != nil {
+		t1.Fatalf("SaveImage() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		req          PDFGenerationTool
+		wantContains []string
+		wantMissing  []string
+		wantErr      bool
+	}{
+		{
+			name: "markdown",
+			req: PDFGenerationTool{
+				Format:  pdfFormatMarkdown,
+				Content: "# 标题\n\n**粗体** 和 `code`\n\n| a | b |\n|---|---|\n| 1 | 2 |",
+				Title:   "文档",
+			},
+			wantContains: []string{"<title>文档</title>", `<h1 id="heading">标题</h1>`, "<strong>粗体</strong>", "<code>code</code>", "<td>1</td>"},
+			wantMissing:  []string{"**", `class="toc"`},
+		},
+		{
+			name: "text is escaped",
+			req: PDFGenerationTool{
+				Format:  pdfFormatText,
+				Content: "第一行\n第二行\n\n<script>alert(1)</script>",
+			},
+			wantContains: []string{"<p>第一行<br>第二行</p>", "&lt;script&gt;"},
+			wantMissing:  []string{"<script>"},
+		},
+		{
+			name: "toc",
+			req: PDFGenerationTool{
+				Format:  pdfFormatHTML,
+				Content: `<h1>Intro</h1><p>a</p><h2 id="usage">Usage</h2><h3>Details</h3><h1>End</h1>`,
+				TOC:     true,
+			},
+			wantContains: []string{
+				`<h1 id="section-1">Intro</h1>`,
+				`<h2 id="usage">Usage</h2>`,
+				`<ul><li class="toc-level-1"><a href="#section-1">Intro</a></li><ul><li class="toc-level-2"><a href="#usage">Usage</a></li>`,
+				`<a href="#section-4">End</a>`,
+			},
+		},
+		{
+			name: "full html document",
+			req: PDFGenerationTool{
+				Format:  pdfFormatHTML,
+				Content: "<!DOCTYPE html><html><body>raw</body></html>",
+				TOC:     true,
+			},
+			wantContains: []string{"<!DOCTYPE html><html><body>raw</body></html>"},
+			wantMissing:  []string{"toc"},
+		},
+		{
+			name: "workspace images are embedded",
+			req: PDFGenerationTool{
+				Format:  pdfFormatMarkdown,
+				Content: "![cover](" + photo + ")\n\n<img src='https://example.com/a.png'>\n\n![missing](photos/none.png)",
+			},
+			wantContains: []string{
+				`<img src="data:image/png;base64,` + base64.StdEncoding.EncodeToString(pngHeader) + `" alt="cover">`,
+				`<img src='https://example.com/a.png'>`,
+				`<img src="photos/none.png"`,
+			},
+		},
+		{
+			name:    "unknown format",
+			req:     PDFGenerationTool{Format: "docx", Content: "x"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown template",
+			req:     PDFGenerationTool{Format: pdfFormatText, Content: "x", Template: "fancy"},
+			wantErr: true,
+		},
+		{
+			name:    "template traversal",
+			req:     PDFGenerationTool{Format: pdfFormatText, Content: "x", Template: "../secret"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			got, err := buildHTMLContent(ctx, tt.req)
+			if (err != nil) != tt.wantErr {
+				t1.Fatalf("buildHTMLContent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(got, want) {
+					t1.Errorf("buildHTMLContent() = %v, want contains %v", got, want)
+				}
+			}
+			for _, missing := range tt.wantMissing {
+				if strings.Contains(got, missing) {
+					t1.Errorf("buildHTMLContent() = %v, should not contain %v", got, missing)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadPDFTemplate(t1 *testing.T) {
+	dir := "../../resource/template/pdf"
+	if got := pdfTemplateNames(dir); strings.Join(got, ",") != "default,minimal,report" {
+		t1.Errorf("pdfTemplateNames() = %v", got)
+	}
+	for _, name := range pdfTemplateNames(dir) {
+		tmpl, err := loadPDFTemplate(dir, name)
+		if err != nil {
+			t1.Fatalf("loadPDFTemplate(%s) error = %v", name, err)
+		}
+		var b strings.Builder
+		err = tmpl.Execute(&b, &pdfTemplateData{Title: "T", Author: "A", Content: "<p>body</p>"})
+		if err != nil {
+			t1.Fatalf("Execute(%s) error = %v", name, err)
+		}
+		if !strings.Contains(b.String(), "<p>body</p>") || !strings.Contains(b.String(), "<title>T</title>") {
+			t1.Errorf("template %s does not render title and content: %v", name, b.String())
+		}
+	}
+}
+
+func TestPageLayout(t1 *testing.T) {
+	tests := []struct {
+		name        string
+		size        string
+		orientation string
+		margin      float64
+		want        pdfPageLayout
+		wantErr     bool
+	}{
+		{name: "default", want: pdfPageLayout{Width: 8.27, Height: 11.69}},
+		{name: "letter landscape", size: "letter", orientation: "landscape", margin: 25.4, want: pdfPageLayout{Width: 11, Height: 8.5, Margin: 1}},
+		{name: "unknown size", size: "B5", wantErr: true},
+		{name: "unknown orientation", orientation: "diagonal", wantErr: true},
+		{name: "margin too large", margin: 80, wantErr: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			got, err := pageLayout(tt.size, tt.orientation, tt.margin)
+			if (err != nil) != tt.wantErr {
+				t1.Fatalf("pageLayout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && *got != tt.want {
+				t1.Errorf("pageLayout() = %v, want %v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeaderFooterTemplate(t1 *testing.T) {
+	got := headerFooterTemplate("<b>{title}</b> 第{page}页", true)
+	for _, want := range []string{"&lt;b&gt;", `<span class="title"></span>`, `第<span class="pageNumber"></span>页`, `<span class="totalPages"></span>`} {
+		if !strings.Contains(got, want) {
+			t1.Errorf("headerFooterTemplate() = %v, want contains %v", got, want)
+		}
+	}
+}
+
+func TestSetPDFMetadata(t1 *testing.T) {
+	body := "%PDF-1.4\n1 0 obj\n<< /Type /Catalog /Pages 2 0 R >>\nendobj\n2 0 obj\n<< /Type /Pages /Kids [] /Count 0 >>\nendobj\n"
+	xref := len(body)
+	pdf := fmt.Sprintf("%sxref\n0 3\n0000000000 65535 f \n0000000009 00000 n \n0000000058 00000 n \ntrailer\n<< /Size 3 /Root 1 0 R /ID [<AB> <AB>] >>\nstartxref\n%d\n%%%%EOF\n", body, xref)
+	now := time.Date(2024, 5, 1, 8, 30, 0, 0, time.FixedZone("CST", 8*3600))
+
+	got, err := setPDFMetadata([]byte(pdf), pdfInfo{Title: "Report (v1)", Author: "张三"}, now)
+	if err != nil {
+		t1.Fatalf("setPDFMetadata() error = %v", err)
+	}
+	out := string(got)
+	if !strings.HasPrefix(out, pdf) {
+		t1.Errorf("setPDFMetadata() must keep the original bytes")
+	}
+	update := out[len(pdf):]
+	for _, want := range []string{
+		"3 0 obj\n<< /Title (Report \\(v1\\)) /Author <FEFF5F204E09> /CreationDate (D:20240501083000+08'00')",
+		"/Size 4 /Root 1 0 R /Info 3 0 R /Prev " + fmt.Sprint(xref) + " /ID [<AB> <AB>] >>",
+	} {
+		if !strings.Contains(update, want) {
+			t1.Errorf("setPDFMetadata() update = %q, want contains %q", update, want)
+		}
+	}
+	// 新的 startxref 指向追加的交叉引用表，表项指向信息字典
+	var newXref, objOffset int
+	fmt.Sscanf(out[strings.LastIndex(out, "startxref")+len("startxref\n"):], "%d", &newXref)
+	if !strings.HasPrefix(out[newXref:], "xref\n3 1\n") {
+		t1.Fatalf("startxref %d does not point to the new xref section", newXref)
+	}
+	fmt.Sscanf(out[newXref+len("xref\n3 1\n"):], "%d", &objOffset)
+	if !strings.HasPrefix(out[objOffset:], "3 0 obj") {
+		t1.Errorf("xref entry %d does not point to the info object", objOffset)
+	}
+
+	if _, err = setPDFMetadata([]byte("%PDF-1.5\nstartxref\n9\n%%EOF\n"), pdfInfo{Title: "x"}, now); err == nil {
+		t1.Errorf("setPDFMetadata() expected error for cross-reference stream")
+	}
+}
@@ -0,0 +1,470 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"agent/internal/secret"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+const (
+	photoProviderPexels   = "pexels"
+	photoProviderUnsplash = "unsplash"
+	photoProviderLocal    = "local"
+
+	defaultPhotoCount    = 5
+	defaultPhotoTimeout  = 10 * time.Second
+	defaultPhotoCacheTTL = time.Hour
+	defaultPhotoLocalDir = "resource/photos"
+
+	// photoIndexFile 本地图库的描述文件，可选
+	photoIndexFile = "index.json"
+)
+
+var (
+	// photoOrientations 支持的方向过滤
+	photoOrientations = []string{"landscape", "portrait", "square"}
+	// photoSizes 支持的尺寸过滤，与 Pexels 一致：large 24MP、medium 12MP、small 4MP 以上
+	photoSizes = []string{"large", "medium", "small"}
+	// photoMinPixels 尺寸过滤对应的最少像素数
+	photoMinPixels = map[string]int{"large": 24_000_000, "medium": 12_000_000, "small": 4_000_000}
+	// photoColors 支持的颜色过滤，与 Pexels 一致，也可以使用 #ffffff 形式的十六进制颜色
+	photoColors = []string{"red", "orange", "yellow", "green", "turquoise", "blue", "violet", "pink", "brown", "black", "gray", "white"}
+)
+
+// PhotoSearchRequest 归一化的图片搜索请求
+type PhotoSearchRequest struct {
+	Query       string
+	Count       int
+	Page        int
+	Orientation string // landscape / portrait / square
+	Size        string // large / medium / small
+	Color       string // photoColors 中的颜色名或十六进制颜色
+	Locale      string // 如 zh-CN、en-US
+}
+
+// Photo 归一化的单张图片
+type Photo struct {
+	ID              string `json:"id"` // provider:原始 ID，下载时使用
+	Provider        string `json:"provider"`
+	Description     string `json:"description"`
+	Width           int    `json:"width"`
+	Height          int    `json:"height"`
+	AvgColor        string `json:"avg_color,omitempty"`
+	PageURL         string `json:"page_url,omitempty"`      // 图片在图库网站上的页面
+	ImageURL        string `json:"image_url,omitempty"`     // 中等尺寸，可直接查看或嵌入
+	LargeURL        string `json:"large_url,omitempty"`     // 大尺寸，下载时使用
+	ThumbnailURL    string `json:"thumbnail_url,omitempty"` // 缩略图
+	Photographer    string `json:"photographer"`
+	PhotographerURL string `json:"photographer_url,omitempty"`
+	Attribution     string `json:"attribution"`              // 使用图片时需要附上的署名
+	Path            string `json:"path,omitempty"`           // 下载到工作目录后的相对路径
+	DownloadError   string `json:"download_error,omitempty"` // 下载失败的原因
+
+	localPath        string // 本地图库中的文件
+	downloadLocation string // Unsplash 要求下载时调用的统计接口
+}
+
+// PhotoProvider 图片搜索后端
+type PhotoProvider interface {
+	Name() string
+	Search(ctx context.Context, req *PhotoSearchRequest) ([]*Photo, error)
+}
+
+// photoDownloadTracker 下载图片前需要通知图库的后端
+type photoDownloadTracker interface {
+	TrackDownload(ctx context.Context, photo *Photo) error
+}
+
+// photoConfig 图片搜索配置
+type photoConfig struct {
+	Provider string
+	APIKey   string
+	BaseURL  string
+	LocalDir string
+	Timeout  time.Duration
+	Retries  int
+	Count    int
+	CacheTTL time.Duration
+}
+
+// photoSecrets 图库服务的 API Key
+var photoSecrets = secret.Declare("photo_search_tool", consts.PhotoSearchAPIKey, consts.PexelsApiKey)
+
+// loadPhotoConfig 读取图片搜索配置
+func loadPhotoConfig(ctx context.Context) photoConfig {
+	cfg := photoConfig{
+		Provider: g.Cfg().MustGet(ctx, consts.PhotoSearchProvider, photoProviderPexels).String(),
+		APIKey:   photoSecrets.Value(ctx, consts.PhotoSearchAPIKey),
+		BaseURL:  g.Cfg().MustGet(ctx, consts.PhotoSearchBaseURL).String(),
+		LocalDir: g.Cfg().MustGet(ctx, consts.PhotoSearchLocalDir, defaultPhotoLocalDir).String(),
+		Timeout:  g.Cfg().MustGet(ctx, consts.PhotoSearchTimeout, defaultPhotoTimeout).Duration(),
+		Retries:  g.Cfg().MustGet(ctx, consts.PhotoSearchRetries, defaultSearchRetries).Int(),
+		Count:    g.Cfg().MustGet(ctx, consts.PhotoSearchCount, defaultPhotoCount).Int(),
+		CacheTTL: g.Cfg().MustGet(ctx, consts.PhotoSearchCacheTTL, defaultPhotoCacheTTL).Duration(),
+	}
+	if cfg.APIKey == "" && cfg.Provider == photoProviderPexels {
+		// 兼容旧配置 ai.pexelsApiKey
+		cfg.APIKey = photoSecrets.Value(ctx, consts.PexelsApiKey)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultPhotoTimeout
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	}
+	if cfg.Count <= 0 {
+		cfg.Count = defaultPhotoCount
+	}
+	return cfg
+}
+
+// newPhotoProvider 根据配置创建图片搜索后端
+func newPhotoProvider(cfg photoConfig) (PhotoProvider, error) {
+	client := &searchClient{
+		http:      &http.Client{Timeout: cfg.Timeout},
+		retries:   cfg.Retries,
+		backoff:   defaultSearchBackoff,
+		userAgent: "agent-photo-search/1.0",
+	}
+	switch cfg.Provider {
+	case photoProviderPexels:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("pexels api key is not configured")
+		}
+		return &pexelsProvider{client: client, baseURL: orDefault(cfg.BaseURL, "https://api.pexels.com/v1/search"), apiKey: cfg.APIKey}, nil
+	case photoProviderUnsplash:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("unsplash access key is not configured")
+		}
+		return &unsplashProvider{client: client, baseURL: strings.TrimSuffix(orDefault(cfg.BaseURL, "https://api.unsplash.com"), "/"), apiKey: cfg.APIKey}, nil
+	case photoProviderLocal:
+		dir, err := filepath.Abs(cfg.LocalDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve local photo dir: %v", err)
+		}
+		return &localPhotoProvider{dir: dir}, nil
+	default:
+		return nil, fmt.Errorf("unsupported photo provider: %s", cfg.Provider)
+	}
+}
+
+// pexelsProvider Pexels API
+type pexelsProvider struct {
+	client  *searchClient
+	baseURL string
+	apiKey  string
+}
+
+func (p *pexelsProvider) Name() string { return photoProviderPexels }
+
+func (p *pexelsProvider) Search(ctx context.Context, req *PhotoSearchRequest) ([]*Photo, error) {
+	// Pexels 的过滤参数与工具参数一致
+	query := url.Values{
+		"query":    {req.Query},
+		"per_page": {strconv.Itoa(min(req.Count, 80))},
+		"page":     {strconv.Itoa(req.Page)},
+	}
+	for key, value := range map[string]string{
+		"orientation": req.Orientation,
+		"size":        req.Size,
+		"color":       strings.TrimPrefix(req.Color, "#"),
+		"locale":      req.Locale,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+
+	var res PexelsResponse
+	header := http.Header{"Authorization": {p.apiKey}}
+	if err := p.client.getJSON(ctx, p.baseURL+"?"+query.Encode(), header, &res); err != nil {
+		return nil, err
+	}
+	photos := make([]*Photo, 0, len(res.Photos))
+	for _, r := range res.Photos {
+		photos = append(photos, &Photo{
+			ID:              photoProviderPexels + ":" + strconv.Itoa(r.ID),
+			Provider:        photoProviderPexels,
+			Description:     r.Alt,
+			Width:           r.Width,
+			Height:          r.Height,
+			AvgColor:        r.AvgColor,
+			PageURL:         r.URL,
+			ImageURL:        r.Src.Medium,
+			LargeURL:        r.Src.Large2x,
+			ThumbnailURL:    r.Src.Tiny,
+			Photographer:    r.Photographer,
+			PhotographerURL: r.PhotographerURL,
+			Attribution:     fmt.Sprintf("Photo by %s on Pexels", r.Photographer),
+		})
+	}
+	return photos, nil
+}
+
+// unsplashProvider Unsplash API，需遵守其署名和下载统计要求
+type unsplashProvider struct {
+	client  *searchClient
+	baseURL string
+	apiKey  string
+}
+
+func (p *unsplashProvider) Name() string { return photoProviderUnsplash }
+
+// unsplashColors 工具颜色到 Unsplash 颜色的映射，Unsplash 不支持的颜色不做过滤
+var unsplashColors = map[string]string{
+	"red": "red", "orange": "orange", "yellow": "yellow", "green": "green", "turquoise": "teal",
+	"blue": "blue", "violet": "purple", "pink": "magenta", "black": "black", "white": "white", "gray": "black_and_white",
+}
+
+// unsplashReferral Unsplash 要求署名链接带上来源参数
+const unsplashReferral = "?utm_source=agent&utm_medium=referral"
+
+func (p *unsplashProvider) header() http.Header {
+	return http.Header{"Authorization": {"Client-ID " + p.apiKey}, "Accept-Version": {"v1"}}
+}
+
+func (p *unsplashProvider) Search(ctx context.Context, req *PhotoSearchRequest) ([]*Photo, error) {
+	query := url.Values{
+		"query":    {req.Query},
+		"per_page": {strconv.Itoa(min(req.Count, 30))},
+		"page":     {strconv.Itoa(req.Page)},
+	}
+	switch req.Orientation {
+	case "square":
+		query.Set("orientation", "squarish")
+	case "landscape", "portrait":
+		query.Set("orientation", req.Orientation)
+	}
+	if color, ok := unsplashColors[req.Color]; ok {
+		query.Set("color", color)
+	}
+	if lang, _, _ := strings.Cut(req.Locale, "-"); lang != "" {
+		query.Set("lang", strings.ToLower(lang))
+	}
+
+	var res struct {
+		Results []struct {
+			ID             string `json:"id"`
+			Width          int    `json:"width"`
+			Height         int    `json:"height"`
+			Color          string `json:"color"`
+			Description    string `json:"description"`
+			AltDescription string `json:"alt_description"`
+			URLs           struct {
+				Full    string `json:"full"`
+				Regular string `json:"regular"`
+				Small   string `json:"small"`
+				Thumb   string `json:"thumb"`
+			} `json:"urls"`
+			Links struct {
+				HTML             string `json:"html"`
+				DownloadLocation string `json:"download_location"`
+			} `json:"links"`
+			User struct {
+				Name  string `json:"name"`
+				Links struct {
+					HTML string `json:"html"`
+				} `json:"links"`
+			} `json:"user"`
+		} `json:"results"`
+	}
+	if err := p.client.getJSON(ctx, p.baseURL+"/search/photos?"+query.Encode(), p.header(), &res); err != nil {
+		return nil, err
+	}
+	photos := make([]*Photo, 0, len(res.Results))
+	for _, r := range res.Results {
+		// Unsplash 搜索不支持尺寸过滤，在结果中过滤
+		if r.Width*r.Height < photoMinPixels[req.Size] {
+			continue
+		}
+		description := r.AltDescription
+		if description == "" {
+			description = r.Description
+		}
+		photos = append(photos, &Photo{
+			ID:               photoProviderUnsplash + ":" + r.ID,
+			Provider:         photoProviderUnsplash,
+			Description:      description,
+			Width:            r.Width,
+			Height:           r.Height,
+			AvgColor:         r.Color,
+			PageURL:          r.Links.HTML + unsplashReferral,
+			ImageURL:         r.URLs.Regular,
+			LargeURL:         r.URLs.Full,
+			ThumbnailURL:     r.URLs.Thumb,
+			Photographer:     r.User.Name,
+			PhotographerURL:  r.User.Links.HTML + unsplashReferral,
+			Attribution:      fmt.Sprintf("Photo by %s on Unsplash", r.User.Name),
+			downloadLocation: r.Links.DownloadLocation,
+		})
+	}
+	return photos, nil
+}
+
+// TrackDownload 按 Unsplash API 规范在下载图片时调用下载统计接口
+func (p *unsplashProvider) TrackDownload(ctx context.Context, photo *Photo) error {
+	if photo.downloadLocation == "" {
+		return nil
+	}
+	var res struct {
+		URL string `json:"url"`
+	}
+	return p.client.getJSON(ctx, photo.downloadLocation, p.header(), &res)
+}
+
+// localPhotoProvider 本地图库，按文件名和 index.json 中的描述、标签匹配
+type localPhotoProvider struct {
+	dir string
+}
+
+// localPhotoEntry index.json 中的单张图片描述
+type localPhotoEntry struct {
+	File            string   `json:"file"`
+	Description     string   `json:"description"`
+	Tags            []string `json:"tags"`
+	Color           string   `json:"color"`
+	Photographer    string   `json:"photographer"`
+	PhotographerURL string   `json:"photographer_url"`
+	License         string   `json:"license"`
+}
+
+func (p *localPhotoProvider) Name() string { return photoProviderLocal }
+
+// entries 读取图库中的图片，没有 index.json 的图片以文件名作为描述
+func (p *localPhotoProvider) entries() ([]*localPhotoEntry, error) {
+	var entries []*localPhotoEntry
+	if data, err := os.ReadFile(filepath.Join(p.dir, photoIndexFile)); err == nil {
+		if err = gjson.DecodeTo(data, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", photoIndexFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %v", photoIndexFile, err)
+	}
+	indexed := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		indexed[e.File] = true
+	}
+	files, err := os.ReadDir(p.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read local photo dir: %v", err)
+	}
+	for _, f := range files {
+		if f.IsDir() || indexed[f.Name()] || f.Name() == photoIndexFile {
+			continue
+		}
+		name := strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))
+		entries = append(entries, &localPhotoEntry{
+			File:        f.Name(),
+			Description: strings.NewReplacer("_", " ", "-", " ").Replace(name),
+		})
+	}
+	return entries, nil
+}
+
+func (p *localPhotoProvider) Search(_ context.Context, req *PhotoSearchRequest) ([]*Photo, error) {
+	entries, err := p.entries()
+	if err != nil {
+		return nil, err
+	}
+	terms := queryTerms(req.Query)
+	type scored struct {
+		photo *Photo
+		score int
+	}
+	var matches []scored
+	for _, e := range entries {
+		// 1. 按关键词打分，没有命中的跳过
+		text := strings.ToLower(e.Description + " " + strings.Join(e.Tags, " ") + " " + e.File)
+		score := 0
+		for _, term := range terms {
+			if strings.Contains(text, term) {
+				score++
+			}
+		}
+		if score == 0 || (req.Color != "" && !strings.EqualFold(e.Color, req.Color)) {
+			continue
+		}
+
+		// 2. 读取尺寸并按方向、大小过滤
+		filePath := filepath.Join(p.dir, filepath.Base(e.File))
+		width, height, err := imageSize(filePath)
+		if err != nil {
+			continue
+		}
+		if !matchOrientation(req.Orientation, width, height) || width*height < photoMinPixels[req.Size] {
+			continue
+		}
+		attribution := "Local photo library"
+		if e.Photographer != "" {
+			attribution = "Photo by " + e.Photographer
+		}
+		if e.License != "" {
+			attribution += " (" + e.License + ")"
+		}
+		matches = append(matches, scored{score: score, photo: &Photo{
+			ID:              photoProviderLocal + ":" + filepath.Base(e.File),
+			Provider:        photoProviderLocal,
+			Description:     e.Description,
+			Width:           width,
+			Height:          height,
+			AvgColor:        e.Color,
+			Photographer:    e.Photographer,
+			PhotographerURL: e.PhotographerURL,
+			Attribution:     attribution,
+			localPath:       filePath,
+		}})
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+
+	// 3. 分页
+	start := (req.Page - 1) * req.Count
+	photos := make([]*Photo, 0, req.Count)
+	for i := start; i < len(matches) && len(photos) < req.Count; i++ {
+		photos = append(photos, matches[i].photo)
+	}
+	return photos, nil
+}
+
+// imageSize 读取图片的宽高
+func imageSize(filePath string) (int, int, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+	cfg, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0, err
+	}
+	return cfg.Width, cfg.Height, nil
+}
+
+// matchOrientation 宽高比是否符合方向过滤，宽高相差 10% 以内视为方形
+func matchOrientation(orientation string, width, height int) bool {
+	switch orientation {
+	case "landscape":
+		return width*10 > height*11
+	case "portrait":
+		return height*10 > width*11
+	case "square":
+		return width*10 <= height*11 && height*10 <= width*11
+	default:
+		return true
+	}
+}
@@ -2,23 +2,40 @@ package tools
 
 import (
 	"agent/internal/consts"
+	"agent/internal/logging"
+	"agent/internal/secret"
 	"context"
 	"fmt"
 	"io"
-	"net/http"
+	"mime"
 	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	"github.com/gogf/gf/v2/encoding/gjson"
 	"github.com/gogf/gf/v2/frame/g"
-	"github.com/gogf/gf/v2/util/gconv"
+	"github.com/gogf/gf/v2/os/gcache"
 )
 
 type PhotoSearchTool struct {
-	Query   string `json:"query"`
-	PerPage int    `json:"per_page,omitempty"`
+	Query       string   `json:"query"`
+	PerPage     int      `json:"per_page,omitempty"`
+	Page        int      `json:"page,omitempty"`
+	Orientation string   `json:"orientation,omitempty"`
+	Size        string   `json:"size,omitempty"`
+	Color       string   `json:"color,omitempty"`
+	Locale      string   `json:"locale,omitempty"`
+	Download    int      `json:"download,omitempty"`
+	DownloadIDs []string `json:"download_ids,omitempty"`
+
+	provider PhotoProvider // 为空时按配置创建
 }
 
 // PexelsPhoto 表示 Pexels API 返回的单张照片信息
@@ -53,16 +70,18 @@ type PexelsResponse struct {
 	NextPage     string        `json:"next_page"`
 }
 
-// PhotoResult 表示返回给用户的简化照片信息
-type PhotoResult struct {
-	ID           int    `json:"id"`
-	Alt          string `json:"alt"`
-	MediumURL    string `json:"medium_url"`
-	Photographer string `json:"photographer"`
-	Width        int    `json:"width"`
-	Height       int    `json:"height"`
+// PhotoSearchResponse photo_search_tool 的返回结果
+type PhotoSearchResponse struct {
+	Query    string   `json:"query,omitempty"`
+	Provider string   `json:"provider"`
+	Cached   bool     `json:"cached,omitempty"`
+	Photos   []*Photo `json:"photos"`
+	Note     string   `json:"note,omitempty"`
 }
 
+// photoCache 搜索结果缓存，同时按 ID 缓存单张图片供 download_ids 使用
+var photoCache = gcache.New()
+
 func NewPhotoSearchTool() *PhotoSearchTool {
 	return &PhotoSearchTool{}
 }
@@ -70,18 +89,58 @@ func NewPhotoSearchTool() *PhotoSearchTool {
 func (t *PhotoSearchTool) Info(_ context.Context) (*schema.ToolInfo, error) {
 	return &schema.ToolInfo{
 		Name: "photo_search_tool",
-		Desc: "Search for high-quality photos and return medium-sized image URLs",
+		Desc: `Search for high-quality photos and return image URLs with photographer attribution.
+Photos can be saved into the photos directory of the session workspace (download or download_ids) to embed them in generated PDFs, e.g. <img src="photos/pexels_123.jpeg">.
+Always credit the photographer with the attribution text when using a photo.`,
 		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
 			"query": {
 				Type:     schema.String,
-				Desc:     "Search query for photos (e.g., 'nature', 'city', 'people')",
-				Required: true,
+				Desc:     "Search query for photos (e.g., 'nature', 'city', 'people'), not required when only download_ids is given",
+				Required: false,
 			},
 			"per_page": {
 				Type:     schema.Integer,
 				Desc:     "Number of photos to return (1-80, default: 5)",
 				Required: false,
 			},
+			"page": {
+				Type:     schema.Integer,
+				Desc:     "Page number of the results (default: 1)",
+				Required: false,
+			},
+			"orientation": {
+				Type:     schema.String,
+				Desc:     "Only return photos with this orientation",
+				Enum:     photoOrientations,
+				Required: false,
+			},
+			"size": {
+				Type:     schema.String,
+				Desc:     "Minimum photo size: large (24MP), medium (12MP) or small (4MP)",
+				Enum:     photoSizes,
+				Required: false,
+			},
+			"color": {
+				Type:     schema.String,
+				Desc:     "Desired photo color: " + strings.Join(photoColors, ", ") + ", or a hex color such as #ffffff",
+				Required: false,
+			},
+			"locale": {
+				Type:     schema.String,
+				Desc:     "Locale of the query, e.g. zh-CN or en-US",
+				Required: false,
+			},
+			"download": {
+				Type:     schema.Integer,
+				Desc:     "Number of top results to also save into the workspace (default: 0)",
+				Required: false,
+			},
+			"download_ids": {
+				Type:     schema.Array,
+				ElemInfo: &schema.ParameterInfo{Type: schema.String},
+				Desc:     "IDs of photos returned by a previous search to save into the workspace",
+				Required: false,
+			},
 		}),
 	}, nil
 }
@@ -95,107 +154,186 @@ func (t *PhotoSearchTool) InvokableRun(ctx context.Context, argumentsInJSON stri
 	}
 
 	// 2. 参数验证和默认值设置
-	if req.Query == "" {
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" && len(req.DownloadIDs) == 0 {
 		return "", fmt.Errorf("query parameter is required")
 	}
-
-	if req.PerPage <= 0 {
-		req.PerPage = 5 // 默认返回5张图片
+	if req.Orientation != "" && !slices.Contains(photoOrientations, req.Orientation) {
+		return "", fmt.Errorf("unsupported orientation: %s", req.Orientation)
 	}
-	if req.PerPage > 80 {
-		req.PerPage = 80 // Pexels API 限制最多80张
+	if req.Size != "" && !slices.Contains(photoSizes, req.Size) {
+		return "", fmt.Errorf("unsupported size: %s", req.Size)
 	}
-
-	// 3. 获取 Pexels API Key
-	apiKey := gconv.String(g.Cfg().MustGet(ctx, consts.PexelsApiKey))
-	if apiKey == "" {
-		return "", fmt.Errorf("Pexels API key not configured")
+	req.Color = strings.ToLower(strings.TrimSpace(req.Color))
+	if req.Color != "" && !slices.Contains(photoColors, req.Color) && !hexColorPattern.MatchString(req.Color) {
+		return "", fmt.Errorf("unsupported color: %s", req.Color)
 	}
-
-	// 4. 构建请求URL
-	baseURL := "https://api.pexels.com/v1/search"
-	queryParams := url.Values{
-		"query":    {req.Query},
-		"per_page": {gconv.String(req.PerPage)},
+	cfg := loadPhotoConfig(ctx)
+	if req.PerPage <= 0 {
+		req.PerPage = cfg.Count
 	}
-	fullURL := fmt.Sprintf("%s?%s", baseURL, queryParams.Encode())
+	req.PerPage = min(req.PerPage, 80) // Pexels API 限制最多80张
+	req.Page = max(req.Page, 1)
 
-	// 5. 创建HTTP请求
-	httpReq, err := http.NewRequestWithContext(ctx, "GET", fullURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %v", err)
+	// 3. 创建搜索后端
+	provider := t.provider
+	if provider == nil {
+		provider, err = newPhotoProvider(cfg)
+		if err != nil {
+			return "", err
+		}
 	}
+	res := &PhotoSearchResponse{Query: req.Query, Provider: provider.Name()}
 
-	// 6. 设置Authorization头
-	httpReq.Header.Set("Authorization", apiKey)
-	httpReq.Header.Set("User-Agent", "PhotoSearchTool/1.0")
+	// 4. 只下载之前搜索到的图片
+	if req.Query == "" {
+		for _, id := range req.DownloadIDs {
+			photo := &Photo{ID: id, DownloadError: "photo not found, it may have expired, search again"}
+			if v, _ := photoCache.Get(ctx, "photo:"+id); v != nil {
+				photo = t.downloadPhoto(ctx, provider, v.Val().(*Photo))
+			}
+			res.Photos = append(res.Photos, photo)
+		}
+		return gjson.EncodeString(res)
+	}
 
-	// 7. 发送请求
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
+	// 5. 搜索，优先使用缓存
+	searchReq := &PhotoSearchRequest{
+		Query:       req.Query,
+		Count:       req.PerPage,
+		Page:        req.Page,
+		Orientation: req.Orientation,
+		Size:        req.Size,
+		Color:       req.Color,
+		Locale:      req.Locale,
+	}
+	photos, cached, err := searchPhotos(ctx, provider, searchReq, cfg.CacheTTL)
 	if err != nil {
-		return "", fmt.Errorf("failed to send request: %v", err)
+		// 搜索失败不中断 Agent 运行，由模型决定是否重试或换个问法
+		g.Log(consts.LoggerTools).Errorf(ctx, "photo search with %s failed: %v", provider.Name(), err)
+		return "Error searching photos: " + secret.Mask(err.Error()), nil
 	}
-	defer resp.Body.Close()
-
-	// 8. 检查响应状态
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+	res.Cached = cached
+	if len(photos) == 0 {
+		res.Note = "No photos found, try a broader query or fewer filters"
 	}
 
-	// 9. 读取响应体
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+	// 6. 下载排名靠前的图片和指定的图片，缓存中的图片对象共享，返回副本
+	for i, photo := range photos {
+		if i < req.Download || slices.Contains(req.DownloadIDs, photo.ID) {
+			res.Photos = append(res.Photos, t.downloadPhoto(ctx, provider, photo))
+			continue
+		}
+		res.Photos = append(res.Photos, photo)
 	}
 
-	// 10. 解析JSON响应
-	var pexelsResp PexelsResponse
-	err = gjson.DecodeTo(body, &pexelsResp)
+	g.Log(consts.LoggerTools).Infof(ctx, "Photo search completed: provider=%s, query=%s, results=%d, cached=%v", provider.Name(), logging.Content(req.Query), len(res.Photos), cached)
+	return gjson.EncodeString(res)
+}
+
+// hexColorPattern 十六进制颜色
+var hexColorPattern = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// searchPhotos 搜索图片，结果按请求缓存
+func searchPhotos(ctx context.Context, provider PhotoProvider, req *PhotoSearchRequest, ttl time.Duration) ([]*Photo, bool, error) {
+	key := "search:" + provider.Name() + ":" + gjson.MustEncodeString(req)
+	if ttl > 0 {
+		if v, _ := photoCache.Get(ctx, key); v != nil {
+			return v.Val().([]*Photo), true, nil
+		}
+	}
+	photos, err := provider.Search(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse response: %v", err)
-	}
-
-	// 11. 提取medium URL和相关信息
-	var results []PhotoResult
-	for _, photo := range pexelsResp.Photos {
-		result := PhotoResult{
-			ID:           photo.ID,
-			Alt:          photo.Alt,
-			MediumURL:    photo.Src.Medium,
-			Photographer: photo.Photographer,
-			Width:        photo.Width,
-			Height:       photo.Height,
+		return nil, false, err
+	}
+	if len(photos) > req.Count {
+		photos = photos[:req.Count]
+	}
+	if ttl > 0 {
+		_ = photoCache.Set(ctx, key, photos, ttl)
+		for _, photo := range photos {
+			_ = photoCache.Set(ctx, "photo:"+photo.ID, photo, ttl)
 		}
-		results = append(results, result)
 	}
+	return photos, false, nil
+}
 
-	// 12. 构建返回信息
-	if len(results) == 0 {
-		return fmt.Sprintf("No photos found for query: %s", req.Query), nil
-	}
+// downloadPhoto 将图片保存到工作目录的 photos 目录，返回带路径或错误信息的副本
+func (t *PhotoSearchTool) downloadPhoto(ctx context.Context, provider PhotoProvider, photo *Photo) *Photo {
+	result := *photo
+	name := sanitizeFilename(strings.ReplaceAll(photo.ID, ":", "_"))
 
-	// 13. 格式化返回结果
-	var resultStrings []string
-	resultStrings = append(resultStrings, fmt.Sprintf("Found %d photos for query '%s':", len(results), req.Query))
+	// 1. 本地图库直接复制
+	if photo.localPath != "" {
+		path, err := copyIntoWorkspace(ctx, photo.localPath, "photos", name+filepath.Ext(photo.localPath))
+		if err != nil {
+			result.DownloadError = err.Error()
+		}
+		result.Path = path
+		return &result
+	}
 
-	for i, result := range results {
-		resultStrings = append(resultStrings, fmt.Sprintf(
-			"%d. %s\n   - Medium URL: %s\n   - Photographer: %s\n   - Size: %dx%d",
-			i+1, result.Alt, result.MediumURL, result.Photographer, result.Width, result.Height,
-		))
+	// 2. 按图库要求上报下载
+	if tracker, ok := provider.(photoDownloadTracker); ok && provider.Name() == photo.Provider {
+		if err := tracker.TrackDownload(ctx, photo); err != nil {
+			g.Log(consts.LoggerTools).Warningf(ctx, "failed to track download of %s: %v", photo.ID, err)
+		}
 	}
 
-	// 14. 同时返回JSON格式供程序使用
-	jsonResult, err := gjson.EncodeString(results)
+	// 3. 下载大尺寸图片，只允许图片类型
+	rawURL := photo.LargeURL
+	if rawURL == "" {
+		rawURL = photo.ImageURL
+	}
+	ext := ".jpg"
+	if u, err := url.Parse(rawURL); err == nil && strings.HasPrefix(mime.TypeByExtension(path.Ext(u.Path)), "image/") {
+		ext = path.Ext(u.Path)
+	}
+	cfg := loadDownloadConfig(ctx)
+	cfg.AllowedTypes = []string{"image/*"}
+	downloaded, _, err := downloadFile(ctx, cfg, rawURL, "photos", name+ext, "")
 	if err != nil {
-		return strings.Join(resultStrings, "\n"), nil
+		result.DownloadError = err.Error()
+		return &result
 	}
+	result.Path = downloaded.Path
+	return &result
+}
 
-	finalResult := strings.Join(resultStrings, "\n") + "\n\nJSON Data:\n" + jsonResult
-
-	g.Log().Infof(ctx, "Photo search completed: query=%s, results=%d", req.Query, len(results))
-
-	return finalResult, nil
+// copyIntoWorkspace 将服务器上的文件复制到工作目录内的 dir 目录，返回相对路径
+func copyIntoWorkspace(ctx context.Context, src, dir, filename string) (string, error) {
+	workspace, err := sessionWorkspace(ctx)
+	if err != nil {
+		return "", err
+	}
+	targetDir, err := resolveInWorkspace(workspace, dir)
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(targetDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create directory: %v", err)
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %v", filepath.Base(src), err)
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat %s: %v", filepath.Base(src), err)
+	}
+	if err = loadWorkspaceQuota(ctx).check(workspace, info.Size(), 1); err != nil {
+		return "", err
+	}
+	filePath := uniquePath(filepath.Join(targetDir, filename))
+	out, err := os.Create(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create file: %v", err)
+	}
+	defer out.Close()
+	if _, err = io.Copy(out, in); err != nil {
+		_ = os.Remove(filePath)
+		return "", fmt.Errorf("failed to copy file: %v", err)
+	}
+	return workspaceRel(workspace, filePath), nil
 }
@@ -1,52 +1,267 @@
 package tools
 
 import (
+	"agent/internal/consts"
+	"agent/internal/model"
+	"bytes"
 	"context"
 	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
-	"github.com/cloudwego/eino/components/tool"
+	"github.com/gogf/gf/v2/encoding/gjson"
 )
 
-func TestPhotoSearchTool_InvokableRun(t1 *testing.T) {
-	type fields struct {
-		Query   string
-		PerPage int
-	}
-	type args struct {
-		ctx             context.Context
-		argumentsInJSON string
-		in2             []tool.Option
+// testPNG 生成指定尺寸的 PNG 图片
+func testPNG(t1 *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t1.Fatal(err)
 	}
+	return buf.Bytes()
+}
+
+// testSearchClient 不重试的测试 HTTP 客户端
+func testSearchClient(server *httptest.Server) *searchClient {
+	return &searchClient{http: server.Client(), backoff: time.Millisecond, userAgent: "test"}
+}
+
+func TestPhotoSearchTool_InvokableRun(t1 *testing.T) {
+	ctx := context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_photo"})
+	defer RemoveSessionWorkspace(ctx, "test_photo")
+	setTestConfig(t1, consts.HTTPAllowPrivateNetworks, true)
+
+	photo := testPNG(t1, 4, 3)
+	var searches atomic.Int32
+	var lastQuery url.Values
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/search":
+			searches.Add(1)
+			lastQuery = r.URL.Query()
+			if r.Header.Get("Authorization") != "test-key" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if r.URL.Query().Get("query") == "empty" {
+				fmt.Fprint(w, `{"photos":[]}`)
+				return
+			}
+			fmt.Fprintf(w, `{"photos":[
+				{"id":1,"width":4000,"height":3000,"url":"https://www.pexels.com/photo/1/","photographer":"Alice","photographer_url":"https://www.pexels.com/@alice","avg_color":"#336699","alt":"Sea at sunset","src":{"medium":"%[1]s/img/1.png?h=350","large2x":"%[1]s/img/1.png","tiny":"%[1]s/img/1.png?h=20"}},
+				{"id":2,"width":3000,"height":4000,"url":"https://www.pexels.com/photo/2/","photographer":"Bob","alt":"Lighthouse","src":{"medium":"%[1]s/img/2.png","large2x":"%[1]s/img/2.png"}}
+			]}`, server.URL)
+		case "/img/1.png", "/img/2.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(photo)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	provider := &pexelsProvider{client: testSearchClient(server), baseURL: server.URL + "/v1/search", apiKey: "test-key"}
+
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    string
-		wantErr bool
+		name       string
+		args       string
+		provider   PhotoProvider
+		wantIDs    []string
+		wantPaths  []string
+		wantQuery  url.Values
+		wantCached bool
+		wantText   string
+		wantErr    bool
 	}{
 		{
-			name: "test",
-			fields: fields{
-				Query:   "computer",
-				PerPage: 3,
-			},
-			args: args{
-				ctx:             context.Background(),
-				argumentsInJSON: `{"query": "computer", "per_page": 3}`,
-				in2:             []tool.Option{},
-			},
+			name:      "filters",
+			args:      `{"query":"sea","per_page":2,"orientation":"landscape","size":"large","color":"#FFAA00","locale":"zh-CN"}`,
+			wantIDs:   []string{"pexels:1", "pexels:2"},
+			wantPaths: []string{"", ""},
+			wantQuery: url.Values{"query": {"sea"}, "per_page": {"2"}, "page": {"1"}, "orientation": {"landscape"}, "size": {"large"}, "color": {"ffaa00"}, "locale": {"zh-CN"}},
+		},
+		{
+			name:       "cached with download",
+			args:       `{"query":"sea","per_page":2,"orientation":"landscape","size":"large","color":"#FFAA00","locale":"zh-CN","download":1}`,
+			wantIDs:    []string{"pexels:1", "pexels:2"},
+			wantPaths:  []string{"photos/pexels_1.png", ""},
+			wantCached: true,
 		},
+		{
+			name:      "download ids",
+			args:      `{"download_ids":["pexels:2","pexels:999"]}`,
+			wantIDs:   []string{"pexels:2", "pexels:999"},
+			wantPaths: []string{"photos/pexels_2.png", ""},
+		},
+		{name: "no results", args: `{"query":"empty"}`, wantIDs: []string{}},
+		{
+			name:     "api error",
+			args:     `{"query":"sea"}`,
+			provider: &pexelsProvider{client: testSearchClient(server), baseURL: server.URL + "/v1/search", apiKey: "wrong"},
+			wantText: "Error searching photos: search request failed with status 401",
+		},
+		{name: "missing query", args: `{"per_page":3}`, wantErr: true},
+		{name: "invalid orientation", args: `{"query":"sea","orientation":"diagonal"}`, wantErr: true},
+		{name: "invalid color", args: `{"query":"sea","color":"rainbow"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			t := &PhotoSearchTool{provider: provider}
+			if tt.provider != nil {
+				t.provider = tt.provider
+			}
+			before := searches.Load()
+			got, err := t.InvokableRun(ctx, tt.args)
+			if (err != nil) != tt.wantErr {
+				t1.Fatalf("InvokableRun() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantText != "" {
+				if !strings.HasPrefix(got, tt.wantText) {
+					t1.Errorf("InvokableRun() got = %v, want prefix %v", got, tt.wantText)
+				}
+				return
+			}
+			var res PhotoSearchResponse
+			if err = gjson.DecodeTo(got, &res); err != nil {
+				t1.Fatalf("failed to decode result: %v", err)
+			}
+			if res.Cached != tt.wantCached || (tt.wantCached && searches.Load() != before) {
+				t1.Errorf("InvokableRun() cached = %v, want %v", res.Cached, tt.wantCached)
+			}
+			if len(res.Photos) != len(tt.wantIDs) {
+				t1.Fatalf("InvokableRun() got %d photos, want %d: %v", len(res.Photos), len(tt.wantIDs), got)
+			}
+			for i, p := range res.Photos {
+				if p.ID != tt.wantIDs[i] || p.Path != tt.wantPaths[i] {
+					t1.Errorf("InvokableRun() photo %d = %s %q, want %s %q (%s)", i, p.ID, p.Path, tt.wantIDs[i], tt.wantPaths[i], p.DownloadError)
+				}
+			}
+			if tt.wantQuery != nil && lastQuery.Encode() != tt.wantQuery.Encode() {
+				t1.Errorf("InvokableRun() query = %v, want %v", lastQuery.Encode(), tt.wantQuery.Encode())
+			}
+			if tt.name == "filters" {
+				p := res.Photos[0]
+				if p.Attribution != "Photo by Alice on Pexels" || p.PhotographerURL != "https://www.pexels.com/@alice" || p.Description != "Sea at sunset" || !strings.HasSuffix(p.ImageURL, "h=350") {
+					t1.Errorf("InvokableRun() photo = %+v", p)
+				}
+			}
+			if tt.name == "download ids" && res.Photos[1].DownloadError == "" {
+				t1.Errorf("InvokableRun() expected a download error for an unknown id")
+			}
+		})
+	}
+}
+
+func TestUnsplashProvider(t1 *testing.T) {
+	var tracked atomic.Int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Client-ID key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/search/photos":
+			want := url.Values{"query": {"forest"}, "per_page": {"5"}, "page": {"2"}, "orientation": {"squarish"}, "color": {"teal"}, "lang": {"zh"}}
+			if r.URL.Query().Encode() != want.Encode() {
+				t1.Errorf("Search() query = %v, want %v", r.URL.Query().Encode(), want.Encode())
+			}
+			fmt.Fprintf(w, `{"results":[
+				{"id":"a1","width":6000,"height":6000,"color":"#0c4020","alt_description":"misty forest","urls":{"full":"https://images.unsplash.com/a1","regular":"https://images.unsplash.com/a1?w=1080","thumb":"https://images.unsplash.com/a1?w=200"},"links":{"html":"https://unsplash.com/photos/a1","download_location":"%s/photos/a1/download"},"user":{"name":"Carol","links":{"html":"https://unsplash.com/@carol"}}},
+				{"id":"b2","width":1000,"height":1000,"description":"tiny","user":{"name":"Dan"}}
+			]}`, server.URL)
+		case "/photos/a1/download":
+			tracked.Add(1)
+			fmt.Fprint(w, `{"url":"https://images.unsplash.com/a1"}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p := &unsplashProvider{client: testSearchClient(server), baseURL: server.URL, apiKey: "key"}
+	photos, err := p.Search(context.Background(), &PhotoSearchRequest{
+		Query: "forest", Count: 5, Page: 2, Orientation: "square", Size: "medium", Color: "turquoise", Locale: "zh-CN",
+	})
+	if err != nil {
+		t1.Fatalf("Search() error = %v", err)
+	}
+	// b2 只有 1MP，被尺寸过滤
+	if len(photos) != 1 {
+		t1.Fatalf("Search() got %d photos, want 1", len(photos))
+	}
+	got := photos[0]
+	if got.ID != "unsplash:a1" || got.Attribution != "Photo by Carol on Unsplash" || got.Description != "misty forest" ||
+		got.PhotographerURL != "https://unsplash.com/@carol"+unsplashReferral || got.LargeURL != "https://images.unsplash.com/a1" {
+		t1.Errorf("Search() photo = %+v", got)
 	}
+	if err = p.TrackDownload(context.Background(), got); err != nil || tracked.Load() != 1 {
+		t1.Errorf("TrackDownload() error = %v, tracked %d", err, tracked.Load())
+	}
+}
+
+func TestLocalPhotoProvider(t1 *testing.T) {
+	dir := t1.TempDir()
+	for name, size := range map[string][2]int{
+		"red_apple.png":   {300, 200},
+		"green-apple.png": {200, 300},
+		"tree.png":        {100, 100},
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), testPNG(t1, size[0], size[1]), 0644); err != nil {
+			t1.Fatal(err)
+		}
+	}
+	index := `[{"file":"tree.png","description":"一棵大树","tags":["nature"],"color":"green","photographer":"Bob","license":"CC0"}]`
+	if err := os.WriteFile(filepath.Join(dir, photoIndexFile), []byte(index), 0644); err != nil {
+		t1.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  PhotoSearchRequest
+		want []string
+	}{
+		{name: "filename", req: PhotoSearchRequest{Query: "apple"}, want: []string{"local:green-apple.png", "local:red_apple.png"}},
+		{name: "orientation", req: PhotoSearchRequest{Query: "apple", Orientation: "portrait"}, want: []string{"local:green-apple.png"}},
+		{name: "square", req: PhotoSearchRequest{Query: "apple tree nature", Orientation: "square"}, want: []string{"local:tree.png"}},
+		{name: "chinese description", req: PhotoSearchRequest{Query: "大树"}, want: []string{"local:tree.png"}},
+		{name: "color", req: PhotoSearchRequest{Query: "nature apple", Color: "green"}, want: []string{"local:tree.png"}},
+		{name: "size", req: PhotoSearchRequest{Query: "apple", Size: "small"}, want: []string{}},
+		{name: "page", req: PhotoSearchRequest{Query: "apple", Count: 1, Page: 2}, want: []string{"local:red_apple.png"}},
+	}
+	p := &localPhotoProvider{dir: dir}
 	for _, tt := range tests {
 		t1.Run(tt.name, func(t1 *testing.T) {
-			t := &PhotoSearchTool{
-				Query:   tt.fields.Query,
-				PerPage: tt.fields.PerPage,
+			if tt.req.Count == 0 {
+				tt.req.Count = 5
+			}
+			tt.req.Page = max(tt.req.Page, 1)
+			photos, err := p.Search(context.Background(), &tt.req)
+			if err != nil {
+				t1.Fatalf("Search() error = %v", err)
+			}
+			ids := make([]string, 0, len(photos))
+			for _, photo := range photos {
+				ids = append(ids, photo.ID)
+			}
+			if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+				t1.Errorf("Search() = %v, want %v", ids, tt.want)
+			}
+			if len(photos) == 1 && photos[0].ID == "local:tree.png" && photos[0].Attribution != "Photo by Bob (CC0)" {
+				t1.Errorf("Search() attribution = %v", photos[0].Attribution)
 			}
-			got, err := t.InvokableRun(tt.args.ctx, tt.args.argumentsInJSON, tt.args.in2...)
-			fmt.Println(got)
-			fmt.Println(err)
 		})
 	}
 }
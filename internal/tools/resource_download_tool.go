@@ -1,26 +1,105 @@
 package tools
 
 import (
+	"agent/internal/consts"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
 )
 
+const (
+	defaultDownloadTimeout  = 5 * time.Minute
+	defaultDownloadMaxBytes = 100 * 1024 * 1024
+	defaultDownloadRetries  = 3
+
+	// partSuffix 未完成的下载文件后缀，中断后可以从已下载的位置续传
+	partSuffix = ".part"
+)
+
+// defaultDownloadTypes 默认允许下载的内容类型，不包含 HTML 以免把错误页或登录页当作文件保存
+var defaultDownloadTypes = []string{
+	"image/*", "audio/*", "video/*", "font/*",
+	"text/plain", "text/csv", "text/markdown",
+	"application/pdf", "application/json", "application/xml",
+	"application/zip", "application/gzip", "application/x-gzip", "application/x-tar", "application/x-7z-compressed",
+	"application/msword", "application/vnd.*", "application/epub+zip", "application/octet-stream",
+}
+
 type ResourceDownloadTool struct {
 	URL      string `json:"url"`
 	Filename string `json:"filename,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+}
+
+// DownloadResult 下载结果
+type DownloadResult struct {
+	Path        string `json:"path"`         // 工作目录内的相对路径
+	FinalURL    string `json:"final_url"`    // 重定向后的最终地址
+	Size        int64  `json:"size"`         // 文件大小（字节）
+	ContentType string `json:"content_type"` // 检测到的内容类型
+	SHA256      string `json:"sha256"`
+	Resumed     bool   `json:"resumed,omitempty"`      // 是否从中断处续传
+	DownloadURL string `json:"download_url,omitempty"` // 产物下载链接
+}
+
+// downloadConfig 下载限制
+type downloadConfig struct {
+	Timeout      time.Duration
+	MaxBytes     int64
+	Retries      int
+	AllowedTypes []string
+}
+
+// loadDownloadConfig 从配置中读取下载限制
+func loadDownloadConfig(ctx context.Context) *downloadConfig {
+	cfg := &downloadConfig{
+		Timeout:      g.Cfg().MustGet(ctx, consts.DownloadTimeout, defaultDownloadTimeout).Duration(),
+		MaxBytes:     g.Cfg().MustGet(ctx, consts.DownloadMaxBytes, defaultDownloadMaxBytes).Int64(),
+		Retries:      g.Cfg().MustGet(ctx, consts.DownloadRetries, defaultDownloadRetries).Int(),
+		AllowedTypes: g.Cfg().MustGet(ctx, consts.DownloadAllowedTypes, defaultDownloadTypes).Strings(),
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultDownloadTimeout
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = defaultDownloadMaxBytes
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	}
+	return cfg
 }
 
+// partMeta 未完成下载的元数据，续传时用于确认远端文件未变化
+type partMeta struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// errNotRetryable 不需要重试的下载错误
+type errNotRetryable struct{ err error }
+
+func (e *errNotRetryable) Error() string { return e.err.Error() }
+func (e *errNotRetryable) Unwrap() error { return e.err }
+
 func NewResourceDownloadTool() *ResourceDownloadTool {
 	return &ResourceDownloadTool{}
 }
@@ -28,7 +107,7 @@ func NewResourceDownloadTool() *ResourceDownloadTool {
 func (t *ResourceDownloadTool) Info(_ context.Context) (*schema.ToolInfo, error) {
 	return &schema.ToolInfo{
 		Name: "resource_download_tool",
-		Desc: "Download files from URL and save to resource/download directory",
+		Desc: "Download files from URL and save to the download directory of the session workspace, returns the saved path, final URL, size, content type and sha256",
 		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
 			"url": {
 				Type:     schema.String,
@@ -40,6 +119,11 @@ func (t *ResourceDownloadTool) Info(_ context.Context) (*schema.ToolInfo, error)
 				Desc:     "Custom filename for the downloaded file (optional, will auto-detect from URL if not provided)",
 				Required: false,
 			},
+			"sha256": {
+				Type:     schema.String,
+				Desc:     "Expected sha256 checksum in hex, the download fails if it does not match (optional)",
+				Required: false,
+			},
 		}),
 	}, nil
 }
@@ -71,62 +155,281 @@ func (t *ResourceDownloadTool) InvokableRun(ctx context.Context, argumentsInJSON
 	filename := req.Filename
 	if filename == "" {
 		// 从URL中提取文件名
-		filename = filepath.Base(parsedURL.Path)
-		if filename == "." || filename == "/" {
-			// 如果无法从URL提取文件名，使用时间戳
-			filename = fmt.Sprintf("download_%d", time.Now().Unix())
-		}
+		filename = path.Base(parsedURL.Path)
 	}
 
 	// 安全检查：确保文件名不包含路径遍历字符
 	filename = filepath.Base(filename)
-	if filename == "" || filename == "." || filename == ".." {
+	if filename == "" || filename == "." || filename == ".." || filename == "/" {
 		filename = fmt.Sprintf("download_%d", time.Now().Unix())
 	}
 
-	// 4. 创建下载目录
-	downloadDir := "resource/download"
-	err = os.MkdirAll(downloadDir, 0755)
+	// 4. 下载到会话工作目录的 download 目录
+	result, filePath, err := downloadFile(ctx, loadDownloadConfig(ctx), parsedURL.String(), "download", filename, req.SHA256)
 	if err != nil {
-		return "", fmt.Errorf("failed to create download directory: %v", err)
+		return "", err
 	}
 
-	// 构建完整文件路径
-	filePath := filepath.Join(downloadDir, filename)
+	// 5. 登记产物，返回下载结果
+	if artifact, err := RegisterArtifact(ctx, filePath, "resource_download_tool"); err != nil {
+		g.Log(consts.LoggerTools).Warningf(ctx, "failed to register artifact %s: %v", filePath, err)
+	} else {
+		result.DownloadURL = artifact.URL
+	}
+	return gjson.EncodeString(result)
+}
+
+// downloadFile 下载到工作目录内的 dir 目录，返回下载结果和文件的绝对路径，文件已存在时添加序号
+func downloadFile(ctx context.Context, cfg *downloadConfig, rawURL, dir, filename, wantSHA256 string) (*DownloadResult, string, error) {
+	// 1. 创建目标目录，位于当前会话的工作目录内
+	workspace, err := sessionWorkspace(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	targetDir, err := resolveInWorkspace(workspace, dir)
+	if err != nil {
+		return nil, "", err
+	}
+	err = os.MkdirAll(targetDir, 0755)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create download directory: %v", err)
+	}
+
+	// 2. 下载到临时文件，失败时按剩余次数续传
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	partPath := filepath.Join(targetDir, filename+partSuffix)
+	result, err := downloadWithResume(ctx, cfg, workspace, rawURL, partPath)
+	if err != nil {
+		return nil, "", err
+	}
 
-	// 检查文件是否已存在，如果存在则添加序号
-	originalPath := filePath
-	counter := 1
-	for {
-		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+	// 3. 校验 sha256，移动到最终路径
+	if wantSHA256 != "" && !strings.EqualFold(wantSHA256, result.SHA256) {
+		removePart(partPath)
+		return nil, "", fmt.Errorf("sha256 mismatch: expected %s, got %s", strings.ToLower(wantSHA256), result.SHA256)
+	}
+	filePath := uniquePath(filepath.Join(targetDir, filename))
+	if err = os.Rename(partPath, filePath); err != nil {
+		return nil, "", fmt.Errorf("failed to save file: %v", err)
+	}
+	_ = os.Remove(partPath + ".json")
+	result.Path = workspaceRel(workspace, filePath)
+	return result, filePath, nil
+}
+
+// downloadWithResume 下载到 partPath，网络中断时使用 Range 请求从已下载的位置继续
+func downloadWithResume(ctx context.Context, cfg *downloadConfig, workspace, rawURL, partPath string) (*DownloadResult, error) {
+	client := safeHTTPClient(ctx, 0)
+	result := &DownloadResult{}
+	var err error
+	for attempt := 0; attempt <= cfg.Retries; attempt++ {
+		if attempt > 0 {
+			g.Log(consts.LoggerTools).Warningf(ctx, "download of %s interrupted, resuming (attempt %d): %v", rawURL, attempt, err)
+			select {
+			case <-ctx.Done():
+				return nil, fmt.Errorf("failed to download from URL: %v", ctx.Err())
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+		err = downloadOnce(ctx, client, cfg, workspace, rawURL, partPath, result)
+		if err == nil {
 			break
 		}
-		ext := filepath.Ext(originalPath)
-		nameWithoutExt := strings.TrimSuffix(filepath.Base(originalPath), ext)
-		filePath = filepath.Join(downloadDir, fmt.Sprintf("%s_%d%s", nameWithoutExt, counter, ext))
-		counter++
+		var notRetryable *errNotRetryable
+		if errors.As(err, &notRetryable) || ctx.Err() != nil {
+			removePart(partPath)
+			return nil, err
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sum, err := fileSHA256(partPath)
+	if err != nil {
+		return nil, err
+	}
+	result.SHA256 = sum
+	return result, nil
+}
+
+// downloadOnce 发起一次请求，已有未完成的文件且远端未变化时从断点续传
+func downloadOnce(ctx context.Context, client *http.Client, cfg *downloadConfig, workspace, rawURL, partPath string, result *DownloadResult) error {
+	// 1. 读取断点
+	var offset int64
+	meta := &partMeta{}
+	if data, err := os.ReadFile(partPath + ".json"); err == nil && gjson.DecodeTo(data, meta) == nil && meta.URL == rawURL {
+		if info, err := os.Stat(partPath); err == nil {
+			offset = info.Size()
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return &errNotRetryable{fmt.Errorf("failed to create request: %v", err)}
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		// 远端文件变化时服务器返回完整内容而不是片段
+		if meta.ETag != "" {
+			req.Header.Set("If-Range", meta.ETag)
+		} else if meta.LastModified != "" {
+			req.Header.Set("If-Range", meta.LastModified)
+		}
 	}
 
-	resp, err := http.Get(req.URL)
+	// 2. 发起请求并检查状态码
+	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to download from URL: %v", err)
+		if errors.Is(err, ErrBlockedAddress) {
+			return &errNotRetryable{fmt.Errorf("failed to download from URL: %v", err)}
+		}
+		return fmt.Errorf("failed to download from URL: %v", err)
 	}
 	defer resp.Body.Close()
+	result.FinalURL = resp.Request.URL.String()
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && offset > 0 && contentRangeStart(resp.Header.Get("Content-Range")) == offset:
+		result.Resumed = true
+	case resp.StatusCode == http.StatusOK:
+		offset = 0
+	case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Errorf("server returned status %d", resp.StatusCode)
+	default:
+		return &errNotRetryable{fmt.Errorf("server returned status %d", resp.StatusCode)}
+	}
+
+	// 3. 检查大小和内容类型
+	if resp.ContentLength > 0 && offset+resp.ContentLength > cfg.MaxBytes {
+		return &errNotRetryable{fmt.Errorf("file size %d exceeds the limit of %d bytes", offset+resp.ContentLength, cfg.MaxBytes)}
+	}
+	head := make([]byte, binarySniffBytes)
+	n, err := io.ReadFull(resp.Body, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("failed to download file content: %v", err)
+	}
+	head = head[:n]
+	if offset == 0 {
+		contentType, err := checkContentType(resp.Header.Get("Content-Type"), head, cfg.AllowedTypes)
+		if err != nil {
+			return &errNotRetryable{err}
+		}
+		result.ContentType = contentType
+	} else if result.ContentType == "" {
+		mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+		result.ContentType = mediaType
+	}
 
-	// 创建目标文件
-	file, err := os.Create(filePath)
+	// 4. 写入断点信息和文件内容
+	if offset == 0 {
+		meta = &partMeta{URL: rawURL, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}
+		data, _ := gjson.Marshal(meta)
+		if err = os.WriteFile(partPath+".json", data, 0644); err != nil {
+			return &errNotRetryable{fmt.Errorf("failed to save download state: %v", err)}
+		}
+	}
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(partPath, flags, 0644)
 	if err != nil {
-		return "", fmt.Errorf("failed to create file: %v", err)
+		return &errNotRetryable{fmt.Errorf("failed to create file: %v", err)}
 	}
 	defer file.Close()
 
-	//下载文件内容
-	_, err = io.Copy(file, resp.Body)
+	// 单个文件大小和工作目录剩余配额取较小值，工作目录用量已包含已下载部分
+	remaining, err := loadWorkspaceQuota(ctx).remaining(workspace)
 	if err != nil {
-		// 如果下载失败，删除部分下载的文件
-		os.Remove(filePath)
-		return "", fmt.Errorf("failed to download file content: %v", err)
+		return &errNotRetryable{err}
+	}
+	limit := min(cfg.MaxBytes-offset, remaining)
+	written, err := copyWithQuota(file, io.MultiReader(strings.NewReader(string(head)), resp.Body), limit)
+	result.Size = offset + written
+	if offset == 0 {
+		addWorkspaceUsage(workspace, written, 1)
+	} else {
+		addWorkspaceUsage(workspace, written, 0)
+	}
+	if errors.Is(err, errQuotaExceeded) {
+		if limit < cfg.MaxBytes-offset {
+			return &errNotRetryable{err}
+		}
+		return &errNotRetryable{fmt.Errorf("file exceeds the limit of %d bytes", cfg.MaxBytes)}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to download file content: %v", err)
+	}
+	if resp.ContentLength > 0 && written < resp.ContentLength {
+		return fmt.Errorf("failed to download file content: got %d of %d bytes", written, resp.ContentLength)
 	}
+	return nil
+}
+
+// checkContentType 结合响应头和文件头判断内容类型，并与允许列表比对
+func checkContentType(header string, head []byte, allowed []string) (string, error) {
+	declared, _, _ := mime.ParseMediaType(header)
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	// 文件头识别出的具体类型优先，识别不出时使用响应头
+	contentType := declared
+	if sniffed != "application/octet-stream" && sniffed != "text/plain" || contentType == "" {
+		contentType = sniffed
+	}
+	for _, t := range []string{declared, contentType} {
+		if t != "" && !mimeAllowed(t, allowed) {
+			return "", fmt.Errorf("content type %s is not allowed", t)
+		}
+	}
+	return contentType, nil
+}
+
+// mimeAllowed 内容类型是否在允许列表中，支持 image/* 和 application/vnd.* 形式的通配
+func mimeAllowed(contentType string, allowed []string) bool {
+	for _, pattern := range allowed {
+		if pattern == "*" || pattern == "*/*" || strings.EqualFold(pattern, contentType) {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(contentType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// contentRangeStart 解析 Content-Range 响应头中的起始位置，格式为 bytes start-end/total
+func contentRangeStart(header string) int64 {
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return -1
+	}
+	start, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// fileSHA256 计算文件的 sha256
+func fileSHA256(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %v", err)
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err = io.Copy(h, file); err != nil {
+		return "", fmt.Errorf("failed to compute sha256: %v", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
 
-	return fmt.Sprintf("Resource downloaded successfully to %s", filePath), nil
+// removePart 删除未完成的下载文件及其断点信息
+func removePart(partPath string) {
+	_ = os.Remove(partPath)
+	_ = os.Remove(partPath + ".json")
 }
@@ -1,10 +1,24 @@
 package tools
 
 import (
+	"agent/internal/consts"
+	"agent/internal/model"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 
 	"github.com/cloudwego/eino/components/tool"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcfg"
 )
 
 func TestResourceDownloadTool_InvokableRun(t1 *testing.T) {
@@ -31,11 +45,11 @@ func TestResourceDownloadTool_InvokableRun(t1 *testing.T) {
 				Filename: "image.jpg",
 			},
 			args: args{
-				ctx:             context.Background(),
+				ctx:             context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_download"}),
 				argumentsInJSON: `{"url": "https://picsum.photos/800/600", "filename": "image.jpg"}`,
 				in2:             []tool.Option{},
 			},
-			want:    "Resource downloaded successfully to image.jpg",
+			want:    "download/image.jpg",
 			wantErr: false,
 		},
 	}
@@ -50,8 +64,149 @@ func TestResourceDownloadTool_InvokableRun(t1 *testing.T) {
 				t1.Errorf("InvokableRun() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if got != tt.want {
-				t1.Errorf("InvokableRun() got = %v, want %v", got, tt.want)
+			if path := gjson.New(got).Get("path").String(); path != tt.want {
+				t1.Errorf("InvokableRun() got = %v, want path %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// setTestConfig 在测试期间修改配置项，测试结束后恢复
+func setTestConfig(t1 *testing.T, key string, value any) {
+	adapter := g.Cfg().GetAdapter().(*gcfg.AdapterFile)
+	old, _ := adapter.Get(context.Background(), key)
+	if err := adapter.Set(key, value); err != nil {
+		t1.Fatalf("failed to set config %s: %v", key, err)
+	}
+	t1.Cleanup(func() { _ = adapter.Set(key, old) })
+}
+
+func TestResourceDownloadTool_Hardening(t1 *testing.T) {
+	ctx := context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_download"})
+	defer RemoveSessionWorkspace(ctx, "test_download")
+
+	payload := append([]byte("\x89PNG\r\n\x1a\n"), []byte(strings.Repeat("pixel", 2000))...)
+	sum := sha256.Sum256(payload)
+	payloadSHA := hex.EncodeToString(sum[:])
+	var resumeCalls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/image.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(payload)
+		case "/redirect":
+			http.Redirect(w, r, "/image.png", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html><body>login</body></html>")
+		case "/disguised.bin":
+			// 响应头声称是二进制文件，实际内容是 HTML
+			w.Header().Set("Content-Type", "application/octet-stream")
+			fmt.Fprint(w, "<!DOCTYPE html><html><body>error</body></html>")
+		case "/resume.png":
+			// 第一次请求只返回一半内容后断开连接，续传请求返回剩余部分
+			w.Header().Set("Content-Type", "image/png")
+			w.Header().Set("ETag", `"v1"`)
+			if rng := r.Header.Get("Range"); rng != "" {
+				var start int
+				fmt.Sscanf(rng, "bytes=%d-", &start)
+				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(payload)-1, len(payload)))
+				w.Header().Set("Content-Length", strconv.Itoa(len(payload)-start))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(payload[start:])
+				return
+			}
+			resumeCalls.Add(1)
+			w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+			w.Write(payload[:len(payload)/2])
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// 默认拦截内网地址
+	_, err := NewResourceDownloadTool().InvokableRun(ctx, `{"url":"`+server.URL+`/image.png"}`)
+	if err == nil || !strings.Contains(err.Error(), ErrBlockedAddress.Error()) {
+		t1.Fatalf("InvokableRun() error = %v, want blocked address", err)
+	}
+	setTestConfig(t1, consts.HTTPAllowPrivateNetworks, true)
+
+	tests := []struct {
+		name        string
+		args        string
+		maxBytes    int64
+		wantPath    string
+		wantResumed bool
+		wantErr     string
+	}{
+		{name: "success", args: `{"url":"` + server.URL + `/image.png"}`, wantPath: "download/image.png"},
+		{name: "redirect", args: `{"url":"` + server.URL + `/redirect","filename":"copy.png","sha256":"` + payloadSHA + `"}`, wantPath: "download/copy.png"},
+		{name: "resume", args: `{"url":"` + server.URL + `/resume.png"}`, wantPath: "download/resume.png", wantResumed: true},
+		{name: "not found", args: `{"url":"` + server.URL + `/missing.png"}`, wantErr: "server returned status 404"},
+		{name: "html page", args: `{"url":"` + server.URL + `/page.html"}`, wantErr: "content type text/html is not allowed"},
+		{name: "disguised html", args: `{"url":"` + server.URL + `/disguised.bin"}`, wantErr: "content type text/html is not allowed"},
+		{name: "redirect loop", args: `{"url":"` + server.URL + `/loop"}`, wantErr: "stopped after 5 redirects"},
+		{name: "too large", args: `{"url":"` + server.URL + `/image.png","filename":"big.png"}`, maxBytes: 100, wantErr: "exceeds the limit of 100 bytes"},
+		{name: "checksum mismatch", args: `{"url":"` + server.URL + `/image.png","filename":"bad.png","sha256":"00"}`, wantErr: "sha256 mismatch"},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			if tt.maxBytes > 0 {
+				setTestConfig(t1, consts.DownloadMaxBytes, tt.maxBytes)
+			}
+			got, err := NewResourceDownloadTool().InvokableRun(ctx, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t1.Fatalf("InvokableRun() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t1.Fatalf("InvokableRun() error = %v", err)
+			}
+			var result DownloadResult
+			if err = gjson.DecodeTo(got, &result); err != nil {
+				t1.Fatalf("failed to decode result: %v", err)
+			}
+			if result.Path != tt.wantPath || result.Size != int64(len(payload)) || result.SHA256 != payloadSHA ||
+				result.ContentType != "image/png" || result.Resumed != tt.wantResumed || !strings.HasSuffix(result.FinalURL, ".png") {
+				t1.Errorf("InvokableRun() = %+v", result)
+			}
+		})
+	}
+	if resumeCalls.Load() != 1 {
+		t1.Errorf("resume download restarted %d times", resumeCalls.Load())
+	}
+}
+
+func TestIsPublicAddr(t1 *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"8.8.8.8", true},
+		{"2606:4700::1111", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"::1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+		{"::ffff:127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.addr, func(t1 *testing.T) {
+			if got := isPublicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+				t1.Errorf("isPublicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
 			}
 		})
 	}
@@ -0,0 +1,114 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+const defaultMaxRedirects = 5
+
+// ErrBlockedAddress 目标地址属于内网或保留地址
+var ErrBlockedAddress = errors.New("access to private or reserved network address is blocked")
+
+// reservedPrefixes net/netip 未覆盖的保留网段
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),     // 本网络
+	netip.MustParsePrefix("100.64.0.0/10"), // 运营商级 NAT
+	netip.MustParsePrefix("192.0.0.0/24"),  // IETF 协议分配
+	netip.MustParsePrefix("198.18.0.0/15"), // 基准测试
+	netip.MustParsePrefix("240.0.0.0/4"),   // 保留
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64
+	netip.MustParsePrefix("2001:db8::/32"), // 文档
+}
+
+// isPublicAddr 地址是否为公网地址，回环、私有、链路本地（含云厂商元数据地址）等均视为非公网
+func isPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// allowPrivateNetworks 是否允许访问内网地址，仅用于本地调试
+func allowPrivateNetworks(ctx context.Context) bool {
+	return g.Cfg().MustGet(ctx, consts.HTTPAllowPrivateNetworks, false).Bool()
+}
+
+// ssrfControl 在 DNS 解析之后、建立连接之前检查目标 IP，防止通过域名解析绕过检查
+func ssrfControl(_, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid dial address %s: %v", address, err)
+	}
+	if !isPublicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addrPort.Addr())
+	}
+	return nil
+}
+
+// safeHTTPClient 访问用户提供 URL 时使用的 HTTP 客户端：拦截内网地址、限制重定向次数、不使用环境代理
+func safeHTTPClient(ctx context.Context, timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if !allowPrivateNetworks(ctx) {
+		dialer.Control = ssrfControl
+	}
+	maxRedirects := g.Cfg().MustGet(ctx, consts.HTTPMaxRedirects, defaultMaxRedirects).Int()
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// 经代理访问时拨号目标为代理地址，无法检查真实目标，因此不使用环境代理
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			ForceAttemptHTTP2:     true,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// checkPublicHost 解析主机名并确认所有地址均为公网地址，用于无法控制拨号过程的场景（如浏览器渲染）
+func checkPublicHost(ctx context.Context, host string) error {
+	if allowPrivateNetworks(ctx) {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", host, err)
+	}
+	for _, addr := range addrs {
+		if !isPublicAddr(addr) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+		}
+	}
+	return nil
+}
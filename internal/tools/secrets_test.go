@@ -0,0 +1,129 @@
+package tools
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// parseGoFiles 解析目录下的非测试 Go 文件
+func parseGoFiles(t1 *testing.T, dir string) map[string]*ast.File {
+	fset := token.NewFileSet()
+	files := make(map[string]*ast.File)
+	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return err
+		}
+		f, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		files[path] = f
+		return nil
+	})
+	if err != nil {
+		t1.Fatal(err)
+	}
+	return files
+}
+
+// selectorName 返回 pkg.Name 形式的表达式，其他表达式返回空值
+func selectorName(expr ast.Expr) string {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	pkg, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	return pkg.Name + "." + sel.Sel.Name
+}
+
+// isCfgCall 调用是否为 g.Cfg().Xxx(...)
+func isCfgCall(call *ast.CallExpr) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	inner, ok := sel.X.(*ast.CallExpr)
+	return ok && selectorName(inner.Fun) == "g.Cfg"
+}
+
+// TestToolsSecretAccess 工具只能通过各自声明的 secret.Scope 取得启动时解析的密钥，
+// 不能通过 g.Cfg() 读取任何组件声明的密钥配置项，也不能自行解析密钥引用
+func TestToolsSecretAccess(t1 *testing.T) {
+	// 1. 配置项常量的值
+	constValues := make(map[string]string)
+	for _, f := range parseGoFiles(t1, "../consts") {
+		ast.Inspect(f, func(n ast.Node) bool {
+			spec, ok := n.(*ast.ValueSpec)
+			if !ok {
+				return true
+			}
+			for i, name := range spec.Names {
+				if i < len(spec.Values) {
+					if lit, ok := spec.Values[i].(*ast.BasicLit); ok && lit.Kind == token.STRING {
+						constValues["consts."+name.Name], _ = strconv.Unquote(lit.Value)
+					}
+				}
+			}
+			return true
+		})
+	}
+
+	// 2. 所有组件通过 secret.Declare 声明的密钥配置项
+	secretKeys := make(map[string]bool)
+	for _, f := range parseGoFiles(t1, "..") {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || selectorName(call.Fun) != "secret.Declare" {
+				return true
+			}
+			for _, arg := range call.Args[1:] {
+				if key, ok := constValues[selectorName(arg)]; ok {
+					secretKeys[key] = true
+				}
+			}
+			return true
+		})
+	}
+	if len(secretKeys) == 0 {
+		t1.Fatal("no secret.Declare found, the check is broken")
+	}
+
+	// 3. 工具代码中不能读取这些配置项，也不能解析引用或读取整份配置
+	for path, f := range parseGoFiles(t1, ".") {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if selectorName(call.Fun) == "secret.Resolve" {
+				t1.Errorf("%s: tools must not call secret.Resolve, declare the key with secret.Declare instead", path)
+			}
+			if !isCfgCall(call) {
+				return true
+			}
+			method := call.Fun.(*ast.SelectorExpr).Sel.Name
+			if method == "Data" || method == "GetAdapter" {
+				t1.Errorf("%s: tools must not read the whole config with g.Cfg().%s", path, method)
+			}
+			for _, arg := range call.Args {
+				key := constValues[selectorName(arg)]
+				if lit, ok := arg.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					key, _ = strconv.Unquote(lit.Value)
+				}
+				if secretKeys[key] {
+					t1.Errorf("%s: tools must not read secret %s through g.Cfg(), use the declared secret.Scope", path, key)
+				}
+			}
+			return true
+		})
+	}
+}
@@ -1,8 +1,13 @@
 package tools
 
 import (
+	"agent/internal/consts"
+	"agent/internal/model"
 	"context"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/cloudwego/eino/components/tool"
 )
@@ -25,18 +30,41 @@ func TestTerminalOperationTool_InvokableRun(t1 *testing.T) {
 		wantErr bool
 	}{
 		{
-			name: "TestTerminalOperationTool_InvokableRun",
+			name: "TestTerminalOperationTool_InvokableRun_Dangerous",
+			fields: fields{
+				Command: "sudo rm -rf /",
+			},
+			args: args{
+				ctx:             context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_terminal"}),
+				argumentsInJSON: `{"command": "sudo rm -rf /"}`,
+				in2:             []tool.Option{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "TestTerminalOperationTool_InvokableRun_OutsideWorkspace",
 			fields: fields{
 				Command:   "ls",
-				Directory: "/Users/offves/GolandProjects/agent",
+				Directory: "../..",
+			},
+			args: args{
+				ctx:             context.WithValue(context.Background(), consts.ContextKey, &model.Context{SessionID: "test_terminal"}),
+				argumentsInJSON: `{"command": "ls", "directory": "../.."}`,
+				in2:             []tool.Option{},
+			},
+			wantErr: true,
+		},
+		{
+			name: "TestTerminalOperationTool_InvokableRun_NoSession",
+			fields: fields{
+				Command: "ls",
 			},
 			args: args{
 				ctx:             context.Background(),
-				argumentsInJSON: `{"command": "ls", "directory": "/Users/offves/GolandProjects/agent"}`,
+				argumentsInJSON: `{"command": "ls"}`,
 				in2:             []tool.Option{},
 			},
-			want:    `{"command":"ls","directory":"/Users/offves/GolandProjects/agent","success":true,"output":"consts\ninternal\ntest\n","timestamp":"2023-08-10 15:04:05"}`,
-			wantErr: false,
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
@@ -56,3 +84,105 @@ func TestTerminalOperationTool_InvokableRun(t1 *testing.T) {
 		})
 	}
 }
+
+func TestSandboxConfig_CheckPolicy(t1 *testing.T) {
+	cfg := sandboxConfig{Deny: []string{"sudo", "mkfs", "shutdown"}}
+	tests := []struct {
+		name     string
+		command  string
+		confined bool
+		wantErr  bool
+	}{
+		{name: "plain", command: "ls -la", wantErr: false},
+		{name: "deny after operator", command: "echo hi && sudo rm -rf /", wantErr: true},
+		{name: "deny quoted", command: `"su"do ls`, wantErr: true},
+		{name: "deny absolute path", command: "/usr/bin/sudo ls", wantErr: true},
+		{name: "deny variant", command: "mkfs.ext4 disk.img", wantErr: true},
+		{name: "deny in pipe", command: "cat a.txt | shutdown now", wantErr: true},
+		{name: "env assignment", command: "FOO=bar shutdown", wantErr: true},
+		{name: "substitution", command: "echo $(whoami)", wantErr: true},
+		{name: "backtick", command: "echo `whoami`", wantErr: true},
+		{name: "absolute path unconfined", command: "cat /etc/passwd", wantErr: true},
+		{name: "absolute path confined", command: "cat /etc/passwd", confined: true, wantErr: false},
+		{name: "parent dir unconfined", command: "cat ../config.yaml", wantErr: true},
+		{name: "redirect unconfined", command: "echo hi >/tmp/x", wantErr: true},
+		{name: "quoted operator", command: `echo "a; sudo"`, wantErr: false},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			commands, err := shellCommands(tt.command)
+			if err == nil {
+				err = cfg.checkPolicy(commands, tt.confined)
+			}
+			if (err != nil) != tt.wantErr {
+				t1.Errorf("checkPolicy(%q) error = %v, wantErr %v", tt.command, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSandboxConfig_ResolveMode(t1 *testing.T) {
+	bwrapErr := bwrapAvailable()
+	tests := []struct {
+		name    string
+		mode    string
+		want    string
+		wantErr bool
+	}{
+		{name: "rlimit is explicit", mode: sandboxModeRlimit, want: sandboxModeRlimit},
+		{name: "auto never falls back to rlimit", mode: sandboxModeAuto, want: sandboxModeBwrap, wantErr: bwrapErr != nil},
+		{name: "bwrap", mode: sandboxModeBwrap, want: sandboxModeBwrap, wantErr: bwrapErr != nil},
+		{name: "unknown", mode: "chroot", wantErr: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			got, err := sandboxConfig{Mode: tt.mode}.resolveMode()
+			if (err != nil) != tt.wantErr {
+				t1.Fatalf("resolveMode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t1.Errorf("resolveMode() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRunSandboxed(t1 *testing.T) {
+	cfg := sandboxConfig{
+		Mode:           sandboxModeRlimit,
+		Network:        true,
+		Timeout:        2 * time.Second,
+		CPUSeconds:     5,
+		MemoryMB:       256,
+		MaxOutputBytes: 16,
+	}
+	workspace := t1.TempDir()
+	if err := os.WriteFile(workspace+"/hello.txt", []byte("hello"), 0644); err != nil {
+		t1.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		command       string
+		wantExitCode  int
+		wantStdout    string
+		wantTruncated bool
+		wantTimedOut  bool
+	}{
+		{name: "stdout", command: "cat hello.txt", wantStdout: "hello"},
+		{name: "exit code", command: "exit 3", wantExitCode: 3},
+		{name: "truncated", command: "printf '%040d' 0", wantStdout: strings.Repeat("0", 16), wantTruncated: true},
+		{name: "timeout", command: "sleep 5", wantExitCode: -1, wantTimedOut: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			got, err := runSandboxed(context.Background(), cfg, workspace, "", tt.command)
+			if err != nil {
+				t1.Fatalf("runSandboxed() error = %v", err)
+			}
+			if got.ExitCode != tt.wantExitCode || got.Stdout != tt.wantStdout ||
+				got.Truncated != tt.wantTruncated || got.TimedOut != tt.wantTimedOut {
+				t1.Errorf("runSandboxed() got = %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,382 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"agent/internal/logging"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+const (
+	sandboxModeAuto   = "auto"
+	sandboxModeBwrap  = "bwrap"
+	sandboxModeRlimit = "rlimit"
+
+	// sandboxWorkdir bwrap 沙箱内工作目录的挂载点
+	sandboxWorkdir = "/workspace"
+	// sandboxMaxFileMB 沙箱内单个文件的最大写入大小
+	sandboxMaxFileMB = 64
+)
+
+// sandboxConfig 终端沙箱配置
+type sandboxConfig struct {
+	Mode           string        // auto / bwrap / rlimit
+	Network        bool          // 是否允许访问网络
+	Timeout        time.Duration // 墙钟超时
+	CPUSeconds     int           // CPU 时间上限
+	MemoryMB       int           // 虚拟内存上限
+	MaxOutputBytes int           // stdout/stderr 各自的最大保留字节数
+	Allow          []string      // 命令白名单，为空表示不限制
+	Deny           []string      // 命令黑名单
+}
+
+// TerminalResult 终端命令的结构化执行结果
+type TerminalResult struct {
+	ExitCode   int    `json:"exit_code"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	Truncated  bool   `json:"truncated"`
+	TimedOut   bool   `json:"timed_out"`
+	Sandbox    string `json:"sandbox"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// loadSandboxConfig 读取终端沙箱配置
+func loadSandboxConfig(ctx context.Context) sandboxConfig {
+	cfg := sandboxConfig{
+		Mode:           g.Cfg().MustGet(ctx, consts.TerminalSandbox, sandboxModeAuto).String(),
+		Network:        g.Cfg().MustGet(ctx, consts.TerminalNetwork, false).Bool(),
+		Timeout:        g.Cfg().MustGet(ctx, consts.TerminalTimeout, "30s").Duration(),
+		CPUSeconds:     g.Cfg().MustGet(ctx, consts.TerminalCPUSeconds, 10).Int(),
+		MemoryMB:       g.Cfg().MustGet(ctx, consts.TerminalMemoryMB, 512).Int(),
+		MaxOutputBytes: g.Cfg().MustGet(ctx, consts.TerminalMaxOutputBytes, 64*1024).Int(),
+		Allow:          g.Cfg().MustGet(ctx, consts.TerminalAllowCommands).Strings(),
+		Deny:           g.Cfg().MustGet(ctx, consts.TerminalDenyCommands, consts.DangerousCommands).Strings(),
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return cfg
+}
+
+// resolveMode 确定实际使用的沙箱方式。auto 和 bwrap 都要求 bwrap 能创建挂载命名空间，否则拒绝执行，
+// 不会自动退回没有文件系统隔离的 rlimit；rlimit 只能显式配置
+func (c sandboxConfig) resolveMode() (string, error) {
+	switch c.Mode {
+	case sandboxModeRlimit:
+		return sandboxModeRlimit, nil
+	case sandboxModeAuto, sandboxModeBwrap, "":
+		if err := bwrapAvailable(); err != nil {
+			return "", fmt.Errorf("terminal sandbox is unavailable: %v; install bubblewrap with user namespaces enabled, "+
+				"or set tools.terminal.sandbox to rlimit to run commands without filesystem isolation", err)
+		}
+		return sandboxModeBwrap, nil
+	default:
+		return "", fmt.Errorf("unsupported terminal sandbox mode: %s", c.Mode)
+	}
+}
+
+// bwrapAvailable 检查 bwrap 是否已安装并能创建命名空间，容器中常因未开启 user namespace 而失败，结果在首次检查后缓存
+var bwrapAvailable = sync.OnceValue(func() error {
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("bwrap requires linux")
+	}
+	path, err := exec.LookPath("bwrap")
+	if err != nil {
+		return fmt.Errorf("bwrap is not installed")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--unshare-all", "--ro-bind", "/", "/", "--tmpfs", "/tmp", "--", "true").CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("bwrap cannot create a mount namespace: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+})
+
+// shellCommands 按 shell 规则分词，并按控制符（; & | 换行 括号）拆分为多条简单命令
+func shellCommands(command string) ([][]string, error) {
+	var (
+		commands [][]string
+		current  []string
+		token    strings.Builder
+		inToken  bool
+		quote    rune
+		escaped  bool
+	)
+	flushToken := func() {
+		if inToken {
+			current = append(current, token.String())
+			token.Reset()
+			inToken = false
+		}
+	}
+	flushCommand := func() {
+		flushToken()
+		if len(current) > 0 {
+			commands = append(commands, current)
+			current = nil
+		}
+	}
+
+	runes := []rune(command)
+	for i, c := range runes {
+		switch {
+		case escaped:
+			token.WriteRune(c)
+			inToken = true
+			escaped = false
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				token.WriteRune(c)
+			}
+		case c == '\\':
+			escaped = true
+		case c == '`':
+			return nil, fmt.Errorf("command substitution is not allowed")
+		case c == '$' && i+1 < len(runes) && (runes[i+1] == '(' || runes[i+1] == '{'):
+			return nil, fmt.Errorf("command substitution and parameter expansion are not allowed")
+		case quote == '"':
+			if c == '"' {
+				quote = 0
+			} else {
+				token.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inToken = true
+		case c == ';' || c == '&' || c == '|' || c == '\n' || c == '(' || c == ')':
+			flushCommand()
+		case c == ' ' || c == '\t' || c == '\r':
+			flushToken()
+		default:
+			token.WriteRune(c)
+			inToken = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in command")
+	}
+	flushCommand()
+	return commands, nil
+}
+
+// commandName 获取简单命令的命令名，跳过前置的环境变量赋值
+func commandName(args []string) (string, int) {
+	for i, arg := range args {
+		if eq := strings.Index(arg, "="); eq > 0 && !strings.ContainsAny(arg[:eq], "/-") {
+			continue
+		}
+		return filepath.Base(arg), i
+	}
+	return "", -1
+}
+
+// checkPolicy 按命令名做黑白名单检查；confined 为 false（无文件系统隔离）时额外禁止访问工作目录之外的路径。
+// 检查只针对命令文本，看不到通配符、解释器（python -c、awk）和 env、xargs 等包装命令在运行时的行为，
+// 只用于尽早拒绝明显的误操作，不是安全边界，隔离依赖 bwrap 沙箱
+func (c sandboxConfig) checkPolicy(commands [][]string, confined bool) error {
+	if len(commands) == 0 {
+		return fmt.Errorf("command cannot be empty")
+	}
+	for _, args := range commands {
+		name, idx := commandName(args)
+		if name == "" {
+			continue
+		}
+		lowerName := strings.ToLower(name)
+		for _, deny := range c.Deny {
+			deny = strings.ToLower(deny)
+			if lowerName == deny || strings.HasPrefix(lowerName, deny+".") {
+				return fmt.Errorf("dangerous command detected and blocked: %s", name)
+			}
+		}
+		if len(c.Allow) > 0 {
+			allowed := false
+			for _, allow := range c.Allow {
+				if strings.EqualFold(lowerName, allow) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return fmt.Errorf("command is not in the allowlist: %s", name)
+			}
+		}
+		if confined {
+			continue
+		}
+		for _, arg := range args[idx+1:] {
+			if escapesWorkspace(arg) {
+				return fmt.Errorf("paths outside the workspace are not allowed: %s", arg)
+			}
+		}
+	}
+	return nil
+}
+
+// escapesWorkspace 判断参数是否引用了工作目录之外的路径
+func escapesWorkspace(arg string) bool {
+	value := arg
+	if eq := strings.Index(arg, "="); eq >= 0 {
+		value = arg[eq+1:]
+	}
+	// 去掉重定向符，如 >/etc/passwd、2>/dev/null
+	redirect := strings.TrimLeft(arg, "0123456789<>")
+	for _, v := range []string{arg, value, redirect} {
+		if strings.HasPrefix(v, "/") || strings.HasPrefix(v, "~") {
+			return true
+		}
+		for _, part := range strings.Split(filepath.ToSlash(v), "/") {
+			if part == ".." {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// limitedBuffer 超过上限后丢弃数据并标记截断
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remain := b.limit - b.buf.Len(); remain < len(p) {
+		if remain > 0 {
+			b.buf.Write(p[:remain])
+		}
+		b.truncated = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+// runSandboxed 在会话工作目录中受限执行命令
+func runSandboxed(ctx context.Context, cfg sandboxConfig, workspace, directory, command string) (*TerminalResult, error) {
+	if runtime.GOOS == "windows" {
+		return nil, fmt.Errorf("sandboxed terminal is not supported on windows")
+	}
+	mode, err := cfg.resolveMode()
+	if err != nil {
+		return nil, err
+	}
+
+	commands, err := shellCommands(command)
+	if err != nil {
+		return nil, err
+	}
+	if err = cfg.checkPolicy(commands, mode == sandboxModeBwrap); err != nil {
+		return nil, err
+	}
+	if mode == sandboxModeRlimit {
+		g.Log(consts.LoggerTools).Warning(ctx, "terminal command runs in rlimit mode without filesystem isolation, "+
+			"it can read and write any path the server can access")
+	}
+	if directory != "" && escapesWorkspace(directory) {
+		return nil, fmt.Errorf("directory must be relative to the workspace: %s", directory)
+	}
+	workdir := filepath.Join(workspace, directory)
+	if info, err := os.Stat(workdir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("directory does not exist in workspace: %s", directory)
+	}
+
+	// 通过 ulimit 限制 CPU 时间、虚拟内存和单个文件大小
+	script := fmt.Sprintf("ulimit -t %d 2>/dev/null; ulimit -v %d 2>/dev/null; ulimit -f %d 2>/dev/null; %s",
+		cfg.CPUSeconds, cfg.MemoryMB*1024, sandboxMaxFileMB*1024*2, command)
+
+	cmdCtx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch mode {
+	case sandboxModeBwrap:
+		args := []string{
+			"--die-with-parent", "--new-session", "--unshare-all",
+			"--ro-bind", "/usr", "/usr",
+			"--ro-bind-try", "/bin", "/bin",
+			"--ro-bind-try", "/sbin", "/sbin",
+			"--ro-bind-try", "/lib", "/lib",
+			"--ro-bind-try", "/lib64", "/lib64",
+			"--ro-bind-try", "/etc/alternatives", "/etc/alternatives",
+			"--proc", "/proc",
+			"--dev", "/dev",
+			"--tmpfs", "/tmp",
+			"--bind", workspace, sandboxWorkdir,
+			"--chdir", filepath.Join(sandboxWorkdir, directory),
+		}
+		if cfg.Network {
+			args = append(args, "--share-net", "--ro-bind-try", "/etc/resolv.conf", "/etc/resolv.conf")
+		}
+		args = append(args, "--", "sh", "-c", script)
+		cmd = exec.CommandContext(cmdCtx, "bwrap", args...)
+		cmd.Env = sandboxEnv(sandboxWorkdir)
+	default:
+		attr, err := sandboxSysProcAttr(!cfg.Network)
+		if err != nil {
+			return nil, err
+		}
+		cmd = exec.CommandContext(cmdCtx, "sh", "-c", script)
+		cmd.Dir = workdir
+		cmd.Env = sandboxEnv(workspace)
+		cmd.SysProcAttr = attr
+		cmd.Cancel = func() error {
+			return killProcessGroup(cmd)
+		}
+	}
+	cmd.WaitDelay = 2 * time.Second
+
+	stdout := &limitedBuffer{limit: cfg.MaxOutputBytes}
+	stderr := &limitedBuffer{limit: cfg.MaxOutputBytes}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	g.Log(consts.LoggerTools).Infof(ctx, "Executing command in %s sandbox: %s in directory: %s", mode, logging.Content(command), workdir)
+	start := time.Now()
+	err = cmd.Run()
+
+	result := &TerminalResult{
+		Stdout:     stdout.buf.String(),
+		Stderr:     stderr.buf.String(),
+		Truncated:  stdout.truncated || stderr.truncated,
+		TimedOut:   errors.Is(cmdCtx.Err(), context.DeadlineExceeded),
+		Sandbox:    mode,
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case result.TimedOut:
+		result.ExitCode = -1
+	default:
+		return nil, fmt.Errorf("failed to start sandboxed command: %v", err)
+	}
+	return result, nil
+}
+
+// sandboxEnv 沙箱内使用最小化的环境变量，避免泄露服务端配置
+func sandboxEnv(home string) []string {
+	return []string{
+		"PATH=/usr/local/bin:/usr/bin:/bin",
+		"HOME=" + home,
+		"LANG=C.UTF-8",
+		"TMPDIR=/tmp",
+	}
+}
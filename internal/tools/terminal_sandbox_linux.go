@@ -0,0 +1,26 @@
+package tools
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// sandboxSysProcAttr 子进程使用独立进程组；禁用网络时放入新的 user + network namespace
+func sandboxSysProcAttr(isolateNetwork bool) (*syscall.SysProcAttr, error) {
+	attr := &syscall.SysProcAttr{Setpgid: true}
+	if isolateNetwork {
+		attr.Cloneflags = syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET
+		attr.UidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}}
+		attr.GidMappings = []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}}
+	}
+	return attr, nil
+}
+
+// killProcessGroup 超时后结束整个进程组
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,25 @@
+//go:build !linux
+
+package tools
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// sandboxSysProcAttr 非 Linux 平台无法隔离网络，要求显式开启网络访问
+func sandboxSysProcAttr(isolateNetwork bool) (*syscall.SysProcAttr, error) {
+	if isolateNetwork {
+		return nil, fmt.Errorf("network isolation is only supported on linux, set tools.terminal.network to true to run without it")
+	}
+	return nil, nil
+}
+
+// killProcessGroup 结束子进程
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
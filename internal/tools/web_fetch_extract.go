@@ -0,0 +1,405 @@
+package tools
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// boilerplateAtoms 不包含正文的标签，连同子节点一起丢弃
+var boilerplateAtoms = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Iframe: true, atom.Svg: true, atom.Canvas: true,
+	atom.Button: true, atom.Select: true, atom.Input: true, atom.Dialog: true,
+}
+
+// boilerplatePattern class / id / role 命中时视为导航、广告等非正文区域
+var boilerplatePattern = regexp.MustCompile(`(?i)(^|[\s_-])(ad|ads|advert|advertisement|banner|breadcrumbs?|comments?|cookie|footer|header|menu|modal|nav|navbar|newsletter|outbrain|popup|promo|related|share|sharing|sidebar|social|sponsor(ed)?|subscribe|taboola|toolbar)($|[\s_-])`)
+
+// readableDocument 提取出的可读内容
+type readableDocument struct {
+	Title    string
+	Markdown string
+}
+
+// extractReadable 从 HTML 中提取正文并转换为 Markdown，链接和图片地址基于 base 转为绝对地址
+func extractReadable(rawHTML string, base *url.URL) (*readableDocument, error) {
+	root, err := html.Parse(strings.NewReader(rawHTML))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html: %v", err)
+	}
+
+	doc := &readableDocument{}
+	if title := findFirst(root, func(n *html.Node) bool { return n.DataAtom == atom.Title }); title != nil {
+		doc.Title = collapseSpaces(textContent(title))
+	}
+
+	body := findFirst(root, func(n *html.Node) bool { return n.DataAtom == atom.Body })
+	if body == nil {
+		body = root
+	}
+	removeBoilerplate(body)
+
+	// 优先使用语义化的正文容器，其次选择文本最多的区块
+	content := findFirst(body, func(n *html.Node) bool {
+		return n.DataAtom == atom.Article || n.DataAtom == atom.Main || attr(n, "role") == "main"
+	})
+	if content == nil || len(strings.TrimSpace(textContent(content))) < 200 {
+		content = densestBlock(body)
+	}
+
+	w := &markdownWriter{base: base}
+	w.render(content)
+	doc.Markdown = w.String()
+	if doc.Title == "" {
+		if h1 := findFirst(content, func(n *html.Node) bool { return n.DataAtom == atom.H1 }); h1 != nil {
+			doc.Title = collapseSpaces(textContent(h1))
+		}
+	}
+	return doc, nil
+}
+
+// removeBoilerplate 删除导航、广告、脚本等非正文节点
+func removeBoilerplate(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && isBoilerplate(c)) {
+			n.RemoveChild(c)
+		} else {
+			removeBoilerplate(c)
+		}
+		c = next
+	}
+}
+
+func isBoilerplate(n *html.Node) bool {
+	if boilerplateAtoms[n.DataAtom] {
+		return true
+	}
+	if attr(n, "hidden") != "" || strings.Contains(strings.ReplaceAll(attr(n, "style"), " ", ""), "display:none") || attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	switch attr(n, "role") {
+	case "navigation", "banner", "contentinfo", "complementary", "search", "dialog":
+		return true
+	}
+	// 正文容器本身不按 class 过滤，避免误删 <article class="post-header-..."> 之类
+	if n.DataAtom == atom.Article || n.DataAtom == atom.Main || n.DataAtom == atom.Body {
+		return false
+	}
+	return boilerplatePattern.MatchString(attr(n, "class")) || boilerplatePattern.MatchString(attr(n, "id"))
+}
+
+// densestBlock 选择正文最多的区块：段落文本多、链接文本占比低
+func densestBlock(body *html.Node) *html.Node {
+	best, bestScore := body, 0.0
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			if c.DataAtom == atom.Div || c.DataAtom == atom.Section || c.DataAtom == atom.Td {
+				text := len([]rune(strings.TrimSpace(textContent(c))))
+				if text > 0 {
+					linkText := 0
+					forEach(c, func(a *html.Node) {
+						if a.DataAtom == atom.A {
+							linkText += len([]rune(strings.TrimSpace(textContent(a))))
+						}
+					})
+					paragraphs := 0
+					forEach(c, func(p *html.Node) {
+						if p.DataAtom == atom.P {
+							paragraphs++
+						}
+					})
+					score := float64(text) * (1 - float64(linkText)/float64(text)) * (1 + float64(paragraphs)/10)
+					if score > bestScore {
+						best, bestScore = c, score
+					}
+				}
+			}
+			walk(c)
+		}
+	}
+	walk(body)
+	// 最佳区块文本过少时退回整个 body
+	if len(strings.TrimSpace(textContent(best))) < len(strings.TrimSpace(textContent(body)))/3 {
+		return body
+	}
+	return best
+}
+
+// markdownWriter 将 HTML 节点渲染为 Markdown
+type markdownWriter struct {
+	base     *url.URL
+	buf      strings.Builder
+	listType []atom.Atom
+	listNum  []int
+	inPre    bool
+}
+
+func (w *markdownWriter) String() string {
+	lines := strings.Split(w.buf.String(), "\n")
+	var out []string
+	blank := 0
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t")
+		if strings.TrimSpace(line) == "" {
+			blank++
+			if blank > 1 {
+				continue
+			}
+			line = ""
+		} else {
+			blank = 0
+		}
+		out = append(out, line)
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// block 开始一个新的块级元素
+func (w *markdownWriter) block() {
+	w.buf.WriteString("\n\n")
+}
+
+func (w *markdownWriter) render(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		if w.inPre {
+			w.buf.WriteString(n.Data)
+			return
+		}
+		text := collapseSpaces(n.Data)
+		if text == "" {
+			if strings.ContainsAny(n.Data, " \n\t") {
+				w.buf.WriteString(" ")
+			}
+			return
+		}
+		if unicode.IsSpace(rune(n.Data[0])) {
+			text = " " + text
+		}
+		if unicode.IsSpace(rune(n.Data[len(n.Data)-1])) {
+			text += " "
+		}
+		w.buf.WriteString(text)
+		return
+	case html.ElementNode:
+	default:
+		w.children(n)
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		w.block()
+		level := int(n.Data[1] - '0')
+		w.buf.WriteString(strings.Repeat("#", level) + " " + collapseSpaces(textContent(n)))
+		w.block()
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Figure, atom.Dl:
+		w.block()
+		w.children(n)
+		w.block()
+	case atom.Br:
+		w.buf.WriteString("\n")
+	case atom.Hr:
+		w.block()
+		w.buf.WriteString("---")
+		w.block()
+	case atom.Strong, atom.B:
+		w.wrapInline(n, "**")
+	case atom.Em, atom.I:
+		w.wrapInline(n, "_")
+	case atom.Code:
+		if w.inPre {
+			w.children(n)
+		} else {
+			w.buf.WriteString("`" + textContent(n) + "`")
+		}
+	case atom.Pre:
+		w.block()
+		w.buf.WriteString("```\n")
+		w.inPre = true
+		w.children(n)
+		w.inPre = false
+		w.buf.WriteString("\n```")
+		w.block()
+	case atom.Blockquote:
+		inner := &markdownWriter{base: w.base}
+		inner.children(n)
+		w.block()
+		for _, line := range strings.Split(inner.String(), "\n") {
+			w.buf.WriteString("> " + line + "\n")
+		}
+		w.block()
+	case atom.Ul, atom.Ol:
+		// 嵌套列表紧跟在父列表项之后，不另起段落
+		nested := len(w.listType) > 0
+		w.listType = append(w.listType, n.DataAtom)
+		w.listNum = append(w.listNum, 0)
+		if !nested {
+			w.block()
+		}
+		w.children(n)
+		if !nested {
+			w.block()
+		}
+		w.listType = w.listType[:len(w.listType)-1]
+		w.listNum = w.listNum[:len(w.listNum)-1]
+	case atom.Li:
+		depth := len(w.listType)
+		marker := "- "
+		if depth > 0 && w.listType[depth-1] == atom.Ol {
+			w.listNum[depth-1]++
+			marker = fmt.Sprintf("%d. ", w.listNum[depth-1])
+		}
+		w.buf.WriteString("\n" + strings.Repeat("  ", max(depth-1, 0)) + marker)
+		w.children(n)
+	case atom.Dt:
+		w.buf.WriteString("\n**" + collapseSpaces(textContent(n)) + "**\n")
+	case atom.Dd:
+		w.buf.WriteString(": ")
+		w.children(n)
+		w.buf.WriteString("\n")
+	case atom.A:
+		text := collapseSpaces(textContent(n))
+		href := w.absURL(attr(n, "href"))
+		if text == "" {
+			return
+		}
+		if href == "" || strings.HasPrefix(href, "javascript:") || strings.HasPrefix(attr(n, "href"), "#") {
+			w.buf.WriteString(text)
+			return
+		}
+		w.buf.WriteString("[" + text + "](" + href + ")")
+	case atom.Img:
+		src := w.absURL(attr(n, "src"))
+		if src == "" || strings.HasPrefix(src, "data:") {
+			return
+		}
+		w.buf.WriteString("![" + collapseSpaces(attr(n, "alt")) + "](" + src + ")")
+	case atom.Table:
+		w.block()
+		w.renderTable(n)
+		w.block()
+	default:
+		w.children(n)
+	}
+}
+
+func (w *markdownWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.render(c)
+	}
+}
+
+func (w *markdownWriter) wrapInline(n *html.Node, mark string) {
+	text := collapseSpaces(textContent(n))
+	if text == "" {
+		return
+	}
+	w.buf.WriteString(mark + text + mark)
+}
+
+// renderTable 将表格渲染为 Markdown 表格，第一行作为表头
+func (w *markdownWriter) renderTable(table *html.Node) {
+	var rows [][]string
+	forEach(table, func(tr *html.Node) {
+		if tr.DataAtom != atom.Tr {
+			return
+		}
+		var cells []string
+		for c := tr.FirstChild; c != nil; c = c.NextSibling {
+			if c.DataAtom == atom.Td || c.DataAtom == atom.Th {
+				cells = append(cells, strings.ReplaceAll(collapseSpaces(textContent(c)), "|", `\|`))
+			}
+		}
+		if len(cells) > 0 {
+			rows = append(rows, cells)
+		}
+	})
+	if len(rows) == 0 {
+		return
+	}
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		w.buf.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			w.buf.WriteString("|" + strings.Repeat(" --- |", columns) + "\n")
+		}
+	}
+}
+
+func (w *markdownWriter) absURL(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || w.base == nil {
+		return ref
+	}
+	u, err := w.base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// findFirst 深度优先查找第一个满足条件的节点
+func findFirst(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findFirst(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// forEach 遍历所有后代元素节点
+func forEach(n *html.Node, fn func(*html.Node)) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode {
+			fn(c)
+		}
+		forEach(c, fn)
+	}
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+	}
+	return sb.String()
+}
+
+func collapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,451 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chromedp/chromedp"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gcache"
+)
+
+const (
+	webFetchRenderAuto    = "auto"
+	webFetchRenderHTTP    = "http"
+	webFetchRenderBrowser = "browser"
+
+	defaultWebFetchTimeout   = 20 * time.Second
+	defaultWebFetchMaxBytes  = 5 * 1024 * 1024
+	defaultWebFetchChunkSize = 4000
+	defaultWebFetchCacheTTL  = 10 * time.Minute
+	defaultWebFetchMaxChunks = 3
+	// webFetchMinReadable auto 模式下 HTTP 提取的正文少于该字数且页面包含脚本时改用浏览器渲染
+	webFetchMinReadable = 500
+)
+
+type WebFetchTool struct {
+	URL       string `json:"url"`
+	Render    string `json:"render,omitempty"`
+	Query     string `json:"query,omitempty"`
+	Chunk     int    `json:"chunk,omitempty"`
+	MaxChunks int    `json:"max_chunks,omitempty"`
+}
+
+// WebChunk 页面内容的一个分块
+type WebChunk struct {
+	Index   int    `json:"index"`
+	Content string `json:"content"`
+}
+
+// WebFetchResult web_fetch_tool 的返回结果
+type WebFetchResult struct {
+	URL         string      `json:"url"`
+	Title       string      `json:"title"`
+	Renderer    string      `json:"renderer"` // http / browser
+	Cached      bool        `json:"cached"`
+	TotalChunks int         `json:"total_chunks"`
+	Chunks      []*WebChunk `json:"chunks"`
+	Note        string      `json:"note,omitempty"`
+}
+
+// webPage 抓取并提取后的页面，按 URL 缓存
+type webPage struct {
+	URL      string
+	Title    string
+	Markdown string
+	Renderer string
+}
+
+// webFetchCache 页面缓存，缓存时间按域名配置
+var webFetchCache = gcache.New()
+
+func NewWebFetchTool() *WebFetchTool {
+	return &WebFetchTool{}
+}
+
+func (t *WebFetchTool) Info(_ context.Context) (*schema.ToolInfo, error) {
+	return &schema.ToolInfo{
+		Name: "web_fetch_tool",
+		Desc: `Fetch a web page and return its main readable content as Markdown, without navigation and ads.
+Long pages are split into chunks: pass query to get the chunks most relevant to it, or chunk to read a specific chunk.`,
+		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
+			"url": {
+				Type:     schema.String,
+				Desc:     "The http or https URL of the page to read",
+				Required: true,
+			},
+			"render": {
+				Type:     schema.String,
+				Desc:     "How to load the page: http for static pages, browser for pages rendered by JavaScript, auto (default) to decide automatically",
+				Enum:     []string{webFetchRenderAuto, webFetchRenderHTTP, webFetchRenderBrowser},
+				Required: false,
+			},
+			"query": {
+				Type:     schema.String,
+				Desc:     "Optional question or keywords, only the most relevant chunks are returned",
+				Required: false,
+			},
+			"chunk": {
+				Type:     schema.Integer,
+				Desc:     "Chunk number to return when no query is given, starting at 1 (default 1)",
+				Required: false,
+			},
+			"max_chunks": {
+				Type:     schema.Integer,
+				Desc:     fmt.Sprintf("Maximum number of relevant chunks to return for a query (default %d)", defaultWebFetchMaxChunks),
+				Required: false,
+			},
+		}),
+	}, nil
+}
+
+func (t *WebFetchTool) InvokableRun(ctx context.Context, argumentsInJSON string, _ ...tool.Option) (string, error) {
+	// 1. 反序列化参数
+	var req WebFetchTool
+	err := gjson.DecodeTo([]byte(argumentsInJSON), &req)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse arguments: %v", err)
+	}
+
+	// 2. 参数验证和默认值设置
+	if req.URL == "" {
+		return "", fmt.Errorf("url parameter is required")
+	}
+	pageURL, err := url.Parse(req.URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL format: %v", err)
+	}
+	if pageURL.Scheme != "http" && pageURL.Scheme != "https" {
+		return "", fmt.Errorf("only HTTP and HTTPS URLs are supported")
+	}
+	pageURL.Fragment = ""
+	if req.Render == "" {
+		req.Render = webFetchRenderAuto
+	}
+	if req.Render != webFetchRenderAuto && req.Render != webFetchRenderHTTP && req.Render != webFetchRenderBrowser {
+		return "", fmt.Errorf("unsupported render mode: %s", req.Render)
+	}
+	if req.Chunk <= 0 {
+		req.Chunk = 1
+	}
+	if req.MaxChunks <= 0 {
+		req.MaxChunks = defaultWebFetchMaxChunks
+	}
+
+	// 3. 读取缓存或抓取页面
+	result := &WebFetchResult{URL: pageURL.String()}
+	cacheKey := req.Render + "|" + pageURL.String()
+	ttl := webFetchCacheTTL(ctx, pageURL.Hostname())
+	var page *webPage
+	if ttl > 0 {
+		if v, _ := webFetchCache.Get(ctx, cacheKey); v != nil {
+			page, result.Cached = v.Val().(*webPage), true
+		}
+	}
+	if page == nil {
+		page, err = fetchWebPage(ctx, pageURL, req.Render)
+		if err != nil {
+			// 抓取失败不中断 Agent 运行
+			g.Log(consts.LoggerTools).Errorf(ctx, "failed to fetch %s: %v", pageURL, err)
+			return "Error fetching page: " + err.Error(), nil
+		}
+		if ttl > 0 {
+			_ = webFetchCache.Set(ctx, cacheKey, page, ttl)
+		}
+	}
+	result.URL, result.Title, result.Renderer = page.URL, page.Title, page.Renderer
+
+	// 4. 分块，按 query 选择相关分块或返回指定分块
+	chunks := chunkMarkdown(page.Markdown, g.Cfg().MustGet(ctx, consts.WebFetchChunkSize, defaultWebFetchChunkSize).Int())
+	result.TotalChunks = len(chunks)
+	switch {
+	case len(chunks) == 0:
+		result.Note = "No readable content found on the page"
+	case strings.TrimSpace(req.Query) != "":
+		for _, i := range rankChunks(chunks, req.Query, req.MaxChunks) {
+			result.Chunks = append(result.Chunks, &WebChunk{Index: i + 1, Content: chunks[i]})
+		}
+		if len(result.Chunks) == 0 {
+			result.Note = "No chunk matches the query, read the page by chunk number instead"
+		}
+	case req.Chunk > len(chunks):
+		return "", fmt.Errorf("chunk %d is out of range, the page has %d chunks", req.Chunk, len(chunks))
+	default:
+		result.Chunks = []*WebChunk{{Index: req.Chunk, Content: chunks[req.Chunk-1]}}
+		if req.Chunk < len(chunks) {
+			result.Note = fmt.Sprintf("More content available, use chunk=%d to continue reading", req.Chunk+1)
+		}
+	}
+	return gjson.EncodeString(result)
+}
+
+// webFetchCacheTTL 按域名获取缓存时间，支持为父域名配置
+func webFetchCacheTTL(ctx context.Context, host string) time.Duration {
+	domains := g.Cfg().MustGet(ctx, consts.WebFetchDomainCacheTTL).MapStrVar()
+	for domain := host; domain != ""; {
+		if v, ok := domains[domain]; ok {
+			return v.Duration()
+		}
+		dot := strings.IndexByte(domain, '.')
+		if dot < 0 {
+			break
+		}
+		domain = domain[dot+1:]
+	}
+	return g.Cfg().MustGet(ctx, consts.WebFetchCacheTTL, defaultWebFetchCacheTTL).Duration()
+}
+
+// fetchWebPage 按渲染方式抓取页面并提取正文
+func fetchWebPage(ctx context.Context, pageURL *url.URL, render string) (*webPage, error) {
+	timeout := g.Cfg().MustGet(ctx, consts.WebFetchTimeout, defaultWebFetchTimeout).Duration()
+	if timeout <= 0 {
+		timeout = defaultWebFetchTimeout
+	}
+	if render == webFetchRenderBrowser {
+		return fetchWithBrowser(ctx, pageURL, timeout)
+	}
+
+	page, rawHTML, err := fetchWithHTTP(ctx, pageURL, timeout)
+	if err != nil || render == webFetchRenderHTTP || rawHTML == "" {
+		return page, err
+	}
+	// 正文过少且页面依赖脚本时，多半是前端渲染的页面
+	if utf8.RuneCountInString(page.Markdown) < webFetchMinReadable && strings.Contains(strings.ToLower(rawHTML), "<script") {
+		rendered, err := fetchWithBrowser(ctx, pageURL, timeout)
+		if err != nil {
+			g.Log(consts.LoggerTools).Warningf(ctx, "browser rendering of %s failed, using the http result: %v", pageURL, err)
+			return page, nil
+		}
+		if utf8.RuneCountInString(rendered.Markdown) > utf8.RuneCountInString(page.Markdown) {
+			return rendered, nil
+		}
+	}
+	return page, nil
+}
+
+// fetchWithHTTP 直接请求页面，HTML 提取正文，纯文本类内容原样返回
+func fetchWithHTTP(ctx context.Context, pageURL *url.URL, timeout time.Duration) (page *webPage, rawHTML string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL.String(), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; AgentWebFetch/1.0)")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,text/plain;q=0.9,*/*;q=0.5")
+
+	res, err := safeHTTPClient(ctx, timeout).Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch page: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, "", fmt.Errorf("page returned status %d", res.StatusCode)
+	}
+
+	maxBytes := g.Cfg().MustGet(ctx, consts.WebFetchMaxBytes, defaultWebFetchMaxBytes).Int64()
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxBytes))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read page: %v", err)
+	}
+
+	page = &webPage{URL: res.Request.URL.String(), Renderer: webFetchRenderHTTP}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType == "" {
+		mediaType = http.DetectContentType(body)
+		mediaType, _, _ = mime.ParseMediaType(mediaType)
+	}
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		doc, err := extractReadable(string(body), res.Request.URL)
+		if err != nil {
+			return nil, "", err
+		}
+		page.Title, page.Markdown = doc.Title, doc.Markdown
+		return page, string(body), nil
+	case strings.HasPrefix(mediaType, "text/") || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if isBinary(body[:min(len(body), binarySniffBytes)]) {
+			return nil, "", fmt.Errorf("page content is not text")
+		}
+		page.Markdown = string(body)
+		return page, "", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported content type %s, use resource_download_tool to download files", mediaType)
+	}
+}
+
+// fetchWithBrowser 使用无头浏览器渲染页面后提取正文
+func fetchWithBrowser(ctx context.Context, pageURL *url.URL, timeout time.Duration) (*webPage, error) {
+	// 浏览器的所有请求经过过滤代理，导航前先检查一次，尽早给出明确的错误
+	if err := checkPublicHost(ctx, pageURL.Hostname()); err != nil {
+		return nil, err
+	}
+	tabCtx, release, err := browserPool(ctx).NewTab(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	tabCtx, cancel := context.WithTimeout(tabCtx, timeout)
+	defer cancel()
+
+	var rawHTML, finalURL string
+	err = chromedp.Run(tabCtx,
+		chromedp.Navigate(pageURL.String()),
+		waitPageSettled(),
+		chromedp.Location(&finalURL),
+		chromedp.OuterHTML("html", &rawHTML),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render page: %v", err)
+	}
+	base, err := url.Parse(finalURL)
+	if err != nil {
+		base = pageURL
+	}
+	// 重定向后的页面同样只能是公网的 http(s) 地址
+	if base.Scheme != "http" && base.Scheme != "https" {
+		return nil, fmt.Errorf("page redirected to unsupported scheme %s", base.Scheme)
+	}
+	if err = checkPublicHost(ctx, base.Hostname()); err != nil {
+		return nil, err
+	}
+	doc, err := extractReadable(rawHTML, base)
+	if err != nil {
+		return nil, err
+	}
+	return &webPage{URL: base.String(), Title: doc.Title, Markdown: doc.Markdown, Renderer: webFetchRenderBrowser}, nil
+}
+
+// chunkMarkdown 按段落将 Markdown 切分为不超过 size 个字符的分块，标题处优先断开
+func chunkMarkdown(markdown string, size int) []string {
+	if size <= 0 {
+		size = defaultWebFetchChunkSize
+	}
+	var (
+		chunks  []string
+		current strings.Builder
+	)
+	flush := func() {
+		if text := strings.TrimSpace(current.String()); text != "" {
+			chunks = append(chunks, text)
+		}
+		current.Reset()
+	}
+	for _, para := range strings.Split(markdown, "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		length := utf8.RuneCountInString(current.String())
+		paraLength := utf8.RuneCountInString(para)
+		isHeading := strings.HasPrefix(para, "#")
+		if length > 0 && (length+paraLength+2 > size || (isHeading && length > size/4)) {
+			flush()
+		}
+		// 单个段落超长时按字符切分
+		for paraLength > size {
+			runes := []rune(para)
+			current.WriteString(string(runes[:size]))
+			flush()
+			para = string(runes[size:])
+			paraLength -= size
+		}
+		if current.Len() > 0 {
+			current.WriteString("\n\n")
+		}
+		current.WriteString(para)
+	}
+	flush()
+	return chunks
+}
+
+// queryTerms 将查询拆分为检索词，中文等连续文字按二元组拆分
+func queryTerms(query string) []string {
+	seen := make(map[string]bool)
+	var terms []string
+	add := func(term string) {
+		if term != "" && !seen[term] {
+			seen[term] = true
+			terms = append(terms, term)
+		}
+	}
+	for _, field := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return unicode.IsSpace(r) || unicode.IsPunct(r)
+	}) {
+		runes := []rune(field)
+		if len(runes) > 1 && unicode.Is(unicode.Han, runes[0]) {
+			for i := 0; i+1 < len(runes); i++ {
+				add(string(runes[i : i+2]))
+			}
+			continue
+		}
+		if len(runes) > 1 || unicode.Is(unicode.Han, runes[0]) {
+			add(field)
+		}
+	}
+	return terms
+}
+
+// rankChunks 按与查询的相关度选出最多 limit 个分块，返回按原始顺序排列的下标
+func rankChunks(chunks []string, query string, limit int) []int {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	lower := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		lower[i] = strings.ToLower(chunk)
+	}
+	// 出现在越少分块中的词权重越高
+	idf := make(map[string]float64, len(terms))
+	for _, term := range terms {
+		df := 0
+		for _, chunk := range lower {
+			if strings.Contains(chunk, term) {
+				df++
+			}
+		}
+		idf[term] = math.Log(1 + float64(len(chunks))/float64(1+df))
+	}
+
+	type scored struct {
+		index int
+		score float64
+	}
+	var ranked []scored
+	for i, chunk := range lower {
+		score := 0.0
+		for _, term := range terms {
+			tf := float64(strings.Count(chunk, term))
+			score += tf / (tf + 1.2) * idf[term]
+		}
+		if score > 0 {
+			ranked = append(ranked, scored{index: i, score: score})
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].score > ranked[j].score })
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	indexes := make([]int, 0, len(ranked))
+	for _, r := range ranked {
+		indexes = append(indexes, r.index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
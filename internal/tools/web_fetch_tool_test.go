@@ -0,0 +1,275 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+)
+
+const webFetchArticle = `<!DOCTYPE html>
+<html><head><title>Go Concurrency Patterns</title></head>
+<body>
+<nav class="site-nav"><a href="/">Home</a><a href="/blog">Blog</a></nav>
+<div class="ad-banner">Buy now!</div>
+<article>
+<h1>Go Concurrency Patterns</h1>
+<p>Go provides goroutines and channels as first-class primitives, which makes it easy to structure concurrent programs in a readable way.</p>
+<h2>Pipelines</h2>
+<p>A pipeline is a series of stages connected by channels, where each stage is a group of goroutines running the same function.</p>
+<ul><li>Receive values from upstream</li><li>Send values <a href="/docs/send">downstream</a></li></ul>
+<h2>Cancellation</h2>
+<p>Use a context to tell every stage to stop when the consumer no longer needs values, so that no goroutine leaks.</p>
+</article>
+<footer>Copyright 2024</footer>
+</body></html>`
+
+func TestWebFetchTool_InvokableRun(t1 *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		switch r.URL.Path {
+		case "/article":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, webFetchArticle)
+		case "/notes.txt":
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprint(w, "plain notes")
+		case "/archive.zip":
+			w.Header().Set("Content-Type", "application/zip")
+			w.Write([]byte("PK\x03\x04"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	// 默认拦截内网地址
+	got, err := NewWebFetchTool().InvokableRun(context.Background(), `{"url":"`+server.URL+`/article","render":"http"}`)
+	if err != nil || !strings.Contains(got, ErrBlockedAddress.Error()) {
+		t1.Fatalf("InvokableRun() = %v, %v, want blocked address", got, err)
+	}
+	setTestConfig(t1, consts.HTTPAllowPrivateNetworks, true)
+
+	tests := []struct {
+		name         string
+		args         string
+		wantContains []string
+		wantMissing  []string
+		wantText     string
+		wantErr      bool
+	}{
+		{
+			name:         "article",
+			args:         `{"url":"` + server.URL + `/article","render":"http"}`,
+			wantContains: []string{"# Go Concurrency Patterns", "## Pipelines", "- Receive values from upstream", "[downstream](" + server.URL + "/docs/send)"},
+			wantMissing:  []string{"Buy now", "Home", "Copyright"},
+		},
+		{
+			name:         "query",
+			args:         `{"url":"` + server.URL + `/article","render":"http","query":"cancellation context"}`,
+			wantContains: []string{"Cancellation"},
+		},
+		{
+			name:         "plain text",
+			args:         `{"url":"` + server.URL + `/notes.txt","render":"http"}`,
+			wantContains: []string{"plain notes"},
+		},
+		{
+			name:     "unsupported content type",
+			args:     `{"url":"` + server.URL + `/archive.zip","render":"http"}`,
+			wantText: "Error fetching page: unsupported content type application/zip",
+		},
+		{
+			name:     "not found",
+			args:     `{"url":"` + server.URL + `/missing","render":"http"}`,
+			wantText: "Error fetching page: page returned status 404",
+		},
+		{
+			name:    "invalid scheme",
+			args:    `{"url":"file:///etc/passwd"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid render",
+			args:    `{"url":"` + server.URL + `/article","render":"pdf"}`,
+			wantErr: true,
+		},
+		{
+			name:    "chunk out of range",
+			args:    `{"url":"` + server.URL + `/article","render":"http","chunk":5}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			t := NewWebFetchTool()
+			got, err := t.InvokableRun(context.Background(), tt.args)
+			if (err != nil) != tt.wantErr {
+				t1.Errorf("InvokableRun() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantText != "" {
+				if !strings.HasPrefix(got, tt.wantText) {
+					t1.Errorf("InvokableRun() got = %v, want prefix %v", got, tt.wantText)
+				}
+				return
+			}
+			var result WebFetchResult
+			if err := gjson.DecodeTo(got, &result); err != nil {
+				t1.Fatalf("failed to decode result: %v", err)
+			}
+			var content strings.Builder
+			for _, c := range result.Chunks {
+				content.WriteString(c.Content)
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(content.String(), want) {
+					t1.Errorf("InvokableRun() content = %v, want contains %v", content.String(), want)
+				}
+			}
+			for _, missing := range tt.wantMissing {
+				if strings.Contains(content.String(), missing) {
+					t1.Errorf("InvokableRun() content = %v, should not contain %v", content.String(), missing)
+				}
+			}
+		})
+	}
+
+	// 同一 URL 再次抓取命中缓存
+	before := hits.Load()
+	got, err = NewWebFetchTool().InvokableRun(context.Background(), `{"url":"`+server.URL+`/article","render":"http"}`)
+	if err != nil {
+		t1.Fatalf("InvokableRun() error = %v", err)
+	}
+	if hits.Load() != before || !gjson.New(got).Get("cached").Bool() {
+		t1.Errorf("InvokableRun() expected a cached result, got %v", got)
+	}
+}
+
+func TestExtractReadable(t1 *testing.T) {
+	base, _ := url.Parse("https://example.com/post/1")
+	tests := []struct {
+		name         string
+		html         string
+		wantTitle    string
+		wantContains []string
+		wantMissing  []string
+	}{
+		{
+			name:         "densest block without article",
+			html:         `<html><head><title>T</title></head><body><div id="sidebar"><p>Related links</p></div><div class="content"><p>` + strings.Repeat("Readable sentence. ", 20) + `</p><img src="/a.png" alt="chart"></div></body></html>`,
+			wantTitle:    "T",
+			wantContains: []string{"Readable sentence.", "![chart](https://example.com/a.png)"},
+			wantMissing:  []string{"Related links"},
+		},
+		{
+			name:         "nested list and table",
+			html:         `<html><body><main><ul><li>a<ul><li>b</li></ul></li></ul><table><tr><th>k</th><th>v</th></tr><tr><td>1</td><td>2</td></tr></table><p>` + strings.Repeat("x ", 120) + `</p></main></body></html>`,
+			wantContains: []string{"- a\n  - b", "| k | v |", "| 1 | 2 |"},
+		},
+		{
+			name:        "hidden and script",
+			html:        `<html><body><article><p style="display:none">secret</p><script>var x = 1;</script><p>` + strings.Repeat("visible ", 40) + `</p></article></body></html>`,
+			wantMissing: []string{"secret", "var x"},
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			doc, err := extractReadable(tt.html, base)
+			if err != nil {
+				t1.Fatalf("extractReadable() error = %v", err)
+			}
+			if tt.wantTitle != "" && doc.Title != tt.wantTitle {
+				t1.Errorf("extractReadable() title = %v, want %v", doc.Title, tt.wantTitle)
+			}
+			for _, want := range tt.wantContains {
+				if !strings.Contains(doc.Markdown, want) {
+					t1.Errorf("extractReadable() markdown = %q, want contains %q", doc.Markdown, want)
+				}
+			}
+			for _, missing := range tt.wantMissing {
+				if strings.Contains(doc.Markdown, missing) {
+					t1.Errorf("extractReadable() markdown = %q, should not contain %q", doc.Markdown, missing)
+				}
+			}
+		})
+	}
+}
+
+func TestChunkMarkdown(t1 *testing.T) {
+	long := strings.Repeat("a", 250)
+	tests := []struct {
+		name     string
+		markdown string
+		size     int
+		want     int
+	}{
+		{name: "empty", markdown: "", size: 100, want: 0},
+		{name: "single", markdown: "# Title\n\nbody", size: 100, want: 1},
+		{name: "split by size", markdown: strings.Repeat("paragraph of text\n\n", 20), size: 100, want: 4},
+		{name: "split at heading", markdown: "# A\n\n" + strings.Repeat("x", 40) + "\n\n# B\n\ny", size: 100, want: 2},
+		{name: "long paragraph", markdown: long, size: 100, want: 3},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			chunks := chunkMarkdown(tt.markdown, tt.size)
+			if len(chunks) != tt.want {
+				t1.Errorf("chunkMarkdown() got %d chunks %q, want %d", len(chunks), chunks, tt.want)
+			}
+			for _, c := range chunks {
+				if len([]rune(c)) > tt.size {
+					t1.Errorf("chunkMarkdown() chunk size %d exceeds %d", len([]rune(c)), tt.size)
+				}
+			}
+		})
+	}
+}
+
+func TestRankChunks(t1 *testing.T) {
+	chunks := []string{
+		"Installing Go on Linux and macOS",
+		"Goroutines and channels make concurrency simple",
+		"上下文取消可以避免协程泄漏",
+		"Channels can be buffered or unbuffered",
+	}
+	tests := []struct {
+		name  string
+		query string
+		limit int
+		want  []int
+	}{
+		{name: "keyword", query: "channels", limit: 3, want: []int{1, 3}},
+		{name: "limit keeps best", query: "goroutines channels", limit: 1, want: []int{1}},
+		{name: "chinese", query: "协程泄漏", limit: 3, want: []int{2}},
+		{name: "no match", query: "python", limit: 3, want: []int{}},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			got := rankChunks(chunks, tt.query, tt.limit)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t1.Errorf("rankChunks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebFetchCacheTTL(t1 *testing.T) {
+	ctx := context.Background()
+	if got := webFetchCacheTTL(ctx, "example.com"); got != defaultWebFetchCacheTTL {
+		t1.Errorf("webFetchCacheTTL() = %v, want %v", got, defaultWebFetchCacheTTL)
+	}
+	if got := webFetchCacheTTL(ctx, "news.ycombinator.com"); got.Minutes() != 1 {
+		t1.Errorf("webFetchCacheTTL() = %v, want 1m", got)
+	}
+}
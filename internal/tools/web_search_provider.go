@@ -0,0 +1,546 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"agent/internal/secret"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/v2/encoding/gjson"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+const (
+	searchProviderSearchAPI = "searchapi"
+	searchProviderSearxNG   = "searxng"
+	searchProviderBrave     = "brave"
+	searchProviderBing      = "bing"
+	searchProviderFake      = "fake"
+
+	defaultSearchCount    = 5
+	maxSearchCount        = 20
+	defaultSearchTimeout  = 10 * time.Second
+	defaultSearchRetries  = 2
+	defaultSearchBackoff  = 500 * time.Millisecond
+	searchMaxResponseSize = 4 * 1024 * 1024
+)
+
+// searchTimeRanges 支持的时间范围
+var searchTimeRanges = []string{"day", "week", "month", "year"}
+
+// SearchRequest 归一化的搜索请求
+type SearchRequest struct {
+	Query     string
+	Count     int
+	Language  string // 如 zh、en
+	Region    string // 如 CN、US
+	TimeRange string // day / week / month / year，为空表示不限
+	Engine    string // 仅 searchapi 使用，如 google、baidu、bing
+}
+
+// SearchResult 归一化的单条搜索结果
+type SearchResult struct {
+	Position      int    `json:"position"`
+	Title         string `json:"title"`
+	Link          string `json:"link"`
+	DisplayedLink string `json:"displayed_link,omitempty"`
+	Snippet       string `json:"snippet"`
+	Date          string `json:"date,omitempty"`
+	Thumbnail     string `json:"thumbnail,omitempty"`
+	Source        string `json:"source,omitempty"`
+}
+
+// SearchResponse web_search_tool 的返回结果
+type SearchResponse struct {
+	Query    string          `json:"query"`
+	Provider string          `json:"provider"`
+	Results  []*SearchResult `json:"results"`
+}
+
+// SearchProvider 搜索后端
+type SearchProvider interface {
+	Name() string
+	Search(ctx context.Context, req *SearchRequest) ([]*SearchResult, error)
+}
+
+// searchConfig 搜索配置
+type searchConfig struct {
+	Provider  string
+	Timeout   time.Duration
+	Retries   int
+	Backoff   time.Duration
+	Count     int
+	APIKey    string // searchapi / brave / bing 的 API Key
+	Engine    string // searchapi 默认搜索引擎
+	BaseURL   string // 覆盖默认的 API 地址，searxng 必填
+	Fixture   string // fake 使用的结果文件
+	UserAgent string
+}
+
+// searchSecrets 搜索服务的 API Key
+var searchSecrets = secret.Declare("web_search_tool", consts.WebSearchAPIKey, consts.SearchApiKey)
+
+// loadSearchConfig 读取搜索配置
+func loadSearchConfig(ctx context.Context) searchConfig {
+	cfg := searchConfig{
+		Provider:  g.Cfg().MustGet(ctx, consts.WebSearchProvider, searchProviderSearchAPI).String(),
+		Timeout:   g.Cfg().MustGet(ctx, consts.WebSearchTimeout, defaultSearchTimeout).Duration(),
+		Retries:   g.Cfg().MustGet(ctx, consts.WebSearchRetries, defaultSearchRetries).Int(),
+		Backoff:   g.Cfg().MustGet(ctx, consts.WebSearchBackoff, defaultSearchBackoff).Duration(),
+		Count:     g.Cfg().MustGet(ctx, consts.WebSearchCount, defaultSearchCount).Int(),
+		APIKey:    searchSecrets.Value(ctx, consts.WebSearchAPIKey),
+		Engine:    g.Cfg().MustGet(ctx, consts.WebSearchEngine, "google").String(),
+		BaseURL:   g.Cfg().MustGet(ctx, consts.WebSearchBaseURL).String(),
+		Fixture:   g.Cfg().MustGet(ctx, consts.WebSearchFixture).String(),
+		UserAgent: "agent-web-search/1.0",
+	}
+	if cfg.APIKey == "" && cfg.Provider == searchProviderSearchAPI {
+		// 兼容旧配置 ai.SearchApiKey
+		cfg.APIKey = searchSecrets.Value(ctx, consts.SearchApiKey)
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultSearchTimeout
+	}
+	if cfg.Retries < 0 {
+		cfg.Retries = 0
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = defaultSearchBackoff
+	}
+	if cfg.Count <= 0 {
+		cfg.Count = defaultSearchCount
+	}
+	return cfg
+}
+
+// newSearchProvider 根据配置创建搜索后端
+func newSearchProvider(cfg searchConfig) (SearchProvider, error) {
+	client := &searchClient{
+		http:      &http.Client{Timeout: cfg.Timeout},
+		retries:   cfg.Retries,
+		backoff:   cfg.Backoff,
+		userAgent: cfg.UserAgent,
+	}
+	switch cfg.Provider {
+	case searchProviderSearchAPI:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("searchapi api key is not configured")
+		}
+		return &searchAPIProvider{client: client, baseURL: orDefault(cfg.BaseURL, "https://www.searchapi.io/api/v1/search"), apiKey: cfg.APIKey, engine: cfg.Engine}, nil
+	case searchProviderSearxNG:
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("searxng base url is not configured")
+		}
+		return &searxngProvider{client: client, baseURL: strings.TrimSuffix(cfg.BaseURL, "/") + "/search"}, nil
+	case searchProviderBrave:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("brave api key is not configured")
+		}
+		return &braveProvider{client: client, baseURL: orDefault(cfg.BaseURL, "https://api.search.brave.com/res/v1/web/search"), apiKey: cfg.APIKey}, nil
+	case searchProviderBing:
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("bing api key is not configured")
+		}
+		return &bingProvider{client: client, baseURL: orDefault(cfg.BaseURL, "https://api.bing.microsoft.com/v7.0/search"), apiKey: cfg.APIKey}, nil
+	case searchProviderFake:
+		return &fakeSearchProvider{fixture: cfg.Fixture}, nil
+	default:
+		return nil, fmt.Errorf("unsupported search provider: %s", cfg.Provider)
+	}
+}
+
+func orDefault(value, def string) string {
+	if value == "" {
+		return def
+	}
+	return value
+}
+
+// searchClient 带超时和重试的 HTTP 客户端
+type searchClient struct {
+	http      *http.Client
+	retries   int
+	backoff   time.Duration
+	userAgent string
+}
+
+// retryableError 可重试的错误（网络错误、429、5xx）
+type retryableError struct {
+	err        error
+	retryAfter time.Duration
+}
+
+func (e *retryableError) Error() string { return e.err.Error() }
+
+// getJSON 发送 GET 请求并将 JSON 响应解析到 out，失败时按指数退避重试
+func (c *searchClient) getJSON(ctx context.Context, rawURL string, header http.Header, out any) error {
+	var err error
+	for attempt := 0; attempt <= c.retries; attempt++ {
+		if attempt > 0 {
+			wait := c.backoff * time.Duration(1<<(attempt-1))
+			// 加入抖动，避免同时重试
+			wait += time.Duration(rand.Int63n(int64(c.backoff)/2 + 1))
+			var re *retryableError
+			if errors.As(err, &re) && re.retryAfter > wait {
+				// 服务端要求的等待时间不超过单次请求超时
+				wait = min(re.retryAfter, c.http.Timeout)
+			}
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		var body []byte
+		body, err = c.get(ctx, rawURL, header)
+		if err == nil {
+			if err = gjson.DecodeTo(body, out); err != nil {
+				return fmt.Errorf("failed to decode search response: %v", err)
+			}
+			return nil
+		}
+		var re *retryableError
+		if !errors.As(err, &re) {
+			return err
+		}
+		g.Log(consts.LoggerTools).Warningf(ctx, "search request failed (attempt %d/%d): %v", attempt+1, c.retries+1, err)
+	}
+	return err
+}
+
+// get 发送一次 GET 请求，检查状态码
+func (c *searchClient) get(ctx context.Context, rawURL string, header http.Header) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create search request: %v", err)
+	}
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// *url.Error 包含完整的请求 URL，只保留原因，避免查询参数中的凭证出现在返回给模型的错误中
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, &retryableError{err: fmt.Errorf("failed to send search request: %v", err)}
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, searchMaxResponseSize))
+	if err != nil {
+		return nil, &retryableError{err: fmt.Errorf("failed to read search response: %v", err)}
+	}
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return body, nil
+	}
+
+	err = fmt.Errorf("search request failed with status %d: %s", res.StatusCode, truncateLine(strings.TrimSpace(string(body)), 200))
+	if res.StatusCode == http.StatusTooManyRequests || res.StatusCode >= 500 {
+		retryAfter, _ := strconv.Atoi(res.Header.Get("Retry-After"))
+		return nil, &retryableError{err: err, retryAfter: time.Duration(retryAfter) * time.Second}
+	}
+	return nil, err
+}
+
+// searchAPIProvider searchapi.io
+type searchAPIProvider struct {
+	client  *searchClient
+	baseURL string
+	apiKey  string
+	engine  string
+}
+
+func (p *searchAPIProvider) Name() string { return searchProviderSearchAPI }
+
+func (p *searchAPIProvider) Search(ctx context.Context, req *SearchRequest) ([]*SearchResult, error) {
+	engine := req.Engine
+	if engine == "" {
+		engine = p.engine
+	}
+	query := url.Values{
+		"engine": {engine},
+		"q":      {req.Query},
+		"num":    {strconv.Itoa(req.Count)},
+	}
+	if req.Language != "" {
+		query.Set("hl", req.Language)
+	}
+	if req.Region != "" {
+		query.Set("gl", strings.ToLower(req.Region))
+	}
+	if req.TimeRange != "" {
+		query.Set("time_period", "last_"+req.TimeRange)
+	}
+
+	var res struct {
+		Error          string `json:"error"`
+		OrganicResults []struct {
+			Position      int    `json:"position"`
+			Title         string `json:"title"`
+			Link          string `json:"link"`
+			DisplayedLink string `json:"displayed_link"`
+			Snippet       string `json:"snippet"`
+			Date          string `json:"date"`
+			Thumbnail     string `json:"thumbnail"`
+			Source        string `json:"source"`
+		} `json:"organic_results"`
+	}
+	// API Key 放在请求头中，不出现在 URL 和错误信息里
+	header := http.Header{"Authorization": {"Bearer " + p.apiKey}}
+	if err := p.client.getJSON(ctx, p.baseURL+"?"+query.Encode(), header, &res); err != nil {
+		return nil, err
+	}
+	if res.Error != "" {
+		return nil, fmt.Errorf("searchapi error: %s", res.Error)
+	}
+	results := make([]*SearchResult, 0, len(res.OrganicResults))
+	for _, r := range res.OrganicResults {
+		results = append(results, &SearchResult{
+			Title:         r.Title,
+			Link:          r.Link,
+			DisplayedLink: r.DisplayedLink,
+			Snippet:       r.Snippet,
+			Date:          r.Date,
+			Thumbnail:     r.Thumbnail,
+			Source:        r.Source,
+		})
+	}
+	return results, nil
+}
+
+// searxngProvider 自建 SearxNG，需开启 json 输出格式
+type searxngProvider struct {
+	client  *searchClient
+	baseURL string
+}
+
+func (p *searxngProvider) Name() string { return searchProviderSearxNG }
+
+func (p *searxngProvider) Search(ctx context.Context, req *SearchRequest) ([]*SearchResult, error) {
+	query := url.Values{
+		"q":      {req.Query},
+		"format": {"json"},
+	}
+	if req.Language != "" {
+		language := req.Language
+		if req.Region != "" {
+			language += "-" + strings.ToUpper(req.Region)
+		}
+		query.Set("language", language)
+	}
+	if req.TimeRange != "" {
+		// SearxNG 的 time_range 取值与工具参数一致
+		query.Set("time_range", req.TimeRange)
+	}
+
+	var res struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			PublishedDate string `json:"publishedDate"`
+			Thumbnail     string `json:"thumbnail"`
+			Engine        string `json:"engine"`
+		} `json:"results"`
+	}
+	if err := p.client.getJSON(ctx, p.baseURL+"?"+query.Encode(), nil, &res); err != nil {
+		return nil, err
+	}
+	results := make([]*SearchResult, 0, len(res.Results))
+	for _, r := range res.Results {
+		results = append(results, &SearchResult{
+			Title:         r.Title,
+			Link:          r.URL,
+			DisplayedLink: displayedLink(r.URL),
+			Snippet:       r.Content,
+			Date:          r.PublishedDate,
+			Thumbnail:     r.Thumbnail,
+			Source:        r.Engine,
+		})
+	}
+	return results, nil
+}
+
+// braveProvider Brave Search API
+type braveProvider struct {
+	client  *searchClient
+	baseURL string
+	apiKey  string
+}
+
+func (p *braveProvider) Name() string { return searchProviderBrave }
+
+// braveFreshness 时间范围对应的 freshness 参数
+var braveFreshness = map[string]string{"day": "pd", "week": "pw", "month": "pm", "year": "py"}
+
+func (p *braveProvider) Search(ctx context.Context, req *SearchRequest) ([]*SearchResult, error) {
+	query := url.Values{
+		"q":     {req.Query},
+		"count": {strconv.Itoa(req.Count)},
+	}
+	if req.Language != "" {
+		query.Set("search_lang", req.Language)
+	}
+	if req.Region != "" {
+		query.Set("country", strings.ToUpper(req.Region))
+	}
+	if freshness, ok := braveFreshness[req.TimeRange]; ok {
+		query.Set("freshness", freshness)
+	}
+
+	var res struct {
+		Web struct {
+			Results []struct {
+				Title       string `json:"title"`
+				URL         string `json:"url"`
+				Description string `json:"description"`
+				Age         string `json:"age"`
+				Profile     struct {
+					Name string `json:"name"`
+				} `json:"profile"`
+				Thumbnail struct {
+					Src string `json:"src"`
+				} `json:"thumbnail"`
+			} `json:"results"`
+		} `json:"web"`
+	}
+	header := http.Header{"X-Subscription-Token": {p.apiKey}}
+	if err := p.client.getJSON(ctx, p.baseURL+"?"+query.Encode(), header, &res); err != nil {
+		return nil, err
+	}
+	results := make([]*SearchResult, 0, len(res.Web.Results))
+	for _, r := range res.Web.Results {
+		results = append(results, &SearchResult{
+			Title:         r.Title,
+			Link:          r.URL,
+			DisplayedLink: displayedLink(r.URL),
+			Snippet:       r.Description,
+			Date:          r.Age,
+			Thumbnail:     r.Thumbnail.Src,
+			Source:        r.Profile.Name,
+		})
+	}
+	return results, nil
+}
+
+// bingProvider Bing Web Search API
+type bingProvider struct {
+	client  *searchClient
+	baseURL string
+	apiKey  string
+}
+
+func (p *bingProvider) Name() string { return searchProviderBing }
+
+// bingFreshness 时间范围对应的 freshness 参数，Bing 不支持按年过滤
+var bingFreshness = map[string]string{"day": "Day", "week": "Week", "month": "Month"}
+
+func (p *bingProvider) Search(ctx context.Context, req *SearchRequest) ([]*SearchResult, error) {
+	query := url.Values{
+		"q":     {req.Query},
+		"count": {strconv.Itoa(req.Count)},
+	}
+	if req.Language != "" && req.Region != "" {
+		query.Set("mkt", req.Language+"-"+strings.ToUpper(req.Region))
+	} else if req.Language != "" {
+		query.Set("setLang", req.Language)
+	} else if req.Region != "" {
+		query.Set("cc", strings.ToUpper(req.Region))
+	}
+	if freshness, ok := bingFreshness[req.TimeRange]; ok {
+		query.Set("freshness", freshness)
+	}
+
+	var res struct {
+		WebPages struct {
+			Value []struct {
+				Name            string `json:"name"`
+				URL             string `json:"url"`
+				DisplayURL      string `json:"displayUrl"`
+				Snippet         string `json:"snippet"`
+				DateLastCrawled string `json:"dateLastCrawled"`
+				SiteName        string `json:"siteName"`
+				ThumbnailURL    string `json:"thumbnailUrl"`
+				DatePublished   string `json:"datePublished"`
+			} `json:"value"`
+		} `json:"webPages"`
+	}
+	header := http.Header{"Ocp-Apim-Subscription-Key": {p.apiKey}}
+	if err := p.client.getJSON(ctx, p.baseURL+"?"+query.Encode(), header, &res); err != nil {
+		return nil, err
+	}
+	results := make([]*SearchResult, 0, len(res.WebPages.Value))
+	for _, r := range res.WebPages.Value {
+		date := r.DatePublished
+		if date == "" {
+			date = r.DateLastCrawled
+		}
+		results = append(results, &SearchResult{
+			Title:         r.Name,
+			Link:          r.URL,
+			DisplayedLink: r.DisplayURL,
+			Snippet:       r.Snippet,
+			Date:          date,
+			Thumbnail:     r.ThumbnailURL,
+			Source:        r.SiteName,
+		})
+	}
+	return results, nil
+}
+
+// fakeSearchProvider 从固定的结果文件返回搜索结果，用于测试和离线环境
+type fakeSearchProvider struct {
+	fixture string
+}
+
+func (p *fakeSearchProvider) Name() string { return searchProviderFake }
+
+func (p *fakeSearchProvider) Search(_ context.Context, req *SearchRequest) ([]*SearchResult, error) {
+	if p.fixture == "" {
+		return nil, fmt.Errorf("fake search fixture is not configured")
+	}
+	data, err := os.ReadFile(p.fixture)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read search fixture: %v", err)
+	}
+	// 文件内容为 query -> 结果列表，"*" 为默认结果
+	var fixtures map[string][]*SearchResult
+	if err = gjson.DecodeTo(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("failed to decode search fixture: %v", err)
+	}
+	if results, ok := fixtures[req.Query]; ok {
+		return results, nil
+	}
+	return fixtures["*"], nil
+}
+
+// displayedLink 从 URL 生成展示用的链接
+func displayedLink(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return rawURL
+	}
+	return u.Host + strings.TrimSuffix(u.Path, "/")
+}
@@ -2,22 +2,27 @@ package tools
 
 import (
 	"agent/internal/consts"
+	"agent/internal/secret"
 	"context"
 	"fmt"
-	"io"
-	"net/http"
-	"net/url"
+	"slices"
+	"strings"
 
 	"github.com/cloudwego/eino/components/tool"
 	"github.com/cloudwego/eino/schema"
 	"github.com/gogf/gf/v2/encoding/gjson"
 	"github.com/gogf/gf/v2/frame/g"
-	"github.com/gogf/gf/v2/util/gconv"
 )
 
 type WebSearchTool struct {
-	Q      string `json:"q"`
-	Engine string `json:"engine"`
+	Q         string `json:"q"`
+	Engine    string `json:"engine,omitempty"`
+	Count     int    `json:"count,omitempty"`
+	Language  string `json:"language,omitempty"`
+	Region    string `json:"region,omitempty"`
+	TimeRange string `json:"time_range,omitempty"`
+
+	provider SearchProvider // 为空时按配置创建
 }
 
 func NewWebSearchTool() *WebSearchTool {
@@ -27,7 +32,7 @@ func NewWebSearchTool() *WebSearchTool {
 func (t *WebSearchTool) Info(_ context.Context) (*schema.ToolInfo, error) {
 	return &schema.ToolInfo{
 		Name: "web_search_tool",
-		Desc: "Search for information from Search Engine",
+		Desc: "Search for information from Search Engine, returns a list of results with title, link and snippet",
 		ParamsOneOf: schema.NewParamsOneOfByParams(map[string]*schema.ParameterInfo{
 			"q": {
 				Type:     schema.String,
@@ -35,56 +40,84 @@ func (t *WebSearchTool) Info(_ context.Context) (*schema.ToolInfo, error) {
 				Required: true},
 			"engine": {
 				Type:     schema.String,
-				Desc:     "search engine name",
-				Enum:     []string{"baidu", "google"},
-				Required: true},
+				Desc:     "search engine name, only used by some search providers",
+				Enum:     []string{"baidu", "google", "bing"},
+				Required: false},
+			"count": {
+				Type:     schema.Integer,
+				Desc:     fmt.Sprintf("number of results to return, at most %d", maxSearchCount),
+				Required: false},
+			"language": {
+				Type:     schema.String,
+				Desc:     "result language as an ISO 639-1 code, e.g. 'zh' or 'en'",
+				Required: false},
+			"region": {
+				Type:     schema.String,
+				Desc:     "result region as an ISO 3166-1 country code, e.g. 'CN' or 'US'",
+				Required: false},
+			"time_range": {
+				Type:     schema.String,
+				Desc:     "only return results published within this time range",
+				Enum:     searchTimeRanges,
+				Required: false},
 		}),
 	}, nil
 }
 
 func (t *WebSearchTool) InvokableRun(ctx context.Context, argumentsInJSON string, _ ...tool.Option) (string, error) {
 	// 1. 反序列化 argumentsInJSON，处理 option 等
-	var resp WebSearchTool
-	err := gjson.DecodeTo([]byte(argumentsInJSON), &resp)
+	var req WebSearchTool
+	err := gjson.DecodeTo([]byte(argumentsInJSON), &req)
 	if err != nil {
-		return "", err
-	}
-	// 2. 处理业务逻辑
-	baseURL := "https://www.searchapi.io/api/v1/search"
-	queryParams := url.Values{
-		"engine":  {resp.Engine},
-		"q":       {resp.Q},
-		"api_key": {gconv.String(g.Cfg().MustGet(ctx, consts.SearchApiKey))},
+		return "", fmt.Errorf("failed to parse arguments: %v", err)
 	}
 
-	fullURL := fmt.Sprintf("%s?%s", baseURL, queryParams.Encode())
-	req, _ := http.NewRequest("GET", fullURL, nil)
-	res, _ := http.DefaultClient.Do(req)
-
-	defer res.Body.Close()
-	body, _ := io.ReadAll(res.Body)
-
-	// 3. 解析返回的JSON并提取前5条organic_results
-	var searchResult map[string]interface{}
-	err = gjson.DecodeTo(body, &searchResult)
-	if err != nil {
-		return "", err
+	// 2. 参数验证和默认值设置
+	req.Q = strings.TrimSpace(req.Q)
+	if req.Q == "" {
+		return "", fmt.Errorf("q parameter is required")
 	}
-
-	// 提取organic_results
-	organicResults := gconv.SliceAny(searchResult["organic_results"])
-
-	var top5 []interface{}
-	if len(organicResults) > 5 {
-		top5 = organicResults[:5]
-	} else {
-		top5 = organicResults
+	if req.TimeRange != "" && !slices.Contains(searchTimeRanges, req.TimeRange) {
+		return "", fmt.Errorf("unsupported time_range: %s", req.TimeRange)
+	}
+	cfg := loadSearchConfig(ctx)
+	if req.Count <= 0 {
+		req.Count = cfg.Count
 	}
+	req.Count = min(req.Count, maxSearchCount)
 
-	result, err := gjson.EncodeString(top5)
+	// 3. 调用搜索后端
+	provider := t.provider
+	if provider == nil {
+		provider, err = newSearchProvider(cfg)
+		if err != nil {
+			return "", err
+		}
+	}
+	results, err := provider.Search(ctx, &SearchRequest{
+		Query:     req.Q,
+		Count:     req.Count,
+		Language:  req.Language,
+		Region:    req.Region,
+		TimeRange: req.TimeRange,
+		Engine:    req.Engine,
+	})
 	if err != nil {
-		return "", err
+		// 搜索失败不中断 Agent 运行，由模型决定是否重试或换个问法
+		g.Log(consts.LoggerTools).Errorf(ctx, "web search with %s failed: %v", provider.Name(), err)
+		return "Error searching the web: " + secret.Mask(err.Error()), nil
 	}
 
-	return result, nil
+	// 4. 截取前 count 条并重新编号
+	if len(results) > req.Count {
+		results = results[:req.Count]
+	}
+	for i, r := range results {
+		r.Position = i + 1
+	}
+	return gjson.EncodeString(&SearchResponse{
+		Query:    req.Q,
+		Provider: provider.Name(),
+		Results:  results,
+	})
 }
@@ -2,28 +2,17 @@ package tools
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/cloudwego/eino/components/tool"
+	"github.com/gogf/gf/v2/encoding/gjson"
 )
 
-//func TestWebSearchTool(t *testing.T) {
-//	type args struct {
-//		ctx context.Context
-//	}
-//	tests := []struct {
-//		name string
-//		args args
-//	}{
-//		{name: "01", args: args{ctx: context.Background()}},
-//	}
-//	for _, tt := range tests {
-//		t.Run(tt.name, func(t *testing.T) {
-//			WebSearchTool(tt.args.ctx)
-//		})
-//	}
-//}
-
 func TestWebSearchTool_InvokableRun(t1 *testing.T) {
 	type fields struct {
 		Q      string
@@ -35,40 +24,206 @@ func TestWebSearchTool_InvokableRun(t1 *testing.T) {
 		in2             []tool.Option
 	}
 	tests := []struct {
-		name    string
-		fields  fields
-		args    args
-		want    string
-		wantErr bool
+		name        string
+		fields      fields
+		args        args
+		wantResults int
+		wantErr     bool
 	}{
 		{
 			name: "01",
 			fields: fields{
-				Q:      "丁真",
+				Q:      "golang",
 				Engine: "google",
 			},
 			args: args{
 				ctx:             context.Background(),
-				argumentsInJSON: `{"q":"丁真","engine":"google"}`,
+				argumentsInJSON: `{"q":"golang","engine":"google","count":2}`,
 				in2:             []tool.Option{},
 			},
-			want:    "",
-			wantErr: false,
+			wantResults: 2,
+			wantErr:     false,
+		},
+		{
+			name: "no results",
+			args: args{
+				ctx:             context.Background(),
+				argumentsInJSON: `{"q":"nothing here"}`,
+			},
+			wantResults: 0,
+		},
+		{
+			name: "empty query",
+			args: args{
+				ctx:             context.Background(),
+				argumentsInJSON: `{"q":"  "}`,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid time range",
+			args: args{
+				ctx:             context.Background(),
+				argumentsInJSON: `{"q":"golang","time_range":"decade"}`,
+			},
+			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t1.Run(tt.name, func(t1 *testing.T) {
 			t := &WebSearchTool{
-				Q:      tt.fields.Q,
-				Engine: tt.fields.Engine,
+				Q:        tt.fields.Q,
+				Engine:   tt.fields.Engine,
+				provider: &fakeSearchProvider{fixture: "resource/fixture/web_search.json"},
 			}
 			got, err := t.InvokableRun(tt.args.ctx, tt.args.argumentsInJSON, tt.args.in2...)
 			if (err != nil) != tt.wantErr {
 				t1.Errorf("InvokableRun() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if got != tt.want {
-				t1.Errorf("InvokableRun() got = %v, want %v", got, tt.want)
+			if tt.wantErr {
+				return
+			}
+			var res SearchResponse
+			if err = gjson.DecodeTo(got, &res); err != nil {
+				t1.Fatalf("InvokableRun() returned invalid json: %v", err)
+			}
+			if len(res.Results) != tt.wantResults || res.Provider != searchProviderFake {
+				t1.Errorf("InvokableRun() got = %v", got)
+			}
+			for i, r := range res.Results {
+				if r.Position != i+1 {
+					t1.Errorf("InvokableRun() result %d has position %d", i, r.Position)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchProviders(t1 *testing.T) {
+	tests := []struct {
+		name       string
+		provider   string
+		response   string
+		wantQuery  map[string]string
+		wantHeader map[string]string
+		wantTitle  string
+		wantLink   string
+	}{
+		{
+			name:       "searchapi",
+			provider:   searchProviderSearchAPI,
+			response:   `{"organic_results":[{"title":"A","link":"https://a.example/x","snippet":"a"}]}`,
+			wantQuery:  map[string]string{"q": "golang", "num": "3", "hl": "en", "gl": "us", "time_period": "last_week"},
+			wantHeader: map[string]string{"Authorization": "Bearer key"},
+			wantTitle:  "A",
+			wantLink:   "https://a.example/x",
+		},
+		{
+			name:      "searxng",
+			provider:  searchProviderSearxNG,
+			response:  `{"results":[{"title":"B","url":"https://b.example/y/","content":"b","engine":"duckduckgo"}]}`,
+			wantQuery: map[string]string{"q": "golang", "format": "json", "language": "en-US", "time_range": "week"},
+			wantTitle: "B",
+			wantLink:  "https://b.example/y/",
+		},
+		{
+			name:       "brave",
+			provider:   searchProviderBrave,
+			response:   `{"web":{"results":[{"title":"C","url":"https://c.example/","description":"c"}]}}`,
+			wantQuery:  map[string]string{"q": "golang", "count": "3", "search_lang": "en", "country": "US", "freshness": "pw"},
+			wantHeader: map[string]string{"X-Subscription-Token": "key"},
+			wantTitle:  "C",
+			wantLink:   "https://c.example/",
+		},
+		{
+			name:       "bing",
+			provider:   searchProviderBing,
+			response:   `{"webPages":{"value":[{"name":"D","url":"https://d.example/","snippet":"d"}]}}`,
+			wantQuery:  map[string]string{"q": "golang", "count": "3", "mkt": "en-US", "freshness": "Week"},
+			wantHeader: map[string]string{"Ocp-Apim-Subscription-Key": "key"},
+			wantTitle:  "D",
+			wantLink:   "https://d.example/",
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, want := range tt.wantQuery {
+					if got := r.URL.Query().Get(key); got != want {
+						t1.Errorf("query %s = %q, want %q", key, got, want)
+					}
+				}
+				for key, want := range tt.wantHeader {
+					if got := r.Header.Get(key); got != want {
+						t1.Errorf("header %s = %q, want %q", key, got, want)
+					}
+				}
+				// API Key 不能出现在 URL 中
+				if strings.Contains(r.URL.RawQuery, "key") {
+					t1.Errorf("query %q contains the api key", r.URL.RawQuery)
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(tt.response))
+			}))
+			defer server.Close()
+
+			provider, err := newSearchProvider(searchConfig{
+				Provider: tt.provider,
+				Timeout:  time.Second,
+				Backoff:  time.Millisecond,
+				APIKey:   "key",
+				BaseURL:  server.URL,
+			})
+			if err != nil {
+				t1.Fatal(err)
+			}
+			results, err := provider.Search(context.Background(), &SearchRequest{
+				Query: "golang", Count: 3, Language: "en", Region: "US", TimeRange: "week",
+			})
+			if err != nil {
+				t1.Fatalf("Search() error = %v", err)
+			}
+			if len(results) != 1 || results[0].Title != tt.wantTitle || results[0].Link != tt.wantLink {
+				t1.Errorf("Search() got = %+v", results)
+			}
+		})
+	}
+}
+
+func TestSearchClient_Retry(t1 *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int32
+		status    int
+		retries   int
+		wantCalls int32
+		wantErr   bool
+	}{
+		{name: "recovers after server errors", failures: 2, status: http.StatusServiceUnavailable, retries: 2, wantCalls: 3},
+		{name: "gives up after retries", failures: 5, status: http.StatusTooManyRequests, retries: 1, wantCalls: 2, wantErr: true},
+		{name: "client error is not retried", failures: 5, status: http.StatusUnauthorized, retries: 3, wantCalls: 1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			var calls int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&calls, 1) <= tt.failures {
+					w.WriteHeader(tt.status)
+					return
+				}
+				_, _ = w.Write([]byte(`{"ok":true}`))
+			}))
+			defer server.Close()
+
+			client := &searchClient{http: &http.Client{Timeout: time.Second}, retries: tt.retries, backoff: time.Millisecond}
+			var out map[string]any
+			err := client.getJSON(context.Background(), server.URL, nil, &out)
+			if (err != nil) != tt.wantErr {
+				t1.Errorf("getJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if calls != tt.wantCalls {
+				t1.Errorf("getJSON() calls = %d, want %d", calls, tt.wantCalls)
 			}
 		})
 	}
@@ -0,0 +1,391 @@
+package tools
+
+import (
+	"agent/internal/consts"
+	"agent/internal/model"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtimer"
+)
+
+const (
+	defaultWorkspaceRoot = "resource/workspace"
+
+	defaultWorkspaceMaxBytes = 200 * 1024 * 1024
+	defaultWorkspaceMaxFiles = 1000
+	defaultWorkspaceTTL      = 24 * time.Hour
+	defaultJanitorInterval   = 10 * time.Minute
+	// workspaceUsageTTL 缓存的工作目录用量多久后重新统计，用于校正终端命令等未记录的变化
+	workspaceUsageTTL = time.Minute
+)
+
+// errNoSession 没有会话时不分配工作目录，避免不同调用方共用同一个目录
+var errNoSession = errors.New("this tool requires a session, call it with a session_id")
+
+// sessionIDFromCtx 获取当前会话 ID，没有会话时返回空字符串
+func sessionIDFromCtx(ctx context.Context) string {
+	if c, ok := ctx.Value(consts.ContextKey).(*model.Context); ok {
+		return c.SessionID
+	}
+	return ""
+}
+
+// workspaceRoot 所有会话工作目录的根目录（绝对路径）
+func workspaceRoot(ctx context.Context) (string, error) {
+	root := g.Cfg().MustGet(ctx, consts.WorkspaceRoot, defaultWorkspaceRoot).String()
+	return filepath.Abs(root)
+}
+
+// workspaceDir 会话对应的工作目录（绝对路径），不创建
+func workspaceDir(ctx context.Context, sessionID string) (string, error) {
+	root, err := workspaceRoot(ctx)
+	if err != nil {
+		return "", err
+	}
+	name := sanitizeFilename(sessionID)
+	if name == "" {
+		return "", errNoSession
+	}
+	return filepath.Join(root, name), nil
+}
+
+// sessionWorkspace 返回当前会话的工作目录（绝对路径），不存在时创建
+func sessionWorkspace(ctx context.Context) (string, error) {
+	dir, err := workspaceDir(ctx, sessionIDFromCtx(ctx))
+	if err != nil {
+		return "", err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create workspace: %v", err)
+	}
+	// 统一为真实路径，便于与符号链接解析后的路径比较
+	return filepath.EvalSymlinks(dir)
+}
+
+// resolveInWorkspace 将相对路径解析为工作目录内的绝对路径，符号链接解析后仍必须位于工作目录内
+func resolveInWorkspace(workspace, path string) (string, error) {
+	cleanPath := filepath.Clean(path)
+	if filepath.IsAbs(cleanPath) {
+		return "", fmt.Errorf("absolute paths not allowed, only relative paths within the workspace: %s", path)
+	}
+	if cleanPath == ".." || strings.HasPrefix(cleanPath, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path traversal not allowed: %s", path)
+	}
+	target := filepath.Join(workspace, cleanPath)
+
+	// 找到最长的已存在前缀并解析符号链接，不存在的部分原样拼接
+	existing, rest := target, ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path %s: %v", path, err)
+	}
+	resolved := filepath.Join(real, rest)
+	if !withinDir(workspace, resolved) {
+		return "", fmt.Errorf("path escapes the workspace: %s", path)
+	}
+	return resolved, nil
+}
+
+// withinDir 判断 path 是否为 dir 本身或其子路径
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel == "." || (rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)))
+}
+
+// workspaceRel 返回工作目录内的相对路径，用于返回给模型
+func workspaceRel(workspace, path string) string {
+	rel, err := filepath.Rel(workspace, path)
+	if err != nil {
+		return filepath.Base(path)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// uniquePath 文件已存在时添加序号
+func uniquePath(filePath string) string {
+	ext := filepath.Ext(filePath)
+	nameWithoutExt := strings.TrimSuffix(filePath, ext)
+	for counter := 1; ; counter++ {
+		if _, err := os.Lstat(filePath); os.IsNotExist(err) {
+			return filePath
+		}
+		filePath = fmt.Sprintf("%s_%d%s", nameWithoutExt, counter, ext)
+	}
+}
+
+// workspaceQuota 工作目录配额
+type workspaceQuota struct {
+	MaxBytes int64
+	MaxFiles int
+}
+
+// loadWorkspaceQuota 读取工作目录配额配置
+func loadWorkspaceQuota(ctx context.Context) workspaceQuota {
+	q := workspaceQuota{
+		MaxBytes: g.Cfg().MustGet(ctx, consts.WorkspaceMaxBytes, defaultWorkspaceMaxBytes).Int64(),
+		MaxFiles: g.Cfg().MustGet(ctx, consts.WorkspaceMaxFiles, defaultWorkspaceMaxFiles).Int(),
+	}
+	if q.MaxBytes <= 0 {
+		q.MaxBytes = defaultWorkspaceMaxBytes
+	}
+	if q.MaxFiles <= 0 {
+		q.MaxFiles = defaultWorkspaceMaxFiles
+	}
+	return q
+}
+
+// workspaceUsage 统计工作目录已使用的字节数和文件数
+func countWorkspaceUsage(workspace string) (bytes int64, files int, err error) {
+	err = filepath.WalkDir(workspace, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		bytes += info.Size()
+		files++
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to calculate workspace usage: %v", err)
+	}
+	return bytes, files, nil
+}
+
+// workspaceUsage 缓存的工作目录用量
+// 写入前通过 check 预占用量，超出配额或缓存过期时才重新遍历目录
+type workspaceUsage struct {
+	mu        sync.Mutex
+	bytes     int64
+	files     int
+	countedAt time.Time
+}
+
+// workspaceUsages 按工作目录缓存用量
+var workspaceUsages sync.Map
+
+// usageOf 获取工作目录的用量缓存
+func usageOf(workspace string) *workspaceUsage {
+	u, _ := workspaceUsages.LoadOrStore(workspace, &workspaceUsage{})
+	return u.(*workspaceUsage)
+}
+
+// recount 重新遍历目录统计用量，调用方需持有锁
+func (u *workspaceUsage) recount(workspace string) error {
+	bytes, files, err := countWorkspaceUsage(workspace)
+	if err != nil {
+		return err
+	}
+	u.bytes, u.files, u.countedAt = bytes, files, time.Now()
+	return nil
+}
+
+// load 返回缓存的用量，缓存过期或 exceeds 判断超出配额时重新统计，调用方需持有锁
+func (u *workspaceUsage) load(workspace string, exceeds func() bool) error {
+	if u.countedAt.IsZero() || time.Since(u.countedAt) > workspaceUsageTTL {
+		return u.recount(workspace)
+	}
+	// 预占的用量可能多于实际写入，拒绝前以真实用量为准
+	if exceeds() {
+		return u.recount(workspace)
+	}
+	return nil
+}
+
+// addWorkspaceUsage 记录未经 check 预占的写入
+func addWorkspaceUsage(workspace string, addBytes int64, addFiles int) {
+	u := usageOf(workspace)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.bytes += addBytes
+	u.files += addFiles
+}
+
+// invalidateWorkspaceUsage 目录被未记录的方式修改后（如终端命令），下次检查时重新统计
+func invalidateWorkspaceUsage(workspace string) {
+	u := usageOf(workspace)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.countedAt = time.Time{}
+}
+
+// forgetWorkspaceUsage 删除目录及其子目录的用量缓存
+func forgetWorkspaceUsage(dir string) {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		real = dir
+	}
+	workspaceUsages.Range(func(key, _ any) bool {
+		if path := key.(string); withinDir(dir, path) || withinDir(real, path) {
+			workspaceUsages.Delete(key)
+		}
+		return true
+	})
+}
+
+// check 检查新增 addBytes 字节、addFiles 个文件后是否超出配额，未超出时预占这部分用量
+func (q workspaceQuota) check(workspace string, addBytes int64, addFiles int) error {
+	u := usageOf(workspace)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	exceeds := func() bool {
+		return u.files+addFiles > q.MaxFiles || u.bytes+addBytes > q.MaxBytes
+	}
+	if err := u.load(workspace, exceeds); err != nil {
+		return err
+	}
+	if u.files+addFiles > q.MaxFiles {
+		return fmt.Errorf("workspace file quota exceeded: %d files allowed", q.MaxFiles)
+	}
+	if u.bytes+addBytes > q.MaxBytes {
+		return fmt.Errorf("workspace size quota exceeded: %d of %d bytes used", u.bytes, q.MaxBytes)
+	}
+	u.bytes += addBytes
+	u.files += addFiles
+	return nil
+}
+
+// remaining 工作目录剩余可用字节数，写入后需调用 addWorkspaceUsage 记录
+func (q workspaceQuota) remaining(workspace string) (int64, error) {
+	u := usageOf(workspace)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if err := u.load(workspace, func() bool { return u.files >= q.MaxFiles }); err != nil {
+		return 0, err
+	}
+	if u.files >= q.MaxFiles {
+		return 0, fmt.Errorf("workspace file quota exceeded: %d files allowed", q.MaxFiles)
+	}
+	return q.MaxBytes - u.bytes, nil
+}
+
+// errQuotaExceeded 写入内容超出剩余配额
+var errQuotaExceeded = errors.New("workspace size quota exceeded")
+
+// copyWithQuota 写入不超过 limit 字节，超出时返回 errQuotaExceeded
+func copyWithQuota(dst io.Writer, src io.Reader, limit int64) (int64, error) {
+	if limit < 0 {
+		limit = 0
+	}
+	n, err := io.Copy(dst, io.LimitReader(src, limit+1))
+	if err != nil {
+		return n, err
+	}
+	if n > limit {
+		return n, errQuotaExceeded
+	}
+	return n, nil
+}
+
+// RemoveSessionWorkspace 删除会话的工作目录
+func RemoveSessionWorkspace(ctx context.Context, sessionID string) error {
+	dir, err := workspaceDir(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	forgetWorkspaceUsage(dir)
+	if err = os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("failed to remove workspace: %v", err)
+	}
+	g.Log(consts.LoggerTools).Infof(ctx, "Removed workspace of session: %s", sessionID)
+	return nil
+}
+
+// CleanExpiredWorkspaces 删除超过 ttl 未修改的会话工作目录
+func CleanExpiredWorkspaces(ctx context.Context, ttl time.Duration) error {
+	root, err := workspaceRoot(ctx)
+	if err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read workspace root: %v", err)
+	}
+	deadline := time.Now().Add(-ttl)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+		if lastModified(dir).After(deadline) {
+			continue
+		}
+		forgetWorkspaceUsage(dir)
+		if err = os.RemoveAll(dir); err != nil {
+			g.Log(consts.LoggerTools).Warningf(ctx, "failed to remove expired workspace %s: %v", dir, err)
+			continue
+		}
+		g.Log(consts.LoggerTools).Infof(ctx, "Removed expired workspace: %s", entry.Name())
+	}
+	return nil
+}
+
+// lastModified 目录内最近一次修改时间
+func lastModified(dir string) time.Time {
+	var latest time.Time
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info, err := d.Info(); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+		return nil
+	})
+	return latest
+}
+
+// WorkspaceTTL 会话工作目录超过该时间未修改即被清理，会话的其他数据也按它过期
+func WorkspaceTTL(ctx context.Context) time.Duration {
+	ttl := g.Cfg().MustGet(ctx, consts.WorkspaceTTL, defaultWorkspaceTTL).Duration()
+	if ttl <= 0 {
+		ttl = defaultWorkspaceTTL
+	}
+	return ttl
+}
+
+// StartWorkspaceJanitor 定时清理过期的会话工作目录
+func StartWorkspaceJanitor(ctx context.Context) {
+	ttl := WorkspaceTTL(ctx)
+	interval := g.Cfg().MustGet(ctx, consts.WorkspaceJanitorInterval, defaultJanitorInterval).Duration()
+	if interval <= 0 {
+		interval = defaultJanitorInterval
+	}
+	gtimer.AddSingleton(ctx, interval, func(ctx context.Context) {
+		if err := CleanExpiredWorkspaces(ctx, ttl); err != nil {
+			g.Log(consts.LoggerTools).Warningf(ctx, "workspace janitor: %v", err)
+		}
+	})
+}
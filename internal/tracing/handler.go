@@ -0,0 +1,246 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName 本项目 span 的 instrumentation 名称
+const tracerName = "agent/internal/tracing"
+
+// span 属性，模型和工具相关的属性沿用 OpenTelemetry GenAI 语义约定
+const (
+	AttrComponent    = attribute.Key("eino.component")
+	AttrType         = attribute.Key("eino.type")
+	AttrName         = attribute.Key("eino.name")
+	AttrOperation    = attribute.Key("gen_ai.operation.name")
+	AttrModel        = attribute.Key("gen_ai.request.model")
+	AttrMessages     = attribute.Key("gen_ai.request.message_count")
+	AttrInputTokens  = attribute.Key("gen_ai.usage.input_tokens")
+	AttrOutputTokens = attribute.Key("gen_ai.usage.output_tokens")
+	AttrTotalTokens  = attribute.Key("gen_ai.usage.total_tokens")
+	AttrToolCalls    = attribute.Key("gen_ai.response.tool_call_count")
+	AttrToolName     = attribute.Key("gen_ai.tool.name")
+	AttrToolArgsSize = attribute.Key("gen_ai.tool.arguments_size")
+	AttrToolRespSize = attribute.Key("gen_ai.tool.response_size")
+)
+
+// spanKey 上下文中当前组件 span 的键，避免误结束 HTTP 请求等外层 span
+type spanKey struct{}
+
+// handler 为每次图、节点、模型和工具调用创建一个 span
+type handler struct {
+	tracer trace.Tracer
+}
+
+// NewHandler 创建输出 OpenTelemetry span 的 Eino 回调
+func NewHandler(tracer trace.Tracer) callbacks.Handler {
+	return &handler{tracer: tracer}
+}
+
+func (h *handler) OnStart(ctx context.Context, info *callbacks.RunInfo, input callbacks.CallbackInput) context.Context {
+	ctx, span := h.start(ctx, info)
+	setInputAttributes(span, info, input)
+	return ctx
+}
+
+func (h *handler) OnEnd(ctx context.Context, info *callbacks.RunInfo, output callbacks.CallbackOutput) context.Context {
+	span, ok := ctx.Value(spanKey{}).(trace.Span)
+	if !ok {
+		return ctx
+	}
+	setOutputAttributes(span, info, output)
+	span.End()
+	return ctx
+}
+
+func (h *handler) OnError(ctx context.Context, _ *callbacks.RunInfo, err error) context.Context {
+	span, ok := ctx.Value(spanKey{}).(trace.Span)
+	if !ok {
+		return ctx
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+	span.End()
+	return ctx
+}
+
+func (h *handler) OnStartWithStreamInput(ctx context.Context, info *callbacks.RunInfo,
+	input *schema.StreamReader[callbacks.CallbackInput]) context.Context {
+	input.Close()
+	ctx, _ = h.start(ctx, info)
+	return ctx
+}
+
+// OnEndWithStreamOutput 在后台读完输出流后结束 span，流式模型的 token 用量在最后的数据块中
+func (h *handler) OnEndWithStreamOutput(ctx context.Context, info *callbacks.RunInfo,
+	output *schema.StreamReader[callbacks.CallbackOutput]) context.Context {
+	span, ok := ctx.Value(spanKey{}).(trace.Span)
+	if !ok {
+		output.Close()
+		return ctx
+	}
+	go func() {
+		defer output.Close()
+		defer span.End()
+		s := &streamSummary{}
+		for {
+			frame, err := output.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+				break
+			}
+			s.add(info, frame)
+		}
+		s.apply(span, info)
+	}()
+	return ctx
+}
+
+// start 创建 span 并放入上下文，子组件的 span 以其为父 span
+func (h *handler) start(ctx context.Context, info *callbacks.RunInfo) (context.Context, trace.Span) {
+	kind := trace.SpanKindInternal
+	if info.Component == components.ComponentOfChatModel {
+		kind = trace.SpanKindClient
+	}
+	ctx, span := h.tracer.Start(ctx, spanName(info), trace.WithSpanKind(kind), trace.WithAttributes(
+		AttrComponent.String(string(info.Component)),
+		AttrType.String(info.Type),
+		AttrName.String(info.Name),
+	))
+	switch info.Component {
+	case components.ComponentOfChatModel:
+		span.SetAttributes(AttrOperation.String("chat"))
+	case components.ComponentOfTool:
+		span.SetAttributes(AttrOperation.String("execute_tool"), AttrToolName.String(info.Name))
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// spanName span 名称，如 "chat Ark"、"execute_tool web_search_tool"、"Graph AgentRunner"
+func spanName(info *callbacks.RunInfo) string {
+	name := info.Name
+	if name == "" {
+		name = info.Type
+	}
+	switch info.Component {
+	case components.ComponentOfChatModel:
+		return "chat " + name
+	case components.ComponentOfTool:
+		return "execute_tool " + name
+	case "":
+		return name
+	default:
+		return string(info.Component) + " " + name
+	}
+}
+
+// setInputAttributes 记录模型名、消息数和工具参数大小
+func setInputAttributes(span trace.Span, info *callbacks.RunInfo, input callbacks.CallbackInput) {
+	switch info.Component {
+	case components.ComponentOfChatModel:
+		in := model.ConvCallbackInput(input)
+		if in == nil {
+			return
+		}
+		span.SetAttributes(AttrMessages.Int(len(in.Messages)))
+		if in.Config != nil && in.Config.Model != "" {
+			span.SetAttributes(AttrModel.String(in.Config.Model))
+		}
+	case components.ComponentOfTool:
+		if in := tool.ConvCallbackInput(input); in != nil {
+			span.SetAttributes(AttrToolArgsSize.Int(len(in.ArgumentsInJSON)))
+		}
+	}
+}
+
+// setOutputAttributes 记录 token 用量、工具调用数和工具结果大小
+func setOutputAttributes(span trace.Span, info *callbacks.RunInfo, output callbacks.CallbackOutput) {
+	s := &streamSummary{}
+	s.add(info, output)
+	s.apply(span, info)
+}
+
+// streamSummary 汇总输出（或输出流）中的模型和工具信息
+type streamSummary struct {
+	model     string
+	usage     *model.TokenUsage
+	toolCalls map[string]bool // 流式输出中同一工具调用分布在多个数据块，按序号或 ID 去重
+	respSize  int
+}
+
+func (s *streamSummary) add(info *callbacks.RunInfo, output callbacks.CallbackOutput) {
+	switch info.Component {
+	case components.ComponentOfChatModel:
+		out := model.ConvCallbackOutput(output)
+		if out == nil {
+			return
+		}
+		if out.Config != nil && out.Config.Model != "" {
+			s.model = out.Config.Model
+		}
+		if out.TokenUsage != nil {
+			s.usage = out.TokenUsage
+		}
+		if out.Message != nil {
+			for i, call := range out.Message.ToolCalls {
+				key := call.ID
+				if call.Index != nil {
+					key = "#" + strconv.Itoa(*call.Index)
+				} else if key == "" {
+					key = "#" + strconv.Itoa(i)
+				}
+				if s.toolCalls == nil {
+					s.toolCalls = make(map[string]bool)
+				}
+				s.toolCalls[key] = true
+			}
+			if out.Message.ResponseMeta != nil && out.Message.ResponseMeta.Usage != nil && s.usage == nil {
+				usage := out.Message.ResponseMeta.Usage
+				s.usage = &model.TokenUsage{
+					PromptTokens:     usage.PromptTokens,
+					CompletionTokens: usage.CompletionTokens,
+					TotalTokens:      usage.TotalTokens,
+				}
+			}
+		}
+	case components.ComponentOfTool:
+		if out := tool.ConvCallbackOutput(output); out != nil {
+			s.respSize += len(out.Response)
+		}
+	}
+}
+
+func (s *streamSummary) apply(span trace.Span, info *callbacks.RunInfo) {
+	switch info.Component {
+	case components.ComponentOfChatModel:
+		if s.model != "" {
+			span.SetAttributes(AttrModel.String(s.model))
+		}
+		if s.usage != nil {
+			span.SetAttributes(
+				AttrInputTokens.Int(s.usage.PromptTokens),
+				AttrOutputTokens.Int(s.usage.CompletionTokens),
+				AttrTotalTokens.Int(s.usage.TotalTokens),
+			)
+		}
+		span.SetAttributes(AttrToolCalls.Int(len(s.toolCalls)))
+	case components.ComponentOfTool:
+		span.SetAttributes(AttrToolRespSize.Int(s.respSize))
+	}
+}
@@ -0,0 +1,226 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/components/tool"
+	"github.com/cloudwego/eino/schema"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	modelInfo = &callbacks.RunInfo{Name: "ChatModel", Type: "Ark", Component: components.ComponentOfChatModel}
+	toolInfo  = &callbacks.RunInfo{Name: "web_search_tool", Component: components.ComponentOfTool}
+	graphInfo = &callbacks.RunInfo{Name: "AgentRunner", Type: "Graph", Component: "Graph"}
+)
+
+func newTestHandler() (*tracetest.SpanRecorder, callbacks.Handler) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return recorder, NewHandler(tp.Tracer(tracerName))
+}
+
+// spanAttrs 将 span 属性转换为 map 便于比较
+func spanAttrs(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestHandler(t1 *testing.T) {
+	usage := &model.TokenUsage{PromptTokens: 120, CompletionTokens: 30, TotalTokens: 150}
+	tests := []struct {
+		name      string
+		run       func(ctx context.Context, h callbacks.Handler)
+		wantName  string
+		wantAttrs map[attribute.Key]any
+		wantError bool
+	}{
+		{
+			name: "model",
+			run: func(ctx context.Context, h callbacks.Handler) {
+				ctx = h.OnStart(ctx, modelInfo, &model.CallbackInput{
+					Messages: []*schema.Message{schema.SystemMessage("sys"), schema.UserMessage("hi")},
+					Config:   &model.Config{Model: "doubao-1.5-pro"},
+				})
+				h.OnEnd(ctx, modelInfo, &model.CallbackOutput{
+					Message:    schema.AssistantMessage("", []schema.ToolCall{{ID: "call_1"}}),
+					TokenUsage: usage,
+				})
+			},
+			wantName: "chat ChatModel",
+			wantAttrs: map[attribute.Key]any{
+				AttrOperation:    "chat",
+				AttrModel:        "doubao-1.5-pro",
+				AttrMessages:     int64(2),
+				AttrInputTokens:  int64(120),
+				AttrOutputTokens: int64(30),
+				AttrTotalTokens:  int64(150),
+				AttrToolCalls:    int64(1),
+			},
+		},
+		{
+			name: "tool",
+			run: func(ctx context.Context, h callbacks.Handler) {
+				ctx = h.OnStart(ctx, toolInfo, &tool.CallbackInput{ArgumentsInJSON: `{"query":"go"}`})
+				h.OnEnd(ctx, toolInfo, &tool.CallbackOutput{Response: "results"})
+			},
+			wantName: "execute_tool web_search_tool",
+			wantAttrs: map[attribute.Key]any{
+				AttrOperation:    "execute_tool",
+				AttrToolName:     "web_search_tool",
+				AttrToolArgsSize: int64(14),
+				AttrToolRespSize: int64(7),
+			},
+		},
+		{
+			name: "error",
+			run: func(ctx context.Context, h callbacks.Handler) {
+				ctx = h.OnStart(ctx, toolInfo, &tool.CallbackInput{ArgumentsInJSON: `{}`})
+				h.OnError(ctx, toolInfo, errors.New("timeout"))
+			},
+			wantName:  "execute_tool web_search_tool",
+			wantError: true,
+		},
+		{
+			name: "streaming model",
+			run: func(ctx context.Context, h callbacks.Handler) {
+				ctx = h.OnStart(ctx, modelInfo, &model.CallbackInput{Config: &model.Config{Model: "doubao-1.5-pro"}})
+				index := 0
+				sr, sw := schema.Pipe[callbacks.CallbackOutput](4)
+				sw.Send(&model.CallbackOutput{Message: schema.AssistantMessage("", []schema.ToolCall{{ID: "call_1", Index: &index}})}, nil)
+				sw.Send(&model.CallbackOutput{Message: schema.AssistantMessage("", []schema.ToolCall{{Index: &index}})}, nil)
+				sw.Send(&model.CallbackOutput{TokenUsage: usage}, nil)
+				sw.Close()
+				h.OnEndWithStreamOutput(ctx, modelInfo, sr)
+			},
+			wantName: "chat ChatModel",
+			wantAttrs: map[attribute.Key]any{
+				AttrInputTokens: int64(120),
+				AttrTotalTokens: int64(150),
+				AttrToolCalls:   int64(1),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			recorder, h := newTestHandler()
+			tt.run(context.Background(), h)
+
+			// 流式输出在后台结束 span
+			var spans []sdktrace.ReadOnlySpan
+			for i := 0; i < 100 && len(spans) == 0; i++ {
+				if spans = recorder.Ended(); len(spans) == 0 {
+					time.Sleep(10 * time.Millisecond)
+				}
+			}
+			if len(spans) != 1 {
+				t1.Fatalf("ended spans = %d, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name() != tt.wantName {
+				t1.Errorf("span name = %s, want %s", span.Name(), tt.wantName)
+			}
+			attrs := spanAttrs(span)
+			for key, want := range tt.wantAttrs {
+				if got := attrs[key].AsInterface(); got != want {
+					t1.Errorf("attribute %s = %v, want %v", key, got, want)
+				}
+			}
+			if (span.Status().Code == codes.Error) != tt.wantError {
+				t1.Errorf("span status = %v, wantError %v", span.Status(), tt.wantError)
+			}
+		})
+	}
+}
+
+func TestHandler_Nesting(t1 *testing.T) {
+	recorder, h := newTestHandler()
+	ctx := context.Background()
+
+	graphCtx := h.OnStart(ctx, graphInfo, nil)
+	toolCtx := h.OnStart(graphCtx, toolInfo, &tool.CallbackInput{})
+	h.OnEnd(toolCtx, toolInfo, &tool.CallbackOutput{})
+	h.OnEnd(graphCtx, graphInfo, nil)
+	// 没有对应 OnStart 的回调不结束任何 span
+	h.OnEnd(ctx, toolInfo, &tool.CallbackOutput{})
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t1.Fatalf("ended spans = %d, want 2", len(spans))
+	}
+	toolSpan, graphSpan := spans[0], spans[1]
+	if graphSpan.Name() != "Graph AgentRunner" {
+		t1.Errorf("graph span name = %s", graphSpan.Name())
+	}
+	if toolSpan.Parent().SpanID() != graphSpan.SpanContext().SpanID() {
+		t1.Errorf("tool span parent = %s, want %s", toolSpan.Parent().SpanID(), graphSpan.SpanContext().SpanID())
+	}
+}
+
+func TestNewExporter(t1 *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t1.TempDir(), "trace", "trace.jsonl")
+
+	tests := []struct {
+		name    string
+		cfg     *Config
+		wantErr bool
+	}{
+		{name: "otlp", cfg: &Config{Exporter: ExporterOTLP, Endpoint: "localhost:4318", Insecure: true}},
+		{name: "otlp url", cfg: &Config{Exporter: ExporterOTLP, Endpoint: "https://otel.example.com/v1/traces"}},
+		{name: "stdout", cfg: &Config{Exporter: ExporterStdout}},
+		{name: "file", cfg: &Config{Exporter: ExporterFile, File: file}},
+		{name: "unknown", cfg: &Config{Exporter: "jaeger"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			var console strings.Builder
+			exporter, closer, err := newExporter(ctx, tt.cfg, &console)
+			if (err != nil) != tt.wantErr {
+				t1.Fatalf("newExporter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if tt.cfg.Exporter == ExporterOTLP {
+				_ = exporter.Shutdown(ctx)
+				return
+			}
+
+			// 本地导出器输出 span
+			tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+			_, span := tp.Tracer(tracerName).Start(ctx, "test span")
+			span.End()
+			_ = tp.Shutdown(ctx)
+			if closer != nil {
+				_ = closer.Close()
+			}
+			output := console.String()
+			if tt.cfg.Exporter == ExporterFile {
+				data, err := os.ReadFile(file)
+				if err != nil {
+					t1.Fatalf("failed to read trace file: %v", err)
+				}
+				output = string(data)
+			}
+			if !strings.Contains(output, `"test span"`) {
+				t1.Errorf("exported = %q, want it to contain the span", output)
+			}
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package tracing
+
+import (
+	"agent/internal/consts"
+	"agent/internal/secret"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	ccb "github.com/cloudwego/eino-ext/callbacks/cozeloop"
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/coze-dev/cozeloop-go"
+	"github.com/gogf/gf/v2/frame/g"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+	ExporterFile   = "file"
+
+	defaultServiceName = "agent"
+	defaultTraceFile   = "resource/trace/trace.jsonl"
+)
+
+// Config 链路追踪配置
+type Config struct {
+	Enabled     bool
+	ServiceName string
+	Exporter    string            // otlp / stdout / file
+	Endpoint    string            // OTLP HTTP 地址，如 http://localhost:4318，为空时使用 OTEL_EXPORTER_OTLP_* 环境变量
+	Insecure    bool              // OTLP 使用 HTTP 而不是 HTTPS（endpoint 不带协议时生效）
+	Headers     map[string]string // OTLP 请求头，如鉴权信息
+	File        string            // file 导出器的输出文件，每行一个 span
+	SampleRatio float64           // 采样比例，0-1
+
+	CozeLoop          bool // 同时上报到 CozeLoop
+	CozeLoopWorkspace string
+	CozeLoopToken     string
+}
+
+// tracingSecrets CozeLoop 的 API Token
+var tracingSecrets = secret.Declare("tracing", consts.TracingCozeLoopToken)
+
+// LoadConfig 读取链路追踪配置
+func LoadConfig(ctx context.Context) *Config {
+	cfg := &Config{
+		Enabled:     g.Cfg().MustGet(ctx, consts.TracingEnabled, false).Bool(),
+		ServiceName: g.Cfg().MustGet(ctx, consts.TracingServiceName, defaultServiceName).String(),
+		Exporter:    g.Cfg().MustGet(ctx, consts.TracingExporter, ExporterOTLP).String(),
+		Endpoint:    g.Cfg().MustGet(ctx, consts.TracingEndpoint).String(),
+		Insecure:    g.Cfg().MustGet(ctx, consts.TracingInsecure, true).Bool(),
+		Headers:     g.Cfg().MustGet(ctx, consts.TracingHeaders).MapStrStr(),
+		File:        g.Cfg().MustGet(ctx, consts.TracingFile, defaultTraceFile).String(),
+		SampleRatio: g.Cfg().MustGet(ctx, consts.TracingSampleRatio, 1).Float64(),
+
+		CozeLoop:          g.Cfg().MustGet(ctx, consts.TracingCozeLoopEnabled, false).Bool(),
+		CozeLoopWorkspace: g.Cfg().MustGet(ctx, consts.TracingCozeLoopWorkspace).String(),
+		CozeLoopToken:     tracingSecrets.Value(ctx, consts.TracingCozeLoopToken),
+	}
+	// 请求头中的鉴权信息可以使用 ${env:...} 等密钥引用
+	for k, v := range cfg.Headers {
+		value, err := secret.Resolve(ctx, v)
+		if err != nil {
+			g.Log().Errorf(ctx, "failed to resolve %s.%s: %v", consts.TracingHeaders, k, err)
+		}
+		cfg.Headers[k] = value
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = defaultServiceName
+	}
+	cfg.SampleRatio = min(max(cfg.SampleRatio, 0), 1)
+	return cfg
+}
+
+// Init 按配置初始化链路追踪并注册 Eino 全局回调，只应在启动时调用一次；返回的函数在退出时刷新并关闭导出器。
+// console 为 stdout 导出器的输出，stdout 被占用时（如 stdio 传输的 MCP 服务）传入 os.Stderr
+func Init(ctx context.Context, console io.Writer) (shutdown func(context.Context) error, err error) {
+	cfg := LoadConfig(ctx)
+	var shutdowns []func(context.Context) error
+	shutdown = func(ctx context.Context) error {
+		var errs []error
+		for i := len(shutdowns) - 1; i >= 0; i-- {
+			errs = append(errs, shutdowns[i](ctx))
+		}
+		return errors.Join(errs...)
+	}
+
+	// 1. OpenTelemetry，HTTP 服务的请求 span 同样使用全局 TracerProvider
+	if cfg.Enabled {
+		exporter, closer, err := newExporter(ctx, cfg, console)
+		if err != nil {
+			return nil, err
+		}
+		res, err := resource.Merge(resource.Default(),
+			resource.NewSchemaless(attribute.String("service.name", cfg.ServiceName)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create resource: %v", err)
+		}
+		tp := sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(exporter),
+			sdktrace.WithResource(res),
+			sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		)
+		otel.SetTracerProvider(tp)
+		otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+		callbacks.AppendGlobalHandlers(NewHandler(tp.Tracer(tracerName)))
+		shutdowns = append(shutdowns, func(ctx context.Context) error {
+			err := tp.Shutdown(ctx)
+			if closer != nil {
+				err = errors.Join(err, closer.Close())
+			}
+			return err
+		})
+		g.Log().Infof(ctx, "Tracing enabled with %s exporter", cfg.Exporter)
+	}
+
+	// 2. CozeLoop，未配置时使用 COZELOOP_WORKSPACE_ID / COZELOOP_API_TOKEN 环境变量
+	if cfg.CozeLoop {
+		var opts []cozeloop.Option
+		if cfg.CozeLoopWorkspace != "" {
+			opts = append(opts, cozeloop.WithWorkspaceID(cfg.CozeLoopWorkspace))
+		}
+		if cfg.CozeLoopToken != "" {
+			opts = append(opts, cozeloop.WithAPIToken(cfg.CozeLoopToken))
+		}
+		client, err := cozeloop.NewClient(opts...)
+		if err != nil {
+			_ = shutdown(ctx)
+			return nil, fmt.Errorf("failed to create cozeloop client: %v", err)
+		}
+		callbacks.AppendGlobalHandlers(ccb.NewLoopHandler(client))
+		shutdowns = append(shutdowns, func(ctx context.Context) error {
+			client.Close(ctx)
+			return nil
+		})
+		g.Log().Info(ctx, "Tracing to CozeLoop enabled")
+	}
+	return shutdown, nil
+}
+
+// newExporter 按配置创建导出器，file 导出器同时返回需要关闭的文件
+func newExporter(ctx context.Context, cfg *Config, console io.Writer) (sdktrace.SpanExporter, io.Closer, error) {
+	switch cfg.Exporter {
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		switch {
+		case strings.HasPrefix(cfg.Endpoint, "http://"), strings.HasPrefix(cfg.Endpoint, "https://"):
+			opts = append(opts, otlptracehttp.WithEndpointURL(cfg.Endpoint))
+		case cfg.Endpoint != "":
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+			if cfg.Insecure {
+				opts = append(opts, otlptracehttp.WithInsecure())
+			}
+		}
+		if len(cfg.Headers) > 0 {
+			opts = append(opts, otlptracehttp.WithHeaders(cfg.Headers))
+		}
+		exporter, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create otlp exporter: %v", err)
+		}
+		return exporter, nil, nil
+	case ExporterStdout:
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(console), stdouttrace.WithPrettyPrint())
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create stdout exporter: %v", err)
+		}
+		return exporter, nil, nil
+	case ExporterFile:
+		if err := os.MkdirAll(filepath.Dir(cfg.File), 0755); err != nil {
+			return nil, nil, fmt.Errorf("failed to create trace directory: %v", err)
+		}
+		file, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open trace file: %v", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			_ = file.Close()
+			return nil, nil, fmt.Errorf("failed to create file exporter: %v", err)
+		}
+		return exporter, file, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported trace exporter: %s", cfg.Exporter)
+	}
+}
@@ -0,0 +1,170 @@
+package usage
+
+import (
+	"agent/internal/consts"
+	"agent/internal/metrics"
+	"agent/internal/model"
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/embedding"
+	einomodel "github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+// handler 在每次模型和向量化调用结束时记录 token 用量
+type handler struct {
+	cfg  *Config
+	sink func(*Record) // 持久化记录，为 nil 时只计入对话轮次
+}
+
+// NewHandler 创建统计 token 用量的 Eino 回调，sink 接收每次调用的用量记录
+func NewHandler(cfg *Config, sink func(*Record)) callbacks.Handler {
+	return &handler{cfg: cfg, sink: sink}
+}
+
+// Needed 只处理模型和向量化组件的结束回调
+func (h *handler) Needed(_ context.Context, info *callbacks.RunInfo, timing callbacks.CallbackTiming) bool {
+	if info == nil || (info.Component != components.ComponentOfChatModel && info.Component != components.ComponentOfEmbedding) {
+		return false
+	}
+	return timing == callbacks.TimingOnEnd || timing == callbacks.TimingOnEndWithStreamOutput
+}
+
+func (h *handler) OnStart(ctx context.Context, _ *callbacks.RunInfo, _ callbacks.CallbackInput) context.Context {
+	return ctx
+}
+
+func (h *handler) OnEnd(ctx context.Context, info *callbacks.RunInfo, output callbacks.CallbackOutput) context.Context {
+	c := &collector{}
+	c.add(info, output)
+	h.record(ctx, info, c)
+	return ctx
+}
+
+func (h *handler) OnError(ctx context.Context, _ *callbacks.RunInfo, _ error) context.Context {
+	return ctx
+}
+
+func (h *handler) OnStartWithStreamInput(ctx context.Context, _ *callbacks.RunInfo,
+	input *schema.StreamReader[callbacks.CallbackInput]) context.Context {
+	input.Close()
+	return ctx
+}
+
+// OnEndWithStreamOutput 在后台读完输出流后记录用量，流式模型的 token 用量在最后的数据块中
+func (h *handler) OnEndWithStreamOutput(ctx context.Context, info *callbacks.RunInfo,
+	output *schema.StreamReader[callbacks.CallbackOutput]) context.Context {
+	turn := turnFromCtx(ctx)
+	if turn != nil {
+		turn.pending.Add(1)
+	}
+	go func() {
+		defer output.Close()
+		if turn != nil {
+			defer turn.pending.Done()
+		}
+		c := &collector{}
+		for {
+			frame, err := output.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return
+			}
+			c.add(info, frame)
+		}
+		h.record(ctx, info, c)
+	}()
+	return ctx
+}
+
+// record 计算费用，计入对话轮次并交给 sink 持久化
+func (h *handler) record(ctx context.Context, info *callbacks.RunInfo, c *collector) {
+	if !c.found {
+		return
+	}
+	r := &Record{
+		Model:            c.model,
+		Kind:             KindChat,
+		PromptTokens:     c.promptTokens,
+		CompletionTokens: c.completionTokens,
+		TotalTokens:      c.totalTokens,
+		CreatedAt:        time.Now(),
+	}
+	if info.Component == components.ComponentOfEmbedding {
+		r.Kind = KindEmbedding
+	}
+	if r.Model == "" {
+		r.Model = info.Type
+	}
+	if r.TotalTokens == 0 {
+		r.TotalTokens = r.PromptTokens + r.CompletionTokens
+	}
+	r.Cost = h.cfg.Cost(r.Model, r.PromptTokens, r.CompletionTokens)
+	if c, ok := ctx.Value(consts.ContextKey).(*model.Context); ok {
+		r.SessionID, r.UserID, r.TenantID, r.Agent = c.SessionID, c.UserID, c.TenantID, c.Agent
+	}
+
+	metrics.ObserveTokens(r.Model, r.Kind, r.PromptTokens, r.CompletionTokens)
+	if turn := turnFromCtx(ctx); turn != nil {
+		turn.add(r)
+	}
+	if h.sink != nil {
+		h.sink(r)
+	}
+	for _, hook := range recordHooks {
+		hook(ctx, r)
+	}
+}
+
+// collector 汇总输出（或输出流）中的模型名和 token 用量
+type collector struct {
+	found            bool
+	model            string
+	promptTokens     int
+	completionTokens int
+	totalTokens      int
+}
+
+func (c *collector) add(info *callbacks.RunInfo, output callbacks.CallbackOutput) {
+	switch info.Component {
+	case components.ComponentOfChatModel:
+		out := einomodel.ConvCallbackOutput(output)
+		if out == nil {
+			return
+		}
+		if out.Config != nil && out.Config.Model != "" {
+			c.model = out.Config.Model
+		}
+		switch {
+		case out.TokenUsage != nil:
+			c.set(out.TokenUsage.PromptTokens, out.TokenUsage.CompletionTokens, out.TokenUsage.TotalTokens)
+		case out.Message != nil && out.Message.ResponseMeta != nil && out.Message.ResponseMeta.Usage != nil:
+			usage := out.Message.ResponseMeta.Usage
+			c.set(usage.PromptTokens, usage.CompletionTokens, usage.TotalTokens)
+		}
+	case components.ComponentOfEmbedding:
+		out := embedding.ConvCallbackOutput(output)
+		if out == nil {
+			return
+		}
+		if out.Config != nil && out.Config.Model != "" {
+			c.model = out.Config.Model
+		}
+		if out.TokenUsage != nil {
+			c.set(out.TokenUsage.PromptTokens, out.TokenUsage.CompletionTokens, out.TokenUsage.TotalTokens)
+		}
+	}
+}
+
+// set 流式输出的用量是累计值，以最后一个带用量的数据块为准
+func (c *collector) set(prompt, completion, total int) {
+	c.found = true
+	c.promptTokens, c.completionTokens, c.totalTokens = prompt, completion, total
+}
@@ -0,0 +1,136 @@
+package usage
+
+import (
+	"agent/internal/consts"
+	agentmodel "agent/internal/model"
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/cloudwego/eino/components"
+	"github.com/cloudwego/eino/components/embedding"
+	"github.com/cloudwego/eino/components/model"
+	"github.com/cloudwego/eino/schema"
+)
+
+var (
+	modelInfo     = &callbacks.RunInfo{Name: "ChatModel", Type: "Ark", Component: components.ComponentOfChatModel}
+	embeddingInfo = &callbacks.RunInfo{Type: "Ark", Component: components.ComponentOfEmbedding}
+	testConfig    = &Config{Prices: []Price{
+		{Model: "doubao-1.5-pro", Input: 1, Output: 2},
+		{Model: "doubao-1.5-pro-32k", Input: 0.8, Output: 2},
+		{Model: "doubao-embedding", Input: 0.5},
+	}}
+)
+
+func TestConfig_Cost(t1 *testing.T) {
+	tests := []struct {
+		name       string
+		model      string
+		prompt     int
+		completion int
+		want       float64
+	}{
+		{name: "exact", model: "doubao-1.5-pro", prompt: 1000000, completion: 500000, want: 2},
+		{name: "longest prefix", model: "doubao-1.5-pro-32k-250115", prompt: 1000, completion: 100, want: 0.001},
+		{name: "embedding", model: "doubao-embedding-text-240715", prompt: 2000, want: 0.001},
+		{name: "unknown model", model: "gpt-4o", prompt: 1000, completion: 1000, want: 0},
+	}
+	for _, tt := range tests {
+		t1.Run(tt.name, func(t1 *testing.T) {
+			if got := testConfig.Cost(tt.model, tt.prompt, tt.completion); got != tt.want {
+				t1.Errorf("Cost() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler(t1 *testing.T) {
+	var mu sync.Mutex
+	var records []*Record
+	h := NewHandler(testConfig, func(r *Record) {
+		mu.Lock()
+		defer mu.Unlock()
+		records = append(records, r)
+	})
+
+	ctx := context.WithValue(context.Background(), consts.ContextKey, &agentmodel.Context{
+		SessionID: "s1", UserID: "u1", Agent: consts.AgentReact,
+	})
+	ctx, turn := StartTurn(ctx)
+
+	// 1. 非流式模型调用
+	h.OnEnd(ctx, modelInfo, &model.CallbackOutput{
+		Message:    schema.AssistantMessage("hi", nil),
+		Config:     &model.Config{Model: "doubao-1.5-pro-32k-250115"},
+		TokenUsage: &model.TokenUsage{PromptTokens: 1000, CompletionTokens: 100, TotalTokens: 1100},
+	})
+
+	// 2. 流式模型调用，用量只在最后的数据块中
+	sr, sw := schema.Pipe[callbacks.CallbackOutput](4)
+	sw.Send(&model.CallbackOutput{Message: schema.AssistantMessage("a", nil), Config: &model.Config{Model: "doubao-1.5-pro"}}, nil)
+	sw.Send(&model.CallbackOutput{Message: schema.AssistantMessage("b", nil)}, nil)
+	sw.Send(&model.CallbackOutput{TokenUsage: &model.TokenUsage{PromptTokens: 500000, CompletionTokens: 250000, TotalTokens: 750000}}, nil)
+	sw.Close()
+	h.OnEndWithStreamOutput(ctx, modelInfo, sr)
+
+	// 3. 向量化调用
+	h.OnEnd(ctx, embeddingInfo, &embedding.CallbackOutput{
+		Config:     &embedding.Config{Model: "doubao-embedding-text-240715"},
+		TokenUsage: &embedding.TokenUsage{PromptTokens: 2000, TotalTokens: 2000},
+	})
+
+	// 4. 没有用量的输出不记录
+	h.OnEnd(ctx, modelInfo, &model.CallbackOutput{Message: schema.AssistantMessage("no usage", nil)})
+
+	got := turn.Summary()
+	want := Summary{
+		Calls:            2,
+		EmbeddingCalls:   1,
+		PromptTokens:     503000,
+		CompletionTokens: 250100,
+		TotalTokens:      753100,
+		Cost:             1.002,
+	}
+	if got != want {
+		t1.Errorf("Summary() = %+v, want %+v", got, want)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(records) != 3 {
+		t1.Fatalf("records = %d, want 3", len(records))
+	}
+	for _, r := range records {
+		if r.SessionID != "s1" || r.UserID != "u1" || r.Agent != consts.AgentReact {
+			t1.Errorf("record tags = %s/%s/%s, want s1/u1/react", r.SessionID, r.UserID, r.Agent)
+		}
+	}
+	kinds := map[string]int{}
+	for _, r := range records {
+		kinds[r.Kind]++
+	}
+	if kinds[KindChat] != 2 || kinds[KindEmbedding] != 1 {
+		t1.Errorf("record kinds = %v, want 2 chat and 1 embedding", kinds)
+	}
+}
+
+func TestHandler_WithoutTurn(t1 *testing.T) {
+	var records []*Record
+	h := NewHandler(&Config{}, func(r *Record) { records = append(records, r) })
+
+	// 不在对话轮次中的调用（如启动时的检索）仍然记录，模型名缺失时使用组件类型
+	h.OnEnd(context.Background(), modelInfo, &model.CallbackOutput{
+		Message: &schema.Message{
+			Role:         schema.Assistant,
+			ResponseMeta: &schema.ResponseMeta{Usage: &schema.TokenUsage{PromptTokens: 10, CompletionTokens: 5}},
+		},
+	})
+	if len(records) != 1 {
+		t1.Fatalf("records = %d, want 1", len(records))
+	}
+	if r := records[0]; r.Model != "Ark" || r.TotalTokens != 15 || r.Cost != 0 {
+		t1.Errorf("record = %+v, want model Ark, 15 total tokens and no cost", r)
+	}
+}
@@ -0,0 +1,170 @@
+package usage
+
+import (
+	"agent/internal/consts"
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cloudwego/eino/callbacks"
+	"github.com/gogf/gf/v2/frame/g"
+)
+
+const (
+	KindChat      = "chat"
+	KindEmbedding = "embedding"
+
+	defaultCurrency = "CNY"
+	// summaryWait 等待流式输出统计完成的最长时间
+	summaryWait = 2 * time.Second
+)
+
+// Price 模型每百万 token 的价格
+type Price struct {
+	Model  string  `json:"model"`  // 模型名或模型名前缀，如 doubao-1.5-pro-32k
+	Input  float64 `json:"input"`  // 输入 token 单价
+	Output float64 `json:"output"` // 输出 token 单价
+}
+
+// Config 用量统计配置
+type Config struct {
+	Persist  bool // 写入数据库 token_usage 表
+	Currency string
+	Prices   []Price
+}
+
+// LoadConfig 读取用量统计配置
+func LoadConfig(ctx context.Context) *Config {
+	cfg := &Config{
+		Persist:  g.Cfg().MustGet(ctx, consts.UsagePersist, false).Bool(),
+		Currency: g.Cfg().MustGet(ctx, consts.UsageCurrency, defaultCurrency).String(),
+	}
+	if err := g.Cfg().MustGet(ctx, consts.UsagePrices).Scan(&cfg.Prices); err != nil {
+		g.Log().Warningf(ctx, "invalid usage prices: %v", err)
+	}
+	return cfg
+}
+
+// Cost 按价格表计算费用，优先精确匹配模型名，其次匹配最长的前缀，没有价格的模型费用为 0
+func (c *Config) Cost(model string, promptTokens, completionTokens int) float64 {
+	var price *Price
+	for i, p := range c.Prices {
+		if p.Model == model {
+			price = &c.Prices[i]
+			break
+		}
+		if strings.HasPrefix(model, p.Model) && (price == nil || len(p.Model) > len(price.Model)) {
+			price = &c.Prices[i]
+		}
+	}
+	if price == nil {
+		return 0
+	}
+	cost := (float64(promptTokens)*price.Input + float64(completionTokens)*price.Output) / 1e6
+	return roundCost(cost)
+}
+
+// roundCost 费用保留 8 位小数，与数据库字段精度一致
+func roundCost(cost float64) float64 {
+	return math.Round(cost*1e8) / 1e8
+}
+
+// Record 一次模型或向量化调用的用量
+type Record struct {
+	SessionID        string
+	UserID           string
+	TenantID         string
+	Agent            string
+	Model            string
+	Kind             string // chat / embedding
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	Cost             float64
+	CreatedAt        time.Time
+}
+
+// Summary 一轮对话累计的用量
+type Summary struct {
+	Calls            int     // 模型调用次数，即本轮模型生成的消息数
+	EmbeddingCalls   int     // 向量化调用次数
+	PromptTokens     int     // 输入 token 数，包含向量化
+	CompletionTokens int     // 输出 token 数
+	TotalTokens      int     // 总 token 数
+	Cost             float64 // 费用
+}
+
+// Turn 统计一轮对话中所有调用的用量
+type Turn struct {
+	mu      sync.Mutex
+	summary Summary
+	pending sync.WaitGroup // 尚未读完的流式输出
+}
+
+// turnKey 上下文中 *Turn 的键
+type turnKey struct{}
+
+// StartTurn 开始统计一轮对话，返回的上下文中的调用都计入该轮
+func StartTurn(ctx context.Context) (context.Context, *Turn) {
+	turn := &Turn{}
+	return context.WithValue(ctx, turnKey{}, turn), turn
+}
+
+// turnFromCtx 当前上下文所属的对话轮次
+func turnFromCtx(ctx context.Context) *Turn {
+	turn, _ := ctx.Value(turnKey{}).(*Turn)
+	return turn
+}
+
+func (t *Turn) add(r *Record) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if r.Kind == KindEmbedding {
+		t.summary.EmbeddingCalls++
+	} else {
+		t.summary.Calls++
+	}
+	t.summary.PromptTokens += r.PromptTokens
+	t.summary.CompletionTokens += r.CompletionTokens
+	t.summary.TotalTokens += r.TotalTokens
+	t.summary.Cost = roundCost(t.summary.Cost + r.Cost)
+}
+
+// Summary 等待进行中的流式输出统计完成后返回累计用量
+func (t *Turn) Summary() Summary {
+	done := make(chan struct{})
+	go func() {
+		t.pending.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(summaryWait):
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.summary
+}
+
+// recordHooks 每条用量记录的回调
+var recordHooks []func(ctx context.Context, r *Record)
+
+// OnRecord 注册每条用量记录的回调，如累计每日配额；需在处理请求之前调用
+func OnRecord(fn func(ctx context.Context, r *Record)) {
+	recordHooks = append(recordHooks, fn)
+}
+
+// Init 注册统计用量的 Eino 全局回调，只应在启动时调用一次；返回的函数在退出时写入剩余的记录
+func Init(ctx context.Context) (shutdown func(context.Context) error) {
+	cfg := LoadConfig(ctx)
+	var sink func(*Record)
+	shutdown = func(context.Context) error { return nil }
+	if cfg.Persist {
+		w := newWriter(ctx)
+		sink, shutdown = w.enqueue, w.close
+	}
+	callbacks.AppendGlobalHandlers(NewHandler(cfg, sink))
+	return shutdown
+}
@@ -0,0 +1,94 @@
+package usage
+
+import (
+	"agent/internal/dao"
+	"agent/internal/model/do"
+	"context"
+	"sync"
+
+	"github.com/gogf/gf/v2/frame/g"
+	"github.com/gogf/gf/v2/os/gtime"
+)
+
+const (
+	writerQueueSize = 1024
+	writerBatchSize = 100
+)
+
+// writer 在后台批量写入用量记录，避免数据库延迟拖慢模型调用
+type writer struct {
+	ctx     context.Context
+	records chan *Record
+	once    sync.Once
+	done    chan struct{}
+}
+
+func newWriter(ctx context.Context) *writer {
+	w := &writer{
+		ctx:     context.WithoutCancel(ctx),
+		records: make(chan *Record, writerQueueSize),
+		done:    make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// enqueue 加入写入队列，队列已满时丢弃记录
+func (w *writer) enqueue(r *Record) {
+	defer func() {
+		// 关闭后仍在结束的流式调用不再写入
+		_ = recover()
+	}()
+	select {
+	case w.records <- r:
+	default:
+		g.Log().Warningf(w.ctx, "usage queue is full, dropping record of session %s", r.SessionID)
+	}
+}
+
+func (w *writer) run() {
+	defer close(w.done)
+	batch := make([]*Record, 0, writerBatchSize)
+	for r := range w.records {
+		batch = append(batch, r)
+		// 取出队列中已有的记录一起写入
+		for len(batch) < writerBatchSize && len(w.records) > 0 {
+			batch = append(batch, <-w.records)
+		}
+		w.insert(batch)
+		batch = batch[:0]
+	}
+}
+
+func (w *writer) insert(batch []*Record) {
+	rows := make([]do.TokenUsage, 0, len(batch))
+	for _, r := range batch {
+		rows = append(rows, do.TokenUsage{
+			SessionId:        r.SessionID,
+			UserId:           r.UserID,
+			TenantId:         r.TenantID,
+			Agent:            r.Agent,
+			Model:            r.Model,
+			Kind:             r.Kind,
+			PromptTokens:     r.PromptTokens,
+			CompletionTokens: r.CompletionTokens,
+			TotalTokens:      r.TotalTokens,
+			Cost:             r.Cost,
+			CreatedAt:        gtime.New(r.CreatedAt),
+		})
+	}
+	if _, err := dao.TokenUsage.Ctx(w.ctx).Data(rows).Insert(); err != nil {
+		g.Log().Warningf(w.ctx, "failed to save %d usage records: %v", len(rows), err)
+	}
+}
+
+// close 停止接收记录，等待队列中的记录写入完成
+func (w *writer) close(ctx context.Context) error {
+	w.once.Do(func() { close(w.records) })
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
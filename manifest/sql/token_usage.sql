@@ -0,0 +1,23 @@
+-- 模型和向量化调用的 token 用量，每次调用一行
+CREATE TABLE IF NOT EXISTS `token_usage` (
+  `id`                BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `session_id`        VARCHAR(128)    NOT NULL DEFAULT '' COMMENT '会话ID',
+  `user_id`           VARCHAR(128)    NOT NULL DEFAULT '' COMMENT '用户ID',
+  `tenant_id`         VARCHAR(64)     NOT NULL DEFAULT '' COMMENT '租户ID',
+  `agent`             VARCHAR(32)     NOT NULL DEFAULT '' COMMENT 'Agent 类型',
+  `model`             VARCHAR(128)    NOT NULL DEFAULT '' COMMENT '模型名',
+  `kind`              VARCHAR(16)     NOT NULL DEFAULT '' COMMENT '调用类型：chat / embedding',
+  `prompt_tokens`     INT             NOT NULL DEFAULT 0  COMMENT '输入 token 数',
+  `completion_tokens` INT             NOT NULL DEFAULT 0  COMMENT '输出 token 数',
+  `total_tokens`      INT             NOT NULL DEFAULT 0  COMMENT '总 token 数',
+  `cost`              DECIMAL(16, 8)  NOT NULL DEFAULT 0  COMMENT '费用',
+  `created_at`        DATETIME        NOT NULL DEFAULT CURRENT_TIMESTAMP COMMENT '创建时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_created_at` (`created_at`),
+  KEY `idx_session_id` (`session_id`),
+  KEY `idx_user_id` (`user_id`),
+  KEY `idx_tenant_id` (`tenant_id`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = 'token 用量';
+
+-- 已有的表升级：
+-- ALTER TABLE `token_usage` ADD COLUMN `tenant_id` VARCHAR(64) NOT NULL DEFAULT '' COMMENT '租户ID' AFTER `user_id`, ADD KEY `idx_tenant_id` (`tenant_id`);
@@ -0,0 +1,27 @@
+-- 工具调用审计日志，每次工具调用一行，只追加不修改
+-- 建议应用使用的数据库账号只授予该表的 INSERT 和 SELECT 权限：
+-- GRANT INSERT, SELECT ON `ai_agent`.`tool_audit` TO 'agent'@'%';
+CREATE TABLE IF NOT EXISTS `tool_audit` (
+  `id`              BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+  `trace_id`        VARCHAR(32)     NOT NULL DEFAULT '' COMMENT '链路追踪 ID',
+  `session_id`      VARCHAR(128)    NOT NULL DEFAULT '' COMMENT '会话ID',
+  `user_id`         VARCHAR(128)    NOT NULL DEFAULT '' COMMENT '用户ID',
+  `tenant_id`       VARCHAR(64)     NOT NULL DEFAULT '' COMMENT '租户ID',
+  `agent`           VARCHAR(32)     NOT NULL DEFAULT '' COMMENT 'Agent 类型',
+  `call_id`         VARCHAR(128)    NOT NULL DEFAULT '' COMMENT '模型生成的工具调用 ID',
+  `tool`            VARCHAR(128)    NOT NULL DEFAULT '' COMMENT '工具名',
+  `arguments`       TEXT            NOT NULL COMMENT '调用参数（已脱敏）',
+  `result`          TEXT            NOT NULL COMMENT '结果摘要（已脱敏）',
+  `error`           TEXT            NOT NULL COMMENT '错误信息',
+  `duration_ms`     INT             NOT NULL DEFAULT 0  COMMENT '耗时（毫秒）',
+  `approval`        VARCHAR(16)     NOT NULL DEFAULT '' COMMENT '审批结果：approve / deny / edit，无需审批时为空',
+  `approval_reason` VARCHAR(512)    NOT NULL DEFAULT '' COMMENT '审批理由',
+  `approver`        VARCHAR(128)    NOT NULL DEFAULT '' COMMENT '审批人，超时自动拒绝时为空',
+  `created_at`      DATETIME(3)     NOT NULL DEFAULT CURRENT_TIMESTAMP(3) COMMENT '调用开始时间',
+  PRIMARY KEY (`id`),
+  KEY `idx_created_at` (`created_at`),
+  KEY `idx_session_id` (`session_id`),
+  KEY `idx_user_id` (`user_id`),
+  KEY `idx_tenant_id` (`tenant_id`),
+  KEY `idx_tool` (`tool`)
+) ENGINE = InnoDB DEFAULT CHARSET = utf8mb4 COMMENT = '工具调用审计日志';
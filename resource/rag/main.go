@@ -4,6 +4,7 @@ import (
 	"agent/resource/rag/md"
 	"context"
 	"log"
+	"os"
 
 	"github.com/cloudwego/eino-ext/components/embedding/ark"
 	"github.com/cloudwego/eino-ext/components/indexer/milvus"
@@ -62,7 +63,7 @@ func main() {
 	ctx := context.Background()
 	// 初始化嵌入器
 	embedder, err := ark.NewEmbedder(ctx, &ark.EmbeddingConfig{
-		APIKey: "xxx",
+		APIKey: os.Getenv("ARK_API_KEY"),
 		Model:  "doubao-embedding-text-240715", // 使用正确的模型名称
 	})
 	if err != nil {